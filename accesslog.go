@@ -0,0 +1,118 @@
+package unfurlist
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// AccessLogFormat is the format of access log records written by AccessLog
+type AccessLogFormat string
+
+// Supported access log formats
+const (
+	// AccessLogJSON writes one JSON object per request
+	AccessLogJSON AccessLogFormat = "json"
+	// AccessLogCommon writes records in Common Log Format, followed by the
+	// number of urls processed and request duration in milliseconds
+	AccessLogCommon AccessLogFormat = "common"
+)
+
+// accessRecord holds request details that are only known by unfurl handler
+type accessRecord struct {
+	urls int
+}
+
+type accessRecordKey struct{}
+
+// AccessLog wraps h with middleware writing a record of each request to out
+// in the given format. If h is unfurl handler created by New, records also
+// have the number of urls processed.
+func AccessLog(h http.Handler, out io.Writer, format AccessLogFormat) http.Handler {
+	var mu sync.Mutex
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := new(accessRecord)
+		cw := &countingWriter{ResponseWriter: w}
+		h.ServeHTTP(cw, r.WithContext(context.WithValue(r.Context(), accessRecordKey{}, rec)))
+		if cw.status == 0 {
+			cw.status = http.StatusOK
+		}
+		client := r.RemoteAddr
+		if host, _, err := net.SplitHostPort(client); err == nil {
+			client = host
+		}
+		dur := time.Since(start)
+		var line []byte
+		switch format {
+		case AccessLogCommon:
+			line = []byte(client + " - - [" + start.Format("02/Jan/2006:15:04:05 -0700") + "] " +
+				strconv.Quote(r.Method+" "+r.URL.RequestURI()+" "+r.Proto) + " " +
+				strconv.Itoa(cw.status) + " " + strconv.FormatInt(cw.bytes, 10) + " " +
+				strconv.Itoa(rec.urls) + " " + strconv.FormatFloat(float64(dur)/float64(time.Millisecond), 'f', 3, 64) + "\n")
+		default:
+			line, _ = json.Marshal(struct {
+				Time      time.Time `json:"time"`
+				Method    string    `json:"method"`
+				Path      string    `json:"path"`
+				Client    string    `json:"client"`
+				URLs      int       `json:"urls"`
+				Status    int       `json:"status"`
+				Bytes     int64     `json:"bytes"`
+				Duration  float64   `json:"duration_ms"`
+				RequestID string    `json:"request_id,omitempty"`
+			}{
+				Time:      start.UTC(),
+				Method:    r.Method,
+				Path:      r.URL.Path,
+				Client:    client,
+				URLs:      rec.urls,
+				Status:    cw.status,
+				Bytes:     cw.bytes,
+				Duration:  float64(dur) / float64(time.Millisecond),
+				RequestID: w.Header().Get(RequestIDHeader),
+			})
+			line = append(line, '\n')
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		out.Write(line)
+	})
+}
+
+// setAccessURLs records number of urls processed for the access log
+func setAccessURLs(ctx context.Context, n int) {
+	if rec, ok := ctx.Value(accessRecordKey{}).(*accessRecord); ok {
+		rec.urls = n
+	}
+}
+
+// countingWriter is http.ResponseWriter recording response status and size
+type countingWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (w *countingWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *countingWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += int64(n)
+	return n, err
+}
+
+func (w *countingWriter) Unwrap() http.ResponseWriter { return w.ResponseWriter }
@@ -0,0 +1,42 @@
+package unfurlist
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestAccessLog(t *testing.T) {
+	handler := New(WithPreFetchHook(func(_ context.Context, link string) (bool, *Result) {
+		return true, nil
+	}))
+	req := httptest.NewRequest(http.MethodGet, "/?content=https://a.example.com/+https://b.example.com/", nil)
+	req.RemoteAddr = "192.0.2.1:1234"
+
+	out := new(strings.Builder)
+	AccessLog(handler, out, AccessLogJSON).ServeHTTP(httptest.NewRecorder(), req)
+	var rec struct {
+		Method, Path, Client string
+		URLs, Status         int
+		Bytes                int64
+		RequestID            string `json:"request_id"`
+	}
+	if err := json.Unmarshal([]byte(out.String()), &rec); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Method != http.MethodGet || rec.Path != "/" || rec.Client != "192.0.2.1" ||
+		rec.URLs != 2 || rec.Status != http.StatusOK || rec.Bytes == 0 || rec.RequestID == "" {
+		t.Fatalf("unexpected JSON record: %s", out)
+	}
+
+	out.Reset()
+	AccessLog(handler, out, AccessLogCommon).ServeHTTP(httptest.NewRecorder(), req)
+	re := regexp.MustCompile(`^192\.0\.2\.1 - - \[[^]]+\] "GET /\?content=\S+ HTTP/1\.1" 200 \d+ 2 [0-9.]+\n$`)
+	if !re.MatchString(out.String()) {
+		t.Fatalf("unexpected Common Log record: %q", out)
+	}
+}
@@ -0,0 +1,133 @@
+package unfurlist
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// airtableEndpoint is base url of Airtable Web API
+const airtableEndpoint = "https://api.airtable.com/v0"
+
+// airtableIcon is Airtable icon used as favicon of previews, API does not
+// expose icons of individual bases
+const airtableIcon = "https://airtable.com/favicon.ico"
+
+var reAirtableID = regexp.MustCompile(`^(app|tbl|viw|shr)[A-Za-z0-9]{14}$`)
+
+// AirtableFetcher returns FetchFunc that gets base and table names of
+// airtable.com links from Airtable metadata API using personal access token
+// with schema.bases:read scope. Links must include base id, i.e.
+// /appID/tblID/viwID or /appID/shrID; share links without base id are not
+// recognized, as API cannot resolve them.
+func AirtableFetcher(token string) FetchFunc {
+	return func(ctx context.Context, client *http.Client, u *url.URL) (*Metadata, bool) {
+		if token == "" || u == nil {
+			return nil, false
+		}
+		if host := strings.ToLower(u.Hostname()); host != "airtable.com" && host != "www.airtable.com" {
+			return nil, false
+		}
+		ids := make(map[string]string)
+		for _, s := range strings.Split(strings.Trim(u.Path, "/"), "/") {
+			if reAirtableID.MatchString(s) {
+				ids[s[:3]] = s
+			}
+		}
+		base := ids["app"]
+		if base == "" {
+			return nil, false
+		}
+		if client == nil {
+			client = http.DefaultClient
+		}
+		ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		defer cancel()
+		get := func(s string, v any) error {
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, airtableEndpoint+s, nil)
+			if err != nil {
+				return err
+			}
+			req.Header.Set("Authorization", "Bearer "+token)
+			resp, err := client.Do(req)
+			if err != nil {
+				return err
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				return &statusError{code: resp.StatusCode, status: resp.Status}
+			}
+			return json.NewDecoder(io.LimitReader(resp.Body, 4<<20)).Decode(v)
+		}
+		var baseName string
+		for offset, n := "", 0; n < 10; n++ { // bases are listed 1000 per page
+			var bases struct {
+				Bases []struct {
+					ID   string `json:"id"`
+					Name string `json:"name"`
+				} `json:"bases"`
+				Offset string `json:"offset"`
+			}
+			s := "/meta/bases"
+			if offset != "" {
+				s += "?offset=" + url.QueryEscape(offset)
+			}
+			if err := get(s, &bases); err != nil {
+				return nil, false
+			}
+			for _, b := range bases.Bases {
+				if b.ID == base {
+					baseName = b.Name
+				}
+			}
+			if offset = bases.Offset; baseName != "" || offset == "" {
+				break
+			}
+		}
+		if baseName == "" {
+			return nil, false
+		}
+		meta := &Metadata{Title: baseName, Type: "website", SiteName: "Airtable", Favicon: airtableIcon}
+		if ids["tbl"] == "" && ids["viw"] == "" {
+			return meta, true
+		}
+		var schema struct {
+			Tables []struct {
+				ID          string `json:"id"`
+				Name        string `json:"name"`
+				Description string `json:"description"`
+				Views       []struct {
+					ID   string `json:"id"`
+					Name string `json:"name"`
+				} `json:"views"`
+			} `json:"tables"`
+		}
+		if err := get("/meta/bases/"+base+"/tables", &schema); err != nil {
+			return meta, true
+		}
+		for _, t := range schema.Tables {
+			view := ""
+			for _, v := range t.Views {
+				if v.ID == ids["viw"] {
+					view = v.Name
+				}
+			}
+			if t.ID != ids["tbl"] && view == "" {
+				continue
+			}
+			meta.Title = t.Name
+			if view != "" {
+				meta.Title += " · " + view
+			}
+			meta.Description = excerpt(t.Description, maxExcerpt)
+			meta.SiteName = baseName + " - Airtable"
+			break
+		}
+		return meta, true
+	}
+}
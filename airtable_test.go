@@ -0,0 +1,58 @@
+package unfurlist
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestAirtableFetcher(t *testing.T) {
+	client := &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		w := httptest.NewRecorder()
+		switch {
+		case r.Header.Get("Authorization") != "Bearer pat":
+			w.WriteHeader(http.StatusUnauthorized)
+		case r.URL.Path == "/v0/meta/bases" && r.URL.Query().Get("offset") == "":
+			w.Write([]byte(`{"bases":[{"id":"appAAAAAAAAAAAAAA","name":"Other"}],"offset":"p2"}`))
+		case r.URL.Path == "/v0/meta/bases" && r.URL.Query().Get("offset") == "p2":
+			w.Write([]byte(`{"bases":[{"id":"appBBBBBBBBBBBBBB","name":"Content calendar"}]}`))
+		case r.URL.Path == "/v0/meta/bases/appBBBBBBBBBBBBBB/tables":
+			w.Write([]byte(`{"tables":[{"id":"tblCCCCCCCCCCCCCC","name":"Posts","description":"Scheduled posts",
+"views":[{"id":"viwDDDDDDDDDDDDDD","name":"Grid view"},{"id":"viwEEEEEEEEEEEEEE","name":"Calendar"}]}]}`))
+		default:
+			http.NotFound(w, r)
+		}
+		return w.Result(), nil
+	})}
+	fetch := AirtableFetcher("pat")
+	for _, tc := range []struct {
+		url, title, siteName, description string
+	}{
+		{"https://airtable.com/appBBBBBBBBBBBBBB", "Content calendar", "Airtable", ""},
+		{"https://airtable.com/appBBBBBBBBBBBBBB/shrFFFFFFFFFFFFFF", "Content calendar", "Airtable", ""},
+		{"https://airtable.com/appBBBBBBBBBBBBBB/tblCCCCCCCCCCCCCC", "Posts", "Content calendar - Airtable", "Scheduled posts"},
+		{"https://airtable.com/appBBBBBBBBBBBBBB/tblCCCCCCCCCCCCCC/viwEEEEEEEEEEEEEE?blocks=hide", "Posts · Calendar", "Content calendar - Airtable", "Scheduled posts"},
+	} {
+		u, _ := url.Parse(tc.url)
+		meta, ok := fetch(context.Background(), client, u)
+		if !ok {
+			t.Errorf("%s: not recognized", tc.url)
+			continue
+		}
+		if meta.Title != tc.title || meta.SiteName != tc.siteName || meta.Description != tc.description || meta.Favicon != airtableIcon {
+			t.Errorf("%s: unexpected result %+v", tc.url, meta)
+		}
+	}
+	for _, s := range []string{
+		"https://airtable.com/shrFFFFFFFFFFFFFF",
+		"https://airtable.com/appZZZZZZZZZZZZZZ/tblCCCCCCCCCCCCCC",
+		"https://airtable.com/pricing",
+	} {
+		u, _ := url.Parse(s)
+		if meta, ok := fetch(context.Background(), client, u); ok {
+			t.Errorf("%s: unexpected result %+v", s, meta)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package unfurlist
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// StaticMapProvider creates links to static map images
+type StaticMapProvider interface {
+	// StaticMap returns url of map image with a marker at location, which
+	// is either "lat,lng" pair or address; and image dimensions. Zero zoom
+	// means provider's default.
+	StaticMap(location string, zoom int) (imageURL string, width, height int)
+}
+
+// GoogleStaticMaps returns StaticMapProvider using Google Static Maps API
+func GoogleStaticMaps(key string, opts GoogleMapsOptions) StaticMapProvider {
+	return newGoogleMaps(key, opts)
+}
+
+// AppleMapsFetcher returns FetchFunc that recognizes maps.apple.com urls and
+// constructs metadata for them, with preview image from p if it's not nil.
+func AppleMapsFetcher(p StaticMapProvider) FetchFunc {
+	return func(_ context.Context, _ *http.Client, u *url.URL) (*Metadata, bool) {
+		if u == nil || u.Host != "maps.apple.com" {
+			return nil, false
+		}
+		q := u.Query()
+		title := firstNonEmpty(q.Get("name"), q.Get("q"), q.Get("address"), q.Get("daddr"))
+		location := firstNonEmpty(q.Get("coordinate"), q.Get("ll"), q.Get("address"), q.Get("q"),
+			q.Get("sll"), q.Get("daddr"))
+		meta := &Metadata{Title: title, Type: "website", SiteName: "Apple Maps"}
+		if meta.Title == "" {
+			meta.Title = "Apple Maps"
+		}
+		if p == nil || location == "" {
+			return meta, true
+		}
+		zoom, _ := strconv.Atoi(strings.TrimSuffix(q.Get("z"), ".0"))
+		meta.Image, meta.ImageWidth, meta.ImageHeight = p.StaticMap(location, zoom)
+		return meta, true
+	}
+}
+
+func firstNonEmpty(ss ...string) string {
+	for _, s := range ss {
+		if s != "" {
+			return s
+		}
+	}
+	return ""
+}
@@ -0,0 +1,42 @@
+package unfurlist
+
+import (
+	"context"
+	"net/url"
+	"testing"
+)
+
+func TestAppleMapsFetcher(t *testing.T) {
+	fetch := AppleMapsFetcher(GoogleStaticMaps("key", GoogleMapsOptions{}))
+	for _, tc := range []struct {
+		url, title, marker string
+	}{
+		{"https://maps.apple.com/?q=Coffee&ll=50.894967,4.341626&z=10", "Coffee", "color:red|50.894967,4.341626"},
+		{"https://maps.apple.com/?address=1+Infinite+Loop,+Cupertino", "1 Infinite Loop, Cupertino", "color:red|1 Infinite Loop, Cupertino"},
+		{"https://maps.apple.com/place?coordinate=37.33,-122.03&name=Apple+Park", "Apple Park", "color:red|37.33,-122.03"},
+		{"https://maps.apple.com/", "Apple Maps", ""},
+	} {
+		u, err := url.Parse(tc.url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		meta, ok := fetch(context.Background(), nil, u)
+		if !ok {
+			t.Fatalf("%s: not recognized", tc.url)
+		}
+		var marker string
+		if meta.Image != "" {
+			img, err := url.Parse(meta.Image)
+			if err != nil {
+				t.Fatal(err)
+			}
+			marker = img.Query().Get("markers")
+		}
+		if meta.Title != tc.title || marker != tc.marker {
+			t.Errorf("%s: got title %q, image %q", tc.url, meta.Title, meta.Image)
+		}
+	}
+	if _, ok := fetch(context.Background(), nil, &url.URL{Scheme: "https", Host: "example.com"}); ok {
+		t.Error("non-Apple Maps url recognized")
+	}
+}
@@ -0,0 +1,85 @@
+package unfurlist
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// asanaEndpoint is base url of Asana REST API
+var asanaEndpoint = "https://app.asana.com/api/1.0"
+
+// reAsanaTask matches paths of Asana task urls:
+// /0/PROJECT/TASK[/f], /0/search/.../TASK and
+// /1/WORKSPACE/project/PROJECT/task/TASK
+var reAsanaTask = regexp.MustCompile(`^/(?:0/(?:[^/]+/)+(\d+)(?:/f)?|1/\d+/(?:[^/]+/\d+/)*task/(\d+))/?$`)
+
+// AsanaFetcher returns FetchFunc that gets task name, project and assignee of
+// app.asana.com task urls from Asana API using personal access token.
+func AsanaFetcher(token string) FetchFunc {
+	return func(ctx context.Context, client *http.Client, u *url.URL) (*Metadata, bool) {
+		if token == "" || u == nil || !strings.EqualFold(u.Host, "app.asana.com") {
+			return nil, false
+		}
+		m := reAsanaTask.FindStringSubmatch(u.Path)
+		if m == nil {
+			return nil, false
+		}
+		id := m[1] + m[2]
+		if client == nil {
+			client = http.DefaultClient
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet,
+			asanaEndpoint+"/tasks/"+id+"?opt_fields=name,notes,completed,assignee.name,projects.name", nil)
+		if err != nil {
+			return nil, false
+		}
+		req.Header.Set("Accept", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, false
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, false
+		}
+		var out struct {
+			Data struct {
+				Name      string `json:"name"`
+				Notes     string `json:"notes"`
+				Completed bool   `json:"completed"`
+				Assignee  *struct {
+					Name string `json:"name"`
+				} `json:"assignee"`
+				Projects []struct {
+					Name string `json:"name"`
+				} `json:"projects"`
+			} `json:"data"`
+		}
+		if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&out); err != nil || out.Data.Name == "" {
+			return nil, false
+		}
+		task := out.Data
+		meta := &Metadata{
+			Title:       task.Name,
+			Type:        "website",
+			Description: excerpt(task.Notes, maxExcerpt),
+			SiteName:    "Asana",
+		}
+		if task.Completed {
+			meta.Title = "✓ " + meta.Title
+		}
+		if len(task.Projects) != 0 && task.Projects[0].Name != "" {
+			meta.SiteName = task.Projects[0].Name + " - Asana"
+		}
+		if task.Assignee != nil {
+			meta.Author = task.Assignee.Name
+		}
+		return meta, true
+	}
+}
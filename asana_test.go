@@ -0,0 +1,54 @@
+package unfurlist
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestAsanaFetcher(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer pat" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Path != "/tasks/1200000000000002" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"data":{"gid":"1200000000000002","name":"Fix login","notes":"Users cannot\n log in.",
+"completed":false,"assignee":{"gid":"1","name":"Jane Doe"},"projects":[{"gid":"3","name":"Web"}]}}`))
+	}))
+	defer srv.Close()
+	defer func(s string) { asanaEndpoint = s }(asanaEndpoint)
+	asanaEndpoint = srv.URL
+
+	fetch := AsanaFetcher("pat")
+	want := Metadata{Title: "Fix login", Type: "website", Description: "Users cannot log in.", SiteName: "Web - Asana", Author: "Jane Doe"}
+	for _, s := range []string{
+		"https://app.asana.com/0/1200000000000003/1200000000000002",
+		"https://app.asana.com/0/1200000000000003/1200000000000002/f",
+		"https://app.asana.com/0/inbox/1200000000000001/1200000000000002",
+		"https://app.asana.com/1/1200000000000000/project/1200000000000003/task/1200000000000002",
+		"https://app.asana.com/1/1200000000000000/task/1200000000000002?focus=true",
+	} {
+		u, _ := url.Parse(s)
+		meta, ok := fetch(context.Background(), srv.Client(), u)
+		if !ok || *meta != want {
+			t.Errorf("%s: got %+v, %v", s, meta, ok)
+		}
+	}
+	for _, s := range []string{
+		"https://app.asana.com/0/1200000000000003/1200000000000004",
+		"https://app.asana.com/1/1200000000000000/project/1200000000000003/list",
+		"https://asana.com/0/1200000000000003/1200000000000002",
+	} {
+		u, _ := url.Parse(s)
+		if meta, ok := fetch(context.Background(), srv.Client(), u); ok {
+			t.Errorf("%s: unexpected result %+v", s, meta)
+		}
+	}
+}
@@ -0,0 +1,205 @@
+package unfurlist
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// AsyncResult is the body of callback request made once asynchronous job
+// requested with callback_url argument is done
+type AsyncResult struct {
+	JobID string `json:"job_id"`
+	*Envelope
+}
+
+// asyncCallbacks delivers results of asynchronous jobs
+type asyncCallbacks struct {
+	secret []byte // to sign callback requests
+}
+
+// DefaultMaxJobs is the default number of asynchronous jobs processed
+// concurrently, see WithMaxJobs
+const DefaultMaxJobs = 100
+
+// defaultJobTimeout limits processing time of asynchronous jobs if handler
+// has no request time limit
+const defaultJobTimeout = 5 * time.Minute
+
+// WithAsyncCallbacks enables asynchronous processing of requests having
+// callback_url argument: such requests are immediately replied with 202
+// Accepted status and JSON object with "job_id" attribute, once processing is
+// done, AsyncResult is POSTed to callback url as JSON. Callback requests have
+// X-Unfurlist-Job header with job id and X-Unfurlist-Signature header with
+// hex-encoded HMAC-SHA256 of request body made with secret, see
+// SignCallback. Failed callback requests are retried a few times.
+//
+// Callback urls must be allowed with WithCallbackPrefixes, requests with
+// callback_url argument are rejected otherwise. Request signature made by
+// SignContent does not cover callback_url, so without such restriction a
+// captured signed request could be replayed to make handler POST to any url.
+func WithAsyncCallbacks(secret []byte) ConfFunc {
+	return func(h *unfurlHandler) *unfurlHandler {
+		if len(secret) != 0 {
+			h.async = &asyncCallbacks{secret: secret}
+		}
+		return h
+	}
+}
+
+// WithCallbackPrefixes configures unfurl handler to only accept callback_url
+// arguments starting with one of prefixes, it is required for
+// WithAsyncCallbacks to take effect. Callback urls matching them are trusted
+// and may point to private addresses.
+func WithCallbackPrefixes(prefixes []string) ConfFunc {
+	pmap := newPrefixMap(prefixes)
+	return func(h *unfurlHandler) *unfurlHandler {
+		if pmap != nil {
+			h.callbackPrefixes = pmap
+		}
+		return h
+	}
+}
+
+// WithMaxJobs configures unfurl handler to process at most n asynchronous
+// jobs concurrently, requests starting new jobs over the limit are replied
+// with 503 Service Unavailable. If n is not positive, DefaultMaxJobs is used.
+func WithMaxJobs(n int) ConfFunc {
+	return func(h *unfurlHandler) *unfurlHandler {
+		if n > 0 {
+			h.maxJobs = n
+		}
+		return h
+	}
+}
+
+// SignCallback returns signature of callback request body as sent in
+// X-Unfurlist-Signature header by handler configured with
+// WithAsyncCallbacks:
+//
+//	hex(HMAC-SHA256(secret, body))
+func SignCallback(secret, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// validCallbackURL reports whether s is an absolute http(s) url allowed by
+// WithCallbackPrefixes
+func (h *unfurlHandler) validCallbackURL(s string) bool {
+	u, err := url.Parse(s)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return false
+	}
+	return h.callbackPrefixes != nil && h.callbackPrefixes.Match(s)
+}
+
+func newJobID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// startJob processes urls in background and calls done with the results. ctx
+// is only used for its values, its cancellation is ignored; processing is
+// limited by timeout, or defaultJobTimeout if it's not positive. It returns id
+// of the started job, or false if there are too many jobs in progress.
+func (h *unfurlHandler) startJob(ctx context.Context, urls []string, timeout time.Duration, done func(ctx context.Context, id string, env *Envelope)) (string, bool) {
+	select {
+	case h.jobSem <- struct{}{}:
+	default:
+		return "", false
+	}
+	if timeout <= 0 {
+		timeout = defaultJobTimeout
+	}
+	id := newJobID()
+	ctx = context.WithoutCancel(ctx)
+	go func() {
+		defer func() { <-h.jobSem }()
+		uctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+		done(ctx, id, newEnvelope(h.unfurl(uctx, urls)))
+	}()
+	return id, true
+}
+
+// callbackDelivery returns function delivering job results to callbackURL,
+// suitable for startJob
+func (h *unfurlHandler) callbackDelivery(callbackURL string) func(context.Context, string, *Envelope) {
+	return func(ctx context.Context, id string, env *Envelope) {
+		body, err := json.Marshal(AsyncResult{JobID: id, Envelope: env})
+		if err != nil {
+			h.logf(ctx, "job %s: %v", id, err)
+			return
+		}
+		if err := h.deliverCallback(ctx, id, callbackURL, body); err != nil {
+			h.logf(ctx, "job %s: callback to %q: %v", id, callbackURL, err)
+		}
+	}
+}
+
+// deliverCallback POSTs body to callbackURL, retrying with increasing delays
+// on failures
+func (h *unfurlHandler) deliverCallback(ctx context.Context, id, callbackURL string, body []byte) error {
+	const attempts = 4
+	sig := SignCallback(h.async.secret, body)
+	var err error
+	for i := 0; i < attempts; i++ {
+		if i > 0 {
+			time.Sleep(time.Duration(1<<(i-1)) * time.Second)
+		}
+		if err = h.postCallback(ctx, id, callbackURL, sig, body); err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+func (h *unfurlHandler) postCallback(ctx context.Context, id, callbackURL, sig string, body []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, callbackURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Unfurlist-Job", id)
+	req.Header.Set("X-Unfurlist-Signature", sig)
+	resp, err := h.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+	return nil
+}
+
+// writeTooManyJobs replies with 503 Service Unavailable status to requests
+// starting new jobs when there are too many of them in progress
+func writeTooManyJobs(w http.ResponseWriter) {
+	w.Header().Set("Retry-After", "60")
+	http.Error(w, "too many jobs in progress", http.StatusServiceUnavailable)
+}
+
+// writeAccepted replies with 202 Accepted status and job id
+func writeAccepted(w http.ResponseWriter, id string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(struct {
+		JobID string `json:"job_id"`
+	}{id})
+}
@@ -0,0 +1,112 @@
+package unfurlist
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestAsyncCallbacks(t *testing.T) {
+	secret := []byte("secret")
+	type callback struct {
+		hdr  http.Header
+		body []byte
+	}
+	callbacks := make(chan callback, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/page":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(`<html><head><title>Page</title></head></html>`))
+		case "/callback":
+			body, _ := io.ReadAll(r.Body)
+			callbacks <- callback{r.Header, body}
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	args := url.Values{"content": {srv.URL + "/page"}, "callback_url": {srv.URL + "/callback"}}
+	req := httptest.NewRequest(http.MethodGet, "/?"+args.Encode(), nil)
+	w := httptest.NewRecorder()
+	New().ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("got status %d for callback_url without async support, want %d", w.Code, http.StatusBadRequest)
+	}
+
+	w = httptest.NewRecorder()
+	New(WithAsyncCallbacks(secret), WithCallbackPrefixes([]string{srv.URL + "/callback"})).ServeHTTP(w, req)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("got status %d, want %d", w.Code, http.StatusAccepted)
+	}
+	var accepted struct {
+		JobID string `json:"job_id"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &accepted); err != nil || accepted.JobID == "" {
+		t.Fatalf("unexpected response %q: %v", w.Body.String(), err)
+	}
+	var cb callback
+	select {
+	case cb = <-callbacks:
+	case <-time.After(5 * time.Second):
+		t.Fatal("callback was not called")
+	}
+	if got := cb.hdr.Get("X-Unfurlist-Job"); got != accepted.JobID {
+		t.Fatalf("callback job id %q, want %q", got, accepted.JobID)
+	}
+	if got, want := cb.hdr.Get("X-Unfurlist-Signature"), SignCallback(secret, cb.body); got != want {
+		t.Fatalf("callback signature %q, want %q", got, want)
+	}
+	var res AsyncResult
+	if err := json.Unmarshal(cb.body, &res); err != nil {
+		t.Fatal(err)
+	}
+	if res.JobID != accepted.JobID || len(res.Results) != 1 || res.Results[0].Title != "Page" {
+		t.Fatalf("unexpected callback body: %s", cb.body)
+	}
+}
+
+func TestCallbackRestrictions(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+	args := url.Values{"content": {"https://example.com/"}, "callback_url": {srv.URL + "/other"}}
+	req := httptest.NewRequest(http.MethodGet, "/?"+args.Encode(), nil)
+	w := httptest.NewRecorder()
+	New(WithAsyncCallbacks([]byte("secret")), WithCallbackPrefixes([]string{srv.URL + "/callback"})).ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("got status %d for callback_url not matching prefixes, want %d", w.Code, http.StatusBadRequest)
+	}
+
+	args.Set("callback_url", srv.URL+"/callback")
+	req = httptest.NewRequest(http.MethodGet, "/?"+args.Encode(), nil)
+	w = httptest.NewRecorder()
+	New(WithAsyncCallbacks([]byte("secret"))).ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("got status %d for callback_url without allowed prefixes, want %d", w.Code, http.StatusBadRequest)
+	}
+}
+
+func TestMaxJobs(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/page" {
+			<-release
+		}
+	}))
+	defer srv.Close()
+	defer close(release)
+	h := New(WithAsyncCallbacks([]byte("secret")), WithCallbackPrefixes([]string{srv.URL + "/callback"}), WithMaxJobs(1))
+	args := url.Values{"content": {srv.URL + "/page"}, "callback_url": {srv.URL + "/callback"}}
+	for _, want := range []int{http.StatusAccepted, http.StatusServiceUnavailable} {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/?"+args.Encode(), nil))
+		if w.Code != want {
+			t.Fatalf("got status %d, want %d", w.Code, want)
+		}
+	}
+}
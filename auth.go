@@ -0,0 +1,146 @@
+package unfurlist
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TokenSource provides value of Authorization header, i.e. "Bearer xyz", for
+// requests to domains configured with WithAuthorization. Implementations must
+// be safe for concurrent use and are expected to cache and refresh tokens
+// themselves.
+type TokenSource interface {
+	Token(ctx context.Context) (string, error)
+}
+
+// StaticToken returns TokenSource that always provides the same
+// Authorization header value
+func StaticToken(value string) TokenSource { return staticToken(value) }
+
+type staticToken string
+
+func (t staticToken) Token(context.Context) (string, error) { return string(t), nil }
+
+// WithAuthorization configures unfurl handler to authorize requests to domain
+// and its subdomains with Authorization header provided by ts, i.e. to preview
+// links to internal tools requiring a service account. Header is not sent to
+// other hosts, including ones requests are redirected to. If ts fails,
+// request is made without authorization. Option may be used multiple times,
+// the most specific matching domain is used.
+func WithAuthorization(domain string, ts TokenSource) ConfFunc {
+	domain = strings.ToLower(strings.TrimSuffix(domain, "."))
+	return func(h *unfurlHandler) *unfurlHandler {
+		if domain == "" || ts == nil {
+			return h
+		}
+		if h.authorization == nil {
+			h.authorization = make(map[string]TokenSource)
+		}
+		h.authorization[domain] = ts
+		return h
+	}
+}
+
+// setAuthorization sets Authorization header of req if its host belongs to
+// one of domains configured with WithAuthorization
+func (h *unfurlHandler) setAuthorization(req *http.Request) {
+	if len(h.authorization) == 0 {
+		return
+	}
+	for host := strings.ToLower(req.URL.Hostname()); host != ""; {
+		if ts, ok := h.authorization[host]; ok {
+			v, err := ts.Token(req.Context())
+			if err != nil {
+				h.logf(req.Context(), "authorization token for %q: %v", host, err)
+				return
+			}
+			req.Header.Set("Authorization", v)
+			return
+		}
+		_, host, _ = strings.Cut(host, ".")
+	}
+}
+
+// ClientCredentials returns TokenSource that obtains OAuth2 access tokens
+// from tokenURL with client credentials grant (RFC 6749, section 4.4).
+// Tokens are cached and refreshed shortly before they expire. If client is
+// nil, http.DefaultClient is used.
+func ClientCredentials(client *http.Client, tokenURL, clientID, clientSecret string, scopes ...string) TokenSource {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &clientCredentials{
+		client:       client,
+		tokenURL:     tokenURL,
+		clientID:     clientID,
+		clientSecret: clientSecret,
+		scopes:       scopes,
+	}
+}
+
+type clientCredentials struct {
+	client                 *http.Client
+	tokenURL               string
+	clientID, clientSecret string
+	scopes                 []string
+
+	mu      sync.Mutex
+	value   string    // cached Authorization header value
+	expires time.Time // zero if token doesn't expire
+}
+
+func (c *clientCredentials) Token(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.value != "" && (c.expires.IsZero() || time.Until(c.expires) > time.Minute) {
+		return c.value, nil
+	}
+	vals := url.Values{"grant_type": {"client_credentials"}}
+	if len(c.scopes) != 0 {
+		vals.Set("scope", strings.Join(c.scopes, " "))
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.tokenURL, strings.NewReader(vals.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(c.clientID), url.QueryEscape(c.clientSecret))
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("token request: unexpected status %q", resp.Status)
+	}
+	var tok struct {
+		AccessToken string `json:"access_token"`
+		TokenType   string `json:"token_type"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&tok); err != nil {
+		return "", fmt.Errorf("token request: %w", err)
+	}
+	if tok.AccessToken == "" {
+		return "", errors.New("token request: empty access_token")
+	}
+	typ := tok.TokenType
+	if typ == "" || strings.EqualFold(typ, "bearer") {
+		typ = "Bearer"
+	}
+	c.value = typ + " " + tok.AccessToken
+	c.expires = time.Time{}
+	if tok.ExpiresIn > 0 {
+		c.expires = time.Now().Add(time.Duration(tok.ExpiresIn) * time.Second)
+	}
+	return c.value, nil
+}
@@ -0,0 +1,49 @@
+package unfurlist
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func TestClientCredentials(t *testing.T) {
+	var calls atomic.Int32
+	tokenSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, secret, _ := r.BasicAuth()
+		if r.Method != http.MethodPost || id != "client" || secret != "s3cret" ||
+			r.PostFormValue("grant_type") != "client_credentials" || r.PostFormValue("scope") != "read wiki" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		n := calls.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"access_token":"tok%d","token_type":"bearer","expires_in":3600}`, n)
+	}))
+	defer tokenSrv.Close()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/favicon.ico" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprintf(w, "<html><head><title>%s</title></head></html>", r.Header.Get("Authorization"))
+	}))
+	defer srv.Close()
+
+	ts := ClientCredentials(nil, tokenSrv.URL, "client", "s3cret", "read", "wiki")
+	h := New(WithAuthorization("127.0.0.1", ts)).(*unfurlHandler)
+	if res := h.processURL(context.Background(), srv.URL+"/a"); res.Title != "Bearer tok1" {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+	if res := h.processURL(context.Background(), srv.URL+"/b"); res.Title != "Bearer tok1" {
+		t.Fatalf("token was not cached: %+v", res)
+	}
+	req := httptest.NewRequest(http.MethodGet, "https://example.com/", nil)
+	h.setAuthorization(req)
+	if v := req.Header.Get("Authorization"); v != "" {
+		t.Fatalf("authorization leaked to other host: %q", v)
+	}
+}
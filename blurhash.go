@@ -0,0 +1,113 @@
+package unfurlist
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"strings"
+)
+
+// blurHash returns BlurHash (https://blurha.sh) of img with 4x3 components,
+// 4 horizontal ones for landscape images and 3 for portrait ones. Large images
+// are downscaled before encoding.
+func blurHash(img image.Image) string {
+	xComp, yComp := 4, 3
+	if b := img.Bounds(); b.Dy() > b.Dx() {
+		xComp, yComp = 3, 4
+	}
+	img = resizeImage(img, 64, 64)
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w == 0 || h == 0 {
+		return ""
+	}
+	var lin [3][]float64
+	for c := range lin {
+		lin[c] = make([]float64, w*h)
+	}
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			c := color.NRGBAModel.Convert(img.At(b.Min.X+x, b.Min.Y+y)).(color.NRGBA)
+			lin[0][y*w+x] = srgbToLinear(c.R)
+			lin[1][y*w+x] = srgbToLinear(c.G)
+			lin[2][y*w+x] = srgbToLinear(c.B)
+		}
+	}
+	factors := make([][3]float64, 0, xComp*yComp)
+	for j := 0; j < yComp; j++ {
+		for i := 0; i < xComp; i++ {
+			norm := 2.0
+			if i == 0 && j == 0 {
+				norm = 1
+			}
+			var f [3]float64
+			for y := 0; y < h; y++ {
+				cy := math.Cos(math.Pi * float64(j) * float64(y) / float64(h))
+				for x := 0; x < w; x++ {
+					basis := norm * math.Cos(math.Pi*float64(i)*float64(x)/float64(w)) * cy
+					for c := range f {
+						f[c] += basis * lin[c][y*w+x]
+					}
+				}
+			}
+			for c := range f {
+				f[c] /= float64(w * h)
+			}
+			factors = append(factors, f)
+		}
+	}
+	var sb strings.Builder
+	encode83(&sb, (xComp-1)+(yComp-1)*9, 1)
+	maxValue := 1.0
+	if ac := factors[1:]; len(ac) != 0 {
+		var actualMax float64
+		for _, f := range ac {
+			actualMax = max(actualMax, math.Abs(f[0]), math.Abs(f[1]), math.Abs(f[2]))
+		}
+		quantised := int(max(0, min(82, math.Floor(actualMax*166-0.5))))
+		maxValue = float64(quantised+1) / 166
+		encode83(&sb, quantised, 1)
+	} else {
+		encode83(&sb, 0, 1)
+	}
+	dc := factors[0]
+	encode83(&sb, linearToSRGB(dc[0])<<16|linearToSRGB(dc[1])<<8|linearToSRGB(dc[2]), 4)
+	for _, f := range factors[1:] {
+		var q [3]int
+		for c := range q {
+			v := f[c] / maxValue
+			q[c] = int(max(0, min(18, math.Floor(math.Copysign(math.Sqrt(math.Abs(v)), v)*9+9.5))))
+		}
+		encode83(&sb, q[0]*19*19+q[1]*19+q[2], 2)
+	}
+	return sb.String()
+}
+
+const base83 = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz#$%*+,-.:;=?@[]^_{|}~"
+
+// encode83 writes n as length base83 digits
+func encode83(sb *strings.Builder, n, length int) {
+	div := 1
+	for i := 1; i < length; i++ {
+		div *= 83
+	}
+	for ; div > 0; div /= 83 {
+		sb.WriteByte(base83[n/div%83])
+	}
+}
+
+func srgbToLinear(v uint8) float64 {
+	f := float64(v) / 255
+	if f <= 0.04045 {
+		return f / 12.92
+	}
+	return math.Pow((f+0.055)/1.055, 2.4)
+}
+
+func linearToSRGB(v float64) int {
+	v = max(0, min(1, v))
+	if v <= 0.0031308 {
+		return int(v*12.92*255 + 0.5)
+	}
+	return int((1.055*math.Pow(v, 1/2.4)-0.055)*255 + 0.5)
+}
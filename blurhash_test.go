@@ -0,0 +1,20 @@
+package unfurlist
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestBlurHash(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 8, 6))
+	for y := 0; y < 6; y++ {
+		for x := 0; x < 8; x++ {
+			img.Set(x, y, color.NRGBA{uint8(x * 30), uint8(y * 40), uint8(x * y * 7), 0xff})
+		}
+	}
+	const want = "LcE.,z31a{%1zDNOfQnPenf9fQf6"
+	if got := blurHash(img); got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
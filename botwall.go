@@ -0,0 +1,64 @@
+package unfurlist
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// ErrBotProtection is reported for urls leading to captcha or other
+// interstitial pages of bot protection services instead of the actual
+// content. Results for such urls have StatusBotProtection status and are
+// not cached.
+var ErrBotProtection = errors.New("page is blocked by bot protection")
+
+// botWallMarkers are lower-cased fragments of html found on captcha and
+// interstitial pages
+var botWallMarkers = [][]byte{
+	[]byte("<title>just a moment...</title>"),                 // Cloudflare
+	[]byte("<title>attention required! | cloudflare</title>"), // Cloudflare
+	[]byte("cf-browser-verification"),                         // Cloudflare
+	[]byte("<title>robot check</title>"),                      // Amazon
+	[]byte("geo.captcha-delivery.com"),                        // DataDome
+	[]byte("id=\"px-captcha\""),                               // PerimeterX
+}
+
+// isBotWall reports whether response with given status, headers and first
+// chunk of body is a captcha or interstitial page
+func isBotWall(code int, header http.Header, u *url.URL, body []byte) bool {
+	if header.Get("Cf-Mitigated") == "challenge" {
+		return true
+	}
+	// Google redirects suspected bots to https://www.google.com/sorry/index
+	if strings.HasPrefix(u.Path, "/sorry/") && strings.Contains(u.Hostname(), "google.") {
+		return true
+	}
+	if code != http.StatusOK && code != http.StatusForbidden &&
+		code != http.StatusTooManyRequests && code != http.StatusServiceUnavailable {
+		return false
+	}
+	if ct := header.Get("Content-Type"); ct != "" && !strings.HasPrefix(ct, "text/html") {
+		return false
+	}
+	lower := bytes.ToLower(body)
+	for _, m := range botWallMarkers {
+		if bytes.Contains(lower, m) {
+			return true
+		}
+	}
+	return false
+}
+
+// errorBodyPeek reads the beginning of error response body so that it can
+// be checked with isBotWall
+func errorBodyPeek(resp *http.Response) []byte {
+	switch resp.StatusCode {
+	case http.StatusForbidden, http.StatusTooManyRequests, http.StatusServiceUnavailable:
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, 16<<10))
+		return b
+	}
+	return nil
+}
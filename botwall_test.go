@@ -0,0 +1,52 @@
+package unfurlist
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+)
+
+func TestBotProtection(t *testing.T) {
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/challenge":
+			hits.Add(1)
+			w.Header().Set("Cf-Mitigated", "challenge")
+			w.Header().Set("Content-Type", "text/html")
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`<html><head><title>Just a moment...</title></head></html>`))
+		case "/captcha":
+			hits.Add(1)
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(`<html><head><title>Robot Check</title></head></html>`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	handler := New(WithMemcache(newTestMemcache(t)))
+	content := url.QueryEscape(srv.URL + "/challenge " + srv.URL + "/captcha")
+	for i := 0; i < 2; i++ {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/?content="+content, nil))
+		var res []Result
+		if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+			t.Fatal(err)
+		}
+		if len(res) != 2 {
+			t.Fatalf("unexpected results: %+v", res)
+		}
+		for _, r := range res {
+			if r.Status != StatusBotProtection || r.Title != "" {
+				t.Fatalf("unexpected result: %+v", r)
+			}
+		}
+	}
+	if n := hits.Load(); n != 4 {
+		t.Fatalf("got %d upstream requests, want 4 (results should not be cached)", n)
+	}
+}
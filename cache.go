@@ -0,0 +1,72 @@
+package unfurlist
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+// Cache stores results and jobs. Implementations must be safe for concurrent
+// use.
+type Cache interface {
+	// Get returns value stored under key, or ErrCacheMiss if there's no
+	// such value
+	Get(ctx context.Context, key string) ([]byte, error)
+	// Set stores value under key; if ttl is positive, value expires after
+	// it
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+}
+
+// MultiGetter may be implemented by Cache to look up multiple keys in one
+// round-trip. Returned map only has found keys.
+type MultiGetter interface {
+	GetMulti(ctx context.Context, keys []string) (map[string][]byte, error)
+}
+
+// ErrCacheMiss is returned by Cache.Get if there's no value for a key
+var ErrCacheMiss = errors.New("cache miss")
+
+// WithCache configures unfurl handler to keep results and jobs in c. It
+// replaces cache configured by WithMemcache or WithMemcacheOptions.
+func WithCache(c Cache) ConfFunc {
+	return func(h *unfurlHandler) *unfurlHandler {
+		if c != nil {
+			h.cache = c
+		}
+		return h
+	}
+}
+
+// memcacheStore is Cache backed by memcached
+type memcacheStore struct {
+	client *memcache.Client
+}
+
+func (m memcacheStore) Get(_ context.Context, key string) ([]byte, error) {
+	it, err := m.client.Get(key)
+	if err == memcache.ErrCacheMiss {
+		return nil, ErrCacheMiss
+	}
+	if err != nil {
+		return nil, err
+	}
+	return it.Value, nil
+}
+
+func (m memcacheStore) Set(_ context.Context, key string, value []byte, ttl time.Duration) error {
+	return m.client.Set(&memcache.Item{Key: key, Value: value, Expiration: memcacheExpiration(ttl)})
+}
+
+func (m memcacheStore) GetMulti(_ context.Context, keys []string) (map[string][]byte, error) {
+	items, err := m.client.GetMulti(keys)
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string][]byte, len(items))
+	for k, it := range items {
+		out[k] = it.Value
+	}
+	return out, nil
+}
@@ -0,0 +1,76 @@
+package unfurlist
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// WithUpstreamCacheTTL configures unfurl handler to cache results made from
+// fetched pages for as long as page responses allow to cache them, according
+// to their Cache-Control (s-maxage, max-age, no-store, no-cache) or Expires
+// headers, bounded by minTTL and maxTTL. If maxTTL is not positive, TTL is
+// only bounded by minTTL. Results of pages without such headers, and other
+// results, are cached with TTL configured by WithCacheTTL.
+func WithUpstreamCacheTTL(minTTL, maxTTL time.Duration) ConfFunc {
+	return func(h *unfurlHandler) *unfurlHandler {
+		h.upstreamTTL = true
+		h.minTTL, h.maxTTL = max(minTTL, 0), max(maxTTL, 0)
+		return h
+	}
+}
+
+// resultTTL returns TTL to cache result made from chunk with
+func (h *unfurlHandler) resultTTL(chunk *pageChunk) time.Duration {
+	if !h.upstreamTTL || chunk == nil || !chunk.hasTTL {
+		return h.cacheTTL
+	}
+	ttl := max(chunk.ttl, h.minTTL)
+	if h.maxTTL > 0 {
+		ttl = min(ttl, h.maxTTL)
+	}
+	// ttl of 0 means no expiration
+	return max(ttl, time.Second)
+}
+
+// responseTTL returns how long response with headers hdr received at now may
+// be cached for by a shared cache, and whether headers specify it
+func responseTTL(hdr http.Header, now time.Time) (time.Duration, bool) {
+	maxAge, sMaxAge := -1, -1
+	for _, v := range hdr.Values("Cache-Control") {
+		for _, d := range strings.Split(v, ",") {
+			k, v, _ := strings.Cut(strings.TrimSpace(d), "=")
+			switch strings.ToLower(k) {
+			case "no-store", "no-cache", "private":
+				return 0, true
+			case "max-age":
+				if n, err := strconv.Atoi(strings.Trim(v, `"`)); err == nil && n >= 0 {
+					maxAge = n
+				}
+			case "s-maxage":
+				if n, err := strconv.Atoi(strings.Trim(v, `"`)); err == nil && n >= 0 {
+					sMaxAge = n
+				}
+			}
+		}
+	}
+	if sMaxAge >= 0 {
+		maxAge = sMaxAge
+	}
+	if maxAge >= 0 {
+		age, _ := strconv.Atoi(hdr.Get("Age"))
+		return max(time.Duration(maxAge-age)*time.Second, 0), true
+	}
+	if s := hdr.Get("Expires"); s != "" {
+		exp, err := http.ParseTime(s)
+		if err != nil { // invalid values mean already expired
+			return 0, true
+		}
+		if date, err := http.ParseTime(hdr.Get("Date")); err == nil {
+			now = date
+		}
+		return max(exp.Sub(now), 0), true
+	}
+	return 0, false
+}
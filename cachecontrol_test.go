@@ -0,0 +1,70 @@
+package unfurlist
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestResponseTTL(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		hdr http.Header
+		ttl time.Duration
+		ok  bool
+	}{
+		{http.Header{}, 0, false},
+		{http.Header{"Cache-Control": {"public, max-age=600"}}, 10 * time.Minute, true},
+		{http.Header{"Cache-Control": {"max-age=600, s-maxage=60"}}, time.Minute, true},
+		{http.Header{"Cache-Control": {"max-age=600"}, "Age": {"100"}}, 500 * time.Second, true},
+		{http.Header{"Cache-Control": {"no-store"}}, 0, true},
+		{http.Header{"Cache-Control": {"private, max-age=600"}}, 0, true},
+		{http.Header{"Expires": {"Wed, 01 May 2024 13:00:00 GMT"}}, time.Hour, true},
+		{http.Header{"Expires": {"Wed, 01 May 2024 13:00:00 GMT"}, "Date": {"Wed, 01 May 2024 12:30:00 GMT"}}, 30 * time.Minute, true},
+		{http.Header{"Expires": {"0"}}, 0, true},
+		{http.Header{"Cache-Control": {"max-age=60"}, "Expires": {"Wed, 01 May 2024 13:00:00 GMT"}}, time.Minute, true},
+	} {
+		ttl, ok := responseTTL(tc.hdr, now)
+		if ttl != tc.ttl || ok != tc.ok {
+			t.Errorf("%v: got %v, %v, want %v, %v", tc.hdr, ttl, ok, tc.ttl, tc.ok)
+		}
+	}
+}
+
+func TestUpstreamCacheTTL(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/favicon.ico" {
+			http.NotFound(w, r)
+			return
+		}
+		if v := r.URL.Query().Get("cc"); v != "" {
+			w.Header().Set("Cache-Control", v)
+		}
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><head><title>Page</title></head></html>`))
+	}))
+	defer srv.Close()
+	cache := new(mapCache)
+	h := New(WithCache(cache), WithCacheTTL(time.Hour),
+		WithUpstreamCacheTTL(5*time.Minute, 24*time.Hour)).(*unfurlHandler)
+	for _, tc := range []struct {
+		query string
+		ttl   time.Duration
+	}{
+		{"", time.Hour},
+		{"?cc=max-age%3D1800", 30 * time.Minute},
+		{"?cc=max-age%3D10", 5 * time.Minute},
+		{"?cc=no-cache", 5 * time.Minute},
+		{"?cc=max-age%3D31536000", 24 * time.Hour},
+	} {
+		link := srv.URL + "/" + tc.query
+		if res := h.processURL(context.Background(), link); res.Title != "Page" {
+			t.Fatalf("%s: unexpected result: %+v", link, res)
+		}
+		if got := cache.ttls[h.cacheKey(context.Background(), link)]; got != tc.ttl {
+			t.Errorf("%s: cached with ttl %v, want %v", link, got, tc.ttl)
+		}
+	}
+}
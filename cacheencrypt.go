@@ -0,0 +1,103 @@
+package unfurlist
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"time"
+)
+
+// WithCacheEncryption configures unfurl handler to encrypt values it stores
+// in cache with AES-GCM using key, so that they are not readable by other
+// users of the cache servers. Key must be 16, 24 or 32 bytes long, otherwise
+// encryption is not enabled. Values stored without encryption or with
+// different key are treated as cache misses.
+func WithCacheEncryption(key []byte) ConfFunc {
+	var aead cipher.AEAD
+	if block, err := aes.NewCipher(key); err == nil {
+		aead, _ = cipher.NewGCM(block)
+	}
+	return func(h *unfurlHandler) *unfurlHandler {
+		if aead != nil {
+			h.cacheAEAD = aead
+		}
+		return h
+	}
+}
+
+// encryptCache wraps c so that its values are encrypted with aead
+func encryptCache(c Cache, aead cipher.AEAD) Cache {
+	if c == nil {
+		return nil
+	}
+	ec := encryptedCache{c: c, aead: aead}
+	if mg, ok := c.(MultiGetter); ok {
+		return encryptedMultiCache{ec, mg}
+	}
+	return ec
+}
+
+type encryptedCache struct {
+	c    Cache
+	aead cipher.AEAD
+}
+
+// Values are stored as nonce followed by ciphertext. Key is used as
+// additional data, so that value cannot be moved under another key.
+
+func (c encryptedCache) seal(key string, value []byte) ([]byte, error) {
+	nonce := make([]byte, c.aead.NonceSize(), c.aead.NonceSize()+len(value)+c.aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return c.aead.Seal(nonce, nonce, value, []byte(key)), nil
+}
+
+func (c encryptedCache) open(key string, data []byte) ([]byte, error) {
+	n := c.aead.NonceSize()
+	if len(data) < n {
+		return nil, errors.New("encrypted cache value is too short")
+	}
+	return c.aead.Open(nil, data[:n], data[n:], []byte(key))
+}
+
+func (c encryptedCache) Get(ctx context.Context, key string) ([]byte, error) {
+	data, err := c.c.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	value, err := c.open(key, data)
+	if err != nil {
+		return nil, ErrCacheMiss
+	}
+	return value, nil
+}
+
+func (c encryptedCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	data, err := c.seal(key, value)
+	if err != nil {
+		return err
+	}
+	return c.c.Set(ctx, key, data, ttl)
+}
+
+type encryptedMultiCache struct {
+	encryptedCache
+	mg MultiGetter
+}
+
+func (c encryptedMultiCache) GetMulti(ctx context.Context, keys []string) (map[string][]byte, error) {
+	items, err := c.mg.GetMulti(ctx, keys)
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string][]byte, len(items))
+	for k, data := range items {
+		if value, err := c.open(k, data); err == nil {
+			out[k] = value
+		}
+	}
+	return out, nil
+}
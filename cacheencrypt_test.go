@@ -0,0 +1,47 @@
+package unfurlist
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestCacheEncryption(t *testing.T) {
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/favicon.ico" {
+			http.NotFound(w, r)
+			return
+		}
+		hits.Add(1)
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><head><title>Private page</title></head></html>`))
+	}))
+	defer srv.Close()
+	mc := newTestMemcache(t)
+	key1 := []byte("0123456789abcdef0123456789abcdef")
+	key2 := []byte("fedcba9876543210fedcba9876543210")
+	req := "/?content=" + url.QueryEscape(srv.URL)
+	for _, key := range [][]byte{key1, key1, key2} {
+		w := httptest.NewRecorder()
+		New(WithMemcache(mc), WithCacheEncryption(key)).ServeHTTP(w, httptest.NewRequest(http.MethodGet, req, nil))
+		if !strings.Contains(w.Body.String(), "Private page") {
+			t.Fatalf("unexpected response: %s", w.Body)
+		}
+	}
+	if n := hits.Load(); n != 2 {
+		t.Fatalf("got %d upstream requests, want 2 (one per encryption key)", n)
+	}
+	plain := New(WithMemcache(mc)).(*unfurlHandler)
+	data, err := plain.cache.Get(context.Background(), plain.cacheKey(context.Background(), srv.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := decodeCached(data); ok {
+		t.Fatal("cached value is not encrypted")
+	}
+}
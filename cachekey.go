@@ -0,0 +1,63 @@
+package unfurlist
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"reflect"
+	"strings"
+)
+
+// WithCacheNamespace configures unfurl handler to prefix cache keys with ns,
+// so that multiple products or environments can share the same memcached
+// cluster. Namespace may only consist of ASCII letters, digits, '.', '_' and
+// '-', and be up to 64 characters long; otherwise it's ignored.
+func WithCacheNamespace(ns string) ConfFunc {
+	return func(h *unfurlHandler) *unfurlHandler {
+		if validCacheNamespace(ns) {
+			h.cacheNamespace = ns
+		}
+		return h
+	}
+}
+
+func validCacheNamespace(s string) bool {
+	if s == "" || len(s) > 64 {
+		return false
+	}
+	for _, c := range s {
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		case c == '.' || c == '_' || c == '-':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// cacheKey returns key to cache result for link under
+func (h *unfurlHandler) cacheKey(ctx context.Context, link string) string {
+	key := mcKey(resultSchema + "\x00" + resultKey(ctx, link))
+	if h.cacheNamespace == "" {
+		return key
+	}
+	return h.cacheNamespace + ":" + key
+}
+
+// resultSchema is a digest of Result serialized fields. It's mixed into cache
+// keys so that once fields change, results cached by older versions are not
+// decoded into a different shape.
+var resultSchema = func() string {
+	var b strings.Builder
+	t := reflect.TypeOf(Result{})
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		b.WriteString(f.Name + " " + f.Type.String() + " " + f.Tag.Get("json") + "\n")
+	}
+	sum := sha1.Sum([]byte(b.String()))
+	return hex.EncodeToString(sum[:4])
+}()
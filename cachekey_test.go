@@ -0,0 +1,46 @@
+package unfurlist
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+)
+
+func TestCacheNamespace(t *testing.T) {
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/favicon.ico" {
+			http.NotFound(w, r)
+			return
+		}
+		hits.Add(1)
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><head><title>Page</title></head></html>`))
+	}))
+	defer srv.Close()
+	mc := newTestMemcache(t)
+	req := "/?content=" + url.QueryEscape(srv.URL)
+	for _, ns := range []string{"staging", "production", "production"} {
+		h := New(WithMemcache(mc), WithCacheNamespace(ns))
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, req, nil))
+	}
+	if n := hits.Load(); n != 2 {
+		t.Fatalf("got %d upstream requests, want 2 (one per namespace)", n)
+	}
+}
+
+func TestValidCacheNamespace(t *testing.T) {
+	for s, want := range map[string]bool{
+		"":              false,
+		"todoist-prod":  true,
+		"v2.staging_eu": true,
+		"with space":    false,
+		"ключ":          false,
+	} {
+		if got := validCacheNamespace(s); got != want {
+			t.Errorf("validCacheNamespace(%q) = %v, want %v", s, got, want)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package unfurlist
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// canvaOembedEndpoint is Canva oembed endpoint, url of design is appended to
+// it
+const canvaOembedEndpoint = "https://www.canva.com/_oembed?url="
+
+// reCanvaDesign matches paths of Canva design links: /design/ID/view or
+// /design/ID/TOKEN/view (also edit, watch)
+var reCanvaDesign = regexp.MustCompile(`^/design/(DA[A-Za-z0-9_-]{9})/(?:[A-Za-z0-9_-]+/)?(?:view|edit|watch)/?$`)
+
+// CanvaFetcher returns FetchFunc that recognizes canva.com design share links
+// and gets design title and thumbnail from Canva oembed endpoint.
+func CanvaFetcher() FetchFunc {
+	return func(ctx context.Context, client *http.Client, u *url.URL) (*Metadata, bool) {
+		if u == nil {
+			return nil, false
+		}
+		if host := strings.ToLower(u.Hostname()); host != "canva.com" && host != "www.canva.com" {
+			return nil, false
+		}
+		if !reCanvaDesign.MatchString(u.Path) {
+			return nil, false
+		}
+		get := fetcherGet(client)
+		ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		defer cancel()
+		designURL := "https://www.canva.com" + u.Path
+		res, err := fetchOembed(ctx, canvaOembedEndpoint+url.QueryEscape(designURL), get)
+		if err != nil || res.Title == "" {
+			return nil, false
+		}
+		meta := &Metadata{
+			Title:    res.Title,
+			Type:     "website",
+			Image:    res.Image,
+			SiteName: "Canva",
+			HTML:     res.HTML,
+			Author:   res.Author,
+		}
+		meta.ImageWidth, _ = strconv.Atoi(res.Oembed["thumbnail_width"])
+		meta.ImageHeight, _ = strconv.Atoi(res.Oembed["thumbnail_height"])
+		return meta, true
+	}
+}
@@ -0,0 +1,52 @@
+package unfurlist
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestCanvaFetcher(t *testing.T) {
+	const design = "https://www.canva.com/design/DAFabc123_Z/Xy9k2-LmN/view"
+	client := &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		w := httptest.NewRecorder()
+		if r.URL.Path == "/_oembed" && r.URL.Query().Get("url") == design {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"type":"rich","version":"1.0","title":"Team offsite","author_name":"Jane Doe",
+"thumbnail_url":"https://design.canva.ai/thumb.png","thumbnail_width":800,"thumbnail_height":450,"html":"<iframe></iframe>"}`))
+		} else {
+			http.NotFound(w, r)
+		}
+		return w.Result(), nil
+	})}
+	fetch := CanvaFetcher()
+	want := Metadata{
+		Title:       "Team offsite",
+		Type:        "website",
+		Image:       "https://design.canva.ai/thumb.png",
+		ImageWidth:  800,
+		ImageHeight: 450,
+		SiteName:    "Canva",
+		HTML:        "<iframe></iframe>",
+		Author:      "Jane Doe",
+	}
+	for _, s := range []string{design, "https://canva.com/design/DAFabc123_Z/Xy9k2-LmN/view?utm_content=DAFabc123_Z&utm_source=sharebutton"} {
+		u, _ := url.Parse(s)
+		meta, ok := fetch(context.Background(), client, u)
+		if !ok || *meta != want {
+			t.Errorf("%s: got %+v, %v", s, meta, ok)
+		}
+	}
+	for _, s := range []string{
+		"https://www.canva.com/design/DAFother0000/view",
+		"https://www.canva.com/templates/",
+		"https://www.canva.com/",
+	} {
+		u, _ := url.Parse(s)
+		if meta, ok := fetch(context.Background(), client, u); ok {
+			t.Errorf("%s: unexpected result %+v", s, meta)
+		}
+	}
+}
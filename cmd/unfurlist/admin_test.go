@@ -0,0 +1,47 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Doist/unfurlist"
+)
+
+type staticStatus unfurlist.Status
+
+func (s staticStatus) Status() unfurlist.Status { return unfurlist.Status(s) }
+
+func TestAdminStatusHandler(t *testing.T) {
+	h := adminStatusHandler("secret", staticStatus{InFlightURLs: 3, CacheHits: 5})
+	for _, auth := range []string{"", "Bearer wrong", "secret", "bearer secret", "Basic secret"} {
+		req := httptest.NewRequest(http.MethodGet, "/admin/status", nil)
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+		w := httptest.NewRecorder()
+		h(w, req)
+		if w.Code != http.StatusUnauthorized {
+			t.Errorf("Authorization %q: got status %d, want %d", auth, w.Code, http.StatusUnauthorized)
+		}
+		if w.Header().Get("WWW-Authenticate") != "Bearer" {
+			t.Errorf("Authorization %q: no WWW-Authenticate header", auth)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/status", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	w := httptest.NewRecorder()
+	h(w, req)
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("got status %d, content type %q", w.Code, w.Header().Get("Content-Type"))
+	}
+	var st unfurlist.Status
+	if err := json.Unmarshal(w.Body.Bytes(), &st); err != nil {
+		t.Fatal(err)
+	}
+	if st.InFlightURLs != 3 || st.CacheHits != 5 {
+		t.Fatalf("unexpected status: %s", w.Body)
+	}
+}
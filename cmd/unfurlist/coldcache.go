@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Doist/unfurlist"
+	"github.com/Doist/unfurlist/internal/awsv4"
+)
+
+// newColdCache returns object storage cache for location in
+// s3://bucket/prefix form
+func newColdCache(location, endpoint, region string) (*unfurlist.S3Cache, error) {
+	rest, ok := strings.CutPrefix(location, "s3://")
+	if !ok {
+		return nil, fmt.Errorf("unsupported cold cache location %q, want s3://bucket/prefix", location)
+	}
+	bucket, prefix, _ := strings.Cut(rest, "/")
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	if endpoint == "" {
+		endpoint = "https://s3." + region + ".amazonaws.com"
+	}
+	creds, err := awsv4.EnvCredentials()
+	if err != nil {
+		return nil, err
+	}
+	return unfurlist.NewS3Cache(unfurlist.S3Options{
+		Endpoint:        endpoint,
+		Bucket:          bucket,
+		Region:          region,
+		Prefix:          prefix,
+		AccessKeyID:     creds.AccessKeyID,
+		SecretAccessKey: creds.SecretAccessKey,
+		SessionToken:    creds.SessionToken,
+	})
+}
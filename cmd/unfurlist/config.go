@@ -0,0 +1,253 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/Doist/unfurlist"
+	"github.com/Doist/unfurlist/internal/useragent"
+	"gopkg.in/yaml.v3"
+)
+
+// fileConfig holds settings from configuration file that cannot be expressed
+// with command line flags. Settings that have corresponding flags are applied
+// to flags directly.
+type fileConfig struct {
+	// Headers are extra headers added to each outgoing request, they
+	// extend and override default ones
+	Headers map[string]string
+	// UserAgents are User-Agent headers for requests to domains (and
+	// their subdomains), they extend and override useragent.DefaultDomains.
+	// Values "facebook" and "twitterbot" stand for agents of these link
+	// preview bots, for sites that only serve Open Graph tags to them.
+	UserAgents map[string]string
+	// Cookies are Cookie header values for requests to domains (and their
+	// subdomains), see unfurlist.WithCookies
+	Cookies map[string]string
+	// Authorization configures Authorization header for requests to
+	// domains (and their subdomains)
+	Authorization map[string]authConfig
+}
+
+// authConfig describes how to authorize requests to a domain: either with a
+// static Authorization header value, or with OAuth2 access token obtained with
+// client credentials grant
+type authConfig struct {
+	Token        string   `yaml:"token"`
+	TokenURL     string   `yaml:"tokenURL"`
+	ClientID     string   `yaml:"clientID"`
+	ClientSecret string   `yaml:"clientSecret"`
+	Scopes       []string `yaml:"scopes"`
+}
+
+// loadConfig reads YAML configuration file. Top-level keys named after
+// command line flags set values of these flags, unless flags were explicitly
+// set on command line or from environment; lists are joined with commas.
+// Other supported sections are described by fileConfig.
+//
+// References to environment variables in ${NAME} form are replaced with
+// their values before file is parsed.
+//
+// Example:
+//
+//	listen: localhost:8080
+//	cache: ${MEMCACHED_ADDR}
+//	videoDomains: [videos.example.com, media.example.com]
+//	headers:
+//	  Accept-Language: de;q=1, *;q=0.5
+//	userAgents:
+//	  example.com: facebook
+//	  news.example.org: Mozilla/5.0 (compatible; ExampleBot/1.0)
+//	cookies:
+//	  youtube.com: SOCS=CAI
+//	  wiki.internal.example: session=${WIKI_SESSION}
+//	authorization:
+//	  docs.internal.example:
+//	    token: Bearer ${DOCS_TOKEN}
+//	  api.internal.example:
+//	    tokenURL: https://auth.internal.example/oauth/token
+//	    clientID: unfurlist
+//	    clientSecret: ${API_CLIENT_SECRET}
+//	    scopes: [read]
+func loadConfig(name string, fs *flag.FlagSet) (*fileConfig, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	data = expandEnv(data)
+	var raw map[string]yaml.Node
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	explicit := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { explicit[f.Name] = true })
+
+	cfg := new(fileConfig)
+	keys := make([]string, 0, len(raw))
+	for k := range raw {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		node := raw[k]
+		switch k {
+		case "headers":
+			if err := node.Decode(&cfg.Headers); err != nil {
+				return nil, fmt.Errorf("%s: %s: %w", name, k, err)
+			}
+			continue
+		case "userAgents":
+			if err := node.Decode(&cfg.UserAgents); err != nil {
+				return nil, fmt.Errorf("%s: %s: %w", name, k, err)
+			}
+			continue
+		case "cookies":
+			if err := node.Decode(&cfg.Cookies); err != nil {
+				return nil, fmt.Errorf("%s: %s: %w", name, k, err)
+			}
+			continue
+		case "authorization":
+			if err := node.Decode(&cfg.Authorization); err != nil {
+				return nil, fmt.Errorf("%s: %s: %w", name, k, err)
+			}
+			for domain, a := range cfg.Authorization {
+				if (a.Token == "") == (a.TokenURL == "") {
+					return nil, fmt.Errorf("%s: %s: %s: exactly one of token and tokenURL must be set", name, k, domain)
+				}
+			}
+			continue
+		}
+		if fs.Lookup(k) == nil {
+			return nil, fmt.Errorf("%s: unknown setting %q", name, k)
+		}
+		if explicit[k] {
+			continue
+		}
+		val, err := nodeValue(&node)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s: %w", name, k, err)
+		}
+		if err := fs.Set(k, val); err != nil {
+			return nil, fmt.Errorf("%s: %s: %w", name, k, err)
+		}
+	}
+	return cfg, nil
+}
+
+// nodeValue returns string representation of scalar or list of scalars
+// suitable for flag.Value.Set
+func nodeValue(node *yaml.Node) (string, error) {
+	switch node.Kind {
+	case yaml.ScalarNode:
+		return node.Value, nil
+	case yaml.SequenceNode:
+		vals := make([]string, 0, len(node.Content))
+		for _, n := range node.Content {
+			if n.Kind != yaml.ScalarNode {
+				return "", fmt.Errorf("line %d: only lists of scalar values are supported", n.Line)
+			}
+			vals = append(vals, n.Value)
+		}
+		return strings.Join(vals, ","), nil
+	}
+	return "", fmt.Errorf("line %d: unsupported value", node.Line)
+}
+
+// userAgents returns per-domain agents: useragent.DefaultDomains overridden
+// by UserAgents, with preset names replaced by agents
+func (c *fileConfig) userAgents() map[string]string {
+	out := make(map[string]string, len(useragent.DefaultDomains)+len(c.UserAgents))
+	for k, v := range useragent.DefaultDomains {
+		out[k] = v
+	}
+	for k, v := range c.UserAgents {
+		switch strings.ToLower(v) {
+		case "facebook":
+			v = useragent.Facebook
+		case "twitterbot":
+			v = useragent.Twitterbot
+		}
+		out[strings.ToLower(k)] = v
+	}
+	return out
+}
+
+// authorization returns options configuring Authorization header for
+// domains, token requests are made with client
+func (c *fileConfig) authorization(client *http.Client) []unfurlist.ConfFunc {
+	var out []unfurlist.ConfFunc
+	for domain, a := range c.Authorization {
+		ts := unfurlist.StaticToken(a.Token)
+		if a.TokenURL != "" {
+			ts = unfurlist.ClientCredentials(client, a.TokenURL, a.ClientID, a.ClientSecret, a.Scopes...)
+		}
+		out = append(out, unfurlist.WithAuthorization(domain, ts))
+	}
+	return out
+}
+
+var reEnvRef = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// expandEnv replaces ${NAME} references with values of environment variables
+func expandEnv(data []byte) []byte {
+	return reEnvRef.ReplaceAllFunc(data, func(b []byte) []byte {
+		return []byte(os.Getenv(string(b[2 : len(b)-1])))
+	})
+}
+
+// envPrefix is a prefix of environment variables used to set flags
+const envPrefix = "UNFURLIST_"
+
+// setFlagsFromEnv sets flags that were not explicitly set on command line
+// from environment variables named after them: envPrefix followed by flag
+// name in upper snake case, i.e. UNFURLIST_MAX_REQUEST_TIME for
+// -maxRequestTime flag.
+func setFlagsFromEnv(fs *flag.FlagSet) error {
+	explicit := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { explicit[f.Name] = true })
+	var err error
+	fs.VisitAll(func(f *flag.Flag) {
+		if err != nil || explicit[f.Name] {
+			return
+		}
+		name := envName(f.Name)
+		val, ok := os.LookupEnv(name)
+		if !ok {
+			return
+		}
+		if err2 := fs.Set(f.Name, val); err2 != nil {
+			err = fmt.Errorf("%s: %w", name, err2)
+		}
+	})
+	return err
+}
+
+// envName returns environment variable name for a given flag name
+func envName(flagName string) string {
+	var b strings.Builder
+	b.WriteString(envPrefix)
+	prevLower := false
+	for _, r := range flagName {
+		switch {
+		case r == '.' || r == '-':
+			b.WriteByte('_')
+			prevLower = false
+			continue
+		case r >= 'A' && r <= 'Z':
+			if prevLower {
+				b.WriteByte('_')
+			}
+			prevLower = false
+		default:
+			prevLower = true
+		}
+		b.WriteRune(unicode.ToUpper(r))
+	}
+	return b.String()
+}
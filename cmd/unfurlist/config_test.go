@@ -0,0 +1,122 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestEnvName(t *testing.T) {
+	for flagName, want := range map[string]string{
+		"listen":           "UNFURLIST_LISTEN",
+		"maxRequestTime":   "UNFURLIST_MAX_REQUEST_TIME",
+		"forwardRequestID": "UNFURLIST_FORWARD_REQUEST_ID",
+		"image.proxy.url":  "UNFURLIST_IMAGE_PROXY_URL",
+		"pprof-addr":       "UNFURLIST_PPROF_ADDR",
+	} {
+		if got := envName(flagName); got != want {
+			t.Errorf("envName(%q): got %q, want %q", flagName, got, want)
+		}
+	}
+}
+
+func TestExpandEnv(t *testing.T) {
+	t.Setenv("UNFURLIST_TEST_SET", "value")
+	for in, want := range map[string]string{
+		"cache: ${UNFURLIST_TEST_SET}":        "cache: value",
+		"a: ${UNFURLIST_TEST_SET}-${UNSET_X}": "a: value-",
+		"a: $UNFURLIST_TEST_SET":              "a: $UNFURLIST_TEST_SET",
+		"a: ${1INVALID}":                      "a: ${1INVALID}",
+		"a: ${}":                              "a: ${}",
+	} {
+		if got := string(expandEnv([]byte(in))); got != want {
+			t.Errorf("expandEnv(%q): got %q, want %q", in, got, want)
+		}
+	}
+}
+
+// testFlags returns flag set with a few flags of different types
+func testFlags() (*flag.FlagSet, *string, *int, *time.Duration) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	listen := fs.String("listen", "localhost:8080", "")
+	maxResults := fs.Int("max", 20, "")
+	timeout := fs.Duration("maxRequestTime", 0, "")
+	return fs, listen, maxResults, timeout
+}
+
+func TestSetFlagsFromEnv(t *testing.T) {
+	fs, listen, maxResults, timeout := testFlags()
+	if err := fs.Parse([]string{"-listen", ":9000"}); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("UNFURLIST_LISTEN", ":9999")
+	t.Setenv("UNFURLIST_MAX_REQUEST_TIME", "3s")
+	if err := setFlagsFromEnv(fs); err != nil {
+		t.Fatal(err)
+	}
+	if *listen != ":9000" || *maxResults != 20 || *timeout != 3*time.Second {
+		t.Fatalf("unexpected flag values: listen=%q max=%d maxRequestTime=%v", *listen, *maxResults, *timeout)
+	}
+
+	fs, _, _, _ = testFlags()
+	t.Setenv("UNFURLIST_MAX", "many")
+	if err := setFlagsFromEnv(fs); err == nil {
+		t.Fatal("invalid value from environment was accepted")
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	t.Setenv("UNFURLIST_TEST_ADDR", ":7000")
+	name := filepath.Join(t.TempDir(), "config.yaml")
+	write := func(s string) {
+		t.Helper()
+		if err := os.WriteFile(name, []byte(s), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(`listen: ${UNFURLIST_TEST_ADDR}
+max: 5
+maxRequestTime: 2s
+headers:
+  Accept-Language: de
+userAgents:
+  Example.com: facebook
+`)
+	fs, listen, maxResults, timeout := testFlags()
+	// flags set on command line or from environment take precedence
+	// over configuration file
+	if err := fs.Parse([]string{"-max", "7"}); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("UNFURLIST_MAX_REQUEST_TIME", "4s")
+	if err := setFlagsFromEnv(fs); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := loadConfig(name, fs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *listen != ":7000" || *maxResults != 7 || *timeout != 4*time.Second {
+		t.Fatalf("unexpected flag values: listen=%q max=%d maxRequestTime=%v", *listen, *maxResults, *timeout)
+	}
+	if cfg.Headers["Accept-Language"] != "de" {
+		t.Fatalf("unexpected headers: %v", cfg.Headers)
+	}
+	if ua := cfg.userAgents()["example.com"]; ua == "" || ua == "facebook" {
+		t.Fatalf("preset agent was not resolved: %q", ua)
+	}
+
+	for _, bad := range []string{
+		"unknown: 1\n",
+		"max: [1, [2]]\n",
+		"authorization:\n  example.com:\n    token: x\n    tokenURL: https://example.com/\n",
+	} {
+		write(bad)
+		fs, _, _, _ := testFlags()
+		if _, err := loadConfig(name, fs); err == nil {
+			t.Errorf("config %q: no error", bad)
+		}
+	}
+}
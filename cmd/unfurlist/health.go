@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+// healthChecker serves /healthz and /readyz endpoints. Liveness check only
+// reports that process is up, while readiness check verifies that service
+// dependencies are reachable.
+type healthChecker struct {
+	cache    *memcache.Client // may be nil
+	client   *http.Client
+	probeURL string // url to check outbound connectivity with, may be empty
+}
+
+type checkResult struct {
+	Status   string  `json:"status"`
+	Error    string  `json:"error,omitempty"`
+	Duration float64 `json:"duration_ms"`
+}
+
+type healthReport struct {
+	Status string                 `json:"status"`
+	Checks map[string]checkResult `json:"checks,omitempty"`
+}
+
+const (
+	statusOK   = "ok"
+	statusFail = "fail"
+)
+
+// registerHealthChecks registers /ping, /healthz and /readyz handlers on mux
+// if enabled is true
+func registerHealthChecks(mux *http.ServeMux, enabled bool, hc *healthChecker) {
+	if !enabled {
+		return
+	}
+	mux.HandleFunc("/ping", func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusOK) })
+	mux.HandleFunc("/healthz", hc.liveness)
+	mux.HandleFunc("/readyz", hc.readiness)
+}
+
+func (hc *healthChecker) liveness(w http.ResponseWriter, _ *http.Request) {
+	writeReport(w, healthReport{Status: statusOK})
+}
+
+func (hc *healthChecker) readiness(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	checks := make(map[string]func(context.Context) error)
+	if hc.cache != nil {
+		checks["memcache"] = func(context.Context) error { return hc.cache.Ping() }
+	}
+	if hc.probeURL != "" {
+		checks["dns"] = hc.checkDNS
+		checks["outbound"] = hc.checkOutbound
+	}
+	report := healthReport{Status: statusOK, Checks: make(map[string]checkResult, len(checks))}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for name, fn := range checks {
+		wg.Add(1)
+		go func(name string, fn func(context.Context) error) {
+			defer wg.Done()
+			begin := time.Now()
+			err := fn(ctx)
+			res := checkResult{
+				Status:   statusOK,
+				Duration: float64(time.Since(begin).Microseconds()) / 1000,
+			}
+			if err != nil {
+				res.Status, res.Error = statusFail, err.Error()
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			report.Checks[name] = res
+			if err != nil {
+				report.Status = statusFail
+			}
+		}(name, fn)
+	}
+	wg.Wait()
+	writeReport(w, report)
+}
+
+func (hc *healthChecker) checkDNS(ctx context.Context) error {
+	u, err := url.Parse(hc.probeURL)
+	if err != nil {
+		return err
+	}
+	addrs, err := net.DefaultResolver.LookupHost(ctx, u.Hostname())
+	if err != nil {
+		return err
+	}
+	if len(addrs) == 0 {
+		return errors.New("no addresses found")
+	}
+	return nil
+}
+
+func (hc *healthChecker) checkOutbound(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, hc.probeURL, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := hc.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return errors.New(resp.Status)
+	}
+	return nil
+}
+
+func writeReport(w http.ResponseWriter, report healthReport) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if report.Status != statusOK {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(report)
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestReadiness(t *testing.T) {
+	probe := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/down" {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer probe.Close()
+	for _, tc := range []struct {
+		name     string
+		probeURL string
+		code     int
+		failed   []string
+	}{
+		{"no checks", "", http.StatusOK, nil},
+		{"probe ok", probe.URL + "/up", http.StatusOK, nil},
+		{"probe 5xx", probe.URL + "/down", http.StatusServiceUnavailable, []string{"outbound"}},
+		{"dns failure", "http://unfurlist.invalid/", http.StatusServiceUnavailable, []string{"dns", "outbound"}},
+	} {
+		hc := &healthChecker{client: probe.Client(), probeURL: tc.probeURL}
+		w := httptest.NewRecorder()
+		hc.readiness(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+		if w.Code != tc.code {
+			t.Errorf("%s: got status %d, want %d", tc.name, w.Code, tc.code)
+		}
+		var report healthReport
+		if err := json.Unmarshal(w.Body.Bytes(), &report); err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		want := statusOK
+		if len(tc.failed) != 0 {
+			want = statusFail
+		}
+		if report.Status != want {
+			t.Errorf("%s: got status %q, want %q", tc.name, report.Status, want)
+		}
+		for _, name := range tc.failed {
+			if c := report.Checks[name]; c.Status != statusFail || c.Error == "" {
+				t.Errorf("%s: check %q: got %+v, want failure", tc.name, name, c)
+			}
+		}
+	}
+}
+
+func TestRegisterHealthChecks(t *testing.T) {
+	for _, enabled := range []bool{false, true} {
+		mux := http.NewServeMux()
+		registerHealthChecks(mux, enabled, &healthChecker{})
+		want := http.StatusNotFound
+		if enabled {
+			want = http.StatusOK
+		}
+		for _, path := range []string{"/ping", "/healthz", "/readyz"} {
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+			if w.Code != want {
+				t.Errorf("ping %v, %s: got status %d, want %d", enabled, path, w.Code, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,17 @@
+//go:build quic
+
+package main
+
+import (
+	"net/http"
+
+	"github.com/quic-go/quic-go/http3"
+)
+
+// newHTTP3Transport returns transport making requests over HTTP/3. It is
+// only available if command is built with quic build tag, which requires
+// github.com/quic-go/quic-go module:
+//
+//	go get github.com/quic-go/quic-go
+//	go build -tags quic ./cmd/unfurlist
+func newHTTP3Transport() http.RoundTripper { return &http3.Transport{} }
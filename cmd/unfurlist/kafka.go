@@ -0,0 +1,210 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Content types of Confluent REST Proxy API v2
+const (
+	kafkaJSON   = "application/vnd.kafka.v2+json"
+	kafkaBinary = "application/vnd.kafka.binary.v2+json"
+)
+
+// kafkaTopic is a minimal client of Kafka topic talking to Confluent REST
+// Proxy API v2, record values are transferred as is (binary format).
+//
+// Records are consumed as part of consumer group with automatic offset
+// commits disabled, acknowledging record commits its offset. As offsets are
+// committed per partition, records left unacknowledged are only redelivered
+// if no later record of the same partition was acknowledged before consumer
+// restart.
+type kafkaTopic struct {
+	base   string // REST Proxy url
+	topic  string
+	group  string // consumer group
+	client *http.Client
+
+	instance string // consumer instance url, set by first receive
+}
+
+// newKafkaTopic returns client for topic with given url, like
+// http://localhost:8082/topics/name, where http://localhost:8082 is REST
+// Proxy url. Consumer group is taken from group query parameter, "unfurlist"
+// by default.
+func newKafkaTopic(topicURL string) (*kafkaTopic, error) {
+	u, err := url.Parse(topicURL)
+	if err != nil {
+		return nil, err
+	}
+	base, topic, ok := strings.Cut(u.Path, "/topics/")
+	if u.Scheme != "https" && u.Scheme != "http" || u.Host == "" || !ok || topic == "" || strings.Contains(topic, "/") {
+		return nil, fmt.Errorf("invalid Kafka topic url %q", topicURL)
+	}
+	group := u.Query().Get("group")
+	if group == "" {
+		group = "unfurlist"
+	}
+	return &kafkaTopic{
+		base:   u.Scheme + "://" + u.Host + base,
+		topic:  topic,
+		group:  group,
+		client: &http.Client{Timeout: time.Minute},
+	}, nil
+}
+
+// kafkaAPIError is returned on REST Proxy responses with error status
+type kafkaAPIError struct {
+	code    int // http status code
+	message string
+}
+
+func (e *kafkaAPIError) Error() string {
+	return fmt.Sprintf("kafka: %s: %s", http.StatusText(e.code), e.message)
+}
+
+// call makes REST Proxy API request with JSON-encoded in as body of
+// contentType, decoding response to out. Requests without body expect
+// response of contentType.
+func (k *kafkaTopic) call(ctx context.Context, method, url, contentType string, in, out any) error {
+	var body io.Reader
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(b)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return err
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", contentType)
+		req.Header.Set("Accept", kafkaJSON)
+	} else {
+		req.Header.Set("Accept", contentType)
+	}
+	resp, err := k.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 16<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		var apiErr struct {
+			Message string `json:"message"`
+		}
+		json.Unmarshal(data, &apiErr)
+		return &kafkaAPIError{code: resp.StatusCode, message: apiErr.Message}
+	}
+	if out == nil || len(data) == 0 {
+		return nil
+	}
+	return json.Unmarshal(data, out)
+}
+
+// subscribe creates consumer instance subscribed to topic
+func (k *kafkaTopic) subscribe(ctx context.Context) error {
+	var out struct {
+		BaseURI string `json:"base_uri"`
+	}
+	err := k.call(ctx, http.MethodPost, k.base+"/consumers/"+url.PathEscape(k.group), kafkaJSON, map[string]string{
+		"format":             "binary",
+		"auto.offset.reset":  "earliest",
+		"auto.commit.enable": "false",
+	}, &out)
+	if err != nil {
+		return err
+	}
+	if out.BaseURI == "" {
+		return errors.New("kafka: no consumer instance url in response")
+	}
+	if err := k.call(ctx, http.MethodPost, out.BaseURI+"/subscription", kafkaJSON,
+		struct {
+			Topics []string `json:"topics"`
+		}{[]string{k.topic}}, nil); err != nil {
+		return err
+	}
+	k.instance = out.BaseURI
+	return nil
+}
+
+func (k *kafkaTopic) receive(ctx context.Context) ([]delivery, error) {
+	if k.instance == "" {
+		if err := k.subscribe(ctx); err != nil {
+			return nil, err
+		}
+	}
+	var records []struct {
+		Topic     string `json:"topic"`
+		Partition int32  `json:"partition"`
+		Offset    int64  `json:"offset"`
+		Value     []byte `json:"value"`
+	}
+	err := k.call(ctx, http.MethodGet, k.instance+"/records?timeout=20000", kafkaBinary, nil, &records)
+	var apiErr *kafkaAPIError
+	if errors.As(err, &apiErr) && apiErr.code == http.StatusNotFound {
+		// consumer instance expired, a new one is created on next call
+		k.instance = ""
+	}
+	if err != nil {
+		return nil, err
+	}
+	instance := k.instance
+	batch := make([]delivery, 0, len(records))
+	for _, r := range records {
+		offset := map[string]any{"topic": r.Topic, "partition": r.Partition, "offset": r.Offset}
+		batch = append(batch, delivery{
+			body: r.Value,
+			ack: func(ctx context.Context) error {
+				return k.call(ctx, http.MethodPost, instance+"/offsets", kafkaJSON,
+					map[string]any{"offsets": []any{offset}}, nil)
+			},
+		})
+	}
+	return batch, nil
+}
+
+// kafkaMaxMessageSize is the default max.request.size of Kafka producers,
+// with some room for record key and headers
+const kafkaMaxMessageSize = 1<<20 - 1<<10
+
+func (k *kafkaTopic) maxSize() int { return kafkaMaxMessageSize }
+
+// write produces record with body as value, keyed by message id
+func (k *kafkaTopic) write(ctx context.Context, id string, body []byte) error {
+	type record struct {
+		Key   []byte `json:"key"`
+		Value []byte `json:"value"`
+	}
+	var out struct {
+		Offsets []struct {
+			Error string `json:"error"`
+		} `json:"offsets"`
+	}
+	err := k.call(ctx, http.MethodPost, k.base+"/topics/"+url.PathEscape(k.topic), kafkaBinary,
+		struct {
+			Records []record `json:"records"`
+		}{[]record{{[]byte(id), body}}}, &out)
+	if err != nil {
+		return err
+	}
+	for _, o := range out.Offsets {
+		if o.Error != "" {
+			return errors.New("kafka: " + o.Error)
+		}
+	}
+	return nil
+}
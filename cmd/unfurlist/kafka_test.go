@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestKafkaTopic(t *testing.T) {
+	var srv *httptest.Server
+	var committed, produced string
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		switch r.Method + " " + r.URL.Path {
+		case "POST /proxy/consumers/workers":
+			if r.Header.Get("Content-Type") != kafkaJSON {
+				t.Errorf("create consumer: unexpected content type %q", r.Header.Get("Content-Type"))
+			}
+			json.NewEncoder(w).Encode(map[string]string{"instance_id": "c1", "base_uri": srv.URL + "/proxy/consumers/workers/instances/c1"})
+		case "POST /proxy/consumers/workers/instances/c1/subscription":
+			if string(body) != `{"topics":["urls"]}` {
+				t.Errorf("unexpected subscription: %s", body)
+			}
+			w.WriteHeader(http.StatusNoContent)
+		case "GET /proxy/consumers/workers/instances/c1/records":
+			if r.Header.Get("Accept") != kafkaBinary {
+				t.Errorf("records: unexpected accept header %q", r.Header.Get("Accept"))
+			}
+			w.Write([]byte(`[{"topic":"urls","partition":2,"offset":7,"value":"eyJpZCI6IjEifQ=="}]`))
+		case "POST /proxy/consumers/workers/instances/c1/offsets":
+			committed = string(body)
+			w.WriteHeader(http.StatusNoContent)
+		case "POST /proxy/topics/results":
+			produced = string(body)
+			w.Write([]byte(`{"offsets":[{"partition":0,"offset":1}]}`))
+		default:
+			t.Errorf("unexpected call: %s %s", r.Method, r.URL)
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error_code":40403,"message":"Consumer instance not found."}`))
+		}
+	}))
+	defer srv.Close()
+	ctx := context.Background()
+
+	src, err := newKafkaTopic(srv.URL + "/proxy/topics/urls?group=workers")
+	if err != nil {
+		t.Fatal(err)
+	}
+	batch, err := src.receive(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(batch) != 1 || string(batch[0].body) != `{"id":"1"}` {
+		t.Fatalf("unexpected batch: %+v", batch)
+	}
+	if err := batch[0].ack(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"offsets":[{"offset":7,"partition":2,"topic":"urls"}]}`; committed != want {
+		t.Fatalf("committed %s, want %s", committed, want)
+	}
+
+	dst, err := newKafkaTopic(srv.URL + "/proxy/topics/results")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := dst.write(ctx, "1", []byte(`{}`)); err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"records":[{"key":"MQ==","value":"e30="}]}`; produced != want {
+		t.Fatalf("produced %s, want %s", produced, want)
+	}
+
+	for _, s := range []string{srv.URL + "/topics/", srv.URL + "/urls", "ftp://host/topics/urls"} {
+		if _, err := newKafkaTopic(s); err == nil {
+			t.Errorf("%q: want error", s)
+		}
+	}
+}
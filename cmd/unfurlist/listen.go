@@ -0,0 +1,39 @@
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"net"
+	"os"
+	"strings"
+)
+
+// listen returns listener for addr, which is either a TCP address or a path
+// to Unix domain socket prefixed with "unix:". Stale socket file is removed
+// before listening, socket file permissions are set to mode if it's
+// non-zero.
+func listen(addr string, mode fs.FileMode) (net.Listener, error) {
+	name, ok := strings.CutPrefix(addr, "unix:")
+	if !ok {
+		return net.Listen("tcp", addr)
+	}
+	if fi, err := os.Lstat(name); err == nil {
+		if fi.Mode().Type() != fs.ModeSocket {
+			return nil, &os.PathError{Op: "listen", Path: name, Err: errors.New("file exists and is not a socket")}
+		}
+		if err := os.Remove(name); err != nil {
+			return nil, err
+		}
+	}
+	ln, err := net.Listen("unix", name)
+	if err != nil {
+		return nil, err
+	}
+	if mode != 0 {
+		if err := os.Chmod(name, mode); err != nil {
+			ln.Close()
+			return nil, err
+		}
+	}
+	return ln, nil
+}
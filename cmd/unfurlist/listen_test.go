@@ -0,0 +1,54 @@
+package main
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestListenUnix(t *testing.T) {
+	// socket paths are limited in length, t.TempDir may be too long
+	dir, err := os.MkdirTemp("", "unfurlist")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	name := filepath.Join(dir, "sock")
+
+	// leave stale socket file behind, like crashed process would
+	stale, err := net.Listen("unix", name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+	if _, err := os.Lstat(name); err != nil {
+		t.Fatal("stale socket file was removed:", err)
+	}
+
+	ln, err := listen("unix:"+name, 0o660)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	fi, err := os.Stat(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := fi.Mode().Perm(); perm != 0o660 {
+		t.Fatalf("got socket permissions %v, want %v", perm, os.FileMode(0o660))
+	}
+
+	regular := filepath.Join(dir, "file")
+	if err := os.WriteFile(regular, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if ln, err := listen("unix:"+regular, 0); err == nil {
+		ln.Close()
+		t.Fatal("regular file was replaced with socket")
+	}
+	if _, err := os.Stat(regular); err != nil {
+		t.Fatal("regular file was removed:", err)
+	}
+}
@@ -0,0 +1,9 @@
+//go:build !quic
+
+package main
+
+import "net/http"
+
+// newHTTP3Transport returns nil, as command is built without HTTP/3 support,
+// see http3.go
+func newHTTP3Transport() http.RoundTripper { return nil }
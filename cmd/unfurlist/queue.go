@@ -0,0 +1,232 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Doist/unfurlist"
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+// queueMessage is a message consumed in queue mode. Urls are taken from
+// Content, URLs are appended to it.
+type queueMessage struct {
+	ID       string   `json:"id"`
+	Content  string   `json:"content,omitempty"`
+	URLs     []string `json:"urls,omitempty"`
+	Markdown bool     `json:"markdown,omitempty"`
+}
+
+// queueResult is written to sink for each consumed message
+type queueResult struct {
+	ID string `json:"id"`
+	*unfurlist.Envelope
+}
+
+// delivery is a single message received from source
+type delivery struct {
+	body []byte
+	ack  func(context.Context) error // confirms message was processed
+}
+
+type source interface {
+	// receive blocks until next batch of messages is available
+	receive(context.Context) ([]delivery, error)
+}
+
+type sink interface {
+	// write stores body of results for message with a given id
+	write(ctx context.Context, id string, body []byte) error
+}
+
+// sizeLimiter is implemented by sinks that cannot store results larger than
+// maxSize bytes
+type sizeLimiter interface {
+	maxSize() int
+}
+
+// consumer processes messages from source, writing results to sink
+type consumer struct {
+	src source
+	dst sink
+	unf unfurlist.Unfurler
+}
+
+// run processes messages until ctx is canceled
+func (c *consumer) run(ctx context.Context) error {
+	for {
+		batch, err := c.src.receive(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			log.Print("queue receive: ", err)
+			time.Sleep(5 * time.Second)
+			continue
+		}
+		var wg sync.WaitGroup
+		for _, d := range batch {
+			wg.Add(1)
+			go func(d delivery) {
+				defer wg.Done()
+				if err := c.process(ctx, d); err != nil {
+					log.Print("queue message: ", err)
+				}
+			}(d)
+		}
+		wg.Wait()
+	}
+}
+
+// process handles single message. Malformed messages and ones which results
+// don't fit into sink even without any results are acknowledged and dropped,
+// messages which results cannot be written to sink are left unacknowledged to
+// be redelivered.
+func (c *consumer) process(ctx context.Context, d delivery) error {
+	var msg queueMessage
+	if err := json.Unmarshal(d.body, &msg); err != nil {
+		drop(ctx, d)
+		return fmt.Errorf("dropping malformed message: %w", err)
+	}
+	content := strings.Join(append([]string{msg.Content}, msg.URLs...), "\n")
+	limit := -1
+	if l, ok := c.dst.(sizeLimiter); ok {
+		limit = l.maxSize()
+	}
+	body, err := fitResults(queueResult{ID: msg.ID, Envelope: c.unf.Unfurl(ctx, content, msg.Markdown)}, limit)
+	if errors.Is(err, errResultTooLarge) {
+		drop(ctx, d)
+		return fmt.Errorf("dropping message %q: %w", msg.ID, err)
+	}
+	if err != nil {
+		return err
+	}
+	if err := c.dst.write(ctx, msg.ID, body); err != nil {
+		return fmt.Errorf("message %q: %w", msg.ID, err)
+	}
+	return d.ack(ctx)
+}
+
+// drop acknowledges message that cannot be processed, so that it's not
+// redelivered
+func drop(ctx context.Context, d delivery) {
+	if err := d.ack(ctx); err != nil {
+		log.Print("queue ack: ", err)
+	}
+}
+
+var errResultTooLarge = errors.New("result is too large for the sink")
+
+// fitResults returns JSON encoding of res no larger than limit bytes, unless
+// limit is negative. Results that don't fit are removed from the end and
+// reported as errors instead. It returns errResultTooLarge if res doesn't fit
+// even without results.
+func fitResults(res queueResult, limit int) ([]byte, error) {
+	for {
+		body, err := json.Marshal(res)
+		if err != nil || limit < 0 || len(body) <= limit {
+			return body, err
+		}
+		n := len(res.Results)
+		if n == 0 {
+			return nil, errResultTooLarge
+		}
+		env := *res.Envelope
+		env.Results = env.Results[: n-1 : n-1]
+		env.Errors = append(env.Errors[:len(env.Errors):len(env.Errors)],
+			unfurlist.ResultError{URL: res.Results[n-1].URL, Error: errResultTooLarge.Error()})
+		res.Envelope = &env
+	}
+}
+
+// newSource returns source for spec, which is "sqs:<queue url>" or
+// "kafka:<topic url>", see newKafkaTopic
+func newSource(spec string) (source, error) {
+	kind, addr, _ := strings.Cut(spec, ":")
+	switch kind {
+	case "sqs":
+		return newSQSQueue(addr)
+	case "kafka":
+		return newKafkaTopic(addr)
+	}
+	return nil, fmt.Errorf("unsupported queue %q", spec)
+}
+
+// newSink returns sink for spec, which is one of "sqs:<queue url>",
+// "kafka:<topic url>", "memcache" or webhook http(s) url
+func newSink(spec string, cache *memcache.Client) (sink, error) {
+	kind, addr, _ := strings.Cut(spec, ":")
+	switch kind {
+	case "sqs":
+		return newSQSQueue(addr)
+	case "kafka":
+		return newKafkaTopic(addr)
+	case "memcache":
+		if cache == nil {
+			return nil, errors.New("memcache sink requires -cache to be set")
+		}
+		return memcacheSink{cache}, nil
+	case "http", "https":
+		return webhookSink{url: spec, client: &http.Client{Timeout: time.Minute}}, nil
+	}
+	return nil, fmt.Errorf("unsupported sink %q", spec)
+}
+
+// webhookSink POSTs results to url
+type webhookSink struct {
+	url    string
+	client *http.Client
+}
+
+func (s webhookSink) write(ctx context.Context, _ string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook: %s", resp.Status)
+	}
+	return nil
+}
+
+// memcacheSink stores results in memcache under key derived from message id,
+// see memcacheResultKey
+type memcacheSink struct {
+	client *memcache.Client
+}
+
+// memcached default item size limit, with some room for key and flags
+func (memcacheSink) maxSize() int { return 1<<20 - 1<<10 }
+
+func (s memcacheSink) write(_ context.Context, id string, body []byte) error {
+	return s.client.Set(&memcache.Item{
+		Key:        memcacheResultKey(id),
+		Value:      body,
+		Expiration: int32((24 * time.Hour).Seconds()),
+	})
+}
+
+// memcacheResultKey returns memcache key results for message with given id
+// are stored under: "unfurlist:queue:" followed by hex-encoded SHA1 of id
+func memcacheResultKey(id string) string {
+	sum := sha1.Sum([]byte(id))
+	return "unfurlist:queue:" + hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/Doist/unfurlist"
+)
+
+func TestFitResults(t *testing.T) {
+	res := queueResult{ID: "msg", Envelope: &unfurlist.Envelope{
+		Results: []*unfurlist.Result{
+			{URL: "https://example.com/1", Title: "One"},
+			{URL: "https://example.com/2", Description: strings.Repeat("x", 1000)},
+		},
+		Errors: []unfurlist.ResultError{},
+	}}
+	full, err := fitResults(res, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body, err := fitResults(res, len(full)); err != nil || string(body) != string(full) {
+		t.Fatalf("result within limit was changed: %s, %v", body, err)
+	}
+
+	body, err := fitResults(res, 500)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(body) > 500 {
+		t.Fatalf("got %d bytes, want at most 500", len(body))
+	}
+	var got queueResult
+	if err := json.Unmarshal(body, &got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Results) != 1 || got.Results[0].Title != "One" ||
+		len(got.Errors) != 1 || got.Errors[0].URL != "https://example.com/2" {
+		t.Fatalf("unexpected result: %s", body)
+	}
+	if len(res.Results) != 2 || len(res.Errors) != 0 {
+		t.Fatalf("original envelope was modified: %+v", res.Envelope)
+	}
+
+	if _, err := fitResults(res, 10); !errors.Is(err, errResultTooLarge) {
+		t.Fatalf("got error %v, want %v", err, errResultTooLarge)
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/Doist/unfurlist"
+	"github.com/artyom/oembed"
+	"gopkg.in/yaml.v3"
+)
+
+// reloadableFiles are configuration files that are re-read on SIGHUP
+type reloadableFiles struct {
+	blocklist       string // url prefixes, see readBlocklist
+	titleBlocklist  string // title rules, one per line, see unfurlist.WithBlocklistTitles
+	oembedProviders string // oembed providers list in json format
+	scrapeRules     string // list of unfurlist.ScrapeRule in yaml format
+}
+
+// load reads configuration files and applies their settings to r. If any of
+// the files cannot be read, no settings are applied.
+func (f reloadableFiles) load(r unfurlist.Reloader) error {
+	var prefixes []string
+	if f.blocklist != "" {
+		var err error
+		if prefixes, err = readBlocklist(f.blocklist); err != nil {
+			return err
+		}
+	}
+	titles := titleBlocklist
+	if f.titleBlocklist != "" {
+		var err error
+		if titles, err = readLines(f.titleBlocklist); err != nil {
+			return err
+		}
+		if err := unfurlist.CheckBlocklistTitles(titles); err != nil {
+			return err
+		}
+	}
+	var lookupFunc oembed.LookupFunc
+	if f.oembedProviders != "" {
+		data, err := os.ReadFile(f.oembedProviders)
+		if err != nil {
+			return err
+		}
+		if lookupFunc, err = oembed.Providers(bytes.NewReader(data)); err != nil {
+			return err
+		}
+	}
+	var scrapeRules []unfurlist.ScrapeRule
+	if f.scrapeRules != "" {
+		data, err := os.ReadFile(f.scrapeRules)
+		if err != nil {
+			return err
+		}
+		if err := yaml.Unmarshal(data, &scrapeRules); err != nil {
+			return fmt.Errorf("%s: %w", f.scrapeRules, err)
+		}
+		if err := unfurlist.CheckScrapeRules(scrapeRules); err != nil {
+			return err
+		}
+	}
+	r.SetBlocklistPrefixes(prefixes)
+	r.SetBlocklistTitles(titles)
+	r.SetOembedLookupFunc(lookupFunc)
+	r.SetScrapeRules(scrapeRules)
+	return nil
+}
+
+// reloadOnSignal starts reloading configuration files in background each time
+// process receives SIGHUP. On failure previously loaded settings are kept.
+func (f reloadableFiles) reloadOnSignal(r unfurlist.Reloader) {
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGHUP)
+	go func() {
+		for range sigCh {
+			if err := f.load(r); err != nil {
+				log.Print("configuration reload: ", err)
+				continue
+			}
+			log.Print("configuration reloaded")
+		}
+	}()
+}
+
+// readLines returns non-empty lines of the file, lines starting with # are
+// skipped as comments.
+func readLines(name string) ([]string, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	s := bufio.NewScanner(io.LimitReader(f, 512*1024))
+	var lines []string
+	for s.Scan() {
+		if line := strings.TrimSpace(s.Text()); line != "" && !strings.HasPrefix(line, "#") {
+			lines = append(lines, line)
+		}
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	return lines, nil
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/Doist/unfurlist"
+)
+
+func TestReadLines(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "lines")
+	if err := os.WriteFile(name, []byte("one\n\n  # comment\n  two  \n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	got, err := readLines(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"one", "two"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+	if _, err := readLines(name + ".missing"); err == nil {
+		t.Fatal("missing file: want error")
+	}
+}
+
+func TestReload(t *testing.T) {
+	dir := t.TempDir()
+	files := reloadableFiles{
+		blocklist:      filepath.Join(dir, "blocklist"),
+		titleBlocklist: filepath.Join(dir, "titles"),
+	}
+	write := func(name, data string) {
+		t.Helper()
+		if err := os.WriteFile(name, []byte(data), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(files.blocklist, "https://example.com/a\n")
+	write(files.titleBlocklist, "captcha\n")
+	h := unfurlist.New()
+	st := h.(unfurlist.StatusReporter)
+	if err := files.load(h.(unfurlist.Reloader)); err != nil {
+		t.Fatal(err)
+	}
+	v1 := st.Status().Blocklist
+	if v1 == nil || v1.Entries != 1 {
+		t.Fatalf("unexpected blocklist version: %+v", v1)
+	}
+
+	write(files.blocklist, "https://example.com/a\nhttps://example.com/b\n")
+	if err := files.load(h.(unfurlist.Reloader)); err != nil {
+		t.Fatal(err)
+	}
+	v2 := st.Status().Blocklist
+	if v2.Digest == v1.Digest || v2.Entries != 2 {
+		t.Fatalf("blocklist was not reloaded: %+v, previous %+v", v2, v1)
+	}
+
+	// invalid title rule makes the whole reload fail
+	write(files.blocklist, "https://example.com/c\n")
+	write(files.titleBlocklist, "/(/\n")
+	if err := files.load(h.(unfurlist.Reloader)); err == nil {
+		t.Fatal("invalid title blocklist: want error")
+	}
+	if v := st.Status().Blocklist; v.Digest != v2.Digest {
+		t.Fatalf("blocklist changed on failed reload: %+v, want %+v", v, v2)
+	}
+
+	write(files.titleBlocklist, "captcha\n")
+	files.reloadOnSignal(h.(unfurlist.Reloader))
+	if err := syscall.Kill(os.Getpid(), syscall.SIGHUP); err != nil {
+		t.Fatal(err)
+	}
+	for deadline := time.Now().Add(5 * time.Second); st.Status().Blocklist.Digest == v2.Digest; {
+		if time.Now().After(deadline) {
+			t.Fatal("blocklist was not reloaded on SIGHUP")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Doist/unfurlist/internal/awsv4"
+)
+
+// sqsQueue is a minimal Amazon SQS client talking JSON protocol. Credentials
+// are taken from environment, see awsv4.EnvCredentials.
+type sqsQueue struct {
+	url      string // queue url
+	endpoint string
+	region   string
+	creds    awsv4.Credentials
+	client   *http.Client
+}
+
+// newSQSQueue returns client for queue with given url, like
+// https://sqs.us-east-1.amazonaws.com/123456789012/name. Region is taken from
+// queue url, or from AWS_REGION environment variable if url has no region.
+func newSQSQueue(queueURL string) (*sqsQueue, error) {
+	u, err := url.Parse(queueURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "https" && u.Scheme != "http" || u.Host == "" {
+		return nil, fmt.Errorf("invalid SQS queue url %q", queueURL)
+	}
+	creds, err := awsv4.EnvCredentials()
+	if err != nil {
+		return nil, err
+	}
+	region := os.Getenv("AWS_REGION")
+	if parts := strings.Split(u.Hostname(), "."); len(parts) > 2 && parts[0] == "sqs" {
+		region = parts[1]
+	}
+	if region == "" {
+		return nil, fmt.Errorf("cannot tell region of SQS queue %q, set AWS_REGION", queueURL)
+	}
+	return &sqsQueue{
+		url:      queueURL,
+		endpoint: u.Scheme + "://" + u.Host + "/",
+		region:   region,
+		creds:    creds,
+		client:   &http.Client{Timeout: time.Minute},
+	}, nil
+}
+
+// call invokes SQS API action with JSON-encoded in as arguments, decoding
+// response to out
+func (q *sqsQueue) call(ctx context.Context, action string, in, out any) error {
+	body, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, q.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.0")
+	req.Header.Set("X-Amz-Target", "AmazonSQS."+action)
+	awsv4.Sign(req, body, q.creds, q.region, "sqs", time.Now())
+	resp, err := q.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		var apiErr struct {
+			Type    string `json:"__type"`
+			Message string `json:"message"`
+		}
+		json.Unmarshal(data, &apiErr)
+		return fmt.Errorf("sqs %s: %s: %s %s", action, resp.Status, apiErr.Type, apiErr.Message)
+	}
+	if out == nil {
+		return nil
+	}
+	return json.Unmarshal(data, out)
+}
+
+func (q *sqsQueue) receive(ctx context.Context) ([]delivery, error) {
+	var out struct {
+		Messages []struct {
+			Body          string
+			ReceiptHandle string
+		}
+	}
+	err := q.call(ctx, "ReceiveMessage", struct {
+		QueueUrl            string
+		MaxNumberOfMessages int
+		WaitTimeSeconds     int
+	}{q.url, 10, 20}, &out)
+	if err != nil {
+		return nil, err
+	}
+	batch := make([]delivery, 0, len(out.Messages))
+	for _, m := range out.Messages {
+		handle := m.ReceiptHandle
+		batch = append(batch, delivery{
+			body: []byte(m.Body),
+			ack: func(ctx context.Context) error {
+				return q.call(ctx, "DeleteMessage", struct{ QueueUrl, ReceiptHandle string }{q.url, handle}, nil)
+			},
+		})
+	}
+	return batch, nil
+}
+
+// sqsMaxMessageSize is maximum size of SQS message body
+const sqsMaxMessageSize = 256 << 10
+
+func (q *sqsQueue) maxSize() int { return sqsMaxMessageSize }
+
+func (q *sqsQueue) write(ctx context.Context, _ string, body []byte) error {
+	return q.call(ctx, "SendMessage", struct{ QueueUrl, MessageBody string }{q.url, string(body)}, nil)
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"errors"
+	"net"
+	"os"
+	"strconv"
+	"time"
+)
+
+// systemdListener returns listener passed by systemd socket activation, see
+// sd_listen_fds(3). It returns nil listener and nil error if process was not
+// socket-activated. Only the first passed descriptor is used.
+func systemdListener() (net.Listener, error) {
+	if pid, err := strconv.Atoi(os.Getenv("LISTEN_PID")); err != nil || pid != os.Getpid() {
+		return nil, nil
+	}
+	n, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || n < 1 {
+		return nil, nil
+	}
+	os.Unsetenv("LISTEN_PID")
+	os.Unsetenv("LISTEN_FDS")
+	os.Unsetenv("LISTEN_FDNAMES")
+	const listenFdsStart = 3
+	f := os.NewFile(listenFdsStart, "systemd-socket")
+	if f == nil {
+		return nil, errors.New("invalid socket-activation file descriptor")
+	}
+	defer f.Close()
+	return net.FileListener(f)
+}
+
+// sdNotify sends state to the service manager, see sd_notify(3). It is a no-op
+// if NOTIFY_SOCKET is not set.
+func sdNotify(state string) error {
+	name := os.Getenv("NOTIFY_SOCKET")
+	if name == "" {
+		return nil
+	}
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: name, Net: "unixgram"})
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = conn.Write([]byte(state))
+	return err
+}
+
+// sdWatchdog periodically sends keep-alive pings to the service manager if
+// watchdog is enabled for the service, see sd_watchdog_enabled(3).
+func sdWatchdog() {
+	if pid, err := strconv.Atoi(os.Getenv("WATCHDOG_PID")); err == nil && pid != os.Getpid() {
+		return
+	}
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return
+	}
+	for range time.Tick(time.Duration(usec) * time.Microsecond / 2) {
+		sdNotify("WATCHDOG=1")
+	}
+}
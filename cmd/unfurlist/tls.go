@@ -0,0 +1,42 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"os"
+
+	"golang.org/x/crypto/acme"
+)
+
+// loadCertPool returns pool of certificates read from PEM file
+func loadCertPool(name string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, errors.New(name + ": no certificates found")
+	}
+	return pool, nil
+}
+
+// requireClientCerts modifies cfg so that clients are required to present
+// certificates signed by one of CAs from pool. If withACME is true,
+// connections made by ACME server to validate tls-alpn-01 challenge are
+// exempt, as such server does not present client certificates. These are
+// told apart the same way autocert does: acme-tls/1 is the only protocol
+// they offer.
+func requireClientCerts(cfg *tls.Config, pool *x509.CertPool, withACME bool) {
+	plain := cfg.Clone()
+	cfg.ClientCAs = pool
+	cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	strict := cfg.Clone()
+	cfg.GetConfigForClient = func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+		if withACME && len(hello.SupportedProtos) == 1 && hello.SupportedProtos[0] == acme.ALPNProto {
+			return plain, nil
+		}
+		return strict, nil
+	}
+}
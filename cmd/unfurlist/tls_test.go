@@ -0,0 +1,42 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"testing"
+
+	"golang.org/x/crypto/acme"
+)
+
+func TestRequireClientCerts(t *testing.T) {
+	pool := x509.NewCertPool()
+	for _, tc := range []struct {
+		withACME bool
+		protos   []string
+		want     tls.ClientAuthType
+	}{
+		{true, []string{"h2", "http/1.1"}, tls.RequireAndVerifyClientCert},
+		{true, nil, tls.RequireAndVerifyClientCert},
+		{true, []string{acme.ALPNProto}, tls.NoClientCert},
+		{true, []string{acme.ALPNProto, "http/1.1"}, tls.RequireAndVerifyClientCert},
+		{true, []string{"h2", acme.ALPNProto}, tls.RequireAndVerifyClientCert},
+		{false, []string{acme.ALPNProto}, tls.RequireAndVerifyClientCert},
+		{false, []string{acme.ALPNProto, "http/1.1"}, tls.RequireAndVerifyClientCert},
+	} {
+		cfg := &tls.Config{NextProtos: []string{"h2", "http/1.1"}}
+		requireClientCerts(cfg, pool, tc.withACME)
+		if cfg.ClientAuth != tls.RequireAndVerifyClientCert || cfg.ClientCAs != pool {
+			t.Fatalf("base config does not require client certificates: %v", cfg.ClientAuth)
+		}
+		got, err := cfg.GetConfigForClient(&tls.ClientHelloInfo{SupportedProtos: tc.protos})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.ClientAuth != tc.want {
+			t.Errorf("acme %v, protocols %q: got client auth %v, want %v", tc.withACME, tc.protos, got.ClientAuth, tc.want)
+		}
+		if got.GetConfigForClient != nil {
+			t.Errorf("acme %v, protocols %q: returned config refers to GetConfigForClient", tc.withACME, tc.protos)
+		}
+	}
+}
@@ -5,9 +5,12 @@ import (
 	"bufio"
 	"bytes"
 	"context"
-	"errors"
+	"crypto/tls"
+	"encoding/hex"
 	"flag"
+	"fmt"
 	"io"
+	"io/fs"
 	"log"
 	"net"
 	"net/http"
@@ -16,50 +19,183 @@ import (
 	"os"
 	"path"
 	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/Doist/unfurlist"
 	"github.com/Doist/unfurlist/internal/useragent"
+	"github.com/Doist/unfurlist/lambda"
 	"github.com/artyom/autoflags"
-	"github.com/artyom/oembed"
 	"github.com/bradfitz/gomemcache/memcache"
+	"golang.org/x/crypto/acme/autocert"
 )
 
 func main() {
 	args := struct {
-		Listen          string        `flag:"listen,address to listen, set both -sslcert and -sslkey for HTTPS"`
-		Pprof           string        `flag:"pprof,address to serve pprof data"`
-		Cert            string        `flag:"sslcert,path to certificate file (PEM format)"`
-		Key             string        `flag:"sslkey,path to certificate file (PEM format)"`
-		Cache           string        `flag:"cache,address of memcached, disabled if empty"`
-		Blocklist       string        `flag:"blocklist,file with url prefixes to block, one per line"`
-		WithDimensions  bool          `flag:"withDimensions,return image dimensions if possible (extra request to fetch image)"`
-		Timeout         time.Duration `flag:"timeout,timeout for remote i/o"`
-		GoogleMapsKey   string        `flag:"googlemapskey,Google Static Maps API key to generate map previews"`
-		VideoDomains    string        `flag:"videoDomains,comma-separated list of domains that host video+thumbnails"`
-		MaxResults      int           `flag:"max,maximum number of results to get for single request"`
-		Ping            bool          `flag:"ping,respond with 200 OK on /ping path (for health checks)"`
-		OembedProviders string        `flag:"oembedProviders,custom oembed providers list in json format"`
+		Listen            string        `flag:"listen,address to listen (unix:/path/to.sock for Unix domain socket; ignored if socket-activated by systemd), set both -sslcert and -sslkey for HTTPS"`
+		SocketMode        string        `flag:"socketMode,permissions of Unix domain socket file, octal (i.e. 0660)"`
+		Pprof             string        `flag:"pprof,address to serve pprof data"`
+		Cert              string        `flag:"sslcert,path to certificate file (PEM format)"`
+		Key               string        `flag:"sslkey,path to certificate file (PEM format)"`
+		ACME              string        `flag:"acme,comma-separated list of domains to automatically get TLS certificates for via ACME (Let's Encrypt), -listen should be on port 443"`
+		ACMECache         string        `flag:"acmeCache,directory to store ACME certificates in"`
+		ClientCA          string        `flag:"clientCA,file with CA certificates (PEM format) to require and verify client certificates against (HTTPS only)"`
+		Cache             string        `flag:"cache,comma-separated addresses of memcached servers, disabled if empty"`
+		CacheTimeout      time.Duration `flag:"cacheTimeout,memcached read/write timeout"`
+		CacheMaxIdle      int           `flag:"cacheMaxIdle,max idle connections per memcached server"`
+		CacheTTL          time.Duration `flag:"cacheTTL,expiration time of cached results (0 for no expiration)"`
+		UpstreamTTL       bool          `flag:"upstreamTTL,cache results of pages for as long as their Cache-Control or Expires headers allow, within -minCacheTTL and -maxCacheTTL"`
+		MinCacheTTL       time.Duration `flag:"minCacheTTL,minimum expiration time of results cached with -upstreamTTL"`
+		MaxCacheTTL       time.Duration `flag:"maxCacheTTL,maximum expiration time of results cached with -upstreamTTL (0 for unlimited)"`
+		MemoTTL           time.Duration `flag:"memoTTL,keep results in memory this long to reuse them for repeated requests, i.e. 5s (0 to disable)"`
+		MemoSize          int64         `flag:"memoSize,maximum size of results kept in memory with -memoTTL, in bytes"`
+		RevalidateTTL     time.Duration `flag:"revalidateTTL,keep ETag and Last-Modified of pages this long after their results expire, to revalidate them with conditional requests (0 to disable)"`
+		CacheNamespace    string        `flag:"cacheNamespace,prefix of cache keys, to share cache between environments"`
+		CacheKey          string        `flag:"cacheKey,hex-encoded 16, 24 or 32 byte key to encrypt cached values with (AES-GCM)"`
+		CacheDir          string        `flag:"cacheDir,directory to keep cached results in, exclusive with -cache and -peers"`
+		CacheDirSize      int64         `flag:"cacheDirSize,max total size of results cached in -cacheDir, bytes"`
+		ColdCache         string        `flag:"coldCache,object storage location to also keep results in for long, s3://bucket/prefix (credentials are taken from AWS_* environment)"`
+		ColdCacheEndpoint string        `flag:"coldCacheEndpoint,object storage API endpoint (https://storage.googleapis.com for Google Cloud Storage)"`
+		ColdCacheRegion   string        `flag:"coldCacheRegion,object storage region (auto for Google Cloud Storage)"`
+		ColdCacheTTL      time.Duration `flag:"coldCacheTTL,expiration time of results kept in -coldCache (0 for no expiration)"`
+		Peers             string        `flag:"peers,comma-separated urls of unfurlist instances sharing in-memory cache with each other, exclusive with -cache"`
+		PeerSelf          string        `flag:"peerSelf,url other -peers reach this instance's cache at (one of -peers)"`
+		PeerListen        string        `flag:"peerListen,address to serve cache requests of -peers on, must not be exposed publicly"`
+		PeerCacheSize     int64         `flag:"peerCacheSize,max size of in-memory cache shared with -peers, bytes"`
+		Blocklist         string        `flag:"blocklist,file with url prefixes to block, one per line"`
+		TitleBlocklist    string        `flag:"titleBlocklist,file with page title/description rules to block, one per line: substrings, /regexps/, optionally prefixed with @host.glob (built-in list is used if empty)"`
+		ScrapeRules       string        `flag:"scrapeRules,yaml file with list of per-domain CSS selector or XPath rules for pages missing metadata: domain (host glob), title, description, image"`
+		SafeBrowsingKey   string        `flag:"safeBrowsingKey,Google Safe Browsing API key to check urls with (disabled if empty)"`
+		SafeBrowsingSkip  bool          `flag:"safeBrowsingSkip,skip urls with Safe Browsing threats entirely instead of returning them with dangerous flag"`
+		MeetingLinks      bool          `flag:"meetingLinks,preview Zoom, Google Meet and Teams meeting links without fetching them"`
+		LoginPages        string        `flag:"loginPages,file with extra login pages to not follow redirects to, one per line: urls or /path regexps/"`
+		WithDimensions    bool          `flag:"withDimensions,return image dimensions if possible (extra request to fetch image)"`
+		MaxImageSize      int64         `flag:"maxImageSize,drop preview images larger than this many bytes (0 for no limit)"`
+		DescSources       string        `flag:"descriptionSources,semicolon-separated rules of page description sources priority: comma-separated og, twitter, meta, jsonld, optionally prefixed with @host.glob and space"`
+		SkipEmpty         bool          `flag:"skipEmpty,omit results without metadata unless request has skip_empty=0"`
+		SchemelessURLs    bool          `flag:"schemelessURLs,also unfurl urls without scheme starting with www. in plain text"`
+		Timeout           time.Duration `flag:"timeout,timeout for remote i/o"`
+		GoogleMapsKey     string        `flag:"googlemapskey,Google Static Maps API key to generate map previews"`
+		GoogleMapsSize    string        `flag:"googleMapsSize,size of map previews before scaling, WxH"`
+		GoogleMapsScale   int           `flag:"googleMapsScale,scale of map previews, 1 or 2"`
+		GoogleMapsZoom    int           `flag:"googleMapsZoom,zoom level of map previews if url has none"`
+		GoogleMapsMarker  string        `flag:"googleMapsMarker,color of map preview markers, i.e. red or 0xFFAA00"`
+		GoogleMapsLang    string        `flag:"googleMapsLanguage,language of map preview labels"`
+		OSMStaticMap      string        `flag:"osmStaticMap,url template of static map images for OpenStreetMap links and geo: URIs with {lat}, {lng} and {zoom} placeholders (Google Static Maps are used if empty)"`
+		OSMStaticMapSize  string        `flag:"osmStaticMapSize,size of images created from -osmStaticMap template, WxH"`
+		NominatimURL      string        `flag:"nominatimURL,Nominatim reverse geocoding endpoint to get titles of OpenStreetMap links and geo: URIs (i.e. https://nominatim.openstreetmap.org/reverse)"`
+		GoogleMapsStyle   string        `flag:"googleMapsStyle,semicolon-separated list of map preview styles, i.e. feature:poi|visibility:off"`
+		VideoDomains      string        `flag:"videoDomains,comma-separated list of domains that host video+thumbnails"`
+		ConfluenceURL     string        `flag:"confluenceURL,base url of Confluence site to preview pages of with REST API, i.e. https://example.atlassian.net/wiki"`
+		ConfluenceUser    string        `flag:"confluenceUser,Confluence Cloud account email (leave empty to use -confluenceToken as Server personal access token)"`
+		ConfluenceToken   string        `flag:"confluenceToken,Confluence API token"`
+		AsanaToken        string        `flag:"asanaToken,Asana personal access token to preview app.asana.com task links with"`
+		AirtableToken     string        `flag:"airtableToken,Airtable personal access token with schema.bases:read scope to preview airtable.com links with"`
+		MaxResults        int           `flag:"max,maximum number of results to get for single request"`
+		MaxJobResults     int           `flag:"maxJobResults,maximum number of urls processed by single job submitted to /jobs"`
+		MaxContentSize    int           `flag:"maxContentSize,maximum size of request content in bytes"`
+		MaxRequestTime    time.Duration `flag:"maxRequestTime,max time to process single request, clients may ask for less with timeout argument (0 for unlimited)"`
+		SlowFetch         time.Duration `flag:"slowFetch,log urls taking longer than this to process (0 to disable)"`
+		MaxFetches        int           `flag:"maxFetches,maximum number of urls processed concurrently across all requests (0 for unlimited)"`
+		MaxOutbound       int           `flag:"maxOutbound,maximum number of simultaneous outbound http requests, extra ones are queued (0 for unlimited)"`
+		HedgeOembed       bool          `flag:"hedgeOembed,fetch pages of oembed provider urls concurrently with oembed requests to reduce latency"`
+		Robots            bool          `flag:"robots,honor nosnippet, max-snippet and max-image-preview directives of robots meta tags and X-Robots-Tag headers"`
+		HTTP2             bool          `flag:"http2,use HTTP/2 for outbound requests to servers supporting it"`
+		HTTP3             bool          `flag:"http3,use HTTP/3 for outbound requests to servers advertising it with Alt-Svc header (requires build with quic tag)"`
+		MaxConnsPerHost   int           `flag:"maxConnsPerHost,maximum number of outbound connections per host (0 for unlimited)"`
+		AdminToken        string        `flag:"adminToken,serve internal status on /admin/status to requests with this bearer token (disabled if empty)"`
+		Queue             string        `flag:"queue,consume messages with urls from this queue (sqs:<queue url>, or kafka:<REST Proxy url>/topics/<topic>?group=<consumer group>) and write results to -sink"`
+		Sink              string        `flag:"sink,where to write results of -queue messages: sqs:<queue url>, kafka:<REST Proxy url>/topics/<topic>, webhook url or memcache"`
+		Statsd            string        `flag:"statsd,address of StatsD/DogStatsD server to send metrics to (host:port)"`
+		StatsdPrefix      string        `flag:"statsdPrefix,prefix of StatsD metric names"`
+		AccessLog         string        `flag:"accessLog,write access log to stdout in this format: json or common (disabled if empty)"`
+		Ping              bool          `flag:"ping,respond with 200 OK on /ping path, serve /healthz and /readyz (for health checks)"`
+		ProbeURL          string        `flag:"probeURL,url to check outbound connectivity with in /readyz (empty to disable)"`
+		OembedProviders   string        `flag:"oembedProviders,custom oembed providers list in json format"`
+		OembedRefresh     time.Duration `flag:"oembedRefresh,re-download oembed providers list from oembed.com this often (0 to disable)"`
+		ForwardHeaders    string        `flag:"forwardHeaders,comma-separated list of client request headers to pass to upstream requests (i.e. Accept-Language)"`
+		ForwardRequestID  bool          `flag:"forwardRequestID,add X-Request-ID header with id of client request to upstream requests"`
+		SignSecret        string        `flag:"signSecret,only accept requests signed with this secret (see unfurlist.SignContent)"`
+		SignMaxAge        time.Duration `flag:"signMaxAge,max age of signed requests"`
+		AsyncSecret       string        `flag:"asyncSecret,enable asynchronous requests with callback_url argument, signing callbacks with this secret"`
+		CallbackPrefixes  string        `flag:"callbackPrefixes,comma-separated url prefixes callback_url must start with, required with -asyncSecret"`
+		MaxJobs           int           `flag:"maxJobs,maximum number of asynchronous jobs processed concurrently"`
+		JSONP             bool          `flag:"jsonp,support JSONP responses (callback argument)"`
+		CacheControl      string        `flag:"cacheControl,value of Cache-Control header to send with responses"`
+		ScreenshotService string        `flag:"screenshotService,url of service rendering page screenshots for pages without images"`
+		ScreenshotSecret  string        `flag:"screenshotSecret,secret to sign screenshot service requests with"`
+		ThumbnailBase     string        `flag:"thumbnailBaseURL,public url of this service to serve resized result images under at /thumbnail (disabled if empty)"`
+		ThumbnailSecret   string        `flag:"thumbnailSecret,secret to sign thumbnail urls with, required with -thumbnailBaseURL"`
+		ImageProxy        string        `flag:"imageProxy,url of image proxy to route result images and favicons through (disabled if empty)"`
+		ImageProxyFormat  string        `flag:"imageProxyFormat,url format of -imageProxy: imgproxy or camo"`
+		ImageProxyKey     string        `flag:"imageProxyKey,image proxy signing key, hex-encoded for imgproxy"`
+		ImageProxySalt    string        `flag:"imageProxySalt,hex-encoded imgproxy signing salt"`
+		ImageProxyOptions string        `flag:"imageProxyOptions,imgproxy processing options, i.e. rs:fit:600:400"`
+		ImageProxyAll     bool          `flag:"imageProxyAll,route https images through -imageProxy too, not only http ones, to hide client addresses from image hosts"`
 	}{
-		Listen:     "localhost:8080",
-		Timeout:    30 * time.Second,
-		MaxResults: unfurlist.DefaultMaxResults,
+		Listen:           "localhost:8080",
+		Timeout:          30 * time.Second,
+		MaxResults:       unfurlist.DefaultMaxResults,
+		MaxContentSize:   unfurlist.DefaultMaxContentSize,
+		MaxJobs:          unfurlist.DefaultMaxJobs,
+		MaxJobResults:    unfurlist.DefaultMaxJobResults,
+		SignMaxAge:       5 * time.Minute,
+		JSONP:            true,
+		ProbeURL:         "https://www.gstatic.com/generate_204",
+		CacheTimeout:     memcache.DefaultTimeout,
+		CacheMaxIdle:     memcache.DefaultMaxIdleConns,
+		StatsdPrefix:     "unfurlist.",
+		PeerCacheSize:    64 << 20,
+		MemoSize:         16 << 20,
+		CacheDirSize:     1 << 30,
+		ColdCacheRegion:  "us-east-1",
+		GoogleMapsSize:   "640x480",
+		GoogleMapsScale:  2,
+		GoogleMapsZoom:   16,
+		GoogleMapsMarker: "red",
+		OSMStaticMapSize: "600x400",
+		MeetingLinks:     true,
+		ImageProxyFormat: "imgproxy",
 	}
 	var discard string
 	flag.StringVar(&discard, "image.proxy.url", "", "DEPRECATED and unused")
 	flag.StringVar(&discard, "image.proxy.secret", "", "DEPRECATED and unused")
 	flag.StringVar(&args.Blocklist, "blacklist", args.Blocklist, "DEPRECATED: use -blocklist instead")
+	var configFile string
+	flag.StringVar(&configFile, "config", "", "YAML configuration `file`, command line flags and environment take precedence over it")
 	autoflags.Define(&args)
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage of %s:\n", os.Args[0])
+		flag.PrintDefaults()
+		fmt.Fprintf(flag.CommandLine.Output(), "\nEach flag can also be set with %s* environment variable,"+
+			" i.e. %s for -maxRequestTime.\nCommand line flags take precedence over environment,"+
+			" environment takes precedence over configuration file.\n", envPrefix, envName("maxRequestTime"))
+	}
 	flag.Parse()
+	if err := setFlagsFromEnv(flag.CommandLine); err != nil {
+		log.Fatal(err)
+	}
+	fileCfg := new(fileConfig)
+	if configFile != "" {
+		var err error
+		if fileCfg, err = loadConfig(configFile, flag.CommandLine); err != nil {
+			log.Fatal(err)
+		}
+	}
 
 	if args.Timeout < 0 {
 		args.Timeout = 0
 	}
+	var h3 http.RoundTripper
+	if args.HTTP3 {
+		if h3 = newHTTP3Transport(); h3 == nil {
+			log.Fatal("-http3 requires unfurlist built with quic tag")
+		}
+	}
 	httpClient := &http.Client{
-		CheckRedirect: failOnLoginPages,
-		Timeout:       args.Timeout,
-		Transport: useragent.Set(&http.Transport{
+		Timeout: args.Timeout,
+		Transport: useragent.SetDomains(unfurlist.NewTransport(&http.Transport{
 			Proxy: http.ProxyFromEnvironment,
 			DialContext: (&net.Dialer{
 				Timeout:   10 * time.Second,
@@ -70,57 +206,270 @@ func main() {
 			IdleConnTimeout:       90 * time.Second,
 			TLSHandshakeTimeout:   10 * time.Second,
 			ExpectContinueTimeout: 1 * time.Second,
-		}, "unfurlist (https://github.com/Doist/unfurlist)"),
+		}, unfurlist.TransportOptions{
+			ForceHTTP2:      args.HTTP2,
+			MaxConnsPerHost: args.MaxConnsPerHost,
+			HTTP3:           h3,
+		}), "unfurlist (https://github.com/Doist/unfurlist)", fileCfg.userAgents()),
 	}
 	logFlags := log.LstdFlags
 	if os.Getenv("AWS_EXECUTION_ENV") != "" {
 		logFlags = 0
 	}
+	headers := map[string]string{
+		"Accept-Language": "en;q=1, *;q=0.5",
+	}
+	for k, v := range fileCfg.Headers {
+		headers[k] = v
+	}
 	configs := []unfurlist.ConfFunc{
-		unfurlist.WithExtraHeaders(map[string]string{
-			"Accept-Language": "en;q=1, *;q=0.5",
-		}),
+		unfurlist.WithExtraHeaders(headers),
+		unfurlist.WithCookies(fileCfg.Cookies),
 		unfurlist.WithLogger(log.New(os.Stderr, "", logFlags)),
 		unfurlist.WithHTTPClient(httpClient),
 		unfurlist.WithImageDimensions(args.WithDimensions),
-		unfurlist.WithBlocklistTitles(titleBlocklist),
+		unfurlist.WithMaxImageSize(args.MaxImageSize),
+		unfurlist.WithSchemelessURLs(args.SchemelessURLs),
+		unfurlist.WithSkipEmptyResults(args.SkipEmpty),
+		unfurlist.WithDescriptionSources(strings.Split(args.DescSources, ";")),
 		unfurlist.WithMaxResults(args.MaxResults),
+		unfurlist.WithMaxContentSize(args.MaxContentSize),
+		unfurlist.WithJSONP(args.JSONP),
+		unfurlist.WithMaxConcurrentFetches(args.MaxFetches),
+		unfurlist.WithMaxOutboundRequests(args.MaxOutbound),
+		unfurlist.WithHedgedOembed(args.HedgeOembed),
+		unfurlist.WithRevalidation(args.RevalidateTTL),
+		unfurlist.WithRobotsDirectives(args.Robots),
+		unfurlist.WithResultMemo(args.MemoTTL, args.MemoSize),
+		unfurlist.WithMaxTimeout(args.MaxRequestTime),
+		unfurlist.WithSlowFetchLog(args.SlowFetch),
+		unfurlist.WithRequestIDForwarding(args.ForwardRequestID),
+	}
+	if args.UpstreamTTL {
+		configs = append(configs, unfurlist.WithUpstreamCacheTTL(args.MinCacheTTL, args.MaxCacheTTL))
+	}
+	if args.OembedRefresh > 0 {
+		configs = append(configs, unfurlist.WithOembedProvidersRefresh(unfurlist.DefaultOembedProvidersURL, args.OembedRefresh))
+	}
+	if args.ForwardHeaders != "" {
+		configs = append(configs, unfurlist.WithForwardedHeaders(strings.Split(args.ForwardHeaders, ",")...))
+	}
+	if args.SignSecret != "" {
+		configs = append(configs, unfurlist.WithRequestSigning([]byte(args.SignSecret), args.SignMaxAge))
+	}
+	if args.AsyncSecret != "" {
+		if args.CallbackPrefixes == "" {
+			log.Fatal("-callbackPrefixes is required with -asyncSecret")
+		}
+		configs = append(configs, unfurlist.WithAsyncCallbacks([]byte(args.AsyncSecret)),
+			unfurlist.WithCallbackPrefixes(strings.Split(args.CallbackPrefixes, ",")))
+	}
+	configs = append(configs, unfurlist.WithMaxJobs(args.MaxJobs), unfurlist.WithMaxJobResults(args.MaxJobResults))
+	if args.Statsd != "" {
+		configs = append(configs, unfurlist.WithStatsd(args.Statsd, args.StatsdPrefix))
+	}
+	if args.CacheControl != "" {
+		configs = append(configs, unfurlist.WithCacheControl(args.CacheControl))
+	}
+	if args.ScreenshotService != "" {
+		configs = append(configs, unfurlist.WithScreenshotService(args.ScreenshotService, args.ScreenshotSecret))
+	}
+	if args.ThumbnailBase != "" {
+		if args.ThumbnailSecret == "" {
+			log.Fatal("-thumbnailSecret is required with -thumbnailBaseURL")
+		}
+		configs = append(configs, unfurlist.WithThumbnails(args.ThumbnailBase, []byte(args.ThumbnailSecret)))
+	}
+	if args.ImageProxy != "" {
+		var rewrite func(string) string
+		switch args.ImageProxyFormat {
+		case "imgproxy":
+			key, err := hex.DecodeString(args.ImageProxyKey)
+			if err != nil {
+				log.Fatal("-imageProxyKey: ", err)
+			}
+			salt, err := hex.DecodeString(args.ImageProxySalt)
+			if err != nil {
+				log.Fatal("-imageProxySalt: ", err)
+			}
+			rewrite = unfurlist.ImgproxyRewriter(args.ImageProxy, key, salt, args.ImageProxyOptions)
+		case "camo":
+			if args.ImageProxyKey == "" {
+				log.Fatal("-imageProxyKey is required for camo")
+			}
+			rewrite = unfurlist.CamoRewriter(args.ImageProxy, []byte(args.ImageProxyKey))
+		default:
+			log.Fatalf("unsupported -imageProxyFormat %q", args.ImageProxyFormat)
+		}
+		if !args.ImageProxyAll {
+			rewrite = unfurlist.InsecureOnly(rewrite)
+		}
+		configs = append(configs, unfurlist.WithImageRewriter(rewrite))
+	}
+	if args.SafeBrowsingKey != "" {
+		verdict := unfurlist.VerdictDangerous
+		if args.SafeBrowsingSkip {
+			verdict = unfurlist.VerdictBlock
+		}
+		configs = append(configs, unfurlist.WithURLReputation(unfurlist.SafeBrowsing(args.SafeBrowsingKey, verdict, nil)))
+	}
+	if args.MeetingLinks {
+		configs = append(configs, unfurlist.WithPreFetchHook(unfurlist.VideoConferenceHook))
 	}
-	if args.OembedProviders != "" {
-		data, err := os.ReadFile(args.OembedProviders)
+	if args.LoginPages != "" {
+		patterns, pages, err := readLoginPages(args.LoginPages)
 		if err != nil {
 			log.Fatal(err)
 		}
-		fn, err := oembed.Providers(bytes.NewReader(data))
+		configs = append(configs, unfurlist.WithLoginPageDetection(patterns, pages))
+	}
+	if args.CacheNamespace != "" {
+		configs = append(configs, unfurlist.WithCacheNamespace(args.CacheNamespace))
+	}
+	if args.CacheKey != "" {
+		key, err := hex.DecodeString(args.CacheKey)
+		if err != nil || (len(key) != 16 && len(key) != 24 && len(key) != 32) {
+			log.Fatal("-cacheKey must be hex-encoded 16, 24 or 32 byte key")
+		}
+		configs = append(configs, unfurlist.WithCacheEncryption(key))
+	}
+	var cache *memcache.Client
+	if args.Cache != "" {
+		log.Print("Enable cache at ", args.Cache)
+		var err error
+		cache, err = unfurlist.NewMemcacheClient(unfurlist.MemcacheOptions{
+			Servers:      strings.Split(args.Cache, ","),
+			Timeout:      args.CacheTimeout,
+			MaxIdleConns: args.CacheMaxIdle,
+		})
 		if err != nil {
 			log.Fatal(err)
 		}
-		configs = append(configs, unfurlist.WithOembedLookupFunc(fn))
+		configs = append(configs, unfurlist.WithMemcache(cache), unfurlist.WithCacheTTL(args.CacheTTL))
 	}
-	if args.Blocklist != "" {
-		prefixes, err := readBlocklist(args.Blocklist)
+	if args.CacheDir != "" {
+		if args.Cache != "" || args.Peers != "" {
+			log.Fatal("-cacheDir is mutually exclusive with -cache and -peers")
+		}
+		dc, err := unfurlist.NewDiskCache(args.CacheDir, args.CacheDirSize)
 		if err != nil {
 			log.Fatal(err)
 		}
-		configs = append(configs, unfurlist.WithBlocklistPrefixes(prefixes))
+		configs = append(configs, unfurlist.WithCache(dc), unfurlist.WithCacheTTL(args.CacheTTL))
 	}
-	if args.Cache != "" {
-		log.Print("Enable cache at ", args.Cache)
-		configs = append(configs, unfurlist.WithMemcache(memcache.New(args.Cache)))
+	if args.ColdCache != "" {
+		cc, err := newColdCache(args.ColdCache, args.ColdCacheEndpoint, args.ColdCacheRegion)
+		if err != nil {
+			log.Fatal(err)
+		}
+		configs = append(configs, unfurlist.WithColdCache(cc, args.ColdCacheTTL))
+	}
+	if args.Peers != "" {
+		if args.Cache != "" {
+			log.Fatal("-peers and -cache are mutually exclusive")
+		}
+		if args.PeerSelf == "" || args.PeerListen == "" {
+			log.Fatal("-peerSelf and -peerListen must be set when -peers is used")
+		}
+		pc := unfurlist.NewPeerCache(args.PeerSelf, strings.Split(args.Peers, ","), args.PeerCacheSize)
+		go func() { log.Fatal(http.ListenAndServe(args.PeerListen, pc)) }()
+		configs = append(configs, unfurlist.WithCache(pc), unfurlist.WithCacheTTL(args.CacheTTL))
 	}
 
-	var ff []unfurlist.FetchFunc
+	fetchers := new(unfurlist.FetcherRegistry)
+	var staticMaps unfurlist.StaticMapProvider
 	if args.GoogleMapsKey != "" {
-		ff = append(ff, unfurlist.GoogleMapsFetcher(args.GoogleMapsKey))
+		opts := unfurlist.GoogleMapsOptions{
+			Scale:       args.GoogleMapsScale,
+			Zoom:        args.GoogleMapsZoom,
+			MarkerColor: args.GoogleMapsMarker,
+			Language:    args.GoogleMapsLang,
+		}
+		if _, err := fmt.Sscanf(args.GoogleMapsSize, "%dx%d", &opts.Width, &opts.Height); err != nil {
+			log.Fatalf("invalid -googleMapsSize %q: %v", args.GoogleMapsSize, err)
+		}
+		if args.GoogleMapsStyle != "" {
+			opts.Styles = strings.Split(args.GoogleMapsStyle, ";")
+		}
+		f := unfurlist.NamedFetcher("googlemaps", unfurlist.GoogleMapsFetcherWithOptions(args.GoogleMapsKey, opts))
+		for _, d := range []string{"*.google.*", "maps.app.goo.gl", "goo.gl"} {
+			fetchers.RegisterFetcher(d, 0, f)
+		}
+		staticMaps = unfurlist.GoogleStaticMaps(args.GoogleMapsKey, opts)
+	}
+	fetchers.RegisterFetcher("maps.apple.com", 0,
+		unfurlist.NamedFetcher("applemaps", unfurlist.AppleMapsFetcher(staticMaps)))
+	loom := unfurlist.NamedFetcher("loom", unfurlist.LoomFetcher())
+	for _, d := range []string{"loom.com", "www.loom.com"} {
+		fetchers.RegisterFetcher(d, 0, loom)
+	}
+	miro := unfurlist.NamedFetcher("miro", unfurlist.MiroFetcher())
+	for _, d := range []string{"miro.com", "www.miro.com"} {
+		fetchers.RegisterFetcher(d, 0, miro)
+	}
+	canva := unfurlist.NamedFetcher("canva", unfurlist.CanvaFetcher())
+	for _, d := range []string{"canva.com", "www.canva.com"} {
+		fetchers.RegisterFetcher(d, 0, canva)
+	}
+	osmMaps := staticMaps
+	if args.OSMStaticMap != "" {
+		var width, height int
+		if _, err := fmt.Sscanf(args.OSMStaticMapSize, "%dx%d", &width, &height); err != nil {
+			log.Fatalf("invalid -osmStaticMapSize %q: %v", args.OSMStaticMapSize, err)
+		}
+		osmMaps = unfurlist.TemplateStaticMaps(args.OSMStaticMap, width, height)
+	}
+	osm := unfurlist.NamedFetcher("openstreetmap", unfurlist.OpenStreetMapFetcher(osmMaps, args.NominatimURL))
+	for _, d := range []string{"openstreetmap.org", "www.openstreetmap.org", "geo:"} {
+		fetchers.RegisterFetcher(d, 0, osm)
 	}
 	if args.VideoDomains != "" {
-		ff = append(ff, videoThumbnailsFetcher(strings.Split(args.VideoDomains, ",")...))
+		domains := strings.Split(args.VideoDomains, ",")
+		f := unfurlist.NamedFetcher("videothumbnails", videoThumbnailsFetcher(domains...))
+		for _, d := range domains {
+			if err := fetchers.RegisterFetcher(d, 0, f); err != nil {
+				log.Fatalf("video domain %q: %v", d, err)
+			}
+		}
 	}
-	if ff != nil {
-		configs = append(configs, unfurlist.WithFetchers(ff...))
+	if args.ConfluenceURL != "" {
+		u, err := url.Parse(args.ConfluenceURL)
+		if err != nil || u.Host == "" || args.ConfluenceToken == "" {
+			log.Fatal("-confluenceURL must be an absolute url and requires -confluenceToken")
+		}
+		f := unfurlist.NamedFetcher("confluence", unfurlist.ConfluenceFetcher(unfurlist.ConfluenceOptions{
+			BaseURL: args.ConfluenceURL,
+			User:    args.ConfluenceUser,
+			Token:   args.ConfluenceToken,
+		}))
+		if err := fetchers.RegisterFetcher(u.Hostname(), 0, f); err != nil {
+			log.Fatalf("confluence: %v", err)
+		}
 	}
+	if args.AsanaToken != "" {
+		fetchers.RegisterFetcher("app.asana.com", 0,
+			unfurlist.NamedFetcher("asana", unfurlist.AsanaFetcher(args.AsanaToken)))
+	}
+	if args.AirtableToken != "" {
+		f := unfurlist.NamedFetcher("airtable", unfurlist.AirtableFetcher(args.AirtableToken))
+		for _, d := range []string{"airtable.com", "www.airtable.com"} {
+			fetchers.RegisterFetcher(d, 0, f)
+		}
+	}
+	configs = append(configs, unfurlist.WithFetcherRegistry(fetchers))
+	configs = append(configs, fileCfg.authorization(&http.Client{Timeout: 10 * time.Second})...)
 
 	handler := unfurlist.New(configs...)
+	files := reloadableFiles{
+		blocklist:       args.Blocklist,
+		titleBlocklist:  args.TitleBlocklist,
+		oembedProviders: args.OembedProviders,
+		scrapeRules:     args.ScrapeRules,
+	}
+	if err := files.load(handler.(unfurlist.Reloader)); err != nil {
+		log.Fatal(err)
+	}
+	files.reloadOnSignal(handler.(unfurlist.Reloader))
 	if args.Pprof != "" {
 		go func(addr string) { log.Println(http.ListenAndServe(addr, nil)) }(args.Pprof)
 	}
@@ -138,21 +487,98 @@ func main() {
 	}()
 	mux := http.NewServeMux()
 	mux.Handle("/", handler)
-	if args.Ping {
-		mux.HandleFunc("/ping", func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusOK) })
+	if args.AdminToken != "" {
+		mux.Handle("/admin/status", adminStatusHandler(args.AdminToken, handler.(unfurlist.StatusReporter)))
+	}
+	registerHealthChecks(mux, args.Ping, &healthChecker{cache: cache, client: httpClient, probeURL: args.ProbeURL})
+	if args.Queue != "" {
+		src, err := newSource(args.Queue)
+		if err != nil {
+			log.Fatal(err)
+		}
+		dst, err := newSink(args.Sink, cache)
+		if err != nil {
+			log.Fatal(err)
+		}
+		c := &consumer{src: src, dst: dst, unf: handler.(unfurlist.Unfurler)}
+		go func() { log.Fatal(c.run(context.Background())) }()
+	}
+	var rootHandler http.Handler = mux
+	switch format := unfurlist.AccessLogFormat(args.AccessLog); format {
+	case "":
+	case unfurlist.AccessLogJSON, unfurlist.AccessLogCommon:
+		rootHandler = unfurlist.AccessLog(mux, os.Stdout, format)
+	default:
+		log.Fatalf("unsupported -accessLog format %q", args.AccessLog)
+	}
+	if lambda.IsLambda() {
+		// requests come as Lambda invocations, one at a time
+		log.Fatal(lambda.Start(rootHandler))
 	}
 	srv := &http.Server{
 		Addr:         args.Listen,
 		ReadTimeout:  5 * time.Second,
 		WriteTimeout: 60 * time.Second,
 		IdleTimeout:  30 * time.Second,
-		Handler:      mux,
+		Handler:      rootHandler,
+	}
+	var socketMode uint64
+	if args.SocketMode != "" {
+		var err error
+		if socketMode, err = strconv.ParseUint(args.SocketMode, 8, 32); err != nil {
+			log.Fatalf("invalid -socketMode value: %v", err)
+		}
 	}
-	if args.Cert != "" && args.Key != "" {
-		log.Fatal(srv.ListenAndServeTLS(args.Cert, args.Key))
-	} else {
-		log.Fatal(srv.ListenAndServe())
+	ln, err := systemdListener()
+	if err != nil {
+		log.Fatal(err)
 	}
+	if ln == nil {
+		if ln, err = listen(args.Listen, fs.FileMode(socketMode)&fs.ModePerm); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if err := sdNotify("READY=1"); err != nil {
+		log.Print("systemd notify: ", err)
+	}
+	go sdWatchdog()
+	var tlsConfig *tls.Config
+	switch {
+	case args.ACME != "":
+		if args.ACMECache == "" {
+			log.Fatal("-acmeCache must be set when -acme is used")
+		}
+		m := &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			HostPolicy: autocert.HostWhitelist(strings.Split(args.ACME, ",")...),
+			Cache:      autocert.DirCache(args.ACMECache),
+		}
+		tlsConfig = m.TLSConfig()
+	case args.Cert != "" && args.Key != "":
+		cert, err := tls.LoadX509KeyPair(args.Cert, args.Key)
+		if err != nil {
+			log.Fatal(err)
+		}
+		tlsConfig = &tls.Config{
+			Certificates: []tls.Certificate{cert},
+			NextProtos:   []string{"h2", "http/1.1"},
+		}
+	}
+	if args.ClientCA != "" {
+		if tlsConfig == nil {
+			log.Fatal("-clientCA requires HTTPS to be enabled")
+		}
+		pool, err := loadCertPool(args.ClientCA)
+		if err != nil {
+			log.Fatal(err)
+		}
+		requireClientCerts(tlsConfig, pool, args.ACME != "")
+	}
+	if tlsConfig != nil {
+		srv.TLSConfig = tlsConfig
+		log.Fatal(srv.ServeTLS(ln, "", ""))
+	}
+	log.Fatal(srv.Serve(ln))
 }
 
 func readBlocklist(blocklist string) ([]string, error) {
@@ -174,50 +600,31 @@ func readBlocklist(blocklist string) ([]string, error) {
 	return prefixes, nil
 }
 
-// failOnLoginPages can be used as http.Client.CheckRedirect to skip redirects
-// to login pages of most commonly used services or most commonly named login
-// pages. It also checks depth of redirect chain and stops on more then 10
-// consecutive redirects.
-func failOnLoginPages(req *http.Request, via []*http.Request) error {
-	if len(via) >= 10 {
-		return errors.New("stopped after 10 redirects")
-	}
-	if l := len(via); l > 0 && *req.URL == *via[l-1].URL {
-		return errors.New("redirect loop")
-	}
-	if strings.Contains(strings.ToLower(req.URL.Host), "login") ||
-		loginPathRe.MatchString(req.URL.Path) {
-		return errWantLogin
-	}
-	u := *req.URL
-	u.RawQuery, u.Fragment = "", ""
-	if _, ok := loginPages[(&u).String()]; ok {
-		return errWantLogin
-	}
-	return nil
-}
-
-var loginPathRe = regexp.MustCompile(`(?i)login|sign.?in`)
-
-var errWantLogin = errors.New("resource requires login")
-
-// loginPages is a set of popular services' known login pages
-var loginPages map[string]struct{}
-
-func init() {
-	pages := []string{
-		"https://bitbucket.org/account/signin/",
-		"https://outlook.live.com/owa/",
+// readLoginPages reads file with login page urls and /regexps/ of their
+// paths, one per line
+func readLoginPages(name string) (patterns, pages []string, err error) {
+	lines, err := readLines(name)
+	if err != nil {
+		return nil, nil, err
 	}
-	loginPages = make(map[string]struct{}, len(pages))
-	for _, u := range pages {
-		loginPages[u] = struct{}{}
+	for _, s := range lines {
+		if len(s) > 2 && strings.HasPrefix(s, "/") && strings.HasSuffix(s, "/") {
+			if _, err := regexp.Compile(s[1 : len(s)-1]); err != nil {
+				return nil, nil, fmt.Errorf("%s: %w", name, err)
+			}
+			patterns = append(patterns, s[1:len(s)-1])
+			continue
+		}
+		if u, err := url.Parse(s); err != nil || u.Host == "" {
+			return nil, nil, fmt.Errorf("%s: invalid url %q", name, s)
+		}
+		pages = append(pages, s)
 	}
-
+	return patterns, pages, nil
 }
 
 var titleBlocklist = []string{
-	"robot check", // Amazon
+	"@*amazon.* robot check",
 }
 
 // videoThumbnailsFetcher return unfurlist.FetchFunc that returns metadata
@@ -0,0 +1,154 @@
+package unfurlist
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Doist/unfurlist/internal/awsv4"
+)
+
+// WithColdCache configures unfurl handler to also keep results in c, i.e.
+// object storage, for ttl (0 for no expiration). It's consulted when result
+// is not found in the main cache configured with WithMemcache or WithCache;
+// results found there are copied back to the main cache. Writes to c are done
+// in background and do not delay responses.
+func WithColdCache(c Cache, ttl time.Duration) ConfFunc {
+	return func(h *unfurlHandler) *unfurlHandler {
+		if c != nil {
+			h.cold, h.coldTTL = c, max(ttl, 0)
+		}
+		return h
+	}
+}
+
+// coldCacheTimeout limits background writes to cold cache
+const coldCacheTimeout = 30 * time.Second
+
+// storeCold saves cached result data in cold cache in background
+func (h *unfurlHandler) storeCold(ctx context.Context, key string, data []byte) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), coldCacheTimeout)
+	go func() {
+		defer cancel()
+		if err := h.cold.Set(ctx, key, data, h.coldTTL); err != nil {
+			h.logf(ctx, "cold cache update: %v", err)
+		}
+	}()
+}
+
+// S3Options configure S3Cache
+type S3Options struct {
+	// Endpoint is the base url of the storage API, i.e.
+	// "https://s3.us-east-1.amazonaws.com", or
+	// "https://storage.googleapis.com" for Google Cloud Storage with HMAC
+	// keys
+	Endpoint string
+	Bucket   string
+	Region   string // "auto" for Google Cloud Storage
+	Prefix   string // prepended to object names, i.e. "unfurlist/"
+
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string // optional
+
+	Client *http.Client // http.DefaultClient if nil
+}
+
+// S3Cache is Cache keeping values as objects in S3 or S3-compatible storage
+// like Google Cloud Storage. Storage does not remove expired objects by
+// itself, configure bucket lifecycle rule to delete objects under the prefix
+// some time after their creation.
+type S3Cache struct {
+	opts  S3Options
+	base  *url.URL
+	creds awsv4.Credentials
+}
+
+// NewS3Cache returns S3Cache for given options
+func NewS3Cache(opts S3Options) (*S3Cache, error) {
+	if opts.Bucket == "" || opts.Region == "" {
+		return nil, errors.New("bucket and region must be set")
+	}
+	if opts.AccessKeyID == "" || opts.SecretAccessKey == "" {
+		return nil, errors.New("access key id and secret access key must be set")
+	}
+	base, err := url.Parse(strings.TrimSuffix(opts.Endpoint, "/"))
+	if err != nil {
+		return nil, err
+	}
+	if base.Scheme != "https" && base.Scheme != "http" || base.Host == "" {
+		return nil, fmt.Errorf("invalid endpoint %q", opts.Endpoint)
+	}
+	if opts.Client == nil {
+		opts.Client = http.DefaultClient
+	}
+	return &S3Cache{opts: opts, base: base, creds: awsv4.Credentials{
+		AccessKeyID:     opts.AccessKeyID,
+		SecretAccessKey: opts.SecretAccessKey,
+		SessionToken:    opts.SessionToken,
+	}}, nil
+}
+
+// s3ExpiresHeader is object metadata holding its expiration time, unix
+// seconds
+const s3ExpiresHeader = "X-Amz-Meta-Unfurlist-Expires"
+
+func (c *S3Cache) do(ctx context.Context, method, key string, body []byte, header http.Header) (*http.Response, error) {
+	u := *c.base
+	u.Path += "/" + c.opts.Bucket + "/" + c.opts.Prefix + key
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.Header.Set("X-Amz-Content-Sha256", awsv4.PayloadHash(body))
+	awsv4.Sign(req, body, c.creds, c.opts.Region, "s3", time.Now())
+	return c.opts.Client.Do(req)
+}
+
+func (c *S3Cache) Get(ctx context.Context, key string) ([]byte, error) {
+	resp, err := c.do(ctx, http.MethodGet, key, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, ErrCacheMiss
+	default:
+		return nil, fmt.Errorf("object storage: %s", resp.Status)
+	}
+	if s := resp.Header.Get(s3ExpiresHeader); s != "" {
+		if exp, err := strconv.ParseInt(s, 10, 64); err == nil && time.Now().Unix() >= exp {
+			return nil, ErrCacheMiss
+		}
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, 10<<20))
+}
+
+func (c *S3Cache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	header := make(http.Header)
+	header.Set("Content-Type", "application/octet-stream")
+	if ttl > 0 {
+		header.Set(s3ExpiresHeader, strconv.FormatInt(time.Now().Add(ttl).Unix(), 10))
+	}
+	resp, err := c.do(ctx, http.MethodPut, key, value, header)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("object storage: %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package unfurlist
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestColdCache(t *testing.T) {
+	var mu sync.Mutex
+	objects := make(map[string][]byte)
+	storage := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 ") ||
+			r.Header.Get("X-Amz-Content-Sha256") == "" {
+			http.Error(w, "unsigned request", http.StatusForbidden)
+			return
+		}
+		if !strings.HasPrefix(r.URL.Path, "/bucket/prefix/") {
+			http.NotFound(w, r)
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		switch r.Method {
+		case http.MethodPut:
+			objects[r.URL.Path], _ = io.ReadAll(r.Body)
+		case http.MethodGet:
+			data, ok := objects[r.URL.Path]
+			if !ok {
+				http.NotFound(w, r)
+				return
+			}
+			w.Write(data)
+		}
+	}))
+	defer storage.Close()
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/favicon.ico" {
+			http.NotFound(w, r)
+			return
+		}
+		hits.Add(1)
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><head><title>Page</title></head></html>`))
+	}))
+	defer srv.Close()
+
+	cold, err := NewS3Cache(S3Options{
+		Endpoint:        storage.URL,
+		Bucket:          "bucket",
+		Region:          "us-east-1",
+		Prefix:          "prefix/",
+		AccessKeyID:     "key",
+		SecretAccessKey: "secret",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := "/?content=" + url.QueryEscape(srv.URL)
+	w := httptest.NewRecorder()
+	New(WithColdCache(cold, 0)).ServeHTTP(w, httptest.NewRequest(http.MethodGet, req, nil))
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		mu.Lock()
+		n := len(objects)
+		mu.Unlock()
+		if n == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("result was not stored in cold cache")
+		}
+	}
+	// fresh instance with empty main cache finds result in cold cache
+	handler := New(WithMemcache(newTestMemcache(t)), WithColdCache(cold, 0))
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, req, nil))
+	if !strings.Contains(w.Body.String(), `"title":"Page"`) {
+		t.Fatalf("unexpected response: %s", w.Body)
+	}
+	if n := hits.Load(); n != 1 {
+		t.Fatalf("got %d upstream requests, want 1", n)
+	}
+	if st := handler.(StatusReporter).Status(); st.CacheHits != 1 {
+		t.Fatalf("unexpected cache counters: %+v", st)
+	}
+}
@@ -1,8 +1,11 @@
 package unfurlist
 
 import (
+	"context"
 	"net/http"
+	"net/url"
 	"strings"
+	"time"
 
 	"github.com/artyom/oembed"
 	"github.com/bradfitz/gomemcache/memcache"
@@ -23,7 +26,7 @@ func WithHTTPClient(client *http.Client) ConfFunc {
 func WithMemcache(client *memcache.Client) ConfFunc {
 	return func(h *unfurlHandler) *unfurlHandler {
 		if client != nil {
-			h.Cache = client
+			h.cache = memcacheStore{client}
 		}
 		return h
 	}
@@ -42,6 +45,79 @@ func WithExtraHeaders(hdr map[string]string) ConfFunc {
 	}
 }
 
+// WithCookies configures unfurl handler to send static cookies with requests
+// to domains, i.e. consent cookies for sites showing cookie walls. Keys are
+// domain names matching themselves and their subdomains, values are Cookie
+// header values like "CONSENT=YES+; SOCS=CAI". Cookies of the most specific
+// matching domain are used.
+func WithCookies(cookies map[string]string) ConfFunc {
+	m := make(map[string]string, len(cookies))
+	for k, v := range cookies {
+		if k, v = strings.ToLower(strings.TrimSuffix(k, ".")), strings.TrimSpace(v); k != "" && v != "" {
+			m[k] = v
+		}
+	}
+	return func(h *unfurlHandler) *unfurlHandler {
+		if len(m) != 0 {
+			h.cookies = m
+		}
+		return h
+	}
+}
+
+// WithForwardedHeaders configures unfurl handler to copy listed headers from
+// incoming client request to outgoing http requests, i.e. to pass client's
+// Accept-Language header so that previews are localized for the client.
+// Forwarded headers take precedence over ones set by WithExtraHeaders. Values
+// of forwarded headers are taken into account when caching results.
+func WithForwardedHeaders(names ...string) ConfFunc {
+	keys := make([]string, 0, len(names))
+	for _, k := range names {
+		if k = strings.TrimSpace(k); k != "" {
+			keys = append(keys, http.CanonicalHeaderKey(k))
+		}
+	}
+	return func(h *unfurlHandler) *unfurlHandler {
+		if len(keys) > 0 {
+			h.forwardHeaders = keys
+		}
+		return h
+	}
+}
+
+// WithRequestSigning configures unfurl handler to only accept requests with
+// content signed by the calling backend with the shared secret, see
+// SignContent. Requests which timestamp differs from the current time by more
+// than maxAge are rejected as stale. This allows exposing unfurl handler to
+// browsers while only serving requests that were authorized by the backend.
+func WithRequestSigning(secret []byte, maxAge time.Duration) ConfFunc {
+	return func(h *unfurlHandler) *unfurlHandler {
+		if len(secret) != 0 && maxAge > 0 {
+			h.signer = &requestSigner{secret: secret, maxAge: maxAge}
+		}
+		return h
+	}
+}
+
+// WithCacheControl configures unfurl handler to send Cache-Control header
+// with the given value (i.e. "private, max-age=300") on its responses.
+func WithCacheControl(value string) ConfFunc {
+	return func(h *unfurlHandler) *unfurlHandler {
+		h.cacheControl = value
+		return h
+	}
+}
+
+// WithJSONP configures unfurl handler whether to support JSONP responses
+// requested with callback argument. JSONP is enabled by default; if disabled,
+// requests with callback argument are rejected.
+func WithJSONP(enable bool) ConfFunc {
+	return func(h *unfurlHandler) *unfurlHandler {
+		h.noJSONP = !enable
+		return h
+	}
+}
+
 // WithBlocklistPrefixes configures unfurl handler to skip unfurling urls
 // matching any provided prefix
 func WithBlocklistPrefixes(prefixes []string) ConfFunc {
@@ -51,27 +127,66 @@ func WithBlocklistPrefixes(prefixes []string) ConfFunc {
 	}
 	return func(h *unfurlHandler) *unfurlHandler {
 		if pmap != nil {
-			h.pmap = pmap
+			h.pmap.Store(pmap)
+			h.stats.blocklist.Store(newListVersion("", prefixes))
 		}
 		return h
 	}
 }
 
 // WithBlocklistTitles configures unfurl handler to skip unfurling urls that
-// return pages which title contains one of substrings provided
-func WithBlocklistTitles(substrings []string) ConfFunc {
-	ss := make([]string, len(substrings))
-	for i, s := range substrings {
-		ss[i] = strings.ToLower(s)
-	}
+// return pages which title or description matches one of rules provided.
+// Rules are case-insensitive substrings, or regular expressions if enclosed
+// in slashes, i.e. "/robot.?check/". Rule may be limited to pages on some
+// hosts by prefixing it with "@" and host pattern (path.Match syntax)
+// followed by space, i.e. "@www.amazon.* robot check". Invalid rules are
+// ignored, see CheckBlocklistTitles.
+func WithBlocklistTitles(rules []string) ConfFunc {
+	parsed, _ := parseTitleRules(rules)
 	return func(h *unfurlHandler) *unfurlHandler {
-		if len(ss) > 0 {
-			h.titleBlocklist = ss
+		if len(parsed) > 0 {
+			h.titleBlocklist.Store(&parsed)
+			h.stats.titleBlocklist.Store(newListVersion("", rules))
 		}
 		return h
 	}
 }
 
+// Reloader is implemented by handler returned by New. It allows replacing
+// some of the handler configuration while handler is serving requests, i.e.
+// to reload configuration files on signal.
+type Reloader interface {
+	// SetBlocklistPrefixes replaces list of url prefixes configured with
+	// WithBlocklistPrefixes
+	SetBlocklistPrefixes(prefixes []string)
+	// SetBlocklistTitles replaces list of title rules configured with
+	// WithBlocklistTitles
+	SetBlocklistTitles(rules []string)
+	// SetOembedLookupFunc replaces oembed.LookupFunc used for oembed
+	// lookups
+	SetOembedLookupFunc(fn oembed.LookupFunc)
+	// SetScrapeRules replaces rules configured with WithScrapeRules
+	SetScrapeRules(rules []ScrapeRule)
+}
+
+func (h *unfurlHandler) SetBlocklistPrefixes(prefixes []string) {
+	h.pmap.Store(newPrefixMap(prefixes))
+	h.stats.blocklist.Store(newListVersion("", prefixes))
+}
+
+func (h *unfurlHandler) SetBlocklistTitles(rules []string) {
+	parsed, _ := parseTitleRules(rules)
+	h.titleBlocklist.Store(&parsed)
+	h.stats.titleBlocklist.Store(newListVersion("", rules))
+}
+
+func (h *unfurlHandler) SetOembedLookupFunc(fn oembed.LookupFunc) {
+	if fn != nil {
+		h.oembedFn.Store(&fn)
+		h.stats.providers.Store(&ListVersion{Loaded: time.Now().UTC(), Source: "custom"})
+	}
+}
+
 // WithImageDimensions configures unfurl handler whether to fetch image
 // dimensions or not.
 func WithImageDimensions(enable bool) ConfFunc {
@@ -81,10 +196,43 @@ func WithImageDimensions(enable bool) ConfFunc {
 	}
 }
 
+// WithMaxImageSize configures unfurl handler to drop preview images larger
+// than n bytes, so that clients don't end up loading huge images some sites
+// reference as their og:image. Image size is taken from the response to image
+// request made with FetchImageSize enabled, or from HEAD request otherwise;
+// images with unknown size are kept. Non-positive n disables the limit.
+func WithMaxImageSize(n int64) ConfFunc {
+	return func(h *unfurlHandler) *unfurlHandler {
+		if n > 0 {
+			h.maxImageSize = n
+		}
+		return h
+	}
+}
+
 // WithFetchers attaches custom fetchers to unfurl handler created by New().
+// Fetchers are called for urls of any domain in the order they're provided,
+// after fetchers registered with higher priority in FetcherRegistry.
 func WithFetchers(fetchers ...FetchFunc) ConfFunc {
 	return func(h *unfurlHandler) *unfurlHandler {
-		h.fetchers = fetchers
+		if h.fetchers == nil {
+			h.fetchers = new(FetcherRegistry)
+		}
+		for _, f := range fetchers {
+			h.fetchers.RegisterFetcher("*", 0, f)
+		}
+		return h
+	}
+}
+
+// WithFetcherRegistry configures unfurl handler to use fetchers from the
+// provided registry. It replaces fetchers attached by previous WithFetchers
+// calls.
+func WithFetcherRegistry(r *FetcherRegistry) ConfFunc {
+	return func(h *unfurlHandler) *unfurlHandler {
+		if r != nil {
+			h.fetchers = r
+		}
 		return h
 	}
 }
@@ -100,6 +248,63 @@ func WithMaxResults(n int) ConfFunc {
 	}
 }
 
+// WithMaxContentSize configures unfurl handler to reply with 413 Payload Too
+// Large status to requests with content argument or body larger than n bytes.
+// n must be positive, DefaultMaxContentSize is used by default.
+func WithMaxContentSize(n int) ConfFunc {
+	return func(h *unfurlHandler) *unfurlHandler {
+		if n > 0 {
+			h.maxContent = n
+		}
+		return h
+	}
+}
+
+// WithSkipEmptyResults configures unfurl handler whether to omit results that
+// have no metadata besides url by default. Clients can override it with
+// skip_empty request argument.
+func WithSkipEmptyResults(enable bool) ConfFunc {
+	return func(h *unfurlHandler) *unfurlHandler {
+		h.skipEmpty = enable
+		return h
+	}
+}
+
+// WithSchemelessURLs configures unfurl handler whether to also find urls
+// without scheme that start with "www.", i.e. www.example.com/foo, in plain
+// text content. Such urls are fetched and returned with https:// prefix.
+func WithSchemelessURLs(enable bool) ConfFunc {
+	return func(h *unfurlHandler) *unfurlHandler {
+		h.schemeless = enable
+		return h
+	}
+}
+
+// WithMaxConcurrentFetches configures unfurl handler to process at most n urls
+// concurrently across all requests it serves; other urls wait for their turn.
+// Results found in cache are returned without waiting. If n is not positive,
+// concurrency is not limited, which is the default.
+func WithMaxConcurrentFetches(n int) ConfFunc {
+	return func(h *unfurlHandler) *unfurlHandler {
+		if n > 0 {
+			h.fetchSem = make(chan struct{}, n)
+		}
+		return h
+	}
+}
+
+// WithMaxTimeout configures unfurl handler to limit time spent processing
+// single request to d. Requests may ask for a shorter timeout with timeout
+// argument.
+func WithMaxTimeout(d time.Duration) ConfFunc {
+	return func(h *unfurlHandler) *unfurlHandler {
+		if d > 0 {
+			h.maxTimeout = d
+		}
+		return h
+	}
+}
+
 // WithOembedLookupFunc configures unfurl handler to use custom
 // oembed.LookupFunc for oembed lookups.
 func WithOembedLookupFunc(fn oembed.LookupFunc) ConfFunc {
@@ -111,6 +316,92 @@ func WithOembedLookupFunc(fn oembed.LookupFunc) ConfFunc {
 	}
 }
 
+// WithScreenshotService configures unfurl handler to use external service
+// rendering page screenshots as a preview image for html pages that don't
+// provide any image. Screenshot url is constructed from endpoint by adding
+// page url as "url" query parameter; if secret is not empty, hex-encoded
+// HMAC-SHA256 signature of page url made with this secret is added as "sig"
+// query parameter.
+func WithScreenshotService(endpoint, secret string) ConfFunc {
+	var s *screenshotService
+	if u, err := url.Parse(endpoint); err == nil && endpoint != "" {
+		s = &screenshotService{endpoint: u, secret: []byte(secret)}
+	}
+	return func(h *unfurlHandler) *unfurlHandler {
+		if s != nil {
+			h.screenshots = s
+		}
+		return h
+	}
+}
+
+// WithOembedProvidersRefresh configures unfurl handler to periodically
+// re-download oembed providers list from providersURL (DefaultOembedProvidersURL
+// if empty) once it is older than interval. Downloaded list replaces the one
+// in use; if download fails, the list in use (embedded one, unless configured
+// otherwise) is kept. Refresh is done in background and is triggered by
+// incoming requests, so idle handler does not make any outgoing requests.
+func WithOembedProvidersRefresh(providersURL string, interval time.Duration) ConfFunc {
+	if providersURL == "" {
+		providersURL = DefaultOembedProvidersURL
+	}
+	return func(h *unfurlHandler) *unfurlHandler {
+		if interval > 0 {
+			h.providersRefresh = &providersRefresher{url: providersURL, interval: interval}
+		}
+		return h
+	}
+}
+
+// PreFetchHook is called for each url before it is processed. If it returns
+// non-nil override, this result is used as is instead of processing url; if
+// it returns skip=true, url is not processed and result only has URL
+// attribute set.
+type PreFetchHook func(ctx context.Context, url string) (skip bool, override *Result)
+
+// ResultHook is called for each url after it is processed, including results
+// taken from cache. Hook may modify result in place or return a new one;
+// if it returns nil, result is used as is.
+type ResultHook func(ctx context.Context, url string, r *Result) *Result
+
+// WithPreFetchHook configures unfurl handler to call fn before processing each
+// url. Multiple hooks are called in the order they were added until one of
+// them asks to skip url or returns override.
+func WithPreFetchHook(fn PreFetchHook) ConfFunc {
+	return func(h *unfurlHandler) *unfurlHandler {
+		if fn != nil {
+			h.preFetchHooks = append(h.preFetchHooks, fn)
+		}
+		return h
+	}
+}
+
+// WithResultHook configures unfurl handler to call fn on each result before
+// returning it to the client. Multiple hooks are called in the order they were
+// added, each one receiving result returned by the previous one.
+func WithResultHook(fn ResultHook) ConfFunc {
+	return func(h *unfurlHandler) *unfurlHandler {
+		if fn != nil {
+			h.resultHooks = append(h.resultHooks, fn)
+		}
+		return h
+	}
+}
+
+// WithImageRewriter configures unfurl handler to pass Image and Favicon urls
+// of each result through fn before returning it to the client, i.e. to route
+// them through CDN or image resizer. It is applied after hooks configured
+// with WithResultHook and doesn't affect cached results. If fn returns an
+// empty string, the url is removed from result.
+func WithImageRewriter(fn func(imageURL string) string) ConfFunc {
+	return func(h *unfurlHandler) *unfurlHandler {
+		if fn != nil {
+			h.imageRewriter = fn
+		}
+		return h
+	}
+}
+
 // WithLogger configures unfurl handler to use provided logger
 func WithLogger(l Logger) ConfFunc {
 	return func(h *unfurlHandler) *unfurlHandler {
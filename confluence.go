@@ -0,0 +1,149 @@
+package unfurlist
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// ConfluenceOptions configures ConfluenceFetcher
+type ConfluenceOptions struct {
+	// BaseURL is url of Confluence site, i.e.
+	// https://example.atlassian.net/wiki for Confluence Cloud or
+	// https://confluence.example.com for Server and Data Center
+	BaseURL string
+	// User and Token are credentials to access REST API with: for
+	// Confluence Cloud account email and API token, used with basic
+	// authentication; for Server and Data Center, if User is empty, Token
+	// is personal access token used as bearer token
+	User, Token string
+}
+
+var reConfluencePage = regexp.MustCompile(`^/spaces/([^/]+)/pages/(\d+)(?:/|$)`)
+
+// ConfluenceFetcher returns FetchFunc that gets title, space name and excerpt
+// of Confluence pages at opts.BaseURL from Confluence REST API, so pages
+// behind single sign-on can be previewed. It recognizes
+// /spaces/KEY/pages/ID/..., /pages/viewpage.action?pageId=ID and
+// /display/KEY/Title urls. It should be registered for host of BaseURL.
+func ConfluenceFetcher(opts ConfluenceOptions) FetchFunc {
+	base, err := url.Parse(strings.TrimSuffix(opts.BaseURL, "/"))
+	if err != nil || base.Host == "" || opts.Token == "" {
+		return func(context.Context, *http.Client, *url.URL) (*Metadata, bool) { return nil, false }
+	}
+	return func(ctx context.Context, client *http.Client, u *url.URL) (*Metadata, bool) {
+		if u == nil || !strings.EqualFold(u.Host, base.Host) {
+			return nil, false
+		}
+		p, ok := strings.CutPrefix(u.Path, base.Path)
+		if !ok {
+			return nil, false
+		}
+		api := base.String() + "/rest/api/content"
+		const expand = "space,history,body.view"
+		var pageURL string
+		switch {
+		case p == "/pages/viewpage.action" && u.Query().Get("pageId") != "":
+			pageURL = api + "/" + url.PathEscape(u.Query().Get("pageId")) + "?expand=" + expand
+		case reConfluencePage.MatchString(p):
+			pageURL = api + "/" + reConfluencePage.FindStringSubmatch(p)[2] + "?expand=" + expand
+		case strings.HasPrefix(p, "/display/"):
+			key, title, ok := strings.Cut(strings.TrimPrefix(p, "/display/"), "/")
+			if !ok || key == "" || title == "" || strings.Contains(title, "/") {
+				return nil, false
+			}
+			vals := url.Values{
+				"spaceKey": {key},
+				"title":    {strings.ReplaceAll(title, "+", " ")},
+				"expand":   {expand},
+			}
+			pageURL = api + "?" + vals.Encode()
+		default:
+			return nil, false
+		}
+		page, err := confluencePage(ctx, client, pageURL, opts)
+		if err != nil || page.Title == "" {
+			return nil, false
+		}
+		meta := &Metadata{
+			Title:       page.Title,
+			Type:        "article",
+			Description: confluenceExcerpt(page.Body.View.Value),
+			SiteName:    "Confluence",
+			Author:      page.History.CreatedBy.DisplayName,
+		}
+		if page.Space.Name != "" {
+			meta.SiteName = page.Space.Name + " - Confluence"
+		}
+		return meta, true
+	}
+}
+
+type confluenceContent struct {
+	Title string `json:"title"`
+	Space struct {
+		Name string `json:"name"`
+	} `json:"space"`
+	History struct {
+		CreatedBy struct {
+			DisplayName string `json:"displayName"`
+		} `json:"createdBy"`
+	} `json:"history"`
+	Body struct {
+		View struct {
+			Value string `json:"value"`
+		} `json:"view"`
+	} `json:"body"`
+}
+
+// confluencePage requests page from Confluence REST API. Search results
+// (having "results" list) are reduced to the first page found.
+func confluencePage(ctx context.Context, client *http.Client, apiURL string, opts ConfluenceOptions) (*confluenceContent, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, apiURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if opts.User != "" {
+		req.SetBasicAuth(opts.User, opts.Token)
+	} else {
+		req.Header.Set("Authorization", "Bearer "+opts.Token)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, &statusError{code: resp.StatusCode, status: resp.Status}
+	}
+	var out struct {
+		confluenceContent
+		Results []confluenceContent `json:"results"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 4<<20)).Decode(&out); err != nil {
+		return nil, err
+	}
+	if len(out.Results) != 0 {
+		return &out.Results[0], nil
+	}
+	return &out.confluenceContent, nil
+}
+
+// confluenceExcerpt returns beginning of text of page html body
+func confluenceExcerpt(body string) string {
+	doc, err := html.Parse(strings.NewReader(body))
+	if err != nil {
+		return ""
+	}
+	return excerpt(nodeText(doc), maxExcerpt)
+}
@@ -0,0 +1,63 @@
+package unfurlist
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestConfluenceFetcher(t *testing.T) {
+	// single sign-on login page
+	login := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><head><title>Log in</title></head><body><form><input type="password"></form></body></html>`))
+	}))
+	defer login.Close()
+	page := `{"title":"Release process","space":{"name":"Engineering"},
+"history":{"createdBy":{"displayName":"Jane Doe"}},
+"body":{"view":{"value":"<h1>Overview</h1><p>How we ` + strings.Repeat("ship ", 100) + `</p>"}}}`
+	wiki := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/wiki/rest/api/content/123":
+		case r.URL.Path == "/wiki/rest/api/content" && r.URL.Query().Get("title") == "Release process":
+			page = `{"results":[` + page + `]}`
+		case strings.HasPrefix(r.URL.Path, "/wiki/rest/"):
+			http.NotFound(w, r)
+			return
+		default:
+			http.Redirect(w, r, login.URL+"/login", http.StatusFound)
+			return
+		}
+		if user, pass, _ := r.BasicAuth(); user != "bot@example.com" || pass != "secret" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(page))
+	}))
+	defer wiki.Close()
+
+	h := New(WithFetchers(ConfluenceFetcher(ConfluenceOptions{
+		BaseURL: wiki.URL + "/wiki/",
+		User:    "bot@example.com",
+		Token:   "secret",
+	}))).(*unfurlHandler)
+	for _, link := range []string{
+		wiki.URL + "/wiki/spaces/ENG/pages/123/Release+process",
+		wiki.URL + "/wiki/pages/viewpage.action?pageId=123",
+		wiki.URL + "/wiki/display/ENG/Release+process",
+	} {
+		res := h.processURL(context.Background(), link)
+		if res.Title != "Release process" || res.SiteName != "Engineering - Confluence" || res.Author != "Jane Doe" {
+			t.Fatalf("%s: unexpected result: %+v", link, res)
+		}
+		if !strings.HasPrefix(res.Description, "Overview How we ship ship") || !strings.HasSuffix(res.Description, "ship…") {
+			t.Fatalf("%s: unexpected description: %q", link, res.Description)
+		}
+	}
+	if res := h.processURL(context.Background(), wiki.URL+"/wiki/spaces/ENG/overview"); res.Title != "" || res.err == nil {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+}
@@ -0,0 +1,119 @@
+package unfurlist
+
+import (
+	"path"
+	"strings"
+)
+
+// Sources of page description for WithDescriptionSources
+const (
+	DescriptionOpenGraph = "og"      // og:description meta tag
+	DescriptionTwitter   = "twitter" // twitter:description meta tag
+	DescriptionMeta      = "meta"    // description meta tag
+	DescriptionJSONLD    = "jsonld"  // description of the first JSON-LD entity having one
+)
+
+// descriptionRule is a parsed entry of the list configured with
+// WithDescriptionSources
+type descriptionRule struct {
+	domain  string // host glob the rule is limited to, empty for any host
+	sources []string
+}
+
+// WithDescriptionSources configures unfurl handler to take description of
+// html pages from the first non-empty of listed sources. Each rule is a
+// comma-separated list of sources (see Description* constants), optionally
+// prefixed with @host.glob and space to only apply it to matching hosts, i.e.
+// "@*.example.com meta,og". The first matching rule is used, so rules for
+// specific hosts should go before the global one. Invalid rules and unknown
+// sources are ignored. Without matching rule, description is taken from
+// og:description, falling back to description meta tag. Descriptions
+// provided by oEmbed and fetchers are not affected.
+func WithDescriptionSources(rules []string) ConfFunc {
+	var parsed []descriptionRule
+	for _, s := range rules {
+		var r descriptionRule
+		if rest, ok := strings.CutPrefix(strings.TrimSpace(s), "@"); ok {
+			domain, list, ok := strings.Cut(rest, " ")
+			if !ok {
+				continue
+			}
+			domain = strings.ToLower(domain)
+			if _, err := path.Match(domain, ""); err != nil {
+				continue
+			}
+			r.domain, s = domain, list
+		}
+		for _, src := range strings.Split(s, ",") {
+			switch src = strings.TrimSpace(src); src {
+			case DescriptionOpenGraph, DescriptionTwitter, DescriptionMeta, DescriptionJSONLD:
+				r.sources = append(r.sources, src)
+			}
+		}
+		if len(r.sources) != 0 {
+			parsed = append(parsed, r)
+		}
+	}
+	return func(h *unfurlHandler) *unfurlHandler {
+		h.descriptionRules = parsed
+		return h
+	}
+}
+
+// pageDescription returns description of the page on host according to
+// rules configured with WithDescriptionSources. It returns false if no rule
+// matches host, or page has none of listed sources.
+func (h *unfurlHandler) pageDescription(host string, meta map[string]string, ld []any) (string, bool) {
+	host = strings.ToLower(host)
+	if hst, _, ok := strings.Cut(host, ":"); ok && !strings.HasPrefix(host, "[") {
+		host = hst
+	}
+	for _, r := range h.descriptionRules {
+		if r.domain != "" {
+			if ok, _ := path.Match(r.domain, host); !ok {
+				continue
+			}
+		}
+		for _, src := range r.sources {
+			var s string
+			switch src {
+			case DescriptionOpenGraph:
+				s = meta["og:description"]
+			case DescriptionTwitter:
+				s = meta["twitter:description"]
+			case DescriptionMeta:
+				s = meta["description"]
+			case DescriptionJSONLD:
+				s = jsonLDDescription(ld)
+			}
+			if s != "" {
+				return s, true
+			}
+		}
+		return "", false
+	}
+	return "", false
+}
+
+// jsonLDDescription returns description of the first entity in decoded
+// JSON-LD v having one
+func jsonLDDescription(v any) string {
+	switch v := v.(type) {
+	case map[string]any:
+		if s := jsonLDString(v["description"]); s != "" {
+			return s
+		}
+		for _, k := range [...]string{"@graph", "mainEntity"} {
+			if s := jsonLDDescription(v[k]); s != "" {
+				return s
+			}
+		}
+	case []any:
+		for _, val := range v {
+			if s := jsonLDDescription(val); s != "" {
+				return s
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,46 @@
+package unfurlist
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestDescriptionSources(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/favicon.ico" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><head><title>Page</title>
+<meta property="og:title" content="Page">
+<meta property="og:description" content="Marketing">
+<meta name="description" content="Summary">
+<script type="application/ld+json">{"@graph":[{"@type":"WebSite"},{"@type":"Article","description":"Abstract"}]}</script>
+</head></html>`))
+	}))
+	defer srv.Close()
+	for _, tc := range []struct {
+		rules []string
+		want  string
+	}{
+		{nil, "Marketing"},
+		{[]string{"meta,og"}, "Summary"},
+		{[]string{"twitter,jsonld"}, "Abstract"},
+		{[]string{"twitter"}, "Marketing"},
+		{[]string{"@other.example.com jsonld", "@127.0.0.* bogus,meta", "og"}, "Summary"},
+	} {
+		w := httptest.NewRecorder()
+		New(WithDescriptionSources(tc.rules)).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/?content="+url.QueryEscape(srv.URL), nil))
+		var res []Result
+		if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+			t.Fatal(err)
+		}
+		if len(res) != 1 || res[0].Description != tc.want {
+			t.Errorf("rules %q: got %+v, want description %q", tc.rules, res, tc.want)
+		}
+	}
+}
@@ -0,0 +1,183 @@
+package unfurlist
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DiskCache is Cache keeping values in files under a directory, so they
+// survive restarts. It's meant for single-instance deployments without
+// memcached. Once total size of stored values goes over the limit, least
+// recently used ones are removed.
+type DiskCache struct {
+	dir      string
+	maxBytes int64
+	size     atomic.Int64
+	evicting sync.Mutex
+}
+
+// NewDiskCache returns DiskCache storing values under dir, creating it if
+// needed, and keeping their total size under maxBytes.
+func NewDiskCache(dir string, maxBytes int64) (*DiskCache, error) {
+	if maxBytes <= 0 {
+		return nil, errors.New("disk cache size limit must be positive")
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	c := &DiskCache{dir: dir, maxBytes: maxBytes}
+	files, err := c.files()
+	if err != nil {
+		return nil, err
+	}
+	var size int64
+	for _, f := range files {
+		size += f.size
+	}
+	c.size.Store(size)
+	return c, nil
+}
+
+// path returns name of the file to keep key value in
+func (c *DiskCache) path(key string) string {
+	sum := sha1.Sum([]byte(key))
+	name := hex.EncodeToString(sum[:])
+	return filepath.Join(c.dir, name[:2], name)
+}
+
+// Value files start with 8 bytes of big endian expiration time in unix
+// seconds, zero if value does not expire.
+const diskCacheHeaderSize = 8
+
+func (c *DiskCache) Get(ctx context.Context, key string) ([]byte, error) {
+	name := c.path(key)
+	data, err := os.ReadFile(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrCacheMiss
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < diskCacheHeaderSize {
+		c.remove(name)
+		return nil, ErrCacheMiss
+	}
+	now := time.Now()
+	if exp := int64(binary.BigEndian.Uint64(data)); exp != 0 && now.Unix() >= exp {
+		c.remove(name)
+		return nil, ErrCacheMiss
+	}
+	// modification time is used as last access time for eviction
+	_ = os.Chtimes(name, now, now)
+	return data[diskCacheHeaderSize:], nil
+}
+
+func (c *DiskCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	data := make([]byte, diskCacheHeaderSize+len(value))
+	if ttl > 0 {
+		binary.BigEndian.PutUint64(data, uint64(time.Now().Add(ttl).Unix()))
+	}
+	copy(data[diskCacheHeaderSize:], value)
+	name := c.path(key)
+	if err := os.MkdirAll(filepath.Dir(name), 0o700); err != nil {
+		return err
+	}
+	tf, err := os.CreateTemp(filepath.Dir(name), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tf.Name())
+	if _, err := tf.Write(data); err != nil {
+		tf.Close()
+		return err
+	}
+	if err := tf.Close(); err != nil {
+		return err
+	}
+	var oldSize int64
+	if fi, err := os.Stat(name); err == nil {
+		oldSize = fi.Size()
+	}
+	if err := os.Rename(tf.Name(), name); err != nil {
+		return err
+	}
+	if c.size.Add(int64(len(data))-oldSize) > c.maxBytes && c.evicting.TryLock() {
+		go func() {
+			defer c.evicting.Unlock()
+			c.evict()
+		}()
+	}
+	return nil
+}
+
+func (c *DiskCache) remove(name string) {
+	fi, err := os.Stat(name)
+	if err != nil {
+		return
+	}
+	if os.Remove(name) == nil {
+		c.size.Add(-fi.Size())
+	}
+}
+
+type diskCacheFile struct {
+	name  string
+	size  int64
+	mtime time.Time
+}
+
+// files returns all value files in cache directory
+func (c *DiskCache) files() ([]diskCacheFile, error) {
+	var out []diskCacheFile
+	err := filepath.WalkDir(c.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || d.Name()[0] == '.' {
+			return nil
+		}
+		fi, err := d.Info()
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		out = append(out, diskCacheFile{name: path, size: fi.Size(), mtime: fi.ModTime()})
+		return nil
+	})
+	return out, err
+}
+
+// evict removes least recently used files until total size is below 90% of
+// the limit
+func (c *DiskCache) evict() {
+	files, err := c.files()
+	if err != nil {
+		return
+	}
+	var size int64
+	for _, f := range files {
+		size += f.size
+	}
+	slices.SortFunc(files, func(a, b diskCacheFile) int { return a.mtime.Compare(b.mtime) })
+	for _, f := range files {
+		if size <= c.maxBytes/10*9 {
+			break
+		}
+		if os.Remove(f.name) == nil {
+			size -= f.size
+		}
+	}
+	c.size.Store(size)
+}
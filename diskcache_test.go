@@ -0,0 +1,59 @@
+package unfurlist
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestDiskCache(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+	c, err := NewDiskCache(dir, 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Set(ctx, "a", []byte("value"), 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Set(ctx, "b", []byte("value"), time.Nanosecond); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Second)
+	if _, err := c.Get(ctx, "b"); err != ErrCacheMiss {
+		t.Fatalf("expired value: got %v, want ErrCacheMiss", err)
+	}
+	// values survive reopening
+	if c, err = NewDiskCache(dir, 1<<20); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := c.Get(ctx, "a"); err != nil || string(v) != "value" {
+		t.Fatalf("got %q, %v", v, err)
+	}
+	if got, want := c.size.Load(), int64(diskCacheHeaderSize+len("value")); got != want {
+		t.Fatalf("size: got %d, want %d", got, want)
+	}
+}
+
+func TestDiskCacheEviction(t *testing.T) {
+	ctx := context.Background()
+	c, err := NewDiskCache(t.TempDir(), 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	value := make([]byte, 100-diskCacheHeaderSize)
+	for i := 0; i < 20; i++ {
+		if err := c.Set(ctx, fmt.Sprint(i), value, 0); err != nil {
+			t.Fatal(err)
+		}
+		c.evicting.Lock() // wait for eviction to finish
+		c.evicting.Unlock()
+	}
+	if size := c.size.Load(); size > 1000 {
+		t.Fatalf("cache size %d is over the limit", size)
+	}
+	if _, err := c.Get(ctx, "19"); err != nil {
+		t.Fatalf("recently stored value: %v", err)
+	}
+}
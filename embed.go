@@ -0,0 +1,52 @@
+package unfurlist
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+var (
+	reYoutubeID = regexp.MustCompile(`^[A-Za-z0-9_-]{11}$`)
+	reVimeoID   = regexp.MustCompile(`^[0-9]{1,12}$`)
+	reLoomID    = regexp.MustCompile(`^[0-9a-f]{32}$`)
+)
+
+// embedHTML returns iframe snippet embedding video at u if u is a video url
+// of YouTube, Vimeo or Loom, otherwise empty string. Video id is validated,
+// so snippet is safe to use as is.
+func embedHTML(u *url.URL) string {
+	host := strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+	first, rest, _ := strings.Cut(strings.TrimPrefix(u.Path, "/"), "/")
+	switch host {
+	case "youtube.com", "m.youtube.com":
+		id := u.Query().Get("v")
+		switch first {
+		case "watch":
+		case "shorts", "embed", "live":
+			id, _, _ = strings.Cut(rest, "/")
+		default:
+			return ""
+		}
+		if reYoutubeID.MatchString(id) {
+			return `<iframe width="560" height="315" src="https://www.youtube.com/embed/` + id +
+				`" frameborder="0" allow="accelerometer; autoplay; clipboard-write; encrypted-media; gyroscope; picture-in-picture" allowfullscreen></iframe>`
+		}
+	case "youtu.be":
+		if reYoutubeID.MatchString(first) && rest == "" {
+			return `<iframe width="560" height="315" src="https://www.youtube.com/embed/` + first +
+				`" frameborder="0" allow="accelerometer; autoplay; clipboard-write; encrypted-media; gyroscope; picture-in-picture" allowfullscreen></iframe>`
+		}
+	case "vimeo.com":
+		if reVimeoID.MatchString(first) {
+			return `<iframe src="https://player.vimeo.com/video/` + first +
+				`" width="640" height="360" frameborder="0" allow="autoplay; fullscreen; picture-in-picture" allowfullscreen></iframe>`
+		}
+	case "loom.com":
+		if id, _, _ := strings.Cut(rest, "/"); first == "share" && reLoomID.MatchString(id) {
+			return `<iframe src="https://www.loom.com/embed/` + id +
+				`" width="640" height="360" frameborder="0" allowfullscreen></iframe>`
+		}
+	}
+	return ""
+}
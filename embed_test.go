@@ -0,0 +1,36 @@
+package unfurlist
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestEmbedHTML(t *testing.T) {
+	for link, want := range map[string]string{
+		"https://www.youtube.com/watch?v=dQw4w9WgXcQ&t=42":            "https://www.youtube.com/embed/dQw4w9WgXcQ",
+		"https://youtu.be/dQw4w9WgXcQ":                                "https://www.youtube.com/embed/dQw4w9WgXcQ",
+		"https://youtube.com/shorts/dQw4w9WgXcQ":                      "https://www.youtube.com/embed/dQw4w9WgXcQ",
+		"https://vimeo.com/76979871":                                  "https://player.vimeo.com/video/76979871",
+		"https://www.loom.com/share/0123456789abcdef0123456789abcdef": "https://www.loom.com/embed/0123456789abcdef0123456789abcdef",
+		"https://www.youtube.com/watch?v=bad\"id<script>":             "",
+		"https://www.youtube.com/feed/trending":                       "",
+		"https://vimeo.com/channels/staffpicks":                       "",
+		"https://example.com/watch?v=dQw4w9WgXcQ":                     "",
+	} {
+		u, err := url.Parse(link)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := embedHTML(u)
+		if want == "" {
+			if got != "" {
+				t.Errorf("%s: unexpected embed %q", link, got)
+			}
+			continue
+		}
+		if !strings.HasPrefix(got, "<iframe") || !strings.Contains(got, `src="`+want+`"`) {
+			t.Errorf("%s: got %q, want iframe with %q", link, got, want)
+		}
+	}
+}
@@ -0,0 +1,322 @@
+package unfurlist
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Media types of supported response encodings
+const (
+	mediaJSON     = "application/json"
+	mediaMsgpack  = "application/msgpack"
+	mediaProtobuf = "application/protobuf"
+)
+
+// negotiateEncoding returns media type of response encoding best matching
+// Accept header value. JSON is used by default.
+func negotiateEncoding(accept string) string {
+	best, bestQ := mediaJSON, 0.0
+	for _, s := range strings.Split(accept, ",") {
+		mt, params, _ := strings.Cut(s, ";")
+		q := 1.0
+		for _, p := range strings.Split(params, ";") {
+			if k, v, ok := strings.Cut(strings.TrimSpace(p), "="); ok && k == "q" {
+				if f, err := strconv.ParseFloat(v, 64); err == nil {
+					q = f
+				}
+			}
+		}
+		var media string
+		switch strings.ToLower(strings.TrimSpace(mt)) {
+		case "application/json":
+			media = mediaJSON
+		case "application/msgpack", "application/x-msgpack", "application/vnd.msgpack":
+			media = mediaMsgpack
+		case "application/protobuf", "application/x-protobuf", "application/vnd.google.protobuf":
+			media = mediaProtobuf
+		default:
+			continue
+		}
+		if q > bestQ {
+			best, bestQ = media, q
+		}
+	}
+	return best
+}
+
+// structField describes exported struct field as seen by encoders
+type structField struct {
+	index     int
+	name      string // name from json tag
+	omitEmpty bool
+	pbNum     int // protobuf field number from pb tag, 0 if not set
+}
+
+// structFields returns exported fields of struct type t that are visible to
+// encoding/json, with their names and options taken from json and pb tags.
+func structFields(t reflect.Type) []structField {
+	var out []structField
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if name == "" {
+			name = f.Name
+		}
+		sf := structField{index: i, name: name, omitEmpty: opts == "omitempty"}
+		if n, err := strconv.Atoi(f.Tag.Get("pb")); err == nil {
+			sf.pbNum = n
+		}
+		out = append(out, sf)
+	}
+	return out
+}
+
+// encodeMsgpack appends MessagePack encoding of v to buf. Structs are encoded
+// as maps using the same keys and omitempty rules as encoding/json.
+func encodeMsgpack(buf *bytes.Buffer, v reflect.Value) error {
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			buf.WriteByte(0xc0)
+			return nil
+		}
+		return encodeMsgpack(buf, v.Elem())
+	case reflect.Bool:
+		if v.Bool() {
+			buf.WriteByte(0xc3)
+		} else {
+			buf.WriteByte(0xc2)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		msgpackInt(buf, v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if u := v.Uint(); u > math.MaxInt64 {
+			buf.WriteByte(0xcf)
+			binary.Write(buf, binary.BigEndian, u)
+		} else {
+			msgpackInt(buf, int64(u))
+		}
+	case reflect.Float32, reflect.Float64:
+		buf.WriteByte(0xcb)
+		binary.Write(buf, binary.BigEndian, v.Float())
+	case reflect.String:
+		msgpackString(buf, v.String())
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			buf.WriteByte(0xc0)
+			return nil
+		}
+		msgpackHeader(buf, v.Len(), 0x90, 0xdc)
+		for i := 0; i < v.Len(); i++ {
+			if err := encodeMsgpack(buf, v.Index(i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		if v.IsNil() {
+			buf.WriteByte(0xc0)
+			return nil
+		}
+		if v.Type().Key().Kind() != reflect.String {
+			return fmt.Errorf("unsupported map key type %v", v.Type().Key())
+		}
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+		msgpackHeader(buf, len(keys), 0x80, 0xde)
+		for _, k := range keys {
+			msgpackString(buf, k.String())
+			if err := encodeMsgpack(buf, v.MapIndex(k)); err != nil {
+				return err
+			}
+		}
+	case reflect.Struct:
+		var fields []structField
+		for _, f := range structFields(v.Type()) {
+			if f.omitEmpty && v.Field(f.index).IsZero() {
+				continue
+			}
+			fields = append(fields, f)
+		}
+		msgpackHeader(buf, len(fields), 0x80, 0xde)
+		for _, f := range fields {
+			msgpackString(buf, f.name)
+			if err := encodeMsgpack(buf, v.Field(f.index)); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("unsupported type %v", v.Type())
+	}
+	return nil
+}
+
+func msgpackInt(buf *bytes.Buffer, n int64) {
+	switch {
+	case n >= 0 && n <= 0x7f:
+		buf.WriteByte(byte(n))
+	case n < 0 && n >= -32:
+		buf.WriteByte(byte(int8(n)))
+	case n > 0 && n <= math.MaxUint8:
+		buf.WriteByte(0xcc)
+		buf.WriteByte(byte(n))
+	case n > 0 && n <= math.MaxUint16:
+		buf.WriteByte(0xcd)
+		binary.Write(buf, binary.BigEndian, uint16(n))
+	case n > 0 && n <= math.MaxUint32:
+		buf.WriteByte(0xce)
+		binary.Write(buf, binary.BigEndian, uint32(n))
+	case n >= math.MinInt32 && n < 0:
+		buf.WriteByte(0xd2)
+		binary.Write(buf, binary.BigEndian, int32(n))
+	default:
+		buf.WriteByte(0xd3)
+		binary.Write(buf, binary.BigEndian, n)
+	}
+}
+
+func msgpackString(buf *bytes.Buffer, s string) {
+	switch n := len(s); {
+	case n <= 31:
+		buf.WriteByte(0xa0 | byte(n))
+	case n <= math.MaxUint8:
+		buf.WriteByte(0xd9)
+		buf.WriteByte(byte(n))
+	case n <= math.MaxUint16:
+		buf.WriteByte(0xda)
+		binary.Write(buf, binary.BigEndian, uint16(n))
+	default:
+		buf.WriteByte(0xdb)
+		binary.Write(buf, binary.BigEndian, uint32(n))
+	}
+	buf.WriteString(s)
+}
+
+// msgpackHeader writes array or map header for n elements; fix is the fixarray
+// or fixmap type byte, code16 is the array16 or map16 one (code32 follows it).
+func msgpackHeader(buf *bytes.Buffer, n int, fix, code16 byte) {
+	switch {
+	case n <= 15:
+		buf.WriteByte(fix | byte(n))
+	case n <= math.MaxUint16:
+		buf.WriteByte(code16)
+		binary.Write(buf, binary.BigEndian, uint16(n))
+	default:
+		buf.WriteByte(code16 + 1)
+		binary.Write(buf, binary.BigEndian, uint32(n))
+	}
+}
+
+// encodeProtobuf appends protobuf encoding of struct v to buf. Only fields
+// having pb tag with field number are encoded, zero values are skipped as
+// in proto3. See unfurlist.proto for the schema.
+func encodeProtobuf(buf *bytes.Buffer, v reflect.Value) error {
+	for v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return fmt.Errorf("unsupported type %v", v.Type())
+	}
+	for _, f := range structFields(v.Type()) {
+		if f.pbNum == 0 {
+			continue
+		}
+		fv := v.Field(f.index)
+		if fv.IsZero() {
+			continue
+		}
+		if err := protobufField(buf, f.pbNum, fv); err != nil {
+			return fmt.Errorf("field %s: %w", f.name, err)
+		}
+	}
+	return nil
+}
+
+func protobufField(buf *bytes.Buffer, num int, v reflect.Value) error {
+	const (
+		wireVarint = 0
+		wire64bit  = 1
+		wireBytes  = 2
+	)
+	key := func(wireType int) { protobufVarint(buf, uint64(num)<<3|uint64(wireType)) }
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() {
+			return nil
+		}
+		return protobufField(buf, num, v.Elem())
+	case reflect.Bool:
+		key(wireVarint)
+		if v.Bool() {
+			protobufVarint(buf, 1)
+		} else {
+			protobufVarint(buf, 0)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		key(wireVarint)
+		protobufVarint(buf, uint64(v.Int()))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		key(wireVarint)
+		protobufVarint(buf, v.Uint())
+	case reflect.Float32, reflect.Float64:
+		key(wire64bit)
+		binary.Write(buf, binary.LittleEndian, v.Float())
+	case reflect.String:
+		key(wireBytes)
+		protobufVarint(buf, uint64(v.Len()))
+		buf.WriteString(v.String())
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			if err := protobufField(buf, num, v.Index(i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		// maps are encoded as repeated key-value entry messages
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool { return fmt.Sprint(keys[i]) < fmt.Sprint(keys[j]) })
+		for _, k := range keys {
+			entry := new(bytes.Buffer)
+			if err := protobufField(entry, 1, k); err != nil {
+				return err
+			}
+			if err := protobufField(entry, 2, v.MapIndex(k)); err != nil {
+				return err
+			}
+			key(wireBytes)
+			protobufVarint(buf, uint64(entry.Len()))
+			buf.Write(entry.Bytes())
+		}
+	case reflect.Struct:
+		msg := new(bytes.Buffer)
+		if err := encodeProtobuf(msg, v); err != nil {
+			return err
+		}
+		key(wireBytes)
+		protobufVarint(buf, uint64(msg.Len()))
+		buf.Write(msg.Bytes())
+	default:
+		return fmt.Errorf("unsupported type %v", v.Type())
+	}
+	return nil
+}
+
+func protobufVarint(buf *bytes.Buffer, x uint64) {
+	var b [binary.MaxVarintLen64]byte
+	buf.Write(b[:binary.PutUvarint(b[:], x)])
+}
@@ -0,0 +1,90 @@
+package unfurlist
+
+import (
+	"bytes"
+	"encoding/hex"
+	"os"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	for accept, want := range map[string]string{
+		"":                       mediaJSON,
+		"*/*":                    mediaJSON,
+		"application/msgpack":    mediaMsgpack,
+		"application/x-protobuf": mediaProtobuf,
+		"application/json;q=0.5, application/msgpack": mediaMsgpack,
+		"application/msgpack;q=0.2, application/json": mediaJSON,
+	} {
+		if got := negotiateEncoding(accept); got != want {
+			t.Errorf("Accept %q: got %q, want %q", accept, got, want)
+		}
+	}
+}
+
+func TestEncodeMsgpack(t *testing.T) {
+	res := unfurlResults{{URL: "u", ImageWidth: 300}}
+	buf := new(bytes.Buffer)
+	if err := encodeMsgpack(buf, reflect.ValueOf(res)); err != nil {
+		t.Fatal(err)
+	}
+	// [{"url": "u", "image_width": 300}]
+	want := "91" + "82" + "a375726c" + "a175" + "ab696d6167655f7769647468" + "cd012c"
+	if got := hex.EncodeToString(buf.Bytes()); got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+}
+
+func TestEncodeProtobuf(t *testing.T) {
+	buf := new(bytes.Buffer)
+	if err := encodeProtobuf(buf, reflect.ValueOf(&Result{URL: "u", ImageWidth: 300})); err != nil {
+		t.Fatal(err)
+	}
+	want := "0a0175" + "48ac02"
+	if got := hex.EncodeToString(buf.Bytes()); got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+}
+
+// TestProtobufSchema ensures that unfurlist.proto describes all fields of
+// encoded types
+func TestProtobufSchema(t *testing.T) {
+	data, err := os.ReadFile("unfurlist.proto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, typ := range map[string]reflect.Type{
+		"Response":    reflect.TypeOf(Envelope{}),
+		"ResultError": reflect.TypeOf(ResultError{}),
+		"Result":      reflect.TypeOf(Result{}),
+	} {
+		_, msg, ok := strings.Cut(string(data), "message "+name+" {")
+		if !ok {
+			t.Errorf("no %s message in schema", name)
+			continue
+		}
+		msg, _, _ = strings.Cut(msg, "}")
+		schema := make(map[string]int)
+		for _, m := range regexp.MustCompile(`(?m)^\s*(?:repeated\s+)?[\w.<>, ]+\s+(\w+)\s*=\s*(\d+);`).FindAllStringSubmatch(msg, -1) {
+			n, _ := strconv.Atoi(m[2])
+			schema[m[1]] = n
+		}
+		fields := structFields(typ)
+		if len(schema) != len(fields) {
+			t.Errorf("%s: schema has %d fields, type has %d", name, len(schema), len(fields))
+		}
+		for _, f := range fields {
+			if f.pbNum == 0 {
+				t.Errorf("%s: field %q has no pb tag", name, f.name)
+				continue
+			}
+			if n := schema[f.name]; n != f.pbNum {
+				t.Errorf("%s: field %q has number %d in schema, %d in pb tag", name, f.name, n, f.pbNum)
+			}
+		}
+	}
+}
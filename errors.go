@@ -0,0 +1,104 @@
+package unfurlist
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strconv"
+)
+
+// ErrorCategory classifies url processing failures
+type ErrorCategory string
+
+// Error categories of ProcessingError and ResultError
+const (
+	CategoryFetch   ErrorCategory = "fetch"   // network or protocol failure fetching url
+	CategoryStatus  ErrorCategory = "status"  // remote server replied with error status
+	CategoryTimeout ErrorCategory = "timeout" // url was not fetched in time
+	CategoryOembed  ErrorCategory = "oembed"  // oembed endpoint failure
+	CategoryImage   ErrorCategory = "image"   // failure to get image dimensions
+	CategoryParse   ErrorCategory = "parse"   // malformed metadata, i.e. invalid image url
+
+	CategoryBotProtection ErrorCategory = "bot_protection" // captcha or interstitial page, see ErrBotProtection
+	CategoryBlocked       ErrorCategory = "blocked"        // url is blocklisted or known to be dangerous
+)
+
+// ProcessingError describes url processing failure reported to ErrorReporter
+type ProcessingError struct {
+	Category ErrorCategory
+	Err      error
+}
+
+func (e *ProcessingError) Error() string { return string(e.Category) + ": " + e.Err.Error() }
+func (e *ProcessingError) Unwrap() error { return e.Err }
+
+// ErrorReporter is called on url processing failures, err is always
+// *ProcessingError. Some failures are not fatal: i.e. url may still have
+// result if its oembed endpoint failed, but page itself has Open Graph
+// metadata.
+type ErrorReporter func(ctx context.Context, url string, err error)
+
+// WithErrorReporter configures unfurl handler to call fn on url processing
+// failures, i.e. to send them to an error tracking service. Multiple
+// reporters are called in the order they were added. Reporters are called
+// synchronously, so they should not block.
+func WithErrorReporter(fn ErrorReporter) ConfFunc {
+	return func(h *unfurlHandler) *unfurlHandler {
+		if fn != nil {
+			h.errorReporters = append(h.errorReporters, fn)
+		}
+		return h
+	}
+}
+
+// reportError passes err to configured error reporters. Errors caused by
+// canceled ctx are not reported, as they're caused by client going away.
+func (h *unfurlHandler) reportError(ctx context.Context, url string, category ErrorCategory, err error) {
+	if len(h.errorReporters) == 0 || errors.Is(err, context.Canceled) {
+		return
+	}
+	perr := &ProcessingError{Category: category, Err: err}
+	for _, fn := range h.errorReporters {
+		fn(ctx, url, perr)
+	}
+}
+
+// fetchErrorCategory returns category of error returned by fetchData
+func fetchErrorCategory(err error) ErrorCategory {
+	var se *statusError
+	var ne net.Error
+	switch {
+	case errors.Is(err, ErrBotProtection):
+		return CategoryBotProtection
+	case errors.As(err, &se):
+		return CategoryStatus
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &ne) && ne.Timeout():
+		return CategoryTimeout
+	}
+	return CategoryFetch
+}
+
+// resultErrorCategory returns category of result processing error as
+// reported in ResultError
+func resultErrorCategory(err error) ErrorCategory {
+	switch {
+	case errors.Is(err, errBlocklisted), errors.Is(err, errDangerousURL):
+		return CategoryBlocked
+	case errors.Is(err, errTimeout):
+		return CategoryTimeout
+	}
+	return fetchErrorCategory(err)
+}
+
+// statusError is returned on http responses with error status
+type statusError struct {
+	code   int
+	status string
+}
+
+func (e *statusError) Error() string {
+	if e.status == "" {
+		return "bad status: " + strconv.Itoa(e.code)
+	}
+	return "bad status: " + e.status
+}
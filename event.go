@@ -0,0 +1,86 @@
+package unfurlist
+
+import (
+	"strings"
+	"time"
+)
+
+// pageEvent describes schema.org Event found in JSON-LD of html page
+type pageEvent struct {
+	name     string
+	start    string // RFC 3339 time, or date
+	location string
+}
+
+// findEvent returns the first schema.org Event (or its subtype, like
+// MusicEvent) found in JSON-LD blocks ld
+func findEvent(ld []any) (pageEvent, bool) {
+	isEvent := func(t string) bool { return strings.HasSuffix(t, "Event") }
+	for _, v := range ld {
+		obj := jsonLDTyped(v, isEvent)
+		if obj == nil {
+			continue
+		}
+		ev := pageEvent{
+			name:     jsonLDString(obj["name"]),
+			start:    normalizeEventStart(jsonLDString(obj["startDate"])),
+			location: eventLocation(obj["location"]),
+		}
+		if ev.start != "" || ev.location != "" {
+			return ev, true
+		}
+	}
+	return pageEvent{}, false
+}
+
+// normalizeEventStart returns RFC 3339 time or date from ISO 8601 startDate
+// value. Values without time zone are returned as is.
+func normalizeEventStart(s string) string {
+	for _, layout := range [...]string{time.RFC3339, "2006-01-02T15:04Z07:00"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.Format(time.RFC3339)
+		}
+	}
+	return s
+}
+
+// eventLocation returns human-readable schema.org Event location: name and
+// address of a Place, url of a VirtualLocation, or text
+func eventLocation(v any) string {
+	switch v := v.(type) {
+	case string:
+		return strings.TrimSpace(v)
+	case []any:
+		for _, val := range v {
+			if s := eventLocation(val); s != "" {
+				return s
+			}
+		}
+	case map[string]any:
+		var parts []string
+		add := func(s string) {
+			for _, p := range parts {
+				if p == s {
+					return
+				}
+			}
+			if s != "" {
+				parts = append(parts, s)
+			}
+		}
+		add(jsonLDString(v["name"]))
+		switch addr := v["address"].(type) {
+		case string:
+			add(strings.TrimSpace(addr))
+		case map[string]any:
+			for _, k := range [...]string{"streetAddress", "addressLocality", "addressRegion", "addressCountry"} {
+				add(jsonLDString(addr[k]))
+			}
+		}
+		if len(parts) == 0 {
+			add(jsonLDString(v["url"]))
+		}
+		return strings.Join(parts, ", ")
+	}
+	return ""
+}
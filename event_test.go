@@ -0,0 +1,32 @@
+package unfurlist
+
+import "testing"
+
+func TestFindEvent(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		page string
+		want pageEvent
+	}{
+		{"place", `<script type="application/ld+json">
+{"@context":"https://schema.org","@type":"MusicEvent","name":"Concert","startDate":"2024-07-02T19:30+02:00",
+"location":{"@type":"Place","name":"Hall","address":{"@type":"PostalAddress","streetAddress":"Main St 1","addressLocality":"Berlin","addressCountry":"DE"}}}
+</script>`, pageEvent{"Concert", "2024-07-02T19:30:00+02:00", "Hall, Main St 1, Berlin, DE"}},
+		{"virtual", `<script type="application/ld+json">
+{"@graph":[{"@type":"Organization"},{"@type":["Event","SocialEvent"],"name":"Meetup","startDate":"2024-07-02",
+"location":{"@type":"VirtualLocation","url":"https://example.com/live"}}]}
+</script>`, pageEvent{"Meetup", "2024-07-02", "https://example.com/live"}},
+		{"local time", `<script type="application/ld+json">
+[{"@type":"Event","startDate":"2024-07-02T18:00","location":"Town square"}]
+</script>`, pageEvent{"", "2024-07-02T18:00", "Town square"}},
+	} {
+		chunk := &pageChunk{ct: "text/html", data: []byte(tc.page)}
+		if got, ok := findEvent(jsonLD(chunk)); !ok || got != tc.want {
+			t.Errorf("%s: got %+v, want %+v", tc.name, got, tc.want)
+		}
+	}
+	chunk := &pageChunk{ct: "text/html", data: []byte(`<script type="application/ld+json">{"@type":"Article"}</script>`)}
+	if ev, ok := findEvent(jsonLD(chunk)); ok {
+		t.Errorf("unexpected event found: %+v", ev)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"net/http"
 	"net/url"
+	"strings"
 )
 
 // FetchFunc defines custom metadata fetchers that can be attached to unfurl
@@ -19,9 +20,88 @@ type Metadata struct {
 	Image       string // image/thumbnail url
 	ImageWidth  int
 	ImageHeight int
+
+	SiteName     string
+	Favicon      string // favicon url, overrides one discovered from the page
+	HTML         string // html snippet to embed resource
+	CanonicalURL string
+	Author       string
+	Duration     int // video duration in seconds
 }
 
 // Valid check that at least one of the mandatory attributes is non-empty
 func (m *Metadata) Valid() bool {
 	return m != nil && (m.Title != "" || m.Description != "" || m.Image != "")
 }
+
+// apply copies metadata attributes to r. Title, Type, Description and image
+// attributes are always copied, other ones only if they are non-empty.
+func (m *Metadata) apply(r *Result) {
+	r.Title = m.Title
+	r.Type = m.Type
+	r.Description = m.Description
+	r.Image = m.Image
+	r.ImageWidth = m.ImageWidth
+	r.ImageHeight = m.ImageHeight
+	if m.SiteName != "" {
+		r.SiteName = m.SiteName
+	}
+	if m.Favicon != "" {
+		r.Favicon = m.Favicon
+	}
+	if m.HTML != "" {
+		r.HTML = m.HTML
+	}
+	if m.CanonicalURL != "" {
+		r.CanonicalURL = m.CanonicalURL
+	}
+	if m.Author != "" {
+		r.Author = m.Author
+	}
+	if m.Duration != 0 {
+		r.Duration = m.Duration
+	}
+}
+
+// maxExcerpt is max length of descriptions fetchers make from longer texts,
+// in runes
+const maxExcerpt = 300
+
+// excerpt returns s with whitespace collapsed, cut at word boundary to at
+// most n runes followed by ellipsis
+func excerpt(s string, n int) string {
+	s = strings.Join(strings.Fields(s), " ")
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	s = string(r[:n])
+	if i := strings.LastIndexByte(s, ' '); i > 0 {
+		s = s[:i]
+	}
+	return s + "…"
+}
+
+// fetcherGet returns function making GET requests with client, or
+// http.DefaultClient if client is nil; responses with non-200 status are
+// returned as errors
+func fetcherGet(client *http.Client) func(context.Context, string) (*http.Response, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return func(ctx context.Context, s string) (*http.Response, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, s, nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, &statusError{code: resp.StatusCode, status: resp.Status}
+		}
+		return resp, nil
+	}
+}
@@ -0,0 +1,114 @@
+package unfurlist
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Fetcher is a custom metadata fetcher that can be registered in
+// FetcherRegistry. Name is used to identify fetcher in logs and metrics.
+type Fetcher interface {
+	Name() string
+	Fetch(context.Context, *http.Client, *url.URL) (*Metadata, bool)
+}
+
+// Fetch calls f, it allows FetchFunc to be used as Fetcher.
+func (f FetchFunc) Fetch(ctx context.Context, client *http.Client, u *url.URL) (*Metadata, bool) {
+	return f(ctx, client, u)
+}
+
+// Name implements Fetcher interface. Use NamedFetcher to give FetchFunc
+// a meaningful name.
+func (f FetchFunc) Name() string { return "custom" }
+
+// NamedFetcher returns Fetcher with the given name calling f.
+func NamedFetcher(name string, f FetchFunc) Fetcher { return namedFetcher{name, f} }
+
+type namedFetcher struct {
+	name string
+	FetchFunc
+}
+
+func (f namedFetcher) Name() string { return f.name }
+
+// FetcherRegistry holds set of fetchers bound to domains. Its zero value is
+// an empty registry ready to use. It is safe to register fetchers while
+// registry is used by unfurl handler.
+type FetcherRegistry struct {
+	mu      sync.RWMutex
+	entries []fetcherEntry // sorted by priority, higher first
+}
+
+type fetcherEntry struct {
+	glob     string
+	priority int
+	fetcher  Fetcher
+}
+
+// RegisterFetcher registers fetcher f to be used for urls with host matching
+// domainGlob pattern. Pattern syntax is the same as used by path.Match, i.e.
+// "*.example.com" matches "www.example.com", but not "example.com"; "*"
+// pattern matches any host. Hosts are matched in lower case without port.
+//
+// Urls with geo: scheme (RFC 5870) are only extracted from content if there
+// are fetchers registered with "geo:" pattern, they are looked up with
+// "geo:" host.
+//
+// For each url fetchers with higher priority are called first; fetchers with
+// the same priority are called in the order of registration. The first
+// fetcher returning valid metadata wins.
+func (r *FetcherRegistry) RegisterFetcher(domainGlob string, priority int, f Fetcher) error {
+	if f == nil {
+		return nil
+	}
+	domainGlob = strings.ToLower(domainGlob)
+	if _, err := path.Match(domainGlob, ""); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.entries = append(r.entries, fetcherEntry{glob: domainGlob, priority: priority, fetcher: f})
+	sort.SliceStable(r.entries, func(i, j int) bool { return r.entries[i].priority > r.entries[j].priority })
+	return nil
+}
+
+// lookup returns fetchers matching given host in the order they should be
+// called.
+func (r *FetcherRegistry) lookup(host string) []Fetcher {
+	if r == nil {
+		return nil
+	}
+	host = strings.ToLower(host)
+	if h, port, ok := strings.Cut(host, ":"); ok && port != "" && !strings.HasPrefix(host, "[") {
+		host = h
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var out []Fetcher
+	for _, e := range r.entries {
+		if ok, _ := path.Match(e.glob, host); ok {
+			out = append(out, e.fetcher)
+		}
+	}
+	return out
+}
+
+// handlesGeoURIs reports whether registry has fetchers for geo: URIs
+func (r *FetcherRegistry) handlesGeoURIs() bool {
+	if r == nil {
+		return false
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, e := range r.entries {
+		if e.glob == "geo:" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,90 @@
+package unfurlist
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFetcherMetadata(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><head><title>Page title</title></head></html>`))
+	}))
+	defer srv.Close()
+	fetcher := func(_ context.Context, _ *http.Client, u *url.URL) (*Metadata, bool) {
+		return &Metadata{
+			Title:        "Custom title",
+			Type:         "article",
+			SiteName:     "Example",
+			Favicon:      "https://example.com/icon.png",
+			HTML:         "<b>embed</b>",
+			CanonicalURL: "https://example.com/canonical",
+			Author:       "John Doe",
+		}, true
+	}
+	handler := New(WithFetchers(fetcher))
+	req := httptest.NewRequest(http.MethodGet, "/?content="+url.QueryEscape(srv.URL+"/page"), nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	var res []Result
+	if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 1 {
+		t.Fatalf("invalid result length: %v", res)
+	}
+	want := Result{
+		URL:          srv.URL + "/page",
+		Title:        "Custom title",
+		Type:         "article",
+		SiteName:     "Example",
+		Favicon:      "https://example.com/icon.png",
+		HTML:         "<b>embed</b>",
+		CanonicalURL: "https://example.com/canonical",
+		Author:       "John Doe",
+	}
+	if !reflect.DeepEqual(res[0], want) {
+		t.Fatalf("got:\n%+v\nwant:\n%+v", res[0], want)
+	}
+}
+
+func TestFetcherRegistry(t *testing.T) {
+	noop := func(context.Context, *http.Client, *url.URL) (*Metadata, bool) { return nil, false }
+	r := new(FetcherRegistry)
+	for _, e := range []struct {
+		glob     string
+		priority int
+		name     string
+	}{
+		{"*", 0, "any"},
+		{"*.example.com", 0, "subdomains"},
+		{"www.example.com", 10, "exact"},
+		{"*.example.*", 5, "any tld"},
+	} {
+		if err := r.RegisterFetcher(e.glob, e.priority, NamedFetcher(e.name, noop)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := r.RegisterFetcher("[", 0, FetchFunc(noop)); err == nil {
+		t.Fatal("malformed pattern should be rejected")
+	}
+	for host, want := range map[string]string{
+		"WWW.Example.com:443": "exact,any tld,any,subdomains",
+		"blog.example.org":    "any tld,any",
+		"example.com":         "any",
+	} {
+		var names []string
+		for _, f := range r.lookup(host) {
+			names = append(names, f.Name())
+		}
+		if got := strings.Join(names, ","); got != want {
+			t.Errorf("host %q: got %q, want %q", host, got, want)
+		}
+	}
+}
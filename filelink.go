@@ -0,0 +1,45 @@
+package unfurlist
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// fileExtensions are extensions of urls that are previewed as files when
+// there's no better metadata
+var fileExtensions = map[string]struct{}{
+	"zip": {}, "rar": {}, "7z": {}, "tar": {}, "gz": {}, "tgz": {}, "bz2": {}, "xz": {},
+	"dmg": {}, "pkg": {}, "exe": {}, "msi": {}, "deb": {}, "rpm": {}, "apk": {}, "ipa": {}, "iso": {},
+	"pdf": {}, "doc": {}, "docx": {}, "xls": {}, "xlsx": {}, "ppt": {}, "pptx": {},
+	"odt": {}, "ods": {}, "odp": {}, "rtf": {}, "csv": {}, "epub": {},
+	"key": {}, "numbers": {}, "pages": {}, "psd": {}, "ai": {}, "sketch": {},
+	"mp3": {}, "wav": {}, "flac": {},
+}
+
+// fileResult returns result of type "file" if url path clearly points to a
+// file, nil otherwise
+func fileResult(u *url.URL) *Result {
+	name := path.Base(u.Path)
+	ext := strings.ToLower(strings.TrimPrefix(path.Ext(name), "."))
+	if _, ok := fileExtensions[ext]; !ok || name == "."+ext {
+		return nil
+	}
+	return &Result{Type: "file", Title: name, Extension: ext}
+}
+
+// getDisallowed reports whether fetch error err means server refused GET
+// request, as servers often do for file downloads
+func getDisallowed(err error) bool {
+	var se *statusError
+	if !errors.As(err, &se) {
+		return false
+	}
+	switch se.code {
+	case http.StatusForbidden, http.StatusMethodNotAllowed, http.StatusNotImplemented:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,60 @@
+package unfurlist
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestFileLinks(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/files/Quarterly Report.xlsx":
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		case "/archive.zip":
+			w.Header().Set("Content-Type", "application/zip")
+			w.Write([]byte("PK\x03\x04"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	content := url.QueryEscape(srv.URL + "/files/Quarterly%20Report.xlsx " + srv.URL + "/archive.zip")
+	w := httptest.NewRecorder()
+	New().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/?content="+content, nil))
+	var res []Result
+	if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 2 {
+		t.Fatalf("unexpected results: %+v", res)
+	}
+	for i, want := range []struct{ title, ext string }{
+		{"Quarterly Report.xlsx", "xlsx"},
+		{"archive.zip", "zip"},
+	} {
+		if r := res[i]; r.Type != "file" || r.Title != want.title || r.Extension != want.ext {
+			t.Errorf("unexpected result: %+v", r)
+		}
+	}
+	if res[1].ContentType != "application/zip" {
+		t.Errorf("unexpected content type: %q", res[1].ContentType)
+	}
+}
+
+func TestFileLinkNotFound(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	defer srv.Close()
+	cache := new(mapCache)
+	h := New(WithCache(cache)).(*unfurlHandler)
+	res := h.processURL(context.Background(), srv.URL+"/report.pdf")
+	if res.err == nil || res.Type == "file" {
+		t.Fatalf("missing file should not have file result: %+v", res)
+	}
+	if len(cache.m) != 0 {
+		t.Fatalf("missing file result was cached: %v", cache.m)
+	}
+}
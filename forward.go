@@ -0,0 +1,90 @@
+package unfurlist
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+type forwardedHeadersKey struct{}
+
+// withForwardedHeaders returns context carrying values of hdr headers listed
+// in names, so that they can be added to outgoing requests.
+func withForwardedHeaders(ctx context.Context, hdr http.Header, names []string) context.Context {
+	var fwd http.Header
+	for _, k := range names {
+		if v := hdr.Get(k); v != "" {
+			if fwd == nil {
+				fwd = make(http.Header, len(names))
+			}
+			fwd.Set(k, v)
+		}
+	}
+	if fwd == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, forwardedHeadersKey{}, fwd)
+}
+
+// forwardedHeaders returns headers attached to context by withForwardedHeaders
+func forwardedHeaders(ctx context.Context) http.Header {
+	hdr, _ := ctx.Value(forwardedHeadersKey{}).(http.Header)
+	return hdr
+}
+
+// setHeaders sets extra headers configured for handler, headers forwarded
+// from client request and request id (if enabled) on outgoing request;
+// forwarded headers take precedence over extra ones.
+func (h *unfurlHandler) setHeaders(req *http.Request) {
+	for i := 0; i < len(h.Headers); i += 2 {
+		req.Header.Set(h.Headers[i], h.Headers[i+1])
+	}
+	if c := h.domainCookies(req.URL.Hostname()); c != "" {
+		req.Header.Set("Cookie", c)
+	}
+	h.setAuthorization(req)
+	for k, v := range forwardedHeaders(req.Context()) {
+		req.Header[k] = v
+	}
+	if h.forwardRequestID {
+		if id := RequestIDFromContext(req.Context()); id != "" {
+			req.Header.Set(RequestIDHeader, id)
+		}
+	}
+}
+
+// domainCookies returns cookies configured with WithCookies for the most
+// specific domain host belongs to
+func (h *unfurlHandler) domainCookies(host string) string {
+	if len(h.cookies) == 0 {
+		return ""
+	}
+	for host = strings.ToLower(host); host != ""; {
+		if c, ok := h.cookies[host]; ok {
+			return c
+		}
+		_, host, _ = strings.Cut(host, ".")
+	}
+	return ""
+}
+
+// resultKey returns key identifying result of link processing: as results may
+// depend on forwarded headers, their values are part of the key.
+func resultKey(ctx context.Context, link string) string {
+	hdr := forwardedHeaders(ctx)
+	if len(hdr) == 0 {
+		return link
+	}
+	keys := make([]string, 0, len(hdr))
+	for k := range hdr {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	b.WriteString(link)
+	for _, k := range keys {
+		b.WriteString("\x00" + k + ":" + hdr.Get(k))
+	}
+	return b.String()
+}
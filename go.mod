@@ -8,8 +8,10 @@ require (
 	github.com/dyatlov/go-opengraph v0.0.0-20210112100619-dae8665a5b09
 	github.com/golang/snappy v0.0.4
 	github.com/gomarkdown/markdown v0.0.0-20241205020045-f7e15b2f3e62
+	golang.org/x/crypto v0.31.0
 	golang.org/x/net v0.33.0
 	golang.org/x/sync v0.10.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -2,69 +2,236 @@ package unfurlist
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"net/http"
 	"net/url"
 	"regexp"
+	"strconv"
 	"strings"
 )
 
 // GoogleMapsFetcher returns FetchFunc that recognizes some Google Maps urls and
 // constructs metadata for them containing preview image from Google Static Maps
-// API. The only argument is the API key to create image links with.
+// API. The only argument is the API key to create image links with. The same
+// key is used with Places API to resolve urls referring to places by their
+// place_id.
+//
+// Short links (maps.app.goo.gl, goo.gl/maps) are resolved by following their
+// redirects, register fetcher for these domains to handle them.
 func GoogleMapsFetcher(key string) FetchFunc {
+	return GoogleMapsFetcherWithOptions(key, GoogleMapsOptions{})
+}
+
+// GoogleMapsOptions configure static map images created by
+// GoogleMapsFetcherWithOptions. Zero values are replaced with defaults.
+type GoogleMapsOptions struct {
+	Width, Height int      // image size before scaling, 640x480 by default
+	Scale         int      // 1 or 2 (default)
+	Zoom          int      // used if url has no zoom level, 16 by default
+	MarkerColor   string   // i.e. "red" (default) or "0xFFAA00"
+	Language      string   // language of map labels, i.e. "de"
+	Styles        []string // map styles, i.e. "feature:poi|visibility:off"
+}
+
+// GoogleMapsFetcherWithOptions is like GoogleMapsFetcher, but allows to
+// configure rendering of map images.
+func GoogleMapsFetcherWithOptions(key string, opts GoogleMapsOptions) FetchFunc {
 	if key == "" {
 		return func(context.Context, *http.Client, *url.URL) (*Metadata, bool) { return nil, false }
 	}
-	return func(_ context.Context, _ *http.Client, u *url.URL) (*Metadata, bool) {
-		if u == nil {
+	return newGoogleMaps(key, opts).fetch
+}
+
+func newGoogleMaps(key string, opts GoogleMapsOptions) *googleMaps {
+	if opts.Width <= 0 || opts.Height <= 0 {
+		opts.Width, opts.Height = 640, 480
+	}
+	if opts.Scale != 1 {
+		opts.Scale = 2
+	}
+	if opts.Zoom <= 0 {
+		opts.Zoom = 16
+	}
+	if opts.MarkerColor == "" {
+		opts.MarkerColor = "red"
+	}
+	return &googleMaps{key: key, opts: opts, placesURL: placeDetailsURL}
+}
+
+const placeDetailsURL = "https://maps.googleapis.com/maps/api/place/details/json"
+
+type googleMaps struct {
+	key       string
+	opts      GoogleMapsOptions
+	placesURL string // Places API details endpoint
+}
+
+func (g *googleMaps) fetch(ctx context.Context, client *http.Client, u *url.URL) (*Metadata, bool) {
+	if u == nil {
+		return nil, false
+	}
+	if isMapsShortLink(u) {
+		var err error
+		if u, err = resolveMapsShortLink(ctx, client, u); err != nil {
 			return nil, false
 		}
-		if idx := strings.LastIndexByte(u.Host, '.'); idx == -1 ||
-			!(strings.HasSuffix(u.Host[:idx], ".google") &&
-				strings.HasPrefix(u.Path, "/maps")) {
+	}
+	if strings.HasPrefix(u.Host, "consent.google.") {
+		// consent page shown in some regions, original url is in
+		// continue argument
+		var err error
+		if u, err = url.Parse(u.Query().Get("continue")); err != nil {
 			return nil, false
 		}
-		if u.Path == "/maps/api/staticmap" {
-			return &Metadata{Image: u.String(), Type: "image"}, true
-		}
-		g := &url.URL{
-			Scheme: "https",
-			Host:   "maps.googleapis.com",
-			Path:   "/maps/api/staticmap",
+	}
+	idx := strings.LastIndexByte(u.Host, '.')
+	if idx == -1 || !strings.HasSuffix(u.Host[:idx], ".google") {
+		return nil, false
+	}
+	mapsHost := strings.HasPrefix(u.Host, "maps.google.")
+	if !strings.HasPrefix(u.Path, "/maps") && !(mapsHost && u.Path == "/") {
+		return nil, false
+	}
+	if u.Path == "/maps/api/staticmap" {
+		return &Metadata{Image: u.String(), Type: "image"}, true
+	}
+	query := u.Query()
+	if placeID := placeIDFromQuery(query); placeID != "" {
+		if name, coords, err := g.placeDetails(ctx, client, placeID); err == nil {
+			return g.metadata(name, coords, ""), true
 		}
-		vals := make(url.Values)
-		vals.Set("key", key)
-		vals.Set("zoom", "16")
-		vals.Set("size", "640x480")
-		vals.Set("scale", "2")
-		if q := u.Query().Get("q"); u.Path == "/maps" && q != "" {
-			if zoom := u.Query().Get("z"); zoom != "" {
-				vals.Set("zoom", zoom)
+	}
+	if q := query.Get("q"); (u.Path == "/maps" || u.Path == "/") && q != "" {
+		return g.metadata("", q, query.Get("z")), true
+	}
+	name, coords, zoom, ok := coordsFromPath(u.Path)
+	if !ok {
+		return &Metadata{Title: "Google Maps", Type: "website"}, true
+	}
+	return g.metadata(name, coords, zoom), true
+}
+
+func (g *googleMaps) StaticMap(location string, zoom int) (string, int, int) {
+	z := ""
+	if zoom > 0 {
+		z = strconv.Itoa(zoom)
+	}
+	meta := g.metadata("", location, z)
+	return meta.Image, meta.ImageWidth, meta.ImageHeight
+}
+
+// metadata returns metadata with static map image with marker at location.
+// If zoom is empty, default one is used.
+func (g *googleMaps) metadata(title, location, zoom string) *Metadata {
+	if zoom == "" {
+		zoom = strconv.Itoa(g.opts.Zoom)
+	}
+	vals := make(url.Values)
+	vals.Set("key", g.key)
+	vals.Set("zoom", zoom)
+	vals.Set("size", strconv.Itoa(g.opts.Width)+"x"+strconv.Itoa(g.opts.Height))
+	vals.Set("scale", strconv.Itoa(g.opts.Scale))
+	vals.Set("markers", "color:"+g.opts.MarkerColor+"|"+location)
+	if g.opts.Language != "" {
+		vals.Set("language", g.opts.Language)
+	}
+	for _, s := range g.opts.Styles {
+		vals.Add("style", s)
+	}
+	img := &url.URL{
+		Scheme:   "https",
+		Host:     "maps.googleapis.com",
+		Path:     "/maps/api/staticmap",
+		RawQuery: vals.Encode(),
+	}
+	return &Metadata{
+		Title:       title,
+		Type:        "website",
+		Image:       img.String(),
+		ImageWidth:  g.opts.Width * g.opts.Scale,
+		ImageHeight: g.opts.Height * g.opts.Scale,
+	}
+}
+
+// placeIDFromQuery returns place id from url arguments of the following
+// forms:
+// https://www.google.com/maps/place/?q=place_id:ChIJ…
+// https://www.google.com/maps/search/?api=1&query=…&query_place_id=ChIJ…
+func placeIDFromQuery(q url.Values) string {
+	if id := q.Get("query_place_id"); id != "" {
+		return id
+	}
+	if id, ok := strings.CutPrefix(q.Get("q"), "place_id:"); ok {
+		return id
+	}
+	return ""
+}
+
+// placeDetails returns name and coordinates of the place using Places API
+func (g *googleMaps) placeDetails(ctx context.Context, client *http.Client, placeID string) (name, coords string, err error) {
+	vals := url.Values{"place_id": {placeID}, "fields": {"name,geometry"}, "key": {g.key}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, g.placesURL+"?"+vals.Encode(), nil)
+	if err != nil {
+		return "", "", err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", "", errors.New(resp.Status)
+	}
+	var out struct {
+		Status string
+		Result struct {
+			Name     string
+			Geometry struct {
+				Location struct{ Lat, Lng float64 }
 			}
-			vals.Set("markers", "color:red|"+q)
-			g.RawQuery = vals.Encode()
-			return &Metadata{
-				Type:        "website",
-				Image:       g.String(),
-				ImageWidth:  640 * 2,
-				ImageHeight: 480 * 2,
-			}, true
 		}
-		name, coords, zoom, ok := coordsFromPath(u.Path)
-		if !ok {
-			return &Metadata{Title: "Google Maps", Type: "website"}, true
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return "", "", err
+	}
+	if out.Status != "OK" {
+		return "", "", errors.New("places api: " + out.Status)
+	}
+	loc := out.Result.Geometry.Location
+	coords = strconv.FormatFloat(loc.Lat, 'f', -1, 64) + "," + strconv.FormatFloat(loc.Lng, 'f', -1, 64)
+	return out.Result.Name, coords, nil
+}
+
+func isMapsShortLink(u *url.URL) bool {
+	return u.Host == "maps.app.goo.gl" || (u.Host == "goo.gl" && strings.HasPrefix(u.Path, "/maps"))
+}
+
+// resolveMapsShortLink follows redirects of Google Maps short link until
+// they lead to a non-short url
+func resolveMapsShortLink(ctx context.Context, client *http.Client, u *url.URL) (*url.URL, error) {
+	c := *client
+	c.CheckRedirect = func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }
+	for i := 0; i < 5 && isMapsShortLink(u); i++ {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+		if err != nil {
+			return nil, err
 		}
-		vals.Set("zoom", zoom)
-		vals.Set("markers", "color:red|"+coords)
-		g.RawQuery = vals.Encode()
-		return &Metadata{
-			Title:       name,
-			Type:        "website",
-			Image:       g.String(),
-			ImageWidth:  640 * 2,
-			ImageHeight: 480 * 2,
-		}, true
+		resp, err := c.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		resp.Body.Close()
+		loc, err := resp.Location()
+		if err != nil {
+			return nil, err
+		}
+		u = loc
+	}
+	if isMapsShortLink(u) {
+		return nil, errors.New("too many redirects")
 	}
+	return u, nil
 }
 
 var googlePlace = regexp.MustCompile(`^/maps/place/(?P<name>[^/]+)/@(?P<coords>[0-9.-]+,[0-9.-]+),(?P<zoom>[0-9.]+)z`)
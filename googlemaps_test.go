@@ -1,6 +1,9 @@
 package unfurlist
 
 import (
+	"context"
+	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"testing"
 )
@@ -30,3 +33,71 @@ func TestCoordsFromPath(t *testing.T) {
 		}
 	}
 }
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (fn roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) { return fn(r) }
+
+func TestGoogleMapsFetcher(t *testing.T) {
+	client := &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		w := httptest.NewRecorder()
+		switch {
+		case r.URL.Host == "maps.app.goo.gl" && r.URL.Path == "/abc":
+			http.Redirect(w, r, "https://www.google.com/maps/place/Some+Cafe/@41.39,2.16,17z", http.StatusFound)
+		case r.URL.Host == "places.example.com" && r.URL.Query().Get("place_id") == "ChIJ123":
+			w.Write([]byte(`{"status":"OK","result":{"name":"Some Park","geometry":{"location":{"lat":51.5,"lng":-0.12}}}}`))
+		default:
+			http.NotFound(w, r)
+		}
+		return w.Result(), nil
+	})}
+	g := newGoogleMaps("key", GoogleMapsOptions{})
+	g.placesURL = "https://places.example.com/details"
+	for _, tc := range []struct {
+		url, title, marker string
+	}{
+		{"https://maps.app.goo.gl/abc", "Some Cafe", "color:red|41.39,2.16"},
+		{"https://www.google.com/maps/search/?api=1&query=park&query_place_id=ChIJ123", "Some Park", "color:red|51.5,-0.12"},
+		{"https://www.google.com/maps/place/?q=place_id:ChIJ123", "Some Park", "color:red|51.5,-0.12"},
+		{"https://maps.google.com/?q=Berlin", "", "color:red|Berlin"},
+	} {
+		u, err := url.Parse(tc.url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		meta, ok := g.fetch(context.Background(), client, u)
+		if !ok {
+			t.Errorf("%s: not recognized", tc.url)
+			continue
+		}
+		img, err := url.Parse(meta.Image)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if meta.Title != tc.title || img.Query().Get("markers") != tc.marker {
+			t.Errorf("%s: got title %q, image %q", tc.url, meta.Title, meta.Image)
+		}
+	}
+}
+
+func TestGoogleMapsOptions(t *testing.T) {
+	g := newGoogleMaps("key", GoogleMapsOptions{
+		Width: 300, Height: 200, Scale: 1, Zoom: 12,
+		MarkerColor: "blue", Language: "de",
+		Styles: []string{"feature:poi|visibility:off"},
+	})
+	meta := g.metadata("Place", "52.52,13.40", "")
+	img, err := url.Parse(meta.Image)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := img.Query()
+	if q.Get("size") != "300x200" || q.Get("scale") != "1" || q.Get("zoom") != "12" ||
+		q.Get("markers") != "color:blue|52.52,13.40" || q.Get("language") != "de" ||
+		q.Get("style") != "feature:poi|visibility:off" {
+		t.Fatalf("unexpected image url: %s", meta.Image)
+	}
+	if meta.ImageWidth != 300 || meta.ImageHeight != 200 {
+		t.Fatalf("unexpected image dimensions: %dx%d", meta.ImageWidth, meta.ImageHeight)
+	}
+}
@@ -0,0 +1,52 @@
+package unfurlist
+
+import (
+	"crypto/md5"
+	"encoding/binary"
+	"sort"
+	"strconv"
+)
+
+// hashRing maps keys to nodes with consistent hashing, so that adding or
+// removing a node only moves a fraction of keys
+type hashRing struct {
+	nodes  []string
+	points []ringPoint // sorted by hash
+}
+
+type ringPoint struct {
+	hash uint32
+	node int // index in nodes
+}
+
+// pointsPerNode is the number of points each node has on the ring
+const pointsPerNode = 160
+
+func newHashRing(nodes []string) *hashRing {
+	r := &hashRing{nodes: nodes, points: make([]ringPoint, 0, len(nodes)*pointsPerNode)}
+	for n, s := range nodes {
+		for i := 0; i < pointsPerNode/4; i++ {
+			// as in ketama, each md5 sum gives four points
+			sum := md5.Sum([]byte(s + "-" + strconv.Itoa(i)))
+			for j := 0; j < 4; j++ {
+				r.points = append(r.points, ringPoint{binary.LittleEndian.Uint32(sum[j*4:]), n})
+			}
+		}
+	}
+	sort.Slice(r.points, func(i, j int) bool { return r.points[i].hash < r.points[j].hash })
+	return r
+}
+
+// pick returns index of node key belongs to, or -1 if ring is empty
+func (r *hashRing) pick(key string) int {
+	if len(r.points) == 0 {
+		return -1
+	}
+	sum := md5.Sum([]byte(key))
+	h := binary.LittleEndian.Uint32(sum[:])
+	i := sort.Search(len(r.points), func(i int) bool { return r.points[i].hash >= h })
+	if i == len(r.points) {
+		i = 0
+	}
+	return r.points[i].node
+}
@@ -0,0 +1,105 @@
+package unfurlist
+
+import "context"
+
+// WithHedgedOembed configures unfurl handler to fetch pages of urls matching
+// known oembed providers concurrently with oembed requests, instead of only
+// after oembed request fails. This reduces latency when oembed endpoint is
+// slow or fails, at the cost of extra requests.
+//
+// If oembed request succeeds first, page fetch is canceled. If page is
+// fetched first, it is processed as usual and oembed response is merged
+// into result if it arrives by then; if page only had basic html metadata,
+// oembed response is waited for and takes precedence.
+func WithHedgedOembed(enable bool) ConfFunc {
+	return func(h *unfurlHandler) *unfurlHandler {
+		h.hedgeOembed = enable
+		return h
+	}
+}
+
+type oembedResult struct {
+	res *Result
+	err error
+}
+
+// hedgedResult is a result of hedgedFetch: either successful oembed
+// response, or page chunk (or its fetch error) with oembed response possibly
+// still in flight
+type hedgedResult struct {
+	oembed  *Result
+	chunk   *pageChunk
+	err     error
+	pending <-chan oembedResult
+}
+
+// hedgedFetch makes request to oembed endpoint and fetches link
+// concurrently. Oembed request is bound to ctx, which should be canceled
+// once result is no longer needed.
+func (h *unfurlHandler) hedgedFetch(ctx context.Context, link, endpoint string) hedgedResult {
+	oc := make(chan oembedResult, 1)
+	go func() {
+		res, err := fetchOembed(ctx, endpoint, h.httpGet)
+		oc <- oembedResult{res: res, err: err}
+	}()
+	pctx, cancelPage := context.WithCancel(ctx)
+	type pageResult struct {
+		chunk *pageChunk
+		err   error
+	}
+	pc := make(chan pageResult, 1)
+	go func() {
+		chunk, err := h.fetchData(pctx, link)
+		pc <- pageResult{chunk: chunk, err: err}
+	}()
+	select {
+	case r := <-oc:
+		if r.err == nil {
+			cancelPage()
+			return hedgedResult{oembed: r.res}
+		}
+		h.reportError(ctx, link, CategoryOembed, r.err)
+		p := <-pc
+		cancelPage()
+		return hedgedResult{chunk: p.chunk, err: p.err}
+	case p := <-pc:
+		cancelPage()
+		if p.err == nil {
+			return hedgedResult{chunk: p.chunk, pending: oc}
+		}
+		r := <-oc
+		if r.err == nil {
+			return hedgedResult{oembed: r.res}
+		}
+		h.reportError(ctx, link, CategoryOembed, r.err)
+		return hedgedResult{chunk: p.chunk, err: p.err}
+	}
+}
+
+// mergePendingOembed merges oembed response that was still in flight when
+// page was processed into result, and returns updated parser name. If
+// page only had basic metadata, it waits for the response and lets it take
+// precedence over page metadata; otherwise response only fills missing
+// attributes if it has already arrived.
+func mergePendingOembed(result *Result, parser string, pending <-chan oembedResult) string {
+	switch parser {
+	case "none", "html":
+		r := <-pending
+		if r.err != nil {
+			return parser
+		}
+		res := *r.res
+		res.Merge(result)
+		res.idx, res.err = result.idx, result.err
+		*result = res
+		return "oembed"
+	}
+	select {
+	case r := <-pending:
+		if r.err == nil {
+			result.Merge(r.res)
+		}
+	default:
+	}
+	return parser
+}
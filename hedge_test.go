@@ -0,0 +1,56 @@
+package unfurlist
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestHedgedOembed(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/oembed":
+			d, _ := time.ParseDuration(r.URL.Query().Get("delay"))
+			time.Sleep(d)
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"version":"1.0","type":"rich","title":"Embed","html":"<iframe></iframe>"}`))
+		case "/og":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(`<html><head><meta property="og:title" content="Page"></head></html>`))
+		case "/basic":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(`<html><head><title>Page</title></head></html>`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	delay := map[string]string{"/og": "300ms", "/basic": "100ms", "/missing": "0s"}
+	h := New(WithHedgedOembed(true), WithOembedLookupFunc(func(s string) (string, bool) {
+		u, err := url.Parse(s)
+		if err != nil {
+			return "", false
+		}
+		return srv.URL + "/oembed?delay=" + delay[u.Path], true
+	})).(*unfurlHandler)
+	for _, tc := range []struct {
+		path, title, html string
+		maxTime           time.Duration
+	}{
+		{"/og", "Page", "", 250 * time.Millisecond}, // page first, oembed still in flight
+		{"/basic", "Embed", "<iframe></iframe>", time.Second},
+		{"/missing", "Embed", "<iframe></iframe>", time.Second}, // page fetch fails
+	} {
+		begin := time.Now()
+		res := h.processURL(context.Background(), srv.URL+tc.path)
+		if d := time.Since(begin); d > tc.maxTime {
+			t.Errorf("%s: took %v", tc.path, d)
+		}
+		if res.Title != tc.title || res.HTML != tc.html {
+			t.Errorf("%s: unexpected result: %+v", tc.path, res)
+		}
+	}
+}
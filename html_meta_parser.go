@@ -8,21 +8,45 @@ import (
 	"errors"
 	"io"
 	"net/http"
+	"path"
 	"strings"
+	"time"
 
 	"golang.org/x/net/html"
 	"golang.org/x/net/html/atom"
 	"golang.org/x/net/html/charset"
 )
 
-func basicParseHTML(chunk *pageChunk) *unfurlResult {
-	result := new(unfurlResult)
+func basicParseHTML(chunk *pageChunk) *Result {
+	result := new(Result)
 	sniffedContentType := http.DetectContentType(chunk.data)
 	result.Type = sniffedContentType
 	switch {
+	case chunk.mediaType() == "text/calendar":
+		result.Type = "event"
+		if ev, ok := parseICS(chunk.data); ok {
+			result.Title, result.EventLocation = ev.summary, ev.location
+			switch {
+			case ev.start.IsZero():
+			case ev.allDay:
+				result.EventStart = ev.start.Format(time.DateOnly)
+			default:
+				result.EventStart = ev.start.Format(time.RFC3339)
+			}
+		}
 	case strings.HasPrefix(result.Type, "image/"):
 		result.Type = "image"
 		result.Image = chunk.url.String()
+		result.Title = path.Base(chunk.url.Path)
+		if result.Title == "/" || result.Title == "." {
+			result.Title = ""
+		}
+		if chunk.size > 0 {
+			result.ContentLength = chunk.size
+		}
+		if _, format, err := decodeImageConfig(bytes.NewReader(chunk.data)); err == nil {
+			result.ImageFormat = format
+		}
 	case strings.HasPrefix(result.Type, "text/"):
 		result.Type = "website"
 		// pass Content-Type from response headers as it may have
@@ -41,6 +65,17 @@ func basicParseHTML(chunk *pageChunk) *unfurlResult {
 	case strings.HasPrefix(result.Type, "video/"):
 		result.Type = "video"
 	}
+	if result.Type != "image" && !strings.HasPrefix(sniffedContentType, "text/html") {
+		if res := fileResult(chunk.url); res != nil {
+			result.Type, result.Title, result.Extension = res.Type, res.Title, res.Extension
+		}
+	}
+	if !strings.HasPrefix(sniffedContentType, "text/html") {
+		result.ContentType = chunk.mediaType()
+		if chunk.size > 0 {
+			result.ContentLength = chunk.size
+		}
+	}
 	return result
 }
 
@@ -1,6 +1,7 @@
 package unfurlist
 
 import (
+	"net/url"
 	"os"
 	"testing"
 )
@@ -111,3 +112,17 @@ var titleTestCases = []struct {
 	{"<html><TITLE>Hello</TITLE></html>", "Hello"},
 	{"<html><title>Hello\n</title></html>", "Hello\n"},
 }
+
+func TestBasicParseNonHTML(t *testing.T) {
+	u, _ := url.Parse("https://example.com/report.pdf")
+	chunk := &pageChunk{
+		data: []byte("%PDF-1.7\n"),
+		url:  u,
+		ct:   "application/pdf; qs=0.001",
+		size: 2516582,
+	}
+	res := basicParseHTML(chunk)
+	if res.ContentType != "application/pdf" || res.ContentLength != 2516582 {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+}
@@ -0,0 +1,98 @@
+package unfurlist
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+	"time"
+)
+
+// icsEvent holds attributes of calendar event
+type icsEvent struct {
+	summary  string
+	location string
+	start    time.Time
+	allDay   bool
+}
+
+// parseICS returns the first VEVENT of iCalendar (RFC 5545) data
+func parseICS(data []byte) (icsEvent, bool) {
+	var ev icsEvent
+	var inEvent bool
+	for _, line := range unfoldICS(data) {
+		name, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		name, params, _ := strings.Cut(name, ";")
+		switch strings.ToUpper(name) {
+		case "BEGIN":
+			inEvent = inEvent || strings.EqualFold(value, "VEVENT")
+		case "END":
+			if inEvent && strings.EqualFold(value, "VEVENT") {
+				return ev, ev.summary != "" || !ev.start.IsZero()
+			}
+		case "SUMMARY":
+			if inEvent {
+				ev.summary = unescapeICS(value)
+			}
+		case "LOCATION":
+			if inEvent {
+				ev.location = unescapeICS(value)
+			}
+		case "DTSTART":
+			if inEvent {
+				ev.start, ev.allDay = parseICSTime(value, params)
+			}
+		}
+	}
+	return ev, false
+}
+
+// unfoldICS splits data into content lines, joining folded ones
+func unfoldICS(data []byte) []string {
+	var lines []string
+	s := bufio.NewScanner(bytes.NewReader(data))
+	for s.Scan() {
+		line := strings.TrimRight(s.Text(), "\r")
+		if len(lines) > 0 && (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+var icsUnescaper = strings.NewReplacer(`\n`, "\n", `\N`, "\n", `\,`, ",", `\;`, ";", `\\`, `\`)
+
+func unescapeICS(s string) string { return icsUnescaper.Replace(s) }
+
+// parseICSTime parses DATE-TIME or DATE value with given property parameters
+func parseICSTime(value, params string) (t time.Time, allDay bool) {
+	loc := time.UTC
+	for _, p := range strings.Split(params, ";") {
+		k, v, _ := strings.Cut(p, "=")
+		switch strings.ToUpper(k) {
+		case "VALUE":
+			allDay = strings.EqualFold(v, "DATE")
+		case "TZID":
+			if l, err := time.LoadLocation(strings.Trim(v, `"`)); err == nil {
+				loc = l
+			}
+		}
+	}
+	if allDay || len(value) == len("20060102") {
+		t, err := time.ParseInLocation("20060102", value, loc)
+		if err != nil {
+			return time.Time{}, false
+		}
+		return t, true
+	}
+	if strings.HasSuffix(value, "Z") {
+		t, _ = time.Parse("20060102T150405Z", value)
+		return t, false
+	}
+	t, _ = time.ParseInLocation("20060102T150405", value, loc)
+	return t, false
+}
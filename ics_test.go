@@ -0,0 +1,63 @@
+package unfurlist
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+const testICS = "BEGIN:VCALENDAR\r\n" +
+	"VERSION:2.0\r\n" +
+	"BEGIN:VTIMEZONE\r\nTZID:Europe/Berlin\r\nEND:VTIMEZONE\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"SUMMARY:Quarterly planning\\, Q3 \r\n" +
+	" review\r\n" +
+	"DTSTART;TZID=Europe/Berlin:20240702T150000\r\n" +
+	"LOCATION:Room 4\\; 2nd floor\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\nSUMMARY:Second\r\nEND:VEVENT\r\n" +
+	"END:VCALENDAR\r\n"
+
+func TestParseICS(t *testing.T) {
+	ev, ok := parseICS([]byte(testICS))
+	if !ok {
+		t.Fatal("no event found")
+	}
+	if ev.summary != "Quarterly planning, Q3 review" || ev.location != "Room 4; 2nd floor" {
+		t.Fatalf("unexpected event: %+v", ev)
+	}
+	if got := ev.start.UTC().Format("2006-01-02 15:04"); got != "2024-07-02 13:00" {
+		t.Fatalf("unexpected start time: %s", got)
+	}
+	ev, ok = parseICS([]byte("BEGIN:VEVENT\nSUMMARY:Holiday\nDTSTART;VALUE=DATE:20241225\nEND:VEVENT\n"))
+	if !ok || !ev.allDay || ev.start.Format("2006-01-02") != "2024-12-25" {
+		t.Fatalf("unexpected all-day event: %+v", ev)
+	}
+}
+
+func TestCalendarLink(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/invite.ics" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+		w.Write([]byte(testICS))
+	}))
+	defer srv.Close()
+	w := httptest.NewRecorder()
+	New().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/?content="+url.QueryEscape(srv.URL+"/invite.ics"), nil))
+	var res []Result
+	if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 1 {
+		t.Fatalf("unexpected results: %+v", res)
+	}
+	if r := res[0]; r.Type != "event" || r.Title != "Quarterly planning, Q3 review" ||
+		r.EventStart != "2024-07-02T15:00:00+02:00" || r.EventLocation != "Room 4; 2nd floor" {
+		t.Fatalf("unexpected result: %+v", r)
+	}
+}
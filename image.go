@@ -1,6 +1,7 @@
 package unfurlist
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
@@ -8,6 +9,7 @@ import (
 	_ "image/gif" // register supported image types
 	_ "image/jpeg"
 	_ "image/png"
+	"io"
 	"net/http"
 	"net/url"
 	"strings"
@@ -40,29 +42,81 @@ func absoluteImageURL(originURL, imageURL string) (string, error) {
 	return base.ResolveReference(iu).String(), nil
 }
 
-// imageDimensions tries to retrieve enough of image to get its dimensions. If
-// provided client is nil, http.DefaultClient is used.
-func imageDimensions(ctx context.Context, client *http.Client, imageURL string) (width, height int, err error) {
+// maxDecodeSize is the max size of remote image that fetchImageInfo fully
+// decodes to get its dominant color and BlurHash
+const maxDecodeSize = 2 << 20
+
+// imageInfo describes remote image
+type imageInfo struct {
+	width, height int
+	size          int64       // size in bytes, 0 if unknown
+	img           image.Image // decoded image, nil if it's too large
+}
+
+// fetchImageInfo tries to retrieve enough of image to get its dimensions. If
+// image is not larger than maxDecodeSize, it is fetched in full and also
+// decoded. If provided client is nil, http.DefaultClient is used.
+func fetchImageInfo(ctx context.Context, client *http.Client, imageURL string) (imageInfo, error) {
+	var info imageInfo
+	resp, err := imageRequest(ctx, client, http.MethodGet, imageURL)
+	if err != nil {
+		return info, err
+	}
+	defer resp.Body.Close()
+	info.size = max(resp.ContentLength, 0)
+	body := io.LimitReader(resp.Body, maxDecodeSize+1)
+	var buf bytes.Buffer
+	cfg, _, err := decodeImageConfig(io.TeeReader(body, &buf))
+	if err != nil {
+		return info, err
+	}
+	info.width, info.height = cfg.Width, cfg.Height
+	if resp.ContentLength > maxDecodeSize || cfg.Width*cfg.Height > maxThumbnailPixels {
+		return info, nil
+	}
+	if _, err := buf.ReadFrom(body); err != nil || buf.Len() > maxDecodeSize {
+		return info, nil
+	}
+	info.size = int64(buf.Len())
+	info.img, _, _ = image.Decode(&buf)
+	return info, nil
+}
+
+// imageSize returns image size in bytes as reported by server in response to
+// HEAD request, or 0 if server doesn't report it
+func imageSize(ctx context.Context, client *http.Client, imageURL string) (int64, error) {
+	resp, err := imageRequest(ctx, client, http.MethodHead, imageURL)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	return max(resp.ContentLength, 0), nil
+}
+
+// imageRequest makes request for the image, returning error if response
+// status is not successful or its content-type is not one of supported image
+// types
+func imageRequest(ctx context.Context, client *http.Client, method, imageURL string) (*http.Response, error) {
 	cl := client
 	if cl == nil {
 		cl = http.DefaultClient
 	}
-	req, err := http.NewRequest(http.MethodGet, imageURL, nil)
+	req, err := http.NewRequest(method, imageURL, nil)
 	if err != nil {
-		return 0, 0, err
+		return nil, err
 	}
 	req = req.WithContext(ctx)
 	resp, err := cl.Do(req)
 	if err != nil {
-		return 0, 0, err
+		return nil, err
 	}
-	defer resp.Body.Close()
-
 	if resp.StatusCode >= http.StatusBadRequest {
-		return 0, 0, errors.New(resp.Status)
+		resp.Body.Close()
+		return nil, errors.New(resp.Status)
 	}
 	switch ct := strings.ToLower(resp.Header.Get("Content-Type")); ct {
-	case "image/jpeg", "image/png", "image/gif":
+	case "image/jpeg", "image/png", "image/gif",
+		"image/x-icon", "image/vnd.microsoft.icon", "image/bmp", "image/x-ms-bmp":
 	default:
 		// for broken servers responding with image/png;charset=UTF-8
 		// (i.e. www.evernote.com)
@@ -71,11 +125,66 @@ func imageDimensions(ctx context.Context, client *http.Client, imageURL string)
 			strings.HasPrefix(ct, "image/gif") {
 			break
 		}
-		return 0, 0, fmt.Errorf("unsupported content-type %q", ct)
+		resp.Body.Close()
+		return nil, fmt.Errorf("unsupported content-type %q", ct)
+	}
+	return resp, nil
+}
+
+// decodeImageChunk decodes image from the chunk data. It only succeeds if
+// chunk holds the whole image and its dimensions are within
+// maxThumbnailPixels, so that small files declaring huge dimensions are not
+// decoded.
+func decodeImageChunk(chunk *pageChunk) (image.Image, error) {
+	if chunk.size > int64(len(chunk.data)) {
+		return nil, errors.New("image is larger than chunk")
 	}
-	cfg, _, err := image.DecodeConfig(resp.Body)
+	cfg, _, err := decodeImageConfig(bytes.NewReader(chunk.data))
 	if err != nil {
-		return 0, 0, err
+		return nil, err
+	}
+	if cfg.Width*cfg.Height > maxThumbnailPixels {
+		return nil, errors.New("image has too many pixels to decode")
+	}
+	img, _, err := image.Decode(bytes.NewReader(chunk.data))
+	return img, err
+}
+
+// dominantColor returns hex-encoded color (i.e. "#a0b1c2") that is the most
+// common one in the image. Colors are grouped into coarse buckets, so that
+// slightly different shades are counted as the same color; fully transparent
+// pixels are skipped. For large images only a sample of pixels is considered.
+func dominantColor(img image.Image) string {
+	type bucket struct {
+		n       int
+		r, g, b uint64
+	}
+	var buckets [1 << 12]bucket
+	bounds := img.Bounds()
+	// sample at most ~128x128 pixels
+	stepX := max(bounds.Dx()/128, 1)
+	stepY := max(bounds.Dy()/128, 1)
+	var best *bucket
+	for y := bounds.Min.Y; y < bounds.Max.Y; y += stepY {
+		for x := bounds.Min.X; x < bounds.Max.X; x += stepX {
+			r, g, b, a := img.At(x, y).RGBA()
+			if a == 0 {
+				continue
+			}
+			r, g, b = r>>8, g>>8, b>>8
+			bk := &buckets[(r>>4)<<8|(g>>4)<<4|b>>4]
+			bk.n++
+			bk.r += uint64(r)
+			bk.g += uint64(g)
+			bk.b += uint64(b)
+			if best == nil || bk.n > best.n {
+				best = bk
+			}
+		}
+	}
+	if best == nil {
+		return ""
 	}
-	return cfg.Width, cfg.Height, nil
+	n := uint64(best.n)
+	return fmt.Sprintf("#%02x%02x%02x", best.r/n, best.g/n, best.b/n)
 }
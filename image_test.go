@@ -0,0 +1,156 @@
+package unfurlist
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestDominantColor(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 10, 10))
+	for x := 0; x < 10; x++ {
+		for y := 0; y < 10; y++ {
+			c := color.RGBA{0x20, 0x40, 0x80, 0xff}
+			if x < 3 {
+				c = color.RGBA{0xff, 0, 0, 0xff}
+			}
+			img.Set(x, y, c)
+		}
+	}
+	if got, want := dominantColor(img), "#204080"; got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+func TestDecodeImageChunkBomb(t *testing.T) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, 1, 1))); err != nil {
+		t.Fatal(err)
+	}
+	// patch IHDR to declare 10000x5000 image, fixing its checksum
+	b := buf.Bytes()
+	ihdr := b[12 : 12+4+13] // chunk type and data
+	binary.BigEndian.PutUint32(ihdr[4:], 10000)
+	binary.BigEndian.PutUint32(ihdr[8:], 5000)
+	binary.BigEndian.PutUint32(b[12+4+13:], crc32.ChecksumIEEE(ihdr))
+
+	chunk := &pageChunk{data: b, size: int64(len(b))}
+	if _, err := decodeImageChunk(chunk); err == nil {
+		t.Fatal("image with too many pixels was decoded")
+	}
+	cfg, _, err := decodeImageConfig(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Width != 10000 || cfg.Height != 5000 {
+		t.Fatalf("unexpected dimensions: %dx%d", cfg.Width, cfg.Height)
+	}
+}
+
+func TestDirectImageURL(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 40, 30))
+	for x := 0; x < 40; x++ {
+		for y := 0; y < 30; y++ {
+			img.Set(x, y, color.RGBA{0x10, 0x20, 0x30, 0xff})
+		}
+	}
+	buf := new(bytes.Buffer)
+	if err := png.Encode(buf, img); err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/images/picture.png" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "image/png")
+		w.Write(buf.Bytes())
+	}))
+	defer srv.Close()
+
+	handler := New(WithHTTPClient(srv.Client()), WithImageDimensions(true))
+	imageURL := srv.URL + "/images/picture.png"
+	req := httptest.NewRequest(http.MethodGet, "/?content="+url.QueryEscape(imageURL), nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected status: %v", w.Code)
+	}
+	var res []Result
+	if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 1 {
+		t.Fatalf("invalid result length: %v", res)
+	}
+	want := Result{
+		URL:           imageURL,
+		Title:         "picture.png",
+		Type:          "image",
+		Image:         imageURL,
+		ImageWidth:    40,
+		ImageHeight:   30,
+		ImageFormat:   "png",
+		ContentType:   "image/png",
+		ContentLength: int64(buf.Len()),
+		DominantColor: "#102030",
+		BlurHash:      "L01:Xyo#fQo#o~fkfQfkfQfQfQfQ",
+		ImageSize:     int64(buf.Len()),
+	}
+	if !reflect.DeepEqual(res[0], want) {
+		t.Fatalf("got:\n%+v\nwant:\n%+v", res[0], want)
+	}
+}
+
+func TestPageImageInfo(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 40, 30))
+	for x := 0; x < 40; x++ {
+		for y := 0; y < 30; y++ {
+			img.Set(x, y, color.RGBA{0x10, 0x20, 0x30, 0xff})
+		}
+	}
+	buf := new(bytes.Buffer)
+	if err := png.Encode(buf, img); err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/picture.png":
+			w.Header().Set("Content-Type", "image/png")
+			w.Write(buf.Bytes())
+		case "/":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(`<html><head><meta property="og:title" content="Page">
+<meta property="og:image" content="/picture.png"></head></html>`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	h := New(WithImageDimensions(true)).(*unfurlHandler)
+	res := h.processURL(context.Background(), srv.URL+"/")
+	if res.ImageWidth != 40 || res.ImageHeight != 30 || res.DominantColor != "#102030" ||
+		res.BlurHash != "L01:Xyo#fQo#o~fkfQfkfQfQfQfQ" || res.ImageSize != int64(buf.Len()) {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+
+	// without FetchImageSize size is taken from HEAD request
+	h = New(WithMaxImageSize(int64(buf.Len()))).(*unfurlHandler)
+	if res := h.processURL(context.Background(), srv.URL+"/"); res.Image == "" || res.ImageSize != int64(buf.Len()) {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+	h = New(WithMaxImageSize(int64(buf.Len()-1)), WithImageDimensions(true)).(*unfurlHandler)
+	if res := h.processURL(context.Background(), srv.URL+"/"); res.Image != "" || res.ImageWidth != 0 || res.ImageSize != 0 {
+		t.Fatalf("oversized image not dropped: %+v", res)
+	}
+}
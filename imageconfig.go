@@ -0,0 +1,91 @@
+package unfurlist
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"image"
+	"io"
+)
+
+// decodeImageConfig is like image.DecodeConfig, but also supports ICO and
+// BMP formats, which are only parsed for dimensions. ICO dimensions are ones
+// of its largest image.
+func decodeImageConfig(r io.Reader) (image.Config, string, error) {
+	br := bufio.NewReader(r)
+	magic, _ := br.Peek(4)
+	switch {
+	case len(magic) == 4 && magic[0] == 0 && magic[1] == 0 && magic[2] == 1 && magic[3] == 0:
+		cfg, err := icoConfig(br)
+		return cfg, "ico", err
+	case len(magic) >= 2 && magic[0] == 'B' && magic[1] == 'M':
+		cfg, err := bmpConfig(br)
+		return cfg, "bmp", err
+	}
+	return image.DecodeConfig(br)
+}
+
+var errInvalidImageHeader = errors.New("invalid image header")
+
+// icoConfig parses ICO directory and returns dimensions of its largest entry
+func icoConfig(r io.Reader) (image.Config, error) {
+	var hdr [6]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return image.Config{}, err
+	}
+	n := int(binary.LittleEndian.Uint16(hdr[4:]))
+	if n == 0 {
+		return image.Config{}, errInvalidImageHeader
+	}
+	var cfg image.Config
+	entry := make([]byte, 16)
+	for i := 0; i < n; i++ {
+		if _, err := io.ReadFull(r, entry); err != nil {
+			return image.Config{}, err
+		}
+		// width and height of 0 mean 256
+		w, h := int(entry[0]), int(entry[1])
+		if w == 0 {
+			w = 256
+		}
+		if h == 0 {
+			h = 256
+		}
+		if w*h > cfg.Width*cfg.Height {
+			cfg.Width, cfg.Height = w, h
+		}
+	}
+	return cfg, nil
+}
+
+// bmpConfig parses BMP file and DIB headers for image dimensions
+func bmpConfig(r io.Reader) (image.Config, error) {
+	var hdr [26]byte
+	if _, err := io.ReadFull(r, hdr[:18]); err != nil {
+		return image.Config{}, err
+	}
+	switch dibSize := binary.LittleEndian.Uint32(hdr[14:]); {
+	case dibSize == 12: // BITMAPCOREHEADER
+		if _, err := io.ReadFull(r, hdr[18:22]); err != nil {
+			return image.Config{}, err
+		}
+		return image.Config{
+			Width:  int(binary.LittleEndian.Uint16(hdr[18:])),
+			Height: int(binary.LittleEndian.Uint16(hdr[20:])),
+		}, nil
+	case dibSize >= 40:
+		if _, err := io.ReadFull(r, hdr[18:26]); err != nil {
+			return image.Config{}, err
+		}
+		w := int32(binary.LittleEndian.Uint32(hdr[18:]))
+		h := int32(binary.LittleEndian.Uint32(hdr[22:]))
+		if h < 0 { // top-down bitmap
+			h = -h
+		}
+		if w <= 0 || h <= 0 {
+			return image.Config{}, errInvalidImageHeader
+		}
+		return image.Config{Width: int(w), Height: int(h)}, nil
+	}
+	return image.Config{}, errInvalidImageHeader
+}
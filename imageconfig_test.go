@@ -0,0 +1,67 @@
+package unfurlist
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+func TestDecodeImageConfig(t *testing.T) {
+	ico := func(sizes ...[2]byte) []byte {
+		b := []byte{0, 0, 1, 0}
+		b = binary.LittleEndian.AppendUint16(b, uint16(len(sizes)))
+		for _, s := range sizes {
+			b = append(b, s[0], s[1])
+			b = append(b, make([]byte, 14)...)
+		}
+		return b
+	}
+	bmp := func(dibSize uint32, w, h int32) []byte {
+		b := append([]byte("BM"), make([]byte, 12)...)
+		b = binary.LittleEndian.AppendUint32(b, dibSize)
+		if dibSize == 12 {
+			b = binary.LittleEndian.AppendUint16(b, uint16(w))
+			return binary.LittleEndian.AppendUint16(b, uint16(h))
+		}
+		b = binary.LittleEndian.AppendUint32(b, uint32(w))
+		return binary.LittleEndian.AppendUint32(b, uint32(h))
+	}
+	for _, tc := range []struct {
+		name   string
+		data   []byte
+		format string
+		w, h   int
+		err    bool
+	}{
+		{name: "ico", data: ico([2]byte{16, 16}, [2]byte{48, 32}), format: "ico", w: 48, h: 32},
+		{name: "ico 0 means 256", data: ico([2]byte{32, 32}, [2]byte{0, 0}), format: "ico", w: 256, h: 256},
+		{name: "ico without entries", data: ico(), format: "ico", err: true},
+		{name: "ico truncated header", data: []byte{0, 0, 1, 0, 1}, format: "ico", err: true},
+		{name: "ico truncated entry", data: ico([2]byte{16, 16})[:12], format: "ico", err: true},
+		{name: "bmp", data: bmp(40, 640, 480), format: "bmp", w: 640, h: 480},
+		{name: "bmp top-down", data: bmp(124, 640, -480), format: "bmp", w: 640, h: 480},
+		{name: "bmp core header", data: bmp(12, 320, 200), format: "bmp", w: 320, h: 200},
+		{name: "bmp zero width", data: bmp(40, 0, 480), format: "bmp", err: true},
+		{name: "bmp min height", data: bmp(40, 640, -1<<31), format: "bmp", err: true},
+		{name: "bmp unknown header", data: bmp(20, 640, 480), format: "bmp", err: true},
+		{name: "bmp truncated file header", data: []byte("BM\x00\x00"), format: "bmp", err: true},
+		{name: "bmp truncated dib header", data: bmp(40, 640, 480)[:22], format: "bmp", err: true},
+		{name: "bmp truncated core header", data: bmp(12, 320, 200)[:20], format: "bmp", err: true},
+		{name: "unknown", data: []byte("BX"), err: true},
+	} {
+		cfg, format, err := decodeImageConfig(bytes.NewReader(tc.data))
+		if tc.err {
+			if err == nil {
+				t.Errorf("%s: got %+v, want error", tc.name, cfg)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+			continue
+		}
+		if format != tc.format || cfg.Width != tc.w || cfg.Height != tc.h {
+			t.Errorf("%s: got %s %dx%d, want %s %dx%d", tc.name, format, cfg.Width, cfg.Height, tc.format, tc.w, tc.h)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package unfurlist
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+)
+
+// ImgproxyRewriter returns function to use with WithImageRewriter that routes
+// images through imgproxy (https://imgproxy.net) at endpoint. Source url is
+// base64-encoded and prefixed with processing options, if any, i.e.
+// "rs:fit:600:400". If key is not empty, urls are signed with HMAC-SHA256 of
+// key and salt, as imgproxy expects them to be hex-decoded; otherwise
+// "insecure" is used in place of signature. It returns nil if endpoint is
+// empty.
+func ImgproxyRewriter(endpoint string, key, salt []byte, options string) func(string) string {
+	if endpoint == "" {
+		return nil
+	}
+	endpoint = strings.TrimSuffix(endpoint, "/")
+	options = strings.Trim(options, "/")
+	return func(imageURL string) string {
+		path := "/" + base64.RawURLEncoding.EncodeToString([]byte(imageURL))
+		if options != "" {
+			path = "/" + options + path
+		}
+		sig := "insecure"
+		if len(key) != 0 {
+			mac := hmac.New(sha256.New, key)
+			mac.Write(salt)
+			mac.Write([]byte(path))
+			sig = base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+		}
+		return endpoint + "/" + sig + path
+	}
+}
+
+// CamoRewriter returns function to use with WithImageRewriter that routes
+// images through Camo (https://github.com/atmos/camo) at endpoint, signing
+// urls with HMAC-SHA1 of key in the format Camo expects:
+// endpoint/hex-digest/hex-encoded-url. It returns nil if endpoint or key is
+// empty.
+func CamoRewriter(endpoint string, key []byte) func(string) string {
+	if endpoint == "" || len(key) == 0 {
+		return nil
+	}
+	endpoint = strings.TrimSuffix(endpoint, "/")
+	return func(imageURL string) string {
+		mac := hmac.New(sha1.New, key)
+		mac.Write([]byte(imageURL))
+		return endpoint + "/" + hex.EncodeToString(mac.Sum(nil)) + "/" + hex.EncodeToString([]byte(imageURL))
+	}
+}
+
+// InsecureOnly wraps image url rewriter so that it only applies to http://
+// urls, which would otherwise cause mixed content warnings; https urls are
+// left as is.
+func InsecureOnly(rewrite func(string) string) func(string) string {
+	if rewrite == nil {
+		return nil
+	}
+	return func(u string) string {
+		if len(u) > len("http://") && strings.EqualFold(u[:len("http://")], "http://") {
+			return rewrite(u)
+		}
+		return u
+	}
+}
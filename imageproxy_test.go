@@ -0,0 +1,35 @@
+package unfurlist
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+func TestImgproxyRewriter(t *testing.T) {
+	key, _ := hex.DecodeString("943b421c9eb07c830af81030552c86009268de4e532ba2ee2eab8247c6da0881")
+	salt, _ := hex.DecodeString("520f986b998545b4785e0defbc4f3c1203f22de2374a3d53cb7a7fe9fea309c5")
+	fn := ImgproxyRewriter("https://imgproxy.example.com/", key, salt, "rs:fit:600:400")
+	want := "https://imgproxy.example.com/pvHi8asOROcqgjdqP9_1HVbfr3Ff4FQbiU5bT6V7iY8/rs:fit:600:400/aHR0cHM6Ly9leGFtcGxlLmNvbS9hLnBuZw"
+	if got := fn("https://example.com/a.png"); got != want {
+		t.Errorf("got  %q\nwant %q", got, want)
+	}
+	fn = ImgproxyRewriter("https://imgproxy.example.com", nil, nil, "")
+	want = "https://imgproxy.example.com/insecure/aHR0cHM6Ly9leGFtcGxlLmNvbS9hLnBuZw"
+	if got := fn("https://example.com/a.png"); got != want {
+		t.Errorf("got  %q\nwant %q", got, want)
+	}
+	if ImgproxyRewriter("", key, salt, "") != nil {
+		t.Error("expected nil function for empty endpoint")
+	}
+}
+
+func TestCamoRewriter(t *testing.T) {
+	fn := CamoRewriter("https://camo.example.com", []byte("secret"))
+	want := "https://camo.example.com/7acabb99fc86089c8ca6c0c2410405f57d79aba0/68747470733a2f2f6578616d706c652e636f6d2f612e706e67"
+	if got := fn("https://example.com/a.png"); got != want {
+		t.Errorf("got  %q\nwant %q", got, want)
+	}
+	if CamoRewriter("https://camo.example.com", nil) != nil {
+		t.Error("expected nil function for empty key")
+	}
+}
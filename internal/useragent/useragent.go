@@ -16,31 +16,83 @@ import (
 	"strings"
 )
 
+// Agents of well-known link preview bots. Some sites only serve Open Graph
+// tags to requests having one of these agents.
+const (
+	Facebook   = "facebookexternalhit/1.1 (+http://www.facebook.com/externalhit_uatext.php)"
+	Twitterbot = "Twitterbot/1.0"
+	Discourse  = "DiscourseBot/1.0"
+)
+
+// DefaultDomains are per-domain agents used by Set
+var DefaultDomains = map[string]string{
+	"twitter.com": Discourse,
+	"x.com":       Discourse,
+}
+
 // Set wraps provided http.RoundTripper returning a new one that adds given
 // agent as User-Agent header for requests without such header or with empty
-// User-Agent header.
+// User-Agent header. Requests to DefaultDomains use their agents instead.
 //
 // If rt is a *http.Transport, the returned RoundTripper would have Transport's
 // methods visible so they can be accessed after type assertion to required
 // interface.
 func Set(rt http.RoundTripper, agent string) http.RoundTripper {
-	if agent == "" {
+	return SetDomains(rt, agent, DefaultDomains)
+}
+
+// SetDomains is like Set, but uses domains to pick agent for requests: keys
+// are domain names matching themselves and their subdomains, values are
+// agents for them. Agent of the most specific matching domain is used,
+// requests to other hosts get the default agent.
+func SetDomains(rt http.RoundTripper, agent string, domains map[string]string) http.RoundTripper {
+	if agent == "" && len(domains) == 0 {
 		return rt
 	}
+	a := agents{agent: agent}
+	if len(domains) != 0 {
+		a.domains = make(map[string]string, len(domains))
+		for k, v := range domains {
+			a.domains[strings.ToLower(strings.TrimSuffix(k, "."))] = v
+		}
+	}
 	if t, ok := rt.(*http.Transport); ok {
-		return uaT{t, agent}
+		return uaT{t, a}
 	}
-	return uaRT{rt, agent}
+	return uaRT{rt, a}
 }
 
-type uaT struct {
-	*http.Transport
-	userAgent string
+type agents struct {
+	agent   string
+	domains map[string]string
 }
 
-func (t uaT) RoundTrip(r *http.Request) (*http.Response, error) {
+// forHost returns agent for requests to host, empty if there's none
+func (a agents) forHost(host string) string {
+	if len(a.domains) != 0 {
+		host = strings.ToLower(host)
+		if h, _, ok := strings.Cut(host, ":"); ok && !strings.HasPrefix(host, "[") {
+			host = h
+		}
+		for h := host; h != ""; {
+			if agent, ok := a.domains[h]; ok {
+				return agent
+			}
+			_, h, _ = strings.Cut(h, ".")
+		}
+	}
+	return a.agent
+}
+
+// withAgent returns r if it already has User-Agent header or there's no
+// agent for its host, otherwise it returns a copy of r with agent set
+func (a agents) withAgent(r *http.Request) *http.Request {
 	if _, ok := r.Header["User-Agent"]; ok {
-		return t.Transport.RoundTrip(r)
+		return r
+	}
+	agent := a.forHost(r.URL.Host)
+	if agent == "" {
+		return r
 	}
 	r2 := new(http.Request)
 	*r2 = *r
@@ -48,29 +100,24 @@ func (t uaT) RoundTrip(r *http.Request) (*http.Response, error) {
 	for k, v := range r.Header {
 		r2.Header[k] = v
 	}
-	r2.Header.Set("User-Agent", t.userAgent)
-	if r.URL.Host == "twitter.com" || strings.HasSuffix(r.URL.Host, ".twitter.com") ||
-		r.URL.Host == "x.com" || strings.HasSuffix(r.URL.Host, ".x.com") {
-		r2.Header.Set("User-Agent", "DiscourseBot/1.0")
-	}
-	return t.Transport.RoundTrip(r2)
+	r2.Header.Set("User-Agent", agent)
+	return r2
+}
+
+type uaT struct {
+	*http.Transport
+	agents agents
+}
+
+func (t uaT) RoundTrip(r *http.Request) (*http.Response, error) {
+	return t.Transport.RoundTrip(t.agents.withAgent(r))
 }
 
 type uaRT struct {
 	http.RoundTripper
-	userAgent string
+	agents agents
 }
 
 func (t uaRT) RoundTrip(r *http.Request) (*http.Response, error) {
-	if _, ok := r.Header["User-Agent"]; ok {
-		return t.RoundTripper.RoundTrip(r)
-	}
-	r2 := new(http.Request)
-	*r2 = *r
-	r2.Header = make(http.Header, len(r.Header)+1)
-	for k, v := range r.Header {
-		r2.Header[k] = v
-	}
-	r2.Header.Set("User-Agent", t.userAgent)
-	return t.RoundTripper.RoundTrip(r2)
+	return t.RoundTripper.RoundTrip(t.agents.withAgent(r))
 }
@@ -0,0 +1,37 @@
+package useragent
+
+import (
+	"net/http"
+	"testing"
+)
+
+type recordRT struct{ agent *string }
+
+func (rt recordRT) RoundTrip(r *http.Request) (*http.Response, error) {
+	*rt.agent = r.Header.Get("User-Agent")
+	return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody, Request: r}, nil
+}
+
+func TestSetDomains(t *testing.T) {
+	var got string
+	client := &http.Client{Transport: SetDomains(recordRT{&got}, "default/1.0", map[string]string{
+		"example.com":      Facebook,
+		"news.example.com": Twitterbot,
+	})}
+	for url, want := range map[string]string{
+		"https://example.com/":          Facebook,
+		"https://www.example.com:443/":  Facebook,
+		"https://a.news.example.com/":   Twitterbot,
+		"https://notexample.com/":       "default/1.0",
+		"https://example.com.evil.net/": "default/1.0",
+	} {
+		resp, err := client.Get(url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if got != want {
+			t.Errorf("%s: got %q, want %q", url, got, want)
+		}
+	}
+}
@@ -0,0 +1,225 @@
+package unfurlist
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Job statuses
+const (
+	JobPending = "pending"
+	JobDone    = "done"
+	JobFailed  = "failed" // results could not be stored
+)
+
+// Job describes state of a job submitted with POST request to /jobs path
+type Job struct {
+	ID      string        `json:"id"`
+	Status  string        `json:"status"` // JobPending, JobDone or JobFailed
+	Created time.Time     `json:"created"`
+	Results []*Result     `json:"results,omitempty"`
+	Errors  []ResultError `json:"errors,omitempty"`
+}
+
+// storedJob is job state as kept in cache. Results are kept under their own
+// keys, see jobResultKey, as all of them may not fit into a single cache item.
+type storedJob struct {
+	Job
+	NumResults int `json:"num_results,omitempty"`
+}
+
+// DefaultMaxJobResults is maximum number of urls processed by a job if not
+// configured by WithMaxJobResults
+const DefaultMaxJobResults = 1000
+
+// WithMaxJobResults configures unfurl handler to only process n first urls
+// of jobs submitted to /jobs path. n must be positive, DefaultMaxJobResults
+// is used by default.
+func WithMaxJobResults(n int) ConfFunc {
+	return func(h *unfurlHandler) *unfurlHandler {
+		if n > 0 {
+			h.maxJobResults = n
+		}
+		return h
+	}
+}
+
+// jobTTL is how long jobs are kept in cache
+const jobTTL = 24 * time.Hour
+
+func jobKey(id string) string { return "unfurlist:job:" + id }
+
+func jobResultKey(id string, i int) string { return jobKey(id) + ":" + strconv.Itoa(i) }
+
+// errJobExpired is returned by loadJob if some of job results are gone from
+// cache
+var errJobExpired = errors.New("job results expired")
+
+// submitJob starts processing request as a job, its state is kept in cache
+func (h *unfurlHandler) submitJob(w http.ResponseWriter, r *http.Request, args requestArgs) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	if h.cache == nil {
+		http.Error(w, "jobs require cache to be configured", http.StatusNotImplemented)
+		return
+	}
+	ctx := withForwardedHeaders(r.Context(), r.Header, h.forwardHeaders)
+	limit := h.maxJobResults
+	if args.Max > 0 && args.Max < limit {
+		limit = args.Max
+	}
+	urls, texts := h.parseURLs(args.Content, args.Format, limit)
+	ctx = withLinkTexts(ctx, texts)
+	setAccessURLs(r.Context(), len(urls))
+	created := time.Now().UTC()
+	pendingStored := make(chan struct{})
+	done := func(ctx context.Context, id string, env *Envelope) {
+		<-pendingStored
+		job := &Job{ID: id, Status: JobDone, Created: created, Results: env.Results, Errors: env.Errors}
+		if err := h.storeJob(job); err != nil {
+			h.logf(ctx, "job %s: %v", id, err)
+			job = &Job{ID: id, Status: JobFailed, Created: created}
+			if err := h.storeJob(job); err != nil {
+				h.logf(ctx, "job %s: %v", id, err)
+			}
+		}
+	}
+	id, ok := h.startJob(ctx, urls, h.requestTimeout(args.Timeout), args.filterJob(done))
+	if !ok {
+		close(pendingStored)
+		writeTooManyJobs(w)
+		return
+	}
+	err := h.storeJob(&Job{ID: id, Status: JobPending, Created: created})
+	close(pendingStored)
+	if err != nil {
+		h.logf(ctx, "job %s: %v", id, err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Location", "/jobs/"+id)
+	writeAccepted(w, id)
+}
+
+// storeJob keeps job state in cache, each of its results under a separate
+// key. Job itself is stored last, so that it's only seen as done once all
+// its results are stored.
+func (h *unfurlHandler) storeJob(job *Job) error {
+	ctx := context.Background()
+	for i, res := range job.Results {
+		data, err := json.Marshal(res)
+		if err != nil {
+			return err
+		}
+		if err := h.cache.Set(ctx, jobResultKey(job.ID, i), data, jobTTL); err != nil {
+			return err
+		}
+	}
+	sj := storedJob{Job: *job, NumResults: len(job.Results)}
+	sj.Results = nil
+	data, err := json.Marshal(sj)
+	if err != nil {
+		return err
+	}
+	return h.cache.Set(ctx, jobKey(job.ID), data, jobTTL)
+}
+
+// loadJob returns job state stored by storeJob
+func (h *unfurlHandler) loadJob(ctx context.Context, id string) (*Job, error) {
+	data, err := h.cache.Get(ctx, jobKey(id))
+	if err != nil {
+		return nil, err
+	}
+	var sj storedJob
+	if err := json.Unmarshal(data, &sj); err != nil {
+		return nil, err
+	}
+	if sj.NumResults == 0 {
+		return &sj.Job, nil
+	}
+	keys := make([]string, sj.NumResults)
+	for i := range keys {
+		keys[i] = jobResultKey(id, i)
+	}
+	var items map[string][]byte
+	if mg, ok := h.cache.(MultiGetter); ok {
+		if items, err = mg.GetMulti(ctx, keys); err != nil {
+			return nil, err
+		}
+	} else {
+		items = make(map[string][]byte, len(keys))
+		for _, k := range keys {
+			switch b, err := h.cache.Get(ctx, k); err {
+			case nil:
+				items[k] = b
+			case ErrCacheMiss:
+			default:
+				return nil, err
+			}
+		}
+	}
+	sj.Results = make([]*Result, len(keys))
+	for i, k := range keys {
+		b, ok := items[k]
+		if !ok {
+			return nil, errJobExpired
+		}
+		res := new(Result)
+		if err := json.Unmarshal(b, res); err != nil {
+			return nil, err
+		}
+		sj.Results[i] = res
+	}
+	return &sj.Job, nil
+}
+
+// serveJob replies with job state for /jobs/{id} requests
+func (h *unfurlHandler) serveJob(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+	default:
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	id := strings.TrimPrefix(r.URL.Path, "/jobs/")
+	if h.cache == nil || !validJobID(id) {
+		http.NotFound(w, r)
+		return
+	}
+	job, err := h.loadJob(r.Context(), id)
+	switch err {
+	case nil:
+	case ErrCacheMiss, errJobExpired:
+		http.NotFound(w, r)
+		return
+	default:
+		h.logf(r.Context(), "job %s: %v", id, err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(job)
+}
+
+// validJobID reports whether s looks like id generated by newJobID
+func validJobID(s string) bool {
+	if len(s) != 32 {
+		return false
+	}
+	for _, c := range s {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
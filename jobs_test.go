@@ -0,0 +1,137 @@
+package unfurlist
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestJobs(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/page" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><head><title>Page</title></head></html>`))
+	}))
+	defer srv.Close()
+	handler := New(WithMemcache(newTestMemcache(t)))
+
+	form := url.Values{"content": {srv.URL + "/page " + srv.URL + "/missing"}}
+	req := httptest.NewRequest(http.MethodPost, "/jobs", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("got status %d, want %d", w.Code, http.StatusAccepted)
+	}
+	loc := w.Header().Get("Location")
+	if !strings.HasPrefix(loc, "/jobs/") {
+		t.Fatalf("unexpected Location header: %q", loc)
+	}
+
+	var job Job
+	for deadline := time.Now().Add(5 * time.Second); job.Status != JobDone; {
+		if time.Now().After(deadline) {
+			t.Fatalf("job is not done in time: %+v", job)
+		}
+		time.Sleep(10 * time.Millisecond)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, loc, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("got status %d, want %d", w.Code, http.StatusOK)
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &job); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(job.Results) != 2 || job.Results[0].Title != "Page" || len(job.Errors) != 1 {
+		t.Fatalf("unexpected job: %+v", job)
+	}
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/jobs/00000000000000000000000000000000", nil))
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("got status %d for unknown job, want %d", w.Code, http.StatusNotFound)
+	}
+}
+
+// limitCache is a Cache rejecting values larger than max bytes, like
+// memcached does
+type limitCache struct {
+	mapCache
+	max int
+}
+
+func (c *limitCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	if len(value) > c.max {
+		return errors.New("value is too large")
+	}
+	return c.mapCache.Set(ctx, key, value, ttl)
+}
+
+func TestLargeJobs(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/favicon.ico" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprintf(w, `<html><head><title>Page %s</title></head></html>`, strings.Repeat("x", 300))
+	}))
+	defer srv.Close()
+	var links []string
+	for i := 0; i < DefaultMaxResults+10; i++ {
+		links = append(links, fmt.Sprintf("%s/page/%d", srv.URL, i))
+	}
+	runJob := func(handler http.Handler) Job {
+		t.Helper()
+		form := url.Values{"content": {strings.Join(links, " ")}}
+		req := httptest.NewRequest(http.MethodPost, "/jobs", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		if w.Code != http.StatusAccepted {
+			t.Fatalf("got status %d, want %d", w.Code, http.StatusAccepted)
+		}
+		loc := w.Header().Get("Location")
+		var job Job
+		for deadline := time.Now().Add(5 * time.Second); job.Status == "" || job.Status == JobPending; {
+			if time.Now().After(deadline) {
+				t.Fatalf("job is not done in time: %+v", job)
+			}
+			time.Sleep(10 * time.Millisecond)
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, loc, nil))
+			if err := json.Unmarshal(w.Body.Bytes(), &job); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return job
+	}
+
+	// whole job doesn't fit into a single item, but each result does
+	job := runJob(New(WithCache(&limitCache{max: 1000})))
+	if job.Status != JobDone || len(job.Results) != len(links) {
+		t.Fatalf("got job with status %q and %d results, want %q and %d",
+			job.Status, len(job.Results), JobDone, len(links))
+	}
+	for i, res := range job.Results {
+		if res.URL != links[i] {
+			t.Fatalf("result %d has url %q, want %q", i, res.URL, links[i])
+		}
+	}
+
+	job = runJob(New(WithCache(&limitCache{max: 250})))
+	if job.Status != JobFailed || len(job.Results) != 0 {
+		t.Fatalf("got job with status %q and %d results, want %q and none", job.Status, len(job.Results), JobFailed)
+	}
+}
@@ -0,0 +1,86 @@
+package unfurlist
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+	"golang.org/x/net/html/charset"
+)
+
+// jsonLD returns decoded JSON-LD blocks of html page
+func jsonLD(chunk *pageChunk) []any {
+	rd, err := charset.NewReader(bytes.NewReader(chunk.data), chunk.ct)
+	if err != nil {
+		return nil
+	}
+	var out []any
+	z := html.NewTokenizer(rd)
+	inLD := false
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return out
+		case html.StartTagToken:
+			name, hasAttr := z.TagName()
+			inLD = hasAttr && atom.Lookup(name) == atom.Script && attrValue(z, "type") == "application/ld+json"
+		case html.TextToken:
+			if !inLD {
+				continue
+			}
+			inLD = false
+			var v any
+			if json.Unmarshal(z.Text(), &v) == nil {
+				out = append(out, v)
+			}
+		case html.EndTagToken:
+			inLD = false
+		}
+	}
+}
+
+// jsonLDString returns string or number JSON-LD value as string
+func jsonLDString(v any) string {
+	switch v := v.(type) {
+	case string:
+		return strings.TrimSpace(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return ""
+}
+
+// jsonLDTyped walks decoded JSON-LD v, following @graph, mainEntity and
+// arrays, and returns the first object which @type is accepted by match
+func jsonLDTyped(v any, match func(typ string) bool) map[string]any {
+	switch v := v.(type) {
+	case map[string]any:
+		switch t := v["@type"].(type) {
+		case string:
+			if match(t) {
+				return v
+			}
+		case []any:
+			for _, t := range t {
+				if s, ok := t.(string); ok && match(s) {
+					return v
+				}
+			}
+		}
+		for _, k := range [...]string{"@graph", "mainEntity"} {
+			if obj := jsonLDTyped(v[k], match); obj != nil {
+				return obj
+			}
+		}
+	case []any:
+		for _, val := range v {
+			if obj := jsonLDTyped(val, match); obj != nil {
+				return obj
+			}
+		}
+	}
+	return nil
+}
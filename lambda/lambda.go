@@ -0,0 +1,271 @@
+// Package lambda runs http.Handler, such as the one created by unfurlist.New,
+// as AWS Lambda function.
+//
+// Function receives API Gateway REST API (payload format 1.0), HTTP API or
+// Function URL (payload format 2.0) events, converts them to http requests
+// and replies with responses in the matching format. It talks to Lambda
+// Runtime API directly, so no extra dependencies are needed; use it with
+// "provided" runtimes:
+//
+//	func main() {
+//		log.Fatal(lambda.Start(unfurlist.New()))
+//	}
+//
+// Lambda execution environment processes a single invocation at a time and
+// is frozen between invocations, so each request is given a deadline matching
+// the invocation deadline and handler's response is fully buffered before it
+// is sent back.
+package lambda
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// IsLambda reports whether process runs in AWS Lambda environment, that is
+// whether Lambda Runtime API address is set. AWS_EXECUTION_ENV is not checked
+// as it is not set on "provided" runtimes.
+func IsLambda() bool {
+	return os.Getenv("AWS_LAMBDA_RUNTIME_API") != ""
+}
+
+// Start runs event loop processing Lambda invocations with h. Address of Lambda
+// Runtime API is taken from AWS_LAMBDA_RUNTIME_API environment variable. Start
+// only returns on errors talking to Runtime API.
+func Start(h http.Handler) error {
+	api := os.Getenv("AWS_LAMBDA_RUNTIME_API")
+	if api == "" {
+		return errors.New("AWS_LAMBDA_RUNTIME_API is not set")
+	}
+	return serve(context.Background(), &http.Client{}, "http://"+api+"/2018-06-01/runtime", h)
+}
+
+// serve processes invocations one by one until ctx is canceled or Runtime
+// API call fails
+func serve(ctx context.Context, client *http.Client, base string, h http.Handler) error {
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, base+"/invocation/next", nil)
+		if err != nil {
+			return err
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		payload, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return err
+		}
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("next invocation: %s", resp.Status)
+		}
+		id := resp.Header.Get("Lambda-Runtime-Aws-Request-Id")
+		if id == "" {
+			return errors.New("next invocation: no request id")
+		}
+		if trace := resp.Header.Get("Lambda-Runtime-Trace-Id"); trace != "" {
+			os.Setenv("_X_AMZN_TRACE_ID", trace)
+		}
+		ictx, cancel := ctx, context.CancelFunc(func() {})
+		if ms, err := strconv.ParseInt(resp.Header.Get("Lambda-Runtime-Deadline-Ms"), 10, 64); err == nil {
+			ictx, cancel = context.WithDeadline(ctx, time.UnixMilli(ms))
+		}
+		out, herr := handle(ictx, h, payload)
+		cancel()
+		if herr != nil {
+			err = post(ctx, client, base+"/invocation/"+id+"/error", errorResponse(herr))
+		} else {
+			err = post(ctx, client, base+"/invocation/"+id+"/response", out)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func post(ctx context.Context, client *http.Client, url string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode != http.StatusAccepted {
+		return fmt.Errorf("%s: %s", url, resp.Status)
+	}
+	return nil
+}
+
+func errorResponse(err error) []byte {
+	b, _ := json.Marshal(struct {
+		Message string `json:"errorMessage"`
+		Type    string `json:"errorType"`
+	}{Message: err.Error(), Type: "InvalidEvent"})
+	return b
+}
+
+// event is a union of API Gateway REST API, HTTP API and Function URL
+// request events
+type event struct {
+	Version string `json:"version"` // "2.0" for HTTP API and Function URL events
+
+	// payload format 1.0
+	HTTPMethod        string              `json:"httpMethod"`
+	Path              string              `json:"path"`
+	MultiValueHeaders map[string][]string `json:"multiValueHeaders"`
+	MultiValueQuery   map[string][]string `json:"multiValueQueryStringParameters"`
+	Query             map[string]string   `json:"queryStringParameters"`
+
+	// payload format 2.0
+	RawPath        string   `json:"rawPath"`
+	RawQueryString string   `json:"rawQueryString"`
+	Cookies        []string `json:"cookies"`
+
+	Headers         map[string]string `json:"headers"`
+	Body            string            `json:"body"`
+	IsBase64Encoded bool              `json:"isBase64Encoded"`
+	RequestContext  struct {
+		HTTP struct {
+			Method   string `json:"method"`
+			SourceIP string `json:"sourceIp"`
+		} `json:"http"`
+		Identity struct {
+			SourceIP string `json:"sourceIp"`
+		} `json:"identity"`
+	} `json:"requestContext"`
+}
+
+// response is a union of payload format 1.0 and 2.0 responses
+type response struct {
+	StatusCode        int                 `json:"statusCode"`
+	Headers           map[string]string   `json:"headers,omitempty"`
+	MultiValueHeaders map[string][]string `json:"multiValueHeaders,omitempty"`
+	Cookies           []string            `json:"cookies,omitempty"`
+	Body              string              `json:"body"`
+	IsBase64Encoded   bool                `json:"isBase64Encoded"`
+}
+
+// handle processes single invocation payload with h and returns encoded
+// response
+func handle(ctx context.Context, h http.Handler, payload []byte) ([]byte, error) {
+	var ev event
+	if err := json.Unmarshal(payload, &ev); err != nil {
+		return nil, err
+	}
+	req, err := ev.request(ctx)
+	if err != nil {
+		return nil, err
+	}
+	w := &responseWriter{header: make(http.Header)}
+	h.ServeHTTP(w, req)
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	resp := response{StatusCode: w.status}
+	if body := w.buf.Bytes(); utf8.Valid(body) {
+		resp.Body = string(body)
+	} else {
+		resp.Body, resp.IsBase64Encoded = base64.StdEncoding.EncodeToString(body), true
+	}
+	if ev.Version == "2.0" {
+		resp.Cookies = w.header.Values("Set-Cookie")
+		w.header.Del("Set-Cookie")
+		resp.Headers = make(map[string]string, len(w.header))
+		for k, v := range w.header {
+			resp.Headers[k] = strings.Join(v, ",")
+		}
+	} else {
+		resp.MultiValueHeaders = w.header
+	}
+	return json.Marshal(resp)
+}
+
+// request converts event to http request
+func (ev *event) request(ctx context.Context) (*http.Request, error) {
+	body := []byte(ev.Body)
+	if ev.IsBase64Encoded {
+		var err error
+		if body, err = base64.StdEncoding.DecodeString(ev.Body); err != nil {
+			return nil, fmt.Errorf("request body: %w", err)
+		}
+	}
+	u := &url.URL{Path: ev.Path, RawQuery: ev.RawQueryString}
+	method, remoteIP := ev.HTTPMethod, ev.RequestContext.Identity.SourceIP
+	if ev.Version == "2.0" {
+		u.Path = ev.RawPath
+		method, remoteIP = ev.RequestContext.HTTP.Method, ev.RequestContext.HTTP.SourceIP
+	} else {
+		q := make(url.Values)
+		for k, v := range ev.Query {
+			q.Set(k, v)
+		}
+		for k, v := range ev.MultiValueQuery {
+			q[k] = v
+		}
+		u.RawQuery = q.Encode()
+	}
+	if method == "" {
+		return nil, errors.New("event is not an http request")
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u.RequestURI(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range ev.Headers {
+		req.Header.Set(k, v)
+	}
+	for k, vv := range ev.MultiValueHeaders {
+		req.Header.Del(k)
+		for _, v := range vv {
+			req.Header.Add(k, v)
+		}
+	}
+	for _, c := range ev.Cookies {
+		req.Header.Add("Cookie", c)
+	}
+	req.Host = req.Header.Get("Host")
+	req.RemoteAddr = remoteIP
+	req.RequestURI = u.RequestURI()
+	return req, nil
+}
+
+// responseWriter is http.ResponseWriter buffering response
+type responseWriter struct {
+	header http.Header
+	status int
+	buf    bytes.Buffer
+}
+
+func (w *responseWriter) Header() http.Header { return w.header }
+
+func (w *responseWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	return w.buf.Write(b)
+}
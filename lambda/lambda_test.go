@@ -0,0 +1,99 @@
+package lambda
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestServe(t *testing.T) {
+	events := []string{
+		`{"httpMethod":"GET","path":"/echo","multiValueQueryStringParameters":{"content":["a b"]},
+			"multiValueHeaders":{"Accept":["text/plain"]},"requestContext":{"identity":{"sourceIp":"10.0.0.1"}}}`,
+		`{"version":"2.0","rawPath":"/echo","rawQueryString":"content=a+b","headers":{"accept":"text/plain"},
+			"requestContext":{"http":{"method":"POST","sourceIp":"10.0.0.1"}},"body":"eA==","isBase64Encoded":true}`,
+		`{"foo":"bar"}`,
+	}
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := r.Context().Deadline(); !ok {
+			t.Error("request context has no deadline")
+		}
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("X-Method", r.Method)
+		w.WriteHeader(http.StatusTeapot)
+		io.WriteString(w, r.URL.Path+" "+r.FormValue("content")+" "+r.Header.Get("Accept")+" "+r.RemoteAddr+" "+string(body))
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var responses, errs []string
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/runtime/invocation/next":
+			n := len(responses) + len(errs)
+			if n == len(events) {
+				cancel()
+				<-r.Context().Done()
+				return
+			}
+			w.Header().Set("Lambda-Runtime-Aws-Request-Id", strconv.Itoa(n))
+			w.Header().Set("Lambda-Runtime-Deadline-Ms", strconv.FormatInt(time.Now().Add(time.Minute).UnixMilli(), 10))
+			io.WriteString(w, events[n])
+			return
+		case "/runtime/invocation/0/response", "/runtime/invocation/1/response":
+			b, _ := io.ReadAll(r.Body)
+			responses = append(responses, string(b))
+		case "/runtime/invocation/2/error":
+			b, _ := io.ReadAll(r.Body)
+			errs = append(errs, string(b))
+		default:
+			t.Errorf("unexpected call: %s %s", r.Method, r.URL)
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer api.Close()
+	if err := serve(ctx, api.Client(), api.URL+"/runtime", handler); !errors.Is(err, context.Canceled) {
+		t.Fatalf("serve returned %v", err)
+	}
+	if len(responses) != 2 || len(errs) != 1 {
+		t.Fatalf("got %d responses and %d errors, want 2 and 1", len(responses), len(errs))
+	}
+	var v1, v2 response
+	if err := json.Unmarshal([]byte(responses[0]), &v1); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal([]byte(responses[1]), &v2); err != nil {
+		t.Fatal(err)
+	}
+	if v1.StatusCode != http.StatusTeapot || v1.Body != "/echo a b text/plain 10.0.0.1 " ||
+		v1.MultiValueHeaders["X-Method"][0] != "GET" {
+		t.Errorf("unexpected payload 1.0 response: %+v", v1)
+	}
+	if v2.StatusCode != http.StatusTeapot || v2.Body != "/echo a b text/plain 10.0.0.1 x" ||
+		v2.Headers["X-Method"] != "POST" {
+		t.Errorf("unexpected payload 2.0 response: %+v", v2)
+	}
+}
+
+func TestIsLambda(t *testing.T) {
+	for _, tc := range []struct {
+		api, env string
+		want     bool
+	}{
+		{"127.0.0.1:9001", "", true},
+		{"127.0.0.1:9001", "AWS_Lambda_go1.x", true},
+		{"", "AWS_Lambda_go1.x", false},
+		{"", "", false},
+	} {
+		t.Setenv("AWS_LAMBDA_RUNTIME_API", tc.api)
+		t.Setenv("AWS_EXECUTION_ENV", tc.env)
+		if got := IsLambda(); got != tc.want {
+			t.Errorf("AWS_LAMBDA_RUNTIME_API=%q AWS_EXECUTION_ENV=%q: got %v, want %v", tc.api, tc.env, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package unfurlist
+
+import (
+	"errors"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// ErrLoginRequired is reported for urls redirecting to login pages, see
+// WithLoginPageDetection
+var ErrLoginRequired = errors.New("resource requires login")
+
+// WithLoginPageDetection extends built-in login page detection with extra
+// regular expressions matched against redirect target path and with known
+// login page urls (query string and fragment are ignored when comparing).
+// Invalid patterns are ignored.
+//
+// Unless http.Client configured with WithHTTPClient has its own
+// CheckRedirect policy, handler stops following redirects once they lead to a
+// login page, reporting ErrLoginRequired, since such pages don't describe the
+// original url.
+func WithLoginPageDetection(patterns, knownPages []string) ConfFunc {
+	var res []*regexp.Regexp
+	for _, s := range patterns {
+		if re, err := regexp.Compile(s); err == nil {
+			res = append(res, re)
+		}
+	}
+	return func(h *unfurlHandler) *unfurlHandler {
+		h.loginPages.patterns = append(h.loginPages.patterns, res...)
+		for _, u := range knownPages {
+			if h.loginPages.known == nil {
+				h.loginPages.known = make(map[string]struct{})
+			}
+			h.loginPages.known[u] = struct{}{}
+		}
+		return h
+	}
+}
+
+// loginPageDetector decides whether redirect leads to a login page
+type loginPageDetector struct {
+	patterns []*regexp.Regexp    // extra patterns for url path
+	known    map[string]struct{} // extra known login pages
+}
+
+// checkRedirect can be used as http.Client.CheckRedirect to skip redirects
+// to login pages of most commonly used services or most commonly named login
+// pages. It also checks depth of redirect chain and stops on more then 10
+// consecutive redirects.
+func (d *loginPageDetector) checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= 10 {
+		return errors.New("stopped after 10 redirects")
+	}
+	if l := len(via); l > 0 && *req.URL == *via[l-1].URL {
+		return errors.New("redirect loop")
+	}
+	if strings.Contains(strings.ToLower(req.URL.Host), "login") ||
+		loginPathRe.MatchString(req.URL.Path) {
+		return ErrLoginRequired
+	}
+	for _, re := range d.patterns {
+		if re.MatchString(req.URL.Path) {
+			return ErrLoginRequired
+		}
+	}
+	u := *req.URL
+	u.RawQuery, u.Fragment = "", ""
+	s := u.String()
+	if _, ok := loginPages[s]; ok {
+		return ErrLoginRequired
+	}
+	if _, ok := d.known[s]; ok {
+		return ErrLoginRequired
+	}
+	return nil
+}
+
+var loginPathRe = regexp.MustCompile(`(?i)login|sign.?in`)
+
+// loginPages is a set of popular services' known login pages
+var loginPages = map[string]struct{}{
+	"https://bitbucket.org/account/signin/": {},
+	"https://outlook.live.com/owa/":         {},
+}
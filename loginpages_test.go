@@ -0,0 +1,50 @@
+package unfurlist
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+)
+
+func TestLoginPageDetection(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/private":
+			http.Redirect(w, r, "/accounts/SignIn?next=/private", http.StatusFound)
+		case "/custom":
+			http.Redirect(w, r, "/auth/gate", http.StatusFound)
+		case "/public":
+			http.Redirect(w, r, "/page", http.StatusFound)
+		case "/page":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(`<html><head><title>Page</title></head></html>`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	var mu sync.Mutex
+	reported := make(map[string]error)
+	reporter := func(_ context.Context, link string, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		reported[link] = err
+	}
+	handler := New(WithErrorReporter(reporter), WithLoginPageDetection([]string{`^/auth/`}, nil))
+	content := srv.URL + "/private " + srv.URL + "/custom " + srv.URL + "/public"
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/?content="+url.QueryEscape(content), nil))
+	mu.Lock()
+	defer mu.Unlock()
+	for _, link := range []string{srv.URL + "/private", srv.URL + "/custom"} {
+		if !errors.Is(reported[link], ErrLoginRequired) {
+			t.Errorf("%s: got error %v, want ErrLoginRequired", link, reported[link])
+		}
+	}
+	if err := reported[srv.URL+"/public"]; err != nil {
+		t.Errorf("unexpected error for redirect to regular page: %v", err)
+	}
+}
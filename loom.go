@@ -0,0 +1,65 @@
+package unfurlist
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// loomOembedEndpoint is Loom oembed endpoint, url of video is appended to it
+const loomOembedEndpoint = "https://www.loom.com/v1/oembed?url="
+
+// LoomFetcher returns FetchFunc that recognizes loom.com/share and
+// loom.com/embed video urls and gets their title, duration and thumbnail
+// from Loom oembed endpoint, complemented by Open Graph metadata of share page.
+func LoomFetcher() FetchFunc {
+	return func(ctx context.Context, client *http.Client, u *url.URL) (*Metadata, bool) {
+		if u == nil {
+			return nil, false
+		}
+		if host := strings.ToLower(u.Hostname()); host != "loom.com" && host != "www.loom.com" {
+			return nil, false
+		}
+		kind, rest, _ := strings.Cut(strings.TrimPrefix(u.Path, "/"), "/")
+		id, _, _ := strings.Cut(rest, "/")
+		if (kind != "share" && kind != "embed") || !reLoomID.MatchString(id) {
+			return nil, false
+		}
+		get := fetcherGet(client)
+		ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		defer cancel()
+		shareURL := "https://www.loom.com/share/" + id
+		meta := &Metadata{Type: "video", SiteName: "Loom", HTML: embedHTML(&url.URL{Host: "www.loom.com", Path: "/share/" + id})}
+		if res, err := fetchOembed(ctx, loomOembedEndpoint+url.QueryEscape(shareURL), get); err == nil {
+			meta.Title = res.Title
+			meta.Image = res.Image
+			meta.Author = res.Author
+			meta.Duration = res.Duration
+			meta.ImageWidth, _ = strconv.Atoi(res.Oembed["thumbnail_width"])
+			meta.ImageHeight, _ = strconv.Atoi(res.Oembed["thumbnail_height"])
+		}
+		if resp, err := get(ctx, shareURL); err == nil {
+			data, _ := io.ReadAll(io.LimitReader(resp.Body, maxOembedSize))
+			resp.Body.Close()
+			page := extractRawMeta(&pageChunk{data: data, ct: resp.Header.Get("Content-Type")})
+			meta.Description = page["og:description"]
+			if meta.Title == "" {
+				meta.Title = page["og:title"]
+			}
+			if meta.Image == "" {
+				meta.Image = page["og:image"]
+			}
+			if meta.Duration == 0 {
+				meta.Duration = videoDuration(page, nil)
+			}
+		}
+		if meta.Title == "" {
+			return nil, false
+		}
+		return meta, true
+	}
+}
@@ -0,0 +1,63 @@
+package unfurlist
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestLoomFetcher(t *testing.T) {
+	const id = "0123456789abcdef0123456789abcdef"
+	client := &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		w := httptest.NewRecorder()
+		switch {
+		case r.URL.Path == "/v1/oembed" && r.URL.Query().Get("url") == "https://www.loom.com/share/"+id:
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"type":"video","version":"1.0","title":"Demo","provider_name":"Loom",
+"thumbnail_url":"https://cdn.loom.com/sessions/thumbnails/` + id + `.gif","thumbnail_width":1280,"thumbnail_height":720,
+"duration":93.4,"html":"<iframe></iframe>"}`))
+		case r.URL.Path == "/share/"+id:
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(`<html><head><meta property="og:title" content="Demo | Loom">
+<meta property="og:description" content="Walkthrough of new feature"></head></html>`))
+		default:
+			http.NotFound(w, r)
+		}
+		return w.Result(), nil
+	})}
+	fetch := LoomFetcher()
+	want := Metadata{
+		Title:       "Demo",
+		Type:        "video",
+		Description: "Walkthrough of new feature",
+		Image:       "https://cdn.loom.com/sessions/thumbnails/" + id + ".gif",
+		ImageWidth:  1280,
+		ImageHeight: 720,
+		SiteName:    "Loom",
+		HTML:        embedHTML(&url.URL{Host: "www.loom.com", Path: "/share/" + id}),
+		Duration:    93,
+	}
+	for _, s := range []string{
+		"https://www.loom.com/share/" + id,
+		"https://loom.com/share/" + id + "?sid=abc",
+		"https://www.loom.com/embed/" + id,
+	} {
+		u, _ := url.Parse(s)
+		meta, ok := fetch(context.Background(), client, u)
+		if !ok || *meta != want {
+			t.Errorf("%s: got %+v, %v", s, meta, ok)
+		}
+	}
+	for _, s := range []string{
+		"https://www.loom.com/share/0123",
+		"https://www.loom.com/share/fedcba9876543210fedcba9876543210",
+		"https://www.loom.com/looms/videos",
+	} {
+		u, _ := url.Parse(s)
+		if meta, ok := fetch(context.Background(), client, u); ok {
+			t.Errorf("%s: unexpected result %+v", s, meta)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package unfurlist
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// lruCache is in-memory cache limited by total size of stored values, least
+// recently used values are evicted first
+type lruCache struct {
+	maxBytes int64
+
+	mu    sync.Mutex
+	size  int64
+	ll    *list.List // of *lruEntry, most recently used first
+	items map[string]*list.Element
+}
+
+type lruEntry struct {
+	key     string
+	value   []byte
+	expires time.Time // zero if value does not expire
+}
+
+func newLRUCache(maxBytes int64) *lruCache {
+	return &lruCache{maxBytes: maxBytes, ll: list.New(), items: make(map[string]*list.Element)}
+}
+
+func (c *lruCache) get(key string, now time.Time) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	e := el.Value.(*lruEntry)
+	if !e.expires.IsZero() && now.After(e.expires) {
+		c.remove(el)
+		return nil, false
+	}
+	c.ll.MoveToFront(el)
+	return e.value, true
+}
+
+func (c *lruCache) set(key string, value []byte, expires time.Time) {
+	sz := int64(len(key) + len(value))
+	if sz > c.maxBytes {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.items[key]; ok {
+		c.remove(el)
+	}
+	c.items[key] = c.ll.PushFront(&lruEntry{key: key, value: value, expires: expires})
+	c.size += sz
+	for c.size > c.maxBytes {
+		c.remove(c.ll.Back())
+	}
+}
+
+func (c *lruCache) remove(el *list.Element) {
+	e := c.ll.Remove(el).(*lruEntry)
+	delete(c.items, e.key)
+	c.size -= int64(len(e.key) + len(e.value))
+}
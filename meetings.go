@@ -0,0 +1,71 @@
+package unfurlist
+
+import (
+	"context"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// VideoConferenceHook is PreFetchHook recognizing Zoom, Google Meet and
+// Microsoft Teams meeting urls. Results for such urls are constructed from
+// the url itself, without fetching pages that usually require signing in;
+// they have "meeting" `url_type`, provider name as `site_name` and meeting id
+// if it's part of the url.
+func VideoConferenceHook(_ context.Context, link string) (bool, *Result) {
+	u, err := url.Parse(link)
+	if err != nil {
+		return false, nil
+	}
+	host := strings.ToLower(u.Hostname())
+	var provider, icon, id string
+	switch {
+	case host == "zoom.us" || strings.HasSuffix(host, ".zoom.us"):
+		m := zoomMeetingPath.FindStringSubmatch(u.Path)
+		if m == nil {
+			return false, nil
+		}
+		provider, icon, id = "Zoom", "https://zoom.us/favicon.ico", formatZoomID(m[1])
+	case host == "meet.google.com":
+		m := meetMeetingPath.FindStringSubmatch(u.Path)
+		if m == nil {
+			return false, nil
+		}
+		provider, icon, id = "Google Meet", "https://meet.google.com/favicon.ico", m[1]
+	case host == "teams.microsoft.com" && strings.HasPrefix(u.Path, "/l/meetup-join/"):
+		provider, icon = "Microsoft Teams", "https://teams.microsoft.com/favicon.ico"
+	case host == "teams.live.com" && strings.HasPrefix(u.Path, "/meet/"):
+		provider, icon = "Microsoft Teams", "https://teams.live.com/favicon.ico"
+		id = strings.Trim(strings.TrimPrefix(u.Path, "/meet/"), "/")
+	default:
+		return false, nil
+	}
+	res := &Result{
+		URL:       link,
+		Title:     "Join " + provider + " meeting",
+		Type:      "meeting",
+		SiteName:  provider,
+		Favicon:   icon,
+		MeetingID: id,
+	}
+	if id != "" {
+		res.Description = "Meeting ID: " + id
+	}
+	return false, res
+}
+
+var (
+	zoomMeetingPath = regexp.MustCompile(`^/(?:j|w|s|wc/join)/([0-9]{9,11})(?:/|$)`)
+	meetMeetingPath = regexp.MustCompile(`^/([a-z]{3}-[a-z]{4}-[a-z]{3})/?$`)
+)
+
+// formatZoomID groups digits of Zoom meeting id the way Zoom displays them
+func formatZoomID(s string) string {
+	switch len(s) {
+	case 9, 10:
+		return s[:3] + " " + s[3:6] + " " + s[6:]
+	case 11:
+		return s[:3] + " " + s[3:7] + " " + s[7:]
+	}
+	return s
+}
@@ -0,0 +1,35 @@
+package unfurlist
+
+import (
+	"context"
+	"testing"
+)
+
+func TestVideoConferenceHook(t *testing.T) {
+	for _, tc := range []struct {
+		url, provider, id string
+	}{
+		{"https://us02web.zoom.us/j/85412345678?pwd=abc", "Zoom", "854 1234 5678"},
+		{"https://zoom.us/j/1234567890", "Zoom", "123 456 7890"},
+		{"https://meet.google.com/abc-defg-hij", "Google Meet", "abc-defg-hij"},
+		{"https://teams.microsoft.com/l/meetup-join/19%3ameeting_abc%40thread.v2/0", "Microsoft Teams", ""},
+		{"https://teams.live.com/meet/9876543210", "Microsoft Teams", "9876543210"},
+		{"https://zoom.us/pricing", "", ""},
+		{"https://meet.google.com/landing", "", ""},
+		{"https://example.com/j/1234567890", "", ""},
+	} {
+		skip, res := VideoConferenceHook(context.Background(), tc.url)
+		if skip {
+			t.Fatalf("%s: unexpected skip", tc.url)
+		}
+		if tc.provider == "" {
+			if res != nil {
+				t.Errorf("%s: unexpected result %+v", tc.url, res)
+			}
+			continue
+		}
+		if res == nil || res.Type != "meeting" || res.SiteName != tc.provider || res.MeetingID != tc.id || res.URL != tc.url {
+			t.Errorf("%s: unexpected result %+v", tc.url, res)
+		}
+	}
+}
@@ -0,0 +1,212 @@
+package unfurlist
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+	"github.com/golang/snappy"
+)
+
+// MemcacheOptions describes memcache client configuration
+type MemcacheOptions struct {
+	// Servers are addresses of memcached servers, host:port or Unix
+	// socket path. With multiple servers keys are distributed among them
+	// with consistent hashing, so adding or removing a server only moves
+	// a fraction of keys.
+	Servers []string
+	// Timeout is socket read/write timeout, memcache.DefaultTimeout is
+	// used if zero
+	Timeout time.Duration
+	// MaxIdleConns is the number of idle connections kept per server,
+	// memcache.DefaultMaxIdleConns is used if zero
+	MaxIdleConns int
+	// TTL is expiration time of cached results, zero means no expiration
+	TTL time.Duration
+}
+
+// NewMemcacheClient returns memcache client configured with opts. TTL is not
+// a client setting, see WithCacheTTL.
+func NewMemcacheClient(opts MemcacheOptions) (*memcache.Client, error) {
+	if len(opts.Servers) == 0 {
+		return nil, errors.New("no memcache servers")
+	}
+	sel, err := newMemcacheSelector(opts.Servers)
+	if err != nil {
+		return nil, err
+	}
+	c := memcache.NewFromSelector(sel)
+	c.Timeout = opts.Timeout
+	c.MaxIdleConns = opts.MaxIdleConns
+	return c, nil
+}
+
+// WithMemcacheOptions configures unfurl handler to cache metadata in
+// memcached using client created by NewMemcacheClient. If opts are invalid,
+// caching is not enabled.
+func WithMemcacheOptions(opts MemcacheOptions) ConfFunc {
+	c, _ := NewMemcacheClient(opts)
+	return func(h *unfurlHandler) *unfurlHandler {
+		if c != nil {
+			h.cache = memcacheStore{c}
+			h.cacheTTL = opts.TTL
+		}
+		return h
+	}
+}
+
+// WithCacheTTL configures unfurl handler to expire cached results after ttl.
+// By default results do not expire, but may be evicted by memcached.
+func WithCacheTTL(ttl time.Duration) ConfFunc {
+	return func(h *unfurlHandler) *unfurlHandler {
+		if ttl > 0 {
+			h.cacheTTL = ttl
+		}
+		return h
+	}
+}
+
+// memcacheExpiration returns memcached item expiration value for ttl:
+// memcached treats values over 30 days as absolute unix time
+func memcacheExpiration(ttl time.Duration) int32 {
+	const relativeLimit = 30 * 24 * time.Hour
+	switch {
+	case ttl <= 0:
+		return 0
+	case ttl > relativeLimit:
+		return int32(time.Now().Add(ttl).Unix())
+	}
+	return int32((ttl + time.Second - 1) / time.Second)
+}
+
+type cacheBatchKey struct{}
+
+// withCacheBatch looks up results for all links in cache with a single
+// request if cache implements MultiGetter, returning context carrying found
+// results. processURL uses such
+// results instead of making its own cache lookups.
+func (h *unfurlHandler) withCacheBatch(ctx context.Context, links []string) context.Context {
+	mg, ok := h.cache.(MultiGetter)
+	if !ok || len(links) == 0 {
+		return ctx
+	}
+	keys := make([]string, len(links))
+	for i, link := range links {
+		keys[i] = h.cacheKey(ctx, link)
+	}
+	items, err := mg.GetMulti(ctx, keys)
+	if err != nil {
+		h.logf(ctx, "cache lookup: %v", err)
+		return ctx
+	}
+	found := make(map[string]*Result, len(items))
+	for k, v := range items {
+		if res, ok := decodeCached(v); ok {
+			found[k] = res
+		}
+	}
+	return context.WithValue(ctx, cacheBatchKey{}, found)
+}
+
+// cachedResult returns result for link from cache, falling back to cold
+// cache if configured
+func (h *unfurlHandler) cachedResult(ctx context.Context, link string) (*Result, bool) {
+	key := h.cacheKey(ctx, link)
+	if h.cache != nil {
+		if found, ok := ctx.Value(cacheBatchKey{}).(map[string]*Result); ok {
+			if res, ok := found[key]; ok {
+				cached := *res
+				return &cached, true
+			}
+		} else if b, err := h.cache.Get(ctx, key); err == nil {
+			return decodeCached(b)
+		} else if err != ErrCacheMiss {
+			h.logf(ctx, "cache lookup: %v", err)
+		}
+	}
+	if h.cold == nil {
+		return nil, false
+	}
+	b, err := h.cold.Get(ctx, key)
+	if err != nil {
+		if err != ErrCacheMiss {
+			h.logf(ctx, "cold cache lookup: %v", err)
+		}
+		return nil, false
+	}
+	res, ok := decodeCached(b)
+	if ok && h.cache != nil {
+		res.ExpiresAt = ""
+		if h.cacheTTL > 0 {
+			res.ExpiresAt = expiresAt(h.cacheTTL).UTC().Format(time.RFC3339)
+		}
+		if err := h.cache.Set(ctx, key, encodeCached(res), h.cacheTTL); err != nil {
+			h.logf(ctx, "cache update: %v", err)
+		}
+	}
+	return res, ok
+}
+
+// encodeCached returns result encoded for cache, as decoded by decodeCached
+func encodeCached(r *Result) []byte {
+	b, _ := json.Marshal(r)
+	return snappy.Encode(nil, b)
+}
+
+func decodeCached(data []byte) (*Result, bool) {
+	b, err := snappy.Decode(nil, data)
+	if err != nil {
+		return nil, false
+	}
+	var cached Result
+	if err := json.Unmarshal(b, &cached); err != nil {
+		return nil, false
+	}
+	return &cached, true
+}
+
+// memcacheSelector is memcache.ServerSelector distributing keys among
+// servers with consistent hashing
+type memcacheSelector struct {
+	ring  *hashRing
+	addrs []net.Addr
+}
+
+func newMemcacheSelector(servers []string) (*memcacheSelector, error) {
+	addrs := make([]net.Addr, 0, len(servers))
+	for _, s := range servers {
+		var addr net.Addr
+		var err error
+		if strings.Contains(s, "/") {
+			addr, err = net.ResolveUnixAddr("unix", s)
+		} else {
+			addr, err = net.ResolveTCPAddr("tcp", s)
+		}
+		if err != nil {
+			return nil, err
+		}
+		addrs = append(addrs, addr)
+	}
+	return &memcacheSelector{ring: newHashRing(servers), addrs: addrs}, nil
+}
+
+func (s *memcacheSelector) PickServer(key string) (net.Addr, error) {
+	i := s.ring.pick(key)
+	if i < 0 {
+		return nil, memcache.ErrNoServers
+	}
+	return s.addrs[i], nil
+}
+
+func (s *memcacheSelector) Each(fn func(net.Addr) error) error {
+	for _, a := range s.addrs {
+		if err := fn(a); err != nil {
+			return err
+		}
+	}
+	return nil
+}
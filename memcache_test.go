@@ -0,0 +1,172 @@
+package unfurlist
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+// newTestMemcache starts in-process server speaking subset of memcached text
+// protocol sufficient for memcache.Client Get and Set calls, and returns
+// client connected to it
+func newTestMemcache(t *testing.T) *memcache.Client {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	var mu sync.Mutex
+	items := make(map[string][]byte)
+	serve := func(conn net.Conn) {
+		defer conn.Close()
+		rd, wr := bufio.NewReader(conn), bufio.NewWriter(conn)
+		for {
+			line, err := rd.ReadString('\n')
+			if err != nil {
+				return
+			}
+			fields := strings.Fields(line)
+			if len(fields) == 0 {
+				return
+			}
+			switch fields[0] {
+			case "get", "gets":
+				mu.Lock()
+				for _, k := range fields[1:] {
+					if v, ok := items[k]; ok {
+						fmt.Fprintf(wr, "VALUE %s 0 %d 1\r\n%s\r\n", k, len(v), v)
+					}
+				}
+				mu.Unlock()
+				wr.WriteString("END\r\n")
+			case "set":
+				if len(fields) < 5 {
+					return
+				}
+				n, err := strconv.Atoi(fields[4])
+				if err != nil {
+					return
+				}
+				data := make([]byte, n+2)
+				if _, err := io.ReadFull(rd, data); err != nil {
+					return
+				}
+				mu.Lock()
+				items[fields[1]] = data[:n]
+				mu.Unlock()
+				wr.WriteString("STORED\r\n")
+			default:
+				wr.WriteString("ERROR\r\n")
+			}
+			if err := wr.Flush(); err != nil {
+				return
+			}
+		}
+	}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go serve(conn)
+		}
+	}()
+	return memcache.New(ln.Addr().String())
+}
+
+func TestHashRing(t *testing.T) {
+	nodes := []string{"10.0.0.1:11211", "10.0.0.2:11211", "10.0.0.3:11211"}
+	r3 := newHashRing(nodes)
+	r4 := newHashRing(append(nodes, "10.0.0.4:11211"))
+	const n = 10000
+	var moved int
+	perNode := make(map[int]int)
+	for i := 0; i < n; i++ {
+		key := "key" + strconv.Itoa(i)
+		i3, i4 := r3.pick(key), r4.pick(key)
+		perNode[i3]++
+		if i3 != i4 {
+			moved++
+		}
+	}
+	for i, cnt := range perNode {
+		if cnt < n/3/2 {
+			t.Errorf("node %s got only %d of %d keys", nodes[i], cnt, n)
+		}
+	}
+	// ideally a quarter of keys move to the new node
+	if moved > n/3 {
+		t.Errorf("%d of %d keys moved after adding a node", moved, n)
+	}
+}
+
+func TestMemcacheBatch(t *testing.T) {
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/favicon.ico" {
+			http.NotFound(w, r)
+			return
+		}
+		hits.Add(1)
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><head><title>Page</title></head></html>`))
+	}))
+	defer srv.Close()
+	handler := New(WithMemcache(newTestMemcache(t)), WithCacheTTL(time.Hour))
+	content := url.QueryEscape(srv.URL + "/a " + srv.URL + "/b")
+	for i := 0; i < 2; i++ {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/?content="+content, nil))
+		var res []Result
+		if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+			t.Fatal(err)
+		}
+		if len(res) != 2 || res[0].Title != "Page" || res[1].Title != "Page" {
+			t.Fatalf("unexpected results: %+v", res)
+		}
+	}
+	if n := hits.Load(); n != 2 {
+		t.Fatalf("got %d upstream requests, want 2 (second request should be served from cache)", n)
+	}
+	if st := handler.(StatusReporter).Status(); st.CacheHits != 2 || st.CacheMisses != 2 {
+		t.Fatalf("unexpected cache counters: %+v", st)
+	}
+	// cached results served inline are mixed with fetched ones in order
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet,
+		"/?content="+url.QueryEscape(srv.URL+"/b "+srv.URL+"/c "+srv.URL+"/a"), nil))
+	var res []Result
+	if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 3 || res[0].URL != srv.URL+"/b" || res[1].URL != srv.URL+"/c" || res[2].URL != srv.URL+"/a" {
+		t.Fatalf("unexpected results: %+v", res)
+	}
+	if n := hits.Load(); n != 3 {
+		t.Fatalf("got %d upstream requests, want 3", n)
+	}
+}
+
+func TestMemcacheExpiration(t *testing.T) {
+	if got := memcacheExpiration(90 * time.Second); got != 90 {
+		t.Errorf("got %d for relative expiration, want 90", got)
+	}
+	if got := memcacheExpiration(365 * 24 * time.Hour); int64(got) < time.Now().Unix() {
+		t.Errorf("got %d for long expiration, want absolute unix time", got)
+	}
+}
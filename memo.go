@@ -0,0 +1,38 @@
+package unfurlist
+
+import "time"
+
+// WithResultMemo configures unfurl handler to keep results in memory for ttl
+// after they are made, so that requests for the same url arriving within
+// ttl reuse them, even if no cache is configured. This complements
+// collapsing of concurrent requests for the same url. Memo holds at most
+// maxBytes of encoded results, least recently used ones are evicted first.
+// Results of urls that failed to fetch or have no metadata are not memoized.
+func WithResultMemo(ttl time.Duration, maxBytes int64) ConfFunc {
+	return func(h *unfurlHandler) *unfurlHandler {
+		if ttl > 0 && maxBytes > 0 {
+			h.memo, h.memoTTL = newLRUCache(maxBytes), ttl
+		}
+		return h
+	}
+}
+
+// memoized returns result memoized under key
+func (h *unfurlHandler) memoized(key string) (*Result, bool) {
+	if h.memo == nil {
+		return nil, false
+	}
+	b, ok := h.memo.get(key, time.Now())
+	if !ok {
+		return nil, false
+	}
+	return decodeCached(b)
+}
+
+// memoize keeps res under key for h.memoTTL
+func (h *unfurlHandler) memoize(key string, res *Result) {
+	if h.memo == nil || res.err != nil || res.bare() {
+		return
+	}
+	h.memo.set(key, encodeCached(res), time.Now().Add(h.memoTTL))
+}
@@ -0,0 +1,61 @@
+package unfurlist
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// miroOembedEndpoint is Miro oembed endpoint, url of board is appended to it
+const miroOembedEndpoint = "https://miro.com/api/v1/oembed?format=json&url="
+
+var reMiroBoardID = regexp.MustCompile(`^[A-Za-z0-9_-]+=*$`)
+
+// MiroFetcher returns FetchFunc that recognizes miro.com board urls
+// (/app/board/ID/ and /app/live-embed/ID/) and gets board name and snapshot
+// image from Miro oembed endpoint. Only boards shared publicly have
+// previews.
+func MiroFetcher() FetchFunc {
+	return func(ctx context.Context, client *http.Client, u *url.URL) (*Metadata, bool) {
+		if u == nil {
+			return nil, false
+		}
+		if host := strings.ToLower(u.Hostname()); host != "miro.com" && host != "www.miro.com" {
+			return nil, false
+		}
+		p, ok := strings.CutPrefix(u.Path, "/app/board/")
+		if !ok {
+			if p, ok = strings.CutPrefix(u.Path, "/app/live-embed/"); !ok {
+				return nil, false
+			}
+		}
+		id, _, _ := strings.Cut(p, "/")
+		if !reMiroBoardID.MatchString(id) {
+			return nil, false
+		}
+		get := fetcherGet(client)
+		ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		defer cancel()
+		boardURL := "https://miro.com/app/board/" + id + "/"
+		res, err := fetchOembed(ctx, miroOembedEndpoint+url.QueryEscape(boardURL), get)
+		if err != nil || res.Title == "" {
+			return nil, false
+		}
+		meta := &Metadata{
+			Title:        res.Title,
+			Type:         "website",
+			Image:        res.Image,
+			SiteName:     "Miro",
+			HTML:         res.HTML,
+			CanonicalURL: boardURL,
+			Author:       res.Author,
+		}
+		meta.ImageWidth, _ = strconv.Atoi(res.Oembed["thumbnail_width"])
+		meta.ImageHeight, _ = strconv.Atoi(res.Oembed["thumbnail_height"])
+		return meta, true
+	}
+}
@@ -0,0 +1,56 @@
+package unfurlist
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestMiroFetcher(t *testing.T) {
+	client := &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		w := httptest.NewRecorder()
+		if r.URL.Path == "/api/v1/oembed" && r.URL.Query().Get("url") == "https://miro.com/app/board/uXjVO_k9Ab0=/" {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"type":"rich","version":"1.0","title":"Roadmap 2025","provider_name":"Miro",
+"thumbnail_url":"https://miro.medium.com/board/snapshot.png","thumbnail_width":1200,"thumbnail_height":630,
+"html":"<iframe src=\"https://miro.com/app/live-embed/uXjVO_k9Ab0=/\"></iframe>"}`))
+		} else {
+			http.NotFound(w, r)
+		}
+		return w.Result(), nil
+	})}
+	fetch := MiroFetcher()
+	want := Metadata{
+		Title:        "Roadmap 2025",
+		Type:         "website",
+		Image:        "https://miro.medium.com/board/snapshot.png",
+		ImageWidth:   1200,
+		ImageHeight:  630,
+		SiteName:     "Miro",
+		HTML:         `<iframe src="https://miro.com/app/live-embed/uXjVO_k9Ab0=/"></iframe>`,
+		CanonicalURL: "https://miro.com/app/board/uXjVO_k9Ab0=/",
+	}
+	for _, s := range []string{
+		"https://miro.com/app/board/uXjVO_k9Ab0=/",
+		"https://miro.com/app/board/uXjVO_k9Ab0=/?share_link_id=123",
+		"https://miro.com/app/live-embed/uXjVO_k9Ab0=/",
+	} {
+		u, _ := url.Parse(s)
+		meta, ok := fetch(context.Background(), client, u)
+		if !ok || *meta != want {
+			t.Errorf("%s: got %+v, %v", s, meta, ok)
+		}
+	}
+	for _, s := range []string{
+		"https://miro.com/app/board/o9J_private=/",
+		"https://miro.com/app/dashboard/",
+		"https://miro.com/templates/",
+	} {
+		u, _ := url.Parse(s)
+		if meta, ok := fetch(context.Background(), client, u); ok {
+			t.Errorf("%s: unexpected result %+v", s, meta)
+		}
+	}
+}
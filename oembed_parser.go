@@ -1,31 +1,103 @@
 package unfurlist
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"encoding/xml"
+	"io"
 	"net/http"
+	"strings"
 
 	"github.com/artyom/oembed"
 )
 
-func fetchOembed(ctx context.Context, url string, fn func(context.Context, string) (*http.Response, error)) (*unfurlResult, error) {
+// maxOembedSize limits size of oembed provider response
+const maxOembedSize = 1 << 20
+
+func fetchOembed(ctx context.Context, url string, fn func(context.Context, string) (*http.Response, error)) (*Result, error) {
 	resp, err := fn(ctx, url)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxOembedSize))
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
 	meta, err := oembed.FromResponse(resp)
 	if err != nil {
 		return nil, err
 	}
-	res := &unfurlResult{
+	res := &Result{
 		Title:    meta.Title,
 		SiteName: meta.Provider,
 		Type:     string(meta.Type),
 		HTML:     meta.HTML,
 		Image:    meta.Thumbnail,
+		Author:   meta.AuthorName,
+		Oembed:   rawOembed(body),
 	}
 	if meta.Type == oembed.TypePhoto && meta.URL != "" {
 		res.Image = meta.URL
 	}
+	if meta.Type == oembed.TypeVideo {
+		res.Duration = parseSeconds(res.Oembed["duration"])
+	}
 	return res, nil
 }
+
+// rawOembed returns top-level attributes of JSON or XML oembed response as
+// strings: numbers and booleans are in their JSON form, nested JSON values
+// are compact JSON.
+func rawOembed(body []byte) map[string]string {
+	out := make(map[string]string)
+	if b := bytes.TrimSpace(body); len(b) != 0 && b[0] == '{' {
+		var m map[string]json.RawMessage
+		if json.Unmarshal(b, &m) != nil {
+			return nil
+		}
+		for k, v := range m {
+			var s string
+			if json.Unmarshal(v, &s) != nil {
+				buf := new(bytes.Buffer)
+				if json.Compact(buf, v) != nil {
+					continue
+				}
+				s = buf.String()
+			}
+			out[k] = s
+		}
+	} else {
+		dec := xml.NewDecoder(bytes.NewReader(body))
+		var depth int
+		var key string
+		var text strings.Builder
+		for {
+			tok, err := dec.Token()
+			if err != nil {
+				break
+			}
+			switch t := tok.(type) {
+			case xml.StartElement:
+				if depth++; depth == 2 {
+					key = t.Name.Local
+					text.Reset()
+				}
+			case xml.CharData:
+				if depth == 2 {
+					text.Write(t)
+				}
+			case xml.EndElement:
+				if depth--; depth == 1 {
+					out[key] = strings.TrimSpace(text.String())
+				}
+			}
+		}
+	}
+	if len(out) == 0 {
+		return nil
+	}
+	return out
+}
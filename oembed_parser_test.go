@@ -0,0 +1,62 @@
+package unfurlist
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestRawOembed(t *testing.T) {
+	want := map[string]string{
+		"type":          "video",
+		"author_name":   "Author",
+		"cache_age":     "3600",
+		"thumbnail_url": "https://example.com/t.jpg",
+	}
+	got := rawOembed([]byte(`{"type":"video","author_name":"Author","cache_age":3600,"thumbnail_url":"https://example.com/t.jpg"}`))
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("json: got %v, want %v", got, want)
+	}
+	got = rawOembed([]byte(`<?xml version="1.0" encoding="utf-8"?>
+<oembed><type>video</type><author_name>Author</author_name><cache_age>3600</cache_age>
+<thumbnail_url>https://example.com/t.jpg</thumbnail_url></oembed>`))
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("xml: got %v, want %v", got, want)
+	}
+}
+
+func TestIncludeOembed(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/oembed" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"version":"1.0","type":"rich","title":"Embed","provider_url":"https://example.com/","cache_age":60}`))
+	}))
+	defer srv.Close()
+	handler := New(WithOembedLookupFunc(func(u string) (string, bool) {
+		return srv.URL + "/oembed?url=" + url.QueryEscape(u), true
+	}))
+	for _, include := range []string{"", "oembed"} {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet,
+			"/?include="+include+"&content="+url.QueryEscape(srv.URL+"/page"), nil))
+		var res []Result
+		if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+			t.Fatal(err)
+		}
+		if len(res) != 1 || res[0].Title != "Embed" {
+			t.Fatalf("include=%q: unexpected results: %+v", include, res)
+		}
+		switch got := res[0].Oembed; {
+		case include == "" && got != nil:
+			t.Errorf("include=%q: got oembed %v", include, got)
+		case include != "" && (got["provider_url"] != "https://example.com/" || got["cache_age"] != "60"):
+			t.Errorf("include=%q: got oembed %v", include, got)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package unfurlist
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/artyom/oembed"
+)
+
+// DefaultOembedProvidersURL is the address of oembed providers list maintained
+// at oembed.com
+const DefaultOembedProvidersURL = "https://oembed.com/providers.json"
+
+// providersRefresher periodically re-downloads oembed providers list
+type providersRefresher struct {
+	url      string
+	interval time.Duration
+	last     atomic.Int64 // unix nanoseconds of the last refresh attempt
+	running  atomic.Bool
+}
+
+// oembedLookup is an oembed.LookupFunc using currently loaded providers list.
+// If providers refresh is enabled and the list is stale, it triggers
+// background refresh.
+func (h *unfurlHandler) oembedLookup(url string) (string, bool) {
+	if r := h.providersRefresh; r != nil &&
+		time.Since(time.Unix(0, r.last.Load())) > r.interval &&
+		r.running.CompareAndSwap(false, true) {
+		r.last.Store(time.Now().UnixNano())
+		go func() {
+			defer r.running.Store(false)
+			if err := h.refreshProviders(r.url); err != nil {
+				h.Log.Printf("oembed providers refresh from %q: %v", r.url, err)
+			}
+		}()
+	}
+	fn := h.oembedFn.Load()
+	if fn == nil {
+		return "", false
+	}
+	return (*fn)(url)
+}
+
+// refreshProviders downloads oembed providers list from given url and
+// replaces currently used one. On any failure currently used list is kept
+// intact.
+func (h *unfurlHandler) refreshProviders(url string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := h.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return errors.New(resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 10<<20))
+	if err != nil {
+		return err
+	}
+	fn, err := oembed.Providers(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	h.oembedFn.Store(&fn)
+	sum := sha256.Sum256(data)
+	h.stats.providers.Store(&ListVersion{Loaded: time.Now().UTC(), Source: url, Digest: hex.EncodeToString(sum[:8])})
+	h.Log.Printf("oembed providers list refreshed from %q", url)
+	return nil
+}
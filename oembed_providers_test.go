@@ -0,0 +1,33 @@
+package unfurlist
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestOembedProvidersRefresh(t *testing.T) {
+	const providers = `[{"provider_name": "Example", "provider_url": "https://example.com",
+		"endpoints": [{"schemes": ["https://example.com/video/*"], "url": "https://example.com/oembed"}]}]`
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(providers))
+	}))
+	defer srv.Close()
+	h := New(WithOembedProvidersRefresh(srv.URL, time.Nanosecond)).(*unfurlHandler)
+	const link = "https://example.com/video/123"
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, ok := h.oembedLookup(link); ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("providers list was not refreshed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if _, ok := h.oembedLookup("https://www.youtube.com/watch?v=dQw4w9WgXcQ"); ok {
+		t.Fatal("providers list should be replaced with refreshed one")
+	}
+}
@@ -0,0 +1,212 @@
+package unfurlist
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+// serveOpenAPI serves OpenAPI 3 specification of unfurl handler endpoints
+func serveOpenAPI(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+	default:
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	spec, err := openAPISpec()
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(spec)
+}
+
+var openAPISpec = sync.OnceValues(func() ([]byte, error) {
+	return json.MarshalIndent(buildOpenAPISpec(), "", "  ")
+})
+
+type object = map[string]any
+
+// buildOpenAPISpec returns OpenAPI specification generated from requestArgs,
+// Result, Envelope and Job types, so that it's always in sync with them.
+func buildOpenAPISpec() object {
+	var params []object
+	formProps := make(object)
+	t := reflect.TypeOf(requestArgs{})
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, desc, _ := strings.Cut(f.Tag.Get("flag"), ",")
+		if name == "" {
+			continue
+		}
+		schema := jsonSchema(f.Type)
+		params = append(params, object{
+			"name":        name,
+			"in":          "query",
+			"description": desc,
+			"required":    name == "content",
+			"schema":      schema,
+		})
+		formProps[name] = object{"description": desc, "type": schema["type"]}
+	}
+	responses := func(body object) object {
+		content := object{
+			mediaJSON:                  object{"schema": body},
+			mediaMsgpack:               object{"schema": body},
+			mediaProtobuf:              object{"schema": object{"type": "string", "format": "binary", "description": "see unfurlist.proto"}},
+			"application/x-javascript": object{"schema": object{"type": "string", "description": "JSONP response"}},
+		}
+		return object{
+			"200": object{"description": "urls metadata", "content": content},
+			"304": object{"description": "not modified, response matches If-None-Match header"},
+			"400": object{"description": "malformed request"},
+			"403": object{"description": "request signature is missing or invalid"},
+			"413": object{"description": "request content is too large"},
+		}
+	}
+	formBody := object{
+		"required": true,
+		"content": object{
+			"application/x-www-form-urlencoded": object{"schema": object{
+				"type":       "object",
+				"properties": formProps,
+				"required":   []string{"content"},
+			}},
+		},
+	}
+	operation := func(summary string, body object) object {
+		op := object{
+			"summary":    summary,
+			"parameters": params,
+			"responses":  responses(body),
+		}
+		post := object{
+			"summary":     summary,
+			"responses":   responses(body),
+			"requestBody": formBody,
+		}
+		return object{"get": op, "post": post}
+	}
+	accepted := object{
+		"description": "request is accepted for processing",
+		"content": object{mediaJSON: object{"schema": object{
+			"type":       "object",
+			"properties": object{"job_id": object{"type": "string"}},
+		}}},
+	}
+	return object{
+		"openapi": "3.0.3",
+		"info": object{
+			"title":       "unfurlist",
+			"description": "Extracts urls from text and returns their metadata",
+			"version":     "1",
+		},
+		"paths": object{
+			"/": operation("Unfurl urls (legacy response format)",
+				object{"type": "array", "items": object{"$ref": "#/components/schemas/Result"}}),
+			"/v1/unfurl": operation("Unfurl urls",
+				object{"$ref": "#/components/schemas/Envelope"}),
+			"/jobs": object{"post": object{
+				"summary":     "Submit urls to unfurl as a job",
+				"requestBody": formBody,
+				"responses": object{
+					"202": accepted,
+					"400": object{"description": "malformed request"},
+					"403": object{"description": "request signature is missing or invalid"},
+					"413": object{"description": "request content is too large"},
+					"501": object{"description": "jobs are not supported by server configuration"},
+					"503": object{"description": "too many jobs in progress, retry later"},
+				},
+			}},
+			"/jobs/{id}": object{"get": object{
+				"summary": "Get job status and results",
+				"parameters": []object{{
+					"name": "id", "in": "path", "required": true, "schema": object{"type": "string"},
+				}},
+				"responses": object{
+					"200": object{"description": "job state", "content": object{
+						mediaJSON: object{"schema": object{"$ref": "#/components/schemas/Job"}},
+					}},
+					"404": object{"description": "job not found or expired"},
+				},
+			}},
+			"/thumbnail": object{"get": object{
+				"summary": "Get resized image by signed url from result thumbnail attribute",
+				"parameters": []object{
+					{"name": "url", "in": "query", "required": true, "schema": object{"type": "string"}},
+					{"name": "sig", "in": "query", "required": true, "schema": object{"type": "string"}},
+					{"name": "w", "in": "query", "schema": object{"type": "integer", "maximum": maxThumbnailSize}},
+					{"name": "h", "in": "query", "schema": object{"type": "integer", "maximum": maxThumbnailSize}},
+				},
+				"responses": object{
+					"200": object{"description": "jpeg or png image"},
+					"400": object{"description": "malformed request"},
+					"403": object{"description": "signature is invalid"},
+					"404": object{"description": "thumbnails are not enabled by server configuration"},
+					"502": object{"description": "image cannot be fetched or decoded"},
+				},
+			}},
+		},
+		"components": object{
+			"schemas": object{
+				"Result":      jsonSchema(reflect.TypeOf(Result{})),
+				"ResultError": jsonSchema(reflect.TypeOf(ResultError{})),
+				"Envelope":    jsonSchema(reflect.TypeOf(Envelope{})),
+				"Job":         jsonSchema(reflect.TypeOf(Job{})),
+			},
+		},
+	}
+}
+
+// jsonSchema returns OpenAPI schema for the type. Named struct types
+// (other than one the schema is requested for) are referenced as components.
+func jsonSchema(t reflect.Type) object {
+	return jsonSchemaRef(t, true)
+}
+
+func jsonSchemaRef(t reflect.Type, top bool) object {
+	if t == reflect.TypeOf(time.Duration(0)) {
+		return object{"type": "string", "description": "duration, i.e. 300ms, 1.5s"}
+	}
+	switch t.Kind() {
+	case reflect.Pointer:
+		return jsonSchemaRef(t.Elem(), top)
+	case reflect.Bool:
+		return object{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return object{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return object{"type": "number"}
+	case reflect.String:
+		return object{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		return object{"type": "array", "items": jsonSchemaRef(t.Elem(), false)}
+	case reflect.Map:
+		return object{"type": "object", "additionalProperties": jsonSchemaRef(t.Elem(), false)}
+	case reflect.Struct:
+		if !top && t.Name() != "" {
+			return object{"$ref": "#/components/schemas/" + t.Name()}
+		}
+		props := make(object)
+		var required []string
+		for _, f := range structFields(t) {
+			props[f.name] = jsonSchemaRef(t.Field(f.index).Type, false)
+			if !f.omitEmpty {
+				required = append(required, f.name)
+			}
+		}
+		schema := object{"type": "object", "properties": props}
+		if len(required) > 0 {
+			schema["required"] = required
+		}
+		return schema
+	}
+	return object{}
+}
@@ -0,0 +1,39 @@
+package unfurlist
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestOpenAPI(t *testing.T) {
+	w := httptest.NewRecorder()
+	New().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected status: %d", w.Code)
+	}
+	var spec struct {
+		Paths      map[string]map[string]json.RawMessage
+		Components struct {
+			Schemas map[string]struct {
+				Properties map[string]json.RawMessage
+			}
+		}
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &spec); err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []string{"/", "/v1/unfurl"} {
+		if _, ok := spec.Paths[p]["get"]; !ok {
+			t.Errorf("no GET operation for %q path", p)
+		}
+	}
+	props := spec.Components.Schemas["Result"].Properties
+	for _, f := range structFields(reflect.TypeOf(Result{})) {
+		if _, ok := props[f.name]; !ok {
+			t.Errorf("Result schema has no %q property", f.name)
+		}
+	}
+}
@@ -13,7 +13,7 @@ import (
 	"github.com/dyatlov/go-opengraph/opengraph"
 )
 
-func openGraphParseHTML(chunk *pageChunk) *unfurlResult {
+func openGraphParseHTML(chunk *pageChunk) *Result {
 	if !strings.HasPrefix(http.DetectContentType(chunk.data), "text/html") {
 		return nil
 	}
@@ -32,7 +32,7 @@ func openGraphParseHTML(chunk *pageChunk) *unfurlResult {
 	if err != nil || og.Title == "" {
 		return nil
 	}
-	res := &unfurlResult{
+	res := &Result{
 		Type:        og.Type,
 		Title:       og.Title,
 		Description: og.Description,
@@ -0,0 +1,160 @@
+package unfurlist
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// TemplateStaticMaps returns StaticMapProvider creating image urls from
+// template with {lat}, {lng} and {zoom} placeholders, i.e. url of
+// OpenStreetMap-based static map service. Width and height are dimensions of
+// resulting images. Only "lat,lng" locations are supported.
+func TemplateStaticMaps(template string, width, height int) StaticMapProvider {
+	return templateStaticMaps{template: template, width: width, height: height}
+}
+
+type templateStaticMaps struct {
+	template      string
+	width, height int
+}
+
+func (t templateStaticMaps) StaticMap(location string, zoom int) (string, int, int) {
+	lat, lng, ok := parseLatLng(location)
+	if !ok {
+		return "", 0, 0
+	}
+	if zoom <= 0 {
+		zoom = 15
+	}
+	s := strings.NewReplacer(
+		"{lat}", strconv.FormatFloat(lat, 'f', -1, 64),
+		"{lng}", strconv.FormatFloat(lng, 'f', -1, 64),
+		"{zoom}", strconv.Itoa(zoom),
+	).Replace(t.template)
+	return s, t.width, t.height
+}
+
+// OpenStreetMapFetcher returns FetchFunc that recognizes openstreetmap.org
+// urls with #map=zoom/lat/lng fragment or mlat/mlon arguments and geo: URIs
+// (RFC 5870), and constructs metadata for them with preview image from p, if
+// it's not nil. If reverseURL is not empty, it must be an endpoint compatible
+// with Nominatim reverse geocoding API
+// (https://nominatim.openstreetmap.org/reverse), used to get human-readable
+// titles; otherwise coordinates are used as titles.
+//
+// To handle geo: URIs, register fetcher with "geo:" pattern.
+func OpenStreetMapFetcher(p StaticMapProvider, reverseURL string) FetchFunc {
+	return func(ctx context.Context, client *http.Client, u *url.URL) (*Metadata, bool) {
+		if u == nil {
+			return nil, false
+		}
+		var lat, lng float64
+		var zoom int
+		var ok bool
+		meta := &Metadata{Type: "website"}
+		switch {
+		case u.Scheme == "geo":
+			lat, lng, zoom, ok = coordsFromGeoURI(u)
+		case u.Host == "openstreetmap.org" || u.Host == "www.openstreetmap.org":
+			lat, lng, zoom, ok = coordsFromOSM(u)
+			meta.SiteName = "OpenStreetMap"
+			if !ok {
+				meta.Title = "OpenStreetMap"
+				return meta, true
+			}
+		}
+		if !ok {
+			return nil, false
+		}
+		coords := strconv.FormatFloat(lat, 'f', -1, 64) + "," + strconv.FormatFloat(lng, 'f', -1, 64)
+		meta.Title = coords
+		if reverseURL != "" && client != nil {
+			if name, err := reverseGeocode(ctx, client, reverseURL, lat, lng); err == nil && name != "" {
+				meta.Title = name
+			}
+		}
+		if p != nil {
+			meta.Image, meta.ImageWidth, meta.ImageHeight = p.StaticMap(coords, zoom)
+		}
+		return meta, true
+	}
+}
+
+var osmMapFragment = regexp.MustCompile(`(?:^|&)map=(\d+)/(-?[0-9.]+)/(-?[0-9.]+)`)
+
+// coordsFromOSM extracts coordinates and zoom from openstreetmap.org url, i.e.
+// https://www.openstreetmap.org/?mlat=52.52&mlon=13.40#map=15/52.52/13.40
+func coordsFromOSM(u *url.URL) (lat, lng float64, zoom int, ok bool) {
+	if m := osmMapFragment.FindStringSubmatch(u.Fragment); m != nil {
+		zoom, _ = strconv.Atoi(m[1])
+		lat, lng, ok = parseLatLng(m[2] + "," + m[3])
+	}
+	q := u.Query()
+	if mlat, mlon := q.Get("mlat"), q.Get("mlon"); mlat != "" && mlon != "" {
+		if lat2, lng2, ok2 := parseLatLng(mlat + "," + mlon); ok2 {
+			lat, lng, ok = lat2, lng2, true
+		}
+	}
+	return lat, lng, zoom, ok
+}
+
+// coordsFromGeoURI extracts coordinates and zoom from geo: URI, i.e.
+// geo:37.786971,-122.399677;u=35?z=15
+func coordsFromGeoURI(u *url.URL) (lat, lng float64, zoom int, ok bool) {
+	s, _, _ := strings.Cut(u.Opaque, ";")
+	parts := strings.Split(s, ",")
+	if len(parts) < 2 {
+		return 0, 0, 0, false
+	}
+	lat, lng, ok = parseLatLng(parts[0] + "," + parts[1])
+	zoom, _ = strconv.Atoi(u.Query().Get("z"))
+	return lat, lng, zoom, ok
+}
+
+// parseLatLng parses "lat,lng" pair
+func parseLatLng(s string) (lat, lng float64, ok bool) {
+	a, b, found := strings.Cut(s, ",")
+	if !found {
+		return 0, 0, false
+	}
+	lat, err1 := strconv.ParseFloat(strings.TrimSpace(a), 64)
+	lng, err2 := strconv.ParseFloat(strings.TrimSpace(b), 64)
+	if err1 != nil || err2 != nil || lat < -90 || lat > 90 || lng < -180 || lng > 180 {
+		return 0, 0, false
+	}
+	return lat, lng, true
+}
+
+// reverseGeocode returns name of the place at coordinates using Nominatim
+// compatible endpoint
+func reverseGeocode(ctx context.Context, client *http.Client, endpoint string, lat, lng float64) (string, error) {
+	vals := url.Values{
+		"format": {"jsonv2"},
+		"lat":    {strconv.FormatFloat(lat, 'f', -1, 64)},
+		"lon":    {strconv.FormatFloat(lng, 'f', -1, 64)},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint+"?"+vals.Encode(), nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", &statusError{code: resp.StatusCode, status: resp.Status}
+	}
+	var out struct {
+		DisplayName string `json:"display_name"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return "", err
+	}
+	return out.DisplayName, nil
+}
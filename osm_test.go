@@ -0,0 +1,67 @@
+package unfurlist
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestOpenStreetMapFetcher(t *testing.T) {
+	nominatim := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("lat") == "52.52" && r.URL.Query().Get("lon") == "13.405" {
+			w.Write([]byte(`{"display_name":"Mitte, Berlin, Germany"}`))
+			return
+		}
+		w.Write([]byte(`{"error":"Unable to geocode"}`))
+	}))
+	defer nominatim.Close()
+	tiles := TemplateStaticMaps("https://tiles.example.com/map?c={lat},{lng}&z={zoom}", 600, 400)
+	fetch := OpenStreetMapFetcher(tiles, nominatim.URL)
+	for _, tc := range []struct {
+		url, title, image string
+	}{
+		{"https://www.openstreetmap.org/#map=14/52.52/13.405", "Mitte, Berlin, Germany", "https://tiles.example.com/map?c=52.52,13.405&z=14"},
+		{"https://www.openstreetmap.org/?mlat=48.8584&mlon=2.2945#map=17/48.8/2.2", "48.8584,2.2945", "https://tiles.example.com/map?c=48.8584,2.2945&z=17"},
+		{"geo:37.786971,-122.399677;u=35", "37.786971,-122.399677", "https://tiles.example.com/map?c=37.786971,-122.399677&z=15"},
+	} {
+		u, err := url.Parse(tc.url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		meta, ok := fetch(context.Background(), nominatim.Client(), u)
+		if !ok {
+			t.Fatalf("%s: not recognized", tc.url)
+		}
+		if meta.Title != tc.title || meta.Image != tc.image || meta.ImageWidth != 600 {
+			t.Errorf("%s: got %+v", tc.url, meta)
+		}
+	}
+}
+
+func TestGeoURIs(t *testing.T) {
+	fetchers := new(FetcherRegistry)
+	if err := fetchers.RegisterFetcher("geo:", 0, NamedFetcher("osm", OpenStreetMapFetcher(nil, ""))); err != nil {
+		t.Fatal(err)
+	}
+	handler := New(WithFetcherRegistry(fetchers))
+	content := url.QueryEscape("meet at geo:51.5007,-0.1246. See you!")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/?content="+content, nil))
+	var res []Result
+	if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 1 || res[0].URL != "geo:51.5007,-0.1246" || res[0].Title != "51.5007,-0.1246" {
+		t.Fatalf("unexpected results: %+v", res)
+	}
+	// without geo: fetchers such URIs are not extracted
+	w = httptest.NewRecorder()
+	New().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/?content="+content, nil))
+	if strings.TrimSpace(w.Body.String()) != "[]" {
+		t.Fatalf("unexpected response: %s", w.Body)
+	}
+}
@@ -0,0 +1,93 @@
+package unfurlist
+
+import (
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+)
+
+// WithMaxOutboundRequests configures unfurl handler to make at most n
+// outbound http requests at a time: page fetches, favicon, image and oembed
+// requests, requests made by fetchers. Requests over the limit wait in queue
+// until earlier ones complete or their context is done. Request holds its
+// slot until its response body is closed. If n is not positive, number of
+// requests is not limited, which is the default.
+//
+// Unlike WithMaxConcurrentFetches, which limits number of urls processed,
+// this limits number of connections in use, as processing single url may
+// take several requests.
+func WithMaxOutboundRequests(n int) ConfFunc {
+	return func(h *unfurlHandler) *unfurlHandler {
+		if n > 0 {
+			h.outbound = &outboundLimiter{sem: make(chan struct{}, n)}
+		}
+		return h
+	}
+}
+
+// outboundLimiter is http.RoundTripper limiting number of concurrent
+// requests made with underlying transport
+type outboundLimiter struct {
+	rt     http.RoundTripper
+	sem    chan struct{}
+	statsd *statsdClient
+
+	active atomic.Int64 // requests holding slot
+	queued atomic.Int64 // requests waiting for slot
+}
+
+// wrap returns copy of client with transport limited by l
+func (l *outboundLimiter) wrap(client *http.Client) *http.Client {
+	c := *client
+	l.rt = c.Transport
+	if l.rt == nil {
+		l.rt = http.DefaultTransport
+	}
+	c.Transport = l
+	return &c
+}
+
+func (l *outboundLimiter) RoundTrip(req *http.Request) (*http.Response, error) {
+	select {
+	case l.sem <- struct{}{}:
+	default:
+		l.statsd.gauge("outbound.queued", l.queued.Add(1))
+		select {
+		case l.sem <- struct{}{}:
+			l.statsd.gauge("outbound.queued", l.queued.Add(-1))
+		case <-req.Context().Done():
+			l.statsd.gauge("outbound.queued", l.queued.Add(-1))
+			if req.Body != nil {
+				req.Body.Close()
+			}
+			return nil, req.Context().Err()
+		}
+	}
+	l.active.Add(1)
+	resp, err := l.rt.RoundTrip(req)
+	if err != nil || resp.Body == nil {
+		l.release()
+		return resp, err
+	}
+	resp.Body = &releaseOnClose{ReadCloser: resp.Body, release: l.release}
+	return resp, nil
+}
+
+func (l *outboundLimiter) release() {
+	l.active.Add(-1)
+	<-l.sem
+}
+
+// releaseOnClose calls release once body is closed
+type releaseOnClose struct {
+	io.ReadCloser
+	once    sync.Once
+	release func()
+}
+
+func (b *releaseOnClose) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.release)
+	return err
+}
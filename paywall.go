@@ -0,0 +1,111 @@
+package unfurlist
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+	"golang.org/x/net/html/charset"
+)
+
+// detectPaywall reports whether html page marks its content as not freely
+// accessible: either with isAccessibleForFree=false in JSON-LD structured
+// data, or with article:content_tier meta tag set to "locked" or "metered".
+func detectPaywall(chunk *pageChunk) bool {
+	rd, err := charset.NewReader(bytes.NewReader(chunk.data), chunk.ct)
+	if err != nil {
+		return false
+	}
+	z := html.NewTokenizer(rd)
+	inLD := false
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return false
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, hasAttr := z.TagName()
+			if !hasAttr {
+				continue
+			}
+			switch atom.Lookup(name) {
+			case atom.Script:
+				inLD = attrValue(z, "type") == "application/ld+json"
+			case atom.Meta:
+				var key, content string
+				for more := true; more; {
+					var k, v []byte
+					k, v, more = z.TagAttr()
+					switch string(k) {
+					case "name", "property":
+						key = string(v)
+					case "content":
+						content = strings.ToLower(strings.TrimSpace(string(v)))
+					}
+				}
+				if key == "article:content_tier" && (content == "locked" || content == "metered") {
+					return true
+				}
+			}
+		case html.TextToken:
+			if !inLD {
+				continue
+			}
+			inLD = false
+			var v any
+			if json.Unmarshal(z.Text(), &v) == nil && notAccessibleForFree(v) {
+				return true
+			}
+		case html.EndTagToken:
+			inLD = false
+		}
+	}
+}
+
+// attrValue returns value of the named attribute of the current tag,
+// consuming all attributes
+func attrValue(z *html.Tokenizer, name string) string {
+	var out string
+	for more := true; more; {
+		var k, v []byte
+		k, v, more = z.TagAttr()
+		if string(k) == name {
+			out = string(v)
+		}
+	}
+	return out
+}
+
+// notAccessibleForFree walks decoded JSON-LD looking for
+// isAccessibleForFree property set to false
+func notAccessibleForFree(v any) bool {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, val := range v {
+			if k == "isAccessibleForFree" {
+				switch val := val.(type) {
+				case bool:
+					if !val {
+						return true
+					}
+				case string:
+					if strings.EqualFold(val, "false") {
+						return true
+					}
+				}
+				continue
+			}
+			if notAccessibleForFree(val) {
+				return true
+			}
+		}
+	case []any:
+		for _, val := range v {
+			if notAccessibleForFree(val) {
+				return true
+			}
+		}
+	}
+	return false
+}
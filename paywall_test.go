@@ -0,0 +1,29 @@
+package unfurlist
+
+import (
+	"testing"
+)
+
+func TestDetectPaywall(t *testing.T) {
+	for _, tc := range []struct {
+		html string
+		want bool
+	}{
+		{`<html><head><title>Free</title></head></html>`, false},
+		{`<html><head><script type="application/ld+json">
+{"@context":"https://schema.org","@type":"NewsArticle","isAccessibleForFree":false}
+</script></head></html>`, true},
+		{`<html><head><script type="application/ld+json">
+[{"@type":"WebPage"},{"@type":"Article","hasPart":{"isAccessibleForFree":"False"}}]
+</script></head></html>`, true},
+		{`<html><head><script type="application/ld+json">{"isAccessibleForFree":true}</script></head></html>`, false},
+		{`<html><head><script>var x = {"isAccessibleForFree":false}</script></head></html>`, false},
+		{`<html><head><meta property="article:content_tier" content="locked"></head></html>`, true},
+		{`<html><head><meta name="article:content_tier" content="free"></head></html>`, false},
+	} {
+		chunk := &pageChunk{data: []byte(tc.html), ct: "text/html; charset=utf-8"}
+		if got := detectPaywall(chunk); got != tc.want {
+			t.Errorf("detectPaywall(%q) = %v, want %v", tc.html, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package unfurlist
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// PeerCache is Cache shared by a fleet of unfurlist instances without
+// external cache server. Keys are distributed among peers with consistent
+// hashing, each peer keeps values it owns in memory, evicting least recently
+// used ones once over the size limit. Values fetched from other peers are
+// also kept locally for a short time, so hot keys don't hit their owner on
+// each request.
+//
+// Peers talk to each other over HTTP: PeerCache is http.Handler that must be
+// served at the url this instance is listed under in the peers list. It
+// should not be exposed to untrusted clients.
+type PeerCache struct {
+	self   string
+	client *http.Client
+	peers  atomic.Pointer[hashRing]
+	local  *lruCache // values owned by this peer
+	hot    *lruCache // values owned by other peers
+}
+
+// peerHotTTL is how long values fetched from other peers are kept locally
+const peerHotTTL = time.Minute
+
+// NewPeerCache returns PeerCache for instance reachable by other peers at
+// self url, i.e. "http://10.0.0.1:8081". Peers is the list of urls of all
+// peers, self is added to it if missing. Values are kept in memory up to
+// maxBytes total.
+func NewPeerCache(self string, peers []string, maxBytes int64) *PeerCache {
+	c := &PeerCache{
+		self:   strings.TrimSuffix(self, "/"),
+		client: &http.Client{Timeout: 2 * time.Second},
+		local:  newLRUCache(maxBytes),
+		hot:    newLRUCache(maxBytes / 8),
+	}
+	c.SetPeers(peers)
+	return c
+}
+
+// SetPeers replaces the list of peers, i.e. when fleet is scaled
+func (c *PeerCache) SetPeers(peers []string) {
+	list := make([]string, 0, len(peers)+1)
+	for _, p := range peers {
+		list = append(list, strings.TrimSuffix(p, "/"))
+	}
+	if !slices.Contains(list, c.self) {
+		list = append(list, c.self)
+	}
+	slices.Sort(list)
+	list = slices.Compact(list)
+	c.peers.Store(newHashRing(list))
+}
+
+// owner returns url of the peer owning key
+func (c *PeerCache) owner(key string) string {
+	r := c.peers.Load()
+	return r.nodes[r.pick(key)]
+}
+
+func (c *PeerCache) Get(ctx context.Context, key string) ([]byte, error) {
+	now := time.Now()
+	owner := c.owner(key)
+	if owner == c.self {
+		if v, ok := c.local.get(key, now); ok {
+			return v, nil
+		}
+		return nil, ErrCacheMiss
+	}
+	if v, ok := c.hot.get(key, now); ok {
+		return v, nil
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, owner+"/"+url.PathEscape(key), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, ErrCacheMiss
+	default:
+		return nil, fmt.Errorf("peer %s: %s", owner, resp.Status)
+	}
+	v, err := io.ReadAll(io.LimitReader(resp.Body, 10<<20))
+	if err != nil {
+		return nil, err
+	}
+	c.hot.set(key, v, now.Add(peerHotTTL))
+	return v, nil
+}
+
+func (c *PeerCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	owner := c.owner(key)
+	if owner == c.self {
+		c.local.set(key, value, expiresAt(ttl))
+		return nil
+	}
+	u := owner + "/" + url.PathEscape(key)
+	if ttl > 0 {
+		u += "?ttl=" + strconv.FormatInt(int64(ttl/time.Second), 10)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, u, bytes.NewReader(value))
+	if err != nil {
+		return err
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		return fmt.Errorf("peer %s: %s", owner, resp.Status)
+	}
+	c.hot.set(key, value, time.Now().Add(peerHotTTL))
+	return nil
+}
+
+// ServeHTTP serves requests of other peers: GET /{key} to get value, PUT
+// /{key}?ttl={seconds} to store it.
+func (c *PeerCache) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	key := strings.TrimPrefix(r.URL.Path, "/")
+	if key == "" {
+		http.NotFound(w, r)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		v, ok := c.local.get(key, time.Now())
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Write(v)
+	case http.MethodPut:
+		v, err := io.ReadAll(io.LimitReader(r.Body, 10<<20))
+		if err != nil {
+			http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+			return
+		}
+		var ttl time.Duration
+		if s := r.URL.Query().Get("ttl"); s != "" {
+			n, err := strconv.ParseInt(s, 10, 64)
+			if err != nil {
+				http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+				return
+			}
+			ttl = time.Duration(n) * time.Second
+		}
+		c.local.set(key, v, expiresAt(ttl))
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.Header().Set("Allow", "GET, PUT")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	}
+}
+
+// expiresAt returns expiration time for ttl, zero time if ttl is not
+// positive
+func expiresAt(ttl time.Duration) time.Time {
+	if ttl <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(ttl)
+}
@@ -0,0 +1,72 @@
+package unfurlist
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestPeerCache(t *testing.T) {
+	var handlers [3]http.Handler
+	var urls []string
+	for i := range handlers {
+		i := i
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			handlers[i].ServeHTTP(w, r)
+		}))
+		defer srv.Close()
+		urls = append(urls, srv.URL)
+	}
+	var caches []*PeerCache
+	for i, u := range urls {
+		c := NewPeerCache(u, urls, 1<<20)
+		handlers[i] = c
+		caches = append(caches, c)
+	}
+	ctx := context.Background()
+	for i := 0; i < 20; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		if err := caches[i%3].Set(ctx, key, []byte(key), time.Hour); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; i < 20; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		for j, c := range caches {
+			v, err := c.Get(ctx, key)
+			if err != nil {
+				t.Fatalf("peer %d, key %q: %v", j, key, err)
+			}
+			if string(v) != key {
+				t.Fatalf("peer %d, key %q: got %q", j, key, v)
+			}
+		}
+	}
+	if _, err := caches[0].Get(ctx, "missing"); err != ErrCacheMiss {
+		t.Fatalf("got %v, want ErrCacheMiss", err)
+	}
+}
+
+func TestLRUCache(t *testing.T) {
+	c := newLRUCache(15)
+	now := time.Now()
+	c.set("a", []byte("12345"), time.Time{})
+	c.set("b", []byte("12345"), now.Add(-time.Second))
+	if _, ok := c.get("b", now); ok {
+		t.Fatal("expired value returned")
+	}
+	c.get("a", now)
+	c.set("c", []byte("12345"), time.Time{})
+	c.set("d", []byte("12345"), time.Time{}) // evicts least recently used "a"
+	if _, ok := c.get("a", now); ok {
+		t.Fatal("least recently used value was not evicted")
+	}
+	for _, k := range []string{"c", "d"} {
+		if _, ok := c.get(k, now); !ok {
+			t.Fatalf("value %q was evicted", k)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package unfurlist
+
+import "strings"
+
+// productOffer returns price, currency and availability of product page,
+// taken from product:price:* (or og:price:*) and product:availability meta
+// tags, as collected by extractRawMeta, or from schema.org Offer in JSON-LD
+// blocks ld.
+// Availability is returned as schema.org ItemAvailability name, i.e.
+// "InStock" or "OutOfStock".
+func productOffer(ld []any, meta map[string]string) (price, currency, availability string) {
+	price = firstNonEmpty(meta["product:price:amount"], meta["og:price:amount"])
+	currency = firstNonEmpty(meta["product:price:currency"], meta["og:price:currency"])
+	availability = normalizeAvailability(firstNonEmpty(meta["product:availability"], meta["og:availability"]))
+	if price != "" {
+		return price, strings.ToUpper(currency), availability
+	}
+	for _, v := range ld {
+		if offer := findOffer(v); offer != nil {
+			price = jsonLDString(offer["price"])
+			if price == "" {
+				price = jsonLDString(offer["lowPrice"])
+			}
+			currency = jsonLDString(offer["priceCurrency"])
+			if a := normalizeAvailability(jsonLDString(offer["availability"])); a != "" {
+				availability = a
+			}
+			break
+		}
+	}
+	return price, strings.ToUpper(currency), availability
+}
+
+// findOffer walks decoded JSON-LD looking for object of Offer or
+// AggregateOffer type, either top-level or in offers of Product
+func findOffer(v any) map[string]any {
+	isOffer := func(t string) bool { return t == "Offer" || t == "AggregateOffer" }
+	if offer := jsonLDTyped(v, isOffer); offer != nil {
+		return offer
+	}
+	if product := jsonLDTyped(v, func(t string) bool { return t == "Product" }); product != nil {
+		return jsonLDTyped(product["offers"], isOffer)
+	}
+	return nil
+}
+
+// normalizeAvailability returns schema.org ItemAvailability name for either
+// schema.org url or name, or Open Graph product:availability value
+func normalizeAvailability(s string) string {
+	s = strings.TrimSpace(s)
+	for _, prefix := range [...]string{"https://schema.org/", "http://schema.org/", "schema:"} {
+		if len(s) > len(prefix) && strings.EqualFold(s[:len(prefix)], prefix) {
+			s = s[len(prefix):]
+			break
+		}
+	}
+	switch strings.ToLower(strings.Join(strings.Fields(s), "")) {
+	case "":
+		return ""
+	case "instock", "available":
+		return "InStock"
+	case "outofstock", "oos":
+		return "OutOfStock"
+	case "preorder":
+		return "PreOrder"
+	case "backorder":
+		return "BackOrder"
+	case "discontinued":
+		return "Discontinued"
+	case "limitedavailability":
+		return "LimitedAvailability"
+	case "instoreonly":
+		return "InStoreOnly"
+	case "onlineonly":
+		return "OnlineOnly"
+	case "presale":
+		return "PreSale"
+	case "soldout":
+		return "SoldOut"
+	}
+	return ""
+}
@@ -0,0 +1,37 @@
+package unfurlist
+
+import "testing"
+
+func TestProductOffer(t *testing.T) {
+	for _, tc := range []struct {
+		name                          string
+		page                          string
+		price, currency, availability string
+	}{
+		{"meta", `<html><head>
+<meta property="product:price:amount" content="19.99">
+<meta property="product:price:currency" content="eur">
+<meta property="product:availability" content="in stock">
+</head></html>`, "19.99", "EUR", "InStock"},
+		{"og meta", `<html><head>
+<meta property="og:price:amount" content="5">
+<meta property="og:price:currency" content="USD">
+<meta property="og:availability" content="oos">
+</head></html>`, "5", "USD", "OutOfStock"},
+		{"json-ld", `<html><head><script type="application/ld+json">
+{"@context":"https://schema.org","@graph":[{"@type":"WebPage"},{"@type":"Product","name":"Thing",
+"offers":[{"@type":"Offer","price":1299.5,"priceCurrency":"GBP","availability":"https://schema.org/PreOrder"}]}]}
+</script></head></html>`, "1299.5", "GBP", "PreOrder"},
+		{"aggregate offer", `<script type="application/ld+json">
+{"@type":"Product","offers":{"@type":"AggregateOffer","lowPrice":"10.00","priceCurrency":"USD"}}
+</script>`, "10.00", "USD", ""},
+		{"no offer", `<html><head><title>Page</title></head></html>`, "", "", ""},
+	} {
+		chunk := &pageChunk{ct: "text/html", data: []byte(tc.page)}
+		price, currency, availability := productOffer(jsonLD(chunk), extractRawMeta(chunk))
+		if price != tc.price || currency != tc.currency || availability != tc.availability {
+			t.Errorf("%s: got %q, %q, %q, want %q, %q, %q", tc.name,
+				price, currency, availability, tc.price, tc.currency, tc.availability)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package unfurlist
+
+import (
+	"bytes"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+	"golang.org/x/net/html/charset"
+)
+
+// maxRawMeta limits number of meta tags extractRawMeta collects
+const maxRawMeta = 100
+
+// extractRawMeta returns content of meta tags from html page head, keyed by
+// their lowercased property or name attribute. Only the first value of each
+// key is kept. It returns nil if page has no such tags.
+func extractRawMeta(chunk *pageChunk) map[string]string {
+	rd, err := charset.NewReader(bytes.NewReader(chunk.data), chunk.ct)
+	if err != nil {
+		return nil
+	}
+	var out map[string]string
+	z := html.NewTokenizer(rd)
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return out
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, hasAttr := z.TagName()
+			switch atom.Lookup(name) {
+			case atom.Body:
+				return out
+			case atom.Meta:
+			default:
+				continue
+			}
+			if !hasAttr {
+				continue
+			}
+			var key, content string
+			var hasContent bool
+			for more := true; more; {
+				var k, v []byte
+				k, v, more = z.TagAttr()
+				switch string(k) {
+				case "property", "name":
+					if key == "" {
+						key = strings.ToLower(strings.TrimSpace(string(v)))
+					}
+				case "content":
+					content, hasContent = strings.TrimSpace(string(v)), true
+				}
+			}
+			if key == "" || !hasContent {
+				continue
+			}
+			if out == nil {
+				out = make(map[string]string)
+			}
+			if _, ok := out[key]; !ok {
+				out[key] = content
+			}
+			if len(out) == maxRawMeta {
+				return out
+			}
+		case html.EndTagToken:
+			if name, _ := z.TagName(); atom.Lookup(name) == atom.Head {
+				return out
+			}
+		}
+	}
+}
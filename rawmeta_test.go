@@ -0,0 +1,59 @@
+package unfurlist
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestExtractRawMeta(t *testing.T) {
+	chunk := &pageChunk{ct: "text/html", data: []byte(`<html><head>
+<meta charset="utf-8">
+<meta property="og:title" content="Title">
+<meta property="og:title" content="Second title">
+<meta name="Twitter:Card" content=" summary ">
+<meta name="description" content="">
+<meta http-equiv="refresh" content="5">
+</head><body><meta name="late" content="ignored"></body></html>`)}
+	want := map[string]string{"og:title": "Title", "twitter:card": "summary", "description": ""}
+	if got := extractRawMeta(chunk); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if got := extractRawMeta(&pageChunk{ct: "text/html", data: []byte(`<title>No meta</title>`)}); got != nil {
+		t.Fatalf("got %v for page without meta tags", got)
+	}
+}
+
+func TestIncludeRawMeta(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/favicon.ico" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><head><title>Page</title><meta property="article:section" content="Tech"></head></html>`))
+	}))
+	defer srv.Close()
+	handler := New()
+	for _, include := range []string{"", "raw_meta", "other, raw_meta"} {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet,
+			"/?include="+url.QueryEscape(include)+"&content="+url.QueryEscape(srv.URL), nil))
+		var res []Result
+		if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+			t.Fatal(err)
+		}
+		if len(res) != 1 {
+			t.Fatalf("include=%q: unexpected results: %+v", include, res)
+		}
+		switch got := res[0].RawMeta; {
+		case include == "" && got != nil:
+			t.Errorf("include=%q: got raw meta %v", include, got)
+		case include != "" && got["article:section"] != "Tech":
+			t.Errorf("include=%q: got raw meta %v", include, got)
+		}
+	}
+}
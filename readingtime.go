@@ -0,0 +1,115 @@
+package unfurlist
+
+import (
+	"bytes"
+	"unicode"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+	"golang.org/x/net/html/charset"
+)
+
+// Reading speed used to estimate reading time: words per minute for
+// space-separated scripts and characters per minute for Chinese, Japanese
+// and Korean ones
+const (
+	wordsPerMinute = 230
+	cjkPerMinute   = 500
+)
+
+// readingTime returns word count and estimated reading time in minutes of
+// the text of <article> element of html page, or of the whole page body if
+// isArticle is true and page has no <article> element. Text of scripts,
+// navigation, headers, footers and forms is not counted. Since only the first
+// chunk of the page is available, it returns zeroes if the counted element
+// does not end within the chunk, see MaxBodyChunkSize.
+func readingTime(chunk *pageChunk, isArticle bool) (words, minutes int) {
+	rd, err := charset.NewReader(bytes.NewReader(chunk.data), chunk.ct)
+	if err != nil {
+		return 0, 0
+	}
+	type counter struct{ words, cjk int }
+	var article, body counter
+	var inArticle, inBody, skip int
+	var articleSeen bool
+	z := html.NewTokenizer(rd)
+	result := func(c counter) (int, int) {
+		n := c.words + c.cjk
+		if n == 0 {
+			return 0, 0
+		}
+		m := (c.words*cjkPerMinute + c.cjk*wordsPerMinute + wordsPerMinute*cjkPerMinute - 1) /
+			(wordsPerMinute * cjkPerMinute)
+		return n, max(m, 1)
+	}
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return 0, 0
+		case html.StartTagToken:
+			name, _ := z.TagName()
+			switch atom.Lookup(name) {
+			case atom.Body:
+				inBody++
+			case atom.Article:
+				inArticle++
+				articleSeen = true
+			case atom.Script, atom.Style, atom.Noscript, atom.Template, atom.Nav,
+				atom.Header, atom.Footer, atom.Aside, atom.Form, atom.Svg:
+				skip++
+			}
+		case html.EndTagToken:
+			name, _ := z.TagName()
+			switch atom.Lookup(name) {
+			case atom.Body:
+				if !articleSeen && isArticle {
+					return result(body)
+				}
+				return 0, 0
+			case atom.Article:
+				if inArticle--; inArticle == 0 {
+					return result(article)
+				}
+			case atom.Script, atom.Style, atom.Noscript, atom.Template, atom.Nav,
+				atom.Header, atom.Footer, atom.Aside, atom.Form, atom.Svg:
+				if skip > 0 {
+					skip--
+				}
+			}
+		case html.TextToken:
+			if skip > 0 || (inBody == 0 && inArticle == 0) {
+				continue
+			}
+			w, c := countWords(z.Text())
+			if inArticle > 0 {
+				article.words += w
+				article.cjk += c
+			}
+			body.words += w
+			body.cjk += c
+		}
+	}
+}
+
+// countWords returns number of words separated by spaces or punctuation, and
+// number of Chinese, Japanese and Korean characters in text
+func countWords(text []byte) (words, cjk int) {
+	var inWord bool
+	for _, r := range string(text) {
+		switch {
+		case unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul):
+			cjk++
+			inWord = false
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			if !inWord {
+				words++
+			}
+			inWord = true
+		case r == '\'' || r == '’' || r == '-':
+			// keep contractions and hyphenated words whole
+		default:
+			inWord = false
+		}
+	}
+	return words, cjk
+}
@@ -0,0 +1,31 @@
+package unfurlist
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestReadingTime(t *testing.T) {
+	text := strings.Repeat("It's a well-known word. ", 115) // 460 words
+	for _, tc := range []struct {
+		name      string
+		page      string
+		isArticle bool
+		words     int
+		minutes   int
+	}{
+		{"article", `<html><body><nav>Menu items</nav><article><header>Skipped title</header><p>` + text +
+			`</p><script>var x = "skipped";</script></article><footer>Footer</footer></body></html>`, false, 460, 2},
+		{"article type", `<html><body><p>` + text + `</p><footer>Footer</footer></body></html>`, true, 460, 2},
+		{"not article", `<html><body><p>` + text + `</p></body></html>`, false, 0, 0},
+		{"truncated", `<html><body><article><p>` + text, false, 0, 0},
+		{"cjk", `<html><body><article>` + strings.Repeat("日本語の文章", 100) + ` and words</article></body></html>`, false, 602, 2},
+		{"short", `<html><body><article>Hello</article></body></html>`, false, 1, 1},
+	} {
+		chunk := &pageChunk{ct: "text/html; charset=utf-8", data: []byte(tc.page)}
+		words, minutes := readingTime(chunk, tc.isArticle)
+		if words != tc.words || minutes != tc.minutes {
+			t.Errorf("%s: got %d words, %d minutes, want %d, %d", tc.name, words, minutes, tc.words, tc.minutes)
+		}
+	}
+}
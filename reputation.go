@@ -0,0 +1,134 @@
+package unfurlist
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Verdict is a result of url reputation check, see WithURLReputation
+type Verdict int
+
+const (
+	VerdictSafe      Verdict = iota // url is processed as usual
+	VerdictDangerous                // url is not fetched, result has Dangerous flag set
+	VerdictBlock                    // url is skipped as if it was blocklisted
+)
+
+// URLReputation checks url against some threat intelligence source. It
+// should return VerdictSafe if check fails.
+type URLReputation func(ctx context.Context, url string) Verdict
+
+// WithURLReputation configures unfurl handler to check each url with fn
+// before processing it. Urls deemed dangerous are never fetched.
+func WithURLReputation(fn URLReputation) ConfFunc {
+	return func(h *unfurlHandler) *unfurlHandler {
+		if fn != nil {
+			h.reputation = fn
+		}
+		return h
+	}
+}
+
+var errDangerousURL = errors.New("url is known to be dangerous")
+
+// SafeBrowsingURL is the Google Safe Browsing v4 Lookup API endpoint
+const SafeBrowsingURL = "https://safebrowsing.googleapis.com/v4/threatMatches:find"
+
+// SafeBrowsing returns URLReputation checking urls with Google Safe Browsing
+// v4 Lookup API using apiKey, urls with found threats get onMatch verdict.
+// Verdicts are cached in memory for the duration suggested by the API. If
+// client is nil, http.DefaultClient is used.
+func SafeBrowsing(apiKey string, onMatch Verdict, client *http.Client) URLReputation {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	sb := &safeBrowsing{
+		endpoint: SafeBrowsingURL + "?key=" + url.QueryEscape(apiKey),
+		onMatch:  onMatch,
+		client:   client,
+		verdicts: newLRUCache(1 << 20),
+	}
+	return sb.check
+}
+
+type safeBrowsing struct {
+	endpoint string
+	onMatch  Verdict
+	client   *http.Client
+	verdicts *lruCache // value is single byte Verdict
+}
+
+func (sb *safeBrowsing) check(ctx context.Context, link string) Verdict {
+	now := time.Now()
+	if v, ok := sb.verdicts.get(link, now); ok && len(v) == 1 {
+		return Verdict(v[0])
+	}
+	matched, ttl, err := sb.lookup(ctx, link)
+	if err != nil {
+		return VerdictSafe
+	}
+	verdict := VerdictSafe
+	if matched {
+		verdict = sb.onMatch
+	}
+	sb.verdicts.set(link, []byte{byte(verdict)}, now.Add(ttl))
+	return verdict
+}
+
+// lookup returns whether Safe Browsing has threats matching link, and for
+// how long this answer can be cached
+func (sb *safeBrowsing) lookup(ctx context.Context, link string) (bool, time.Duration, error) {
+	type threatEntry struct {
+		URL string `json:"url"`
+	}
+	body, err := json.Marshal(map[string]any{
+		"client": map[string]string{"clientId": "unfurlist", "clientVersion": "1"},
+		"threatInfo": map[string]any{
+			"threatTypes":      []string{"MALWARE", "SOCIAL_ENGINEERING", "UNWANTED_SOFTWARE", "POTENTIALLY_HARMFUL_APPLICATION"},
+			"platformTypes":    []string{"ANY_PLATFORM"},
+			"threatEntryTypes": []string{"URL"},
+			"threatEntries":    []threatEntry{{URL: link}},
+		},
+	})
+	if err != nil {
+		return false, 0, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sb.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return false, 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := sb.client.Do(req)
+	if err != nil {
+		return false, 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return false, 0, fmt.Errorf("safe browsing lookup: %s", resp.Status)
+	}
+	var out struct {
+		Matches []struct {
+			CacheDuration string `json:"cacheDuration"` // i.e. "300s"
+		} `json:"matches"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&out); err != nil {
+		return false, 0, err
+	}
+	if len(out.Matches) == 0 {
+		return false, 5 * time.Minute, nil
+	}
+	ttl, err := time.ParseDuration(out.Matches[0].CacheDuration)
+	if err != nil || ttl <= 0 {
+		ttl = 5 * time.Minute
+	}
+	return true, ttl, nil
+}
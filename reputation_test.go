@@ -0,0 +1,103 @@
+package unfurlist
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestURLReputation(t *testing.T) {
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/favicon.ico" {
+			http.NotFound(w, r)
+			return
+		}
+		hits.Add(1)
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><head><title>Page</title></head></html>`))
+	}))
+	defer srv.Close()
+	reputation := func(_ context.Context, link string) Verdict {
+		switch {
+		case strings.HasSuffix(link, "/phishing"):
+			return VerdictDangerous
+		case strings.HasSuffix(link, "/malware"):
+			return VerdictBlock
+		}
+		return VerdictSafe
+	}
+	handler := New(WithURLReputation(reputation))
+	content := url.QueryEscape(srv.URL + "/phishing " + srv.URL + "/malware " + srv.URL + "/page")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/?content="+content, nil))
+	var res []Result
+	if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+	want := []Result{
+		{URL: srv.URL + "/phishing", Dangerous: true},
+		{URL: srv.URL + "/malware"},
+		{URL: srv.URL + "/page", Title: "Page"},
+	}
+	if len(res) != len(want) {
+		t.Fatalf("unexpected results: %+v", res)
+	}
+	for i := range want {
+		if res[i].URL != want[i].URL || res[i].Dangerous != want[i].Dangerous || res[i].Title != want[i].Title {
+			t.Errorf("result %d: got %+v, want %+v", i, res[i], want[i])
+		}
+	}
+	if n := hits.Load(); n != 1 {
+		t.Fatalf("got %d upstream requests, want 1", n)
+	}
+}
+
+func TestSafeBrowsing(t *testing.T) {
+	var calls atomic.Int32
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		if r.URL.Query().Get("key") != "secret" {
+			http.Error(w, "bad key", http.StatusForbidden)
+			return
+		}
+		var req struct {
+			ThreatInfo struct {
+				ThreatEntries []struct{ URL string }
+			}
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.ThreatInfo.ThreatEntries) != 1 {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		if req.ThreatInfo.ThreatEntries[0].URL == "https://evil.example.com/" {
+			w.Write([]byte(`{"matches":[{"threatType":"MALWARE","cacheDuration":"300s"}]}`))
+			return
+		}
+		w.Write([]byte(`{}`))
+	}))
+	defer api.Close()
+	sb := &safeBrowsing{
+		endpoint: api.URL + "?key=secret",
+		onMatch:  VerdictBlock,
+		client:   api.Client(),
+		verdicts: newLRUCache(1 << 20),
+	}
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		if v := sb.check(ctx, "https://evil.example.com/"); v != VerdictBlock {
+			t.Fatalf("got verdict %v, want VerdictBlock", v)
+		}
+		if v := sb.check(ctx, "https://example.com/"); v != VerdictSafe {
+			t.Fatalf("got verdict %v, want VerdictSafe", v)
+		}
+	}
+	if n := calls.Load(); n != 2 {
+		t.Fatalf("got %d api calls, want 2 (verdicts should be cached)", n)
+	}
+}
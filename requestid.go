@@ -0,0 +1,66 @@
+package unfurlist
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+)
+
+// RequestIDHeader is the name of header carrying request id
+const RequestIDHeader = "X-Request-ID"
+
+type requestIDKey struct{}
+
+// RequestIDFromContext returns id of request being processed, as attached to
+// the context passed to hooks and fetchers. Handler takes request id from
+// X-Request-ID header of incoming request, or generates a new one.
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+func withRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// requestID returns s if it can be used as request id, otherwise it returns
+// newly generated id
+func requestID(s string) string {
+	if validRequestID(s) {
+		return s
+	}
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// validRequestID reports whether s is non-empty, not too long and only has
+// printable ASCII characters
+func validRequestID(s string) bool {
+	if s == "" || len(s) > 128 {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] <= ' ' || s[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// WithRequestIDForwarding configures unfurl handler to add X-Request-ID header
+// with id of request being processed to outgoing requests it makes.
+func WithRequestIDForwarding(enable bool) ConfFunc {
+	return func(h *unfurlHandler) *unfurlHandler {
+		h.forwardRequestID = enable
+		return h
+	}
+}
+
+// logf logs message prefixed with request id taken from ctx, if any
+func (h *unfurlHandler) logf(ctx context.Context, format string, v ...any) {
+	if id := RequestIDFromContext(ctx); id != "" {
+		format = "[" + id + "] " + format
+	}
+	h.Log.Printf(format, v...)
+}
@@ -0,0 +1,128 @@
+package unfurlist
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// Envelope is the response format of the versioned /v1/unfurl endpoint.
+// Results hold one entry per url found in content, in the order urls appear
+// in content. Errors describe urls that could not be processed, results for
+// such urls only have URL attribute set.
+type Envelope struct {
+	Results []*Result     `json:"results" pb:"1"`
+	Errors  []ResultError `json:"errors" pb:"2"`
+}
+
+// ResultError describes failure to process single url. Error of url
+// processing failures is one of ErrorCategory values, their details are only
+// logged and passed to ErrorReporter, as they may reveal internal addresses.
+type ResultError struct {
+	URL        string `json:"url" pb:"1"`
+	Error      string `json:"error" pb:"2"`
+	StatusCode int    `json:"status_code,omitempty" pb:"3"` // status of remote server reply, for "status" errors
+}
+
+// newEnvelope returns Envelope for results, collecting their errors
+func newEnvelope(results unfurlResults) *Envelope {
+	env := &Envelope{Results: results, Errors: []ResultError{}}
+	for _, r := range results {
+		if r.err != nil {
+			env.Errors = append(env.Errors, newResultError(r.URL, r.err))
+		}
+	}
+	return env
+}
+
+// newResultError returns ResultError describing err without its details
+func newResultError(url string, err error) ResultError {
+	re := ResultError{URL: url, Error: string(resultErrorCategory(err))}
+	var se *statusError
+	if errors.As(err, &se) {
+		re.StatusCode = se.code
+	}
+	return re
+}
+
+// writeResults writes v (unfurlResults or *Envelope) to w as JSONP if
+// callback is not empty, otherwise encoding is selected according to request
+// Accept header: JSON, MessagePack and protobuf are supported. Response has
+// ETag header computed from its body; if request has matching If-None-Match
+// header, 304 Not Modified status is sent instead of the body.
+func (h *unfurlHandler) writeResults(w http.ResponseWriter, r *http.Request, v any, callback string) {
+	buf := new(bytes.Buffer)
+	switch media := negotiateEncoding(r.Header.Get("Accept")); {
+	case callback != "":
+		w.Header().Set("Content-Type", "application/x-javascript")
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		buf.WriteString(callback + "(")
+		json.NewEncoder(buf).Encode(v)
+		buf.WriteString(")")
+	case media == mediaMsgpack:
+		if err := encodeMsgpack(buf, reflect.ValueOf(v)); err != nil {
+			h.logf(r.Context(), "msgpack encode: %v", err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", media)
+	case media == mediaProtobuf:
+		// legacy responses are encoded as envelope without errors, see
+		// Response message in unfurlist.proto
+		if results, ok := v.(unfurlResults); ok {
+			v = &Envelope{Results: results}
+		}
+		if err := encodeProtobuf(buf, reflect.ValueOf(v)); err != nil {
+			h.logf(r.Context(), "protobuf encode: %v", err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", media)
+	default:
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(buf).Encode(v)
+	}
+	w.Header().Add("Vary", "Accept")
+	sum := sha1.Sum(buf.Bytes())
+	etag := `"` + hex.EncodeToString(sum[:]) + `"`
+	w.Header().Set("ETag", etag)
+	if h.cacheControl != "" {
+		w.Header().Set("Cache-Control", h.cacheControl)
+	}
+	if etagMatch(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Write(buf.Bytes())
+}
+
+// etagMatch reports whether If-None-Match header value matches etag, using
+// weak comparison as per RFC 9110, section 13.1.2.
+func etagMatch(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+	for _, s := range strings.Split(ifNoneMatch, ",") {
+		s = strings.TrimSpace(s)
+		if s == "*" || strings.TrimPrefix(s, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// reCallback matches JSONP callback names that are safe to reflect in
+// response: JavaScript identifiers, optionally separated by dots
+var reCallback = regexp.MustCompile(`^[a-zA-Z_$][a-zA-Z0-9_$]*(?:\.[a-zA-Z_$][a-zA-Z0-9_$]*)*$`)
+
+// validCallback reports whether s can be used as a JSONP callback name
+func validCallback(s string) bool {
+	return len(s) <= 128 && reCallback.MatchString(s)
+}
@@ -0,0 +1,130 @@
+package unfurlist
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// WithRevalidation configures unfurl handler to keep validators (ETag and
+// Last-Modified headers) of fetched pages in cache together with their
+// results, for ttl longer than results themselves are cached. Once cached
+// result expires, page is requested with If-None-Match and
+// If-Modified-Since headers, and if server responds with 304 Not Modified,
+// previous result is reused without downloading page again.
+//
+// Only results made from page content are revalidated, not ones made by
+// fetchers or oembed providers matching the url. Revalidation requires
+// cache configured with WithCache.
+func WithRevalidation(ttl time.Duration) ConfFunc {
+	return func(h *unfurlHandler) *unfurlHandler {
+		if ttl > 0 {
+			h.revalidateTTL = ttl
+		}
+		return h
+	}
+}
+
+// errNotModified is returned by fetchData if server responded with 304 Not
+// Modified to conditional request
+var errNotModified = errors.New("not modified")
+
+// validators is a cache record used to revalidate expired result
+type validators struct {
+	ETag         string        `json:"etag,omitempty"`
+	LastModified string        `json:"last_modified,omitempty"`
+	Result       []byte        `json:"result"` // result as stored in cache
+	TTL          time.Duration `json:"ttl,omitempty"`
+}
+
+type validatorsKey struct{}
+
+// withValidators returns ctx that makes httpGet send conditional request
+// with v, if v is not nil
+func withValidators(ctx context.Context, v *validators) context.Context {
+	if v == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, validatorsKey{}, v)
+}
+
+// setConditional adds conditional request headers from validators attached
+// to ctx with withValidators
+func setConditional(ctx context.Context, req *http.Request) {
+	v, _ := ctx.Value(validatorsKey{}).(*validators)
+	if v == nil {
+		return
+	}
+	if v.ETag != "" {
+		req.Header.Set("If-None-Match", v.ETag)
+	}
+	if v.LastModified != "" {
+		req.Header.Set("If-Modified-Since", v.LastModified)
+	}
+}
+
+func validatorsCacheKey(key string) string { return key + ":validators" }
+
+// staleValidators returns validators stored for result with key, nil if
+// revalidation is disabled or there's none
+func (h *unfurlHandler) staleValidators(ctx context.Context, key string) *validators {
+	if h.revalidateTTL == 0 || h.cache == nil {
+		return nil
+	}
+	b, err := h.cache.Get(ctx, validatorsCacheKey(key))
+	if err != nil {
+		if err != ErrCacheMiss {
+			h.logf(ctx, "validators lookup: %v", err)
+		}
+		return nil
+	}
+	v := new(validators)
+	if err := json.Unmarshal(b, v); err != nil || len(v.Result) == 0 {
+		return nil
+	}
+	return v
+}
+
+// storeValidators saves validators of page chunk along with result data as
+// stored in cache with ttl
+func (h *unfurlHandler) storeValidators(ctx context.Context, key string, chunk *pageChunk, data []byte, ttl time.Duration) {
+	if h.revalidateTTL == 0 || h.cache == nil || chunk == nil || (chunk.etag == "" && chunk.lastModified == "") {
+		return
+	}
+	h.saveValidators(ctx, key, &validators{ETag: chunk.etag, LastModified: chunk.lastModified, Result: data, TTL: ttl})
+}
+
+func (h *unfurlHandler) saveValidators(ctx context.Context, key string, v *validators) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return
+	}
+	var ttl time.Duration
+	if v.TTL > 0 {
+		ttl = v.TTL + h.revalidateTTL
+	}
+	if err := h.cache.Set(ctx, validatorsCacheKey(key), b, ttl); err != nil {
+		h.logf(ctx, "validators update: %v", err)
+	}
+}
+
+// revalidated returns result stored with validators v after server
+// confirmed it's still valid, and stores it in cache again
+func (h *unfurlHandler) revalidated(ctx context.Context, key string, v *validators) (*Result, bool) {
+	res, ok := decodeCached(v.Result)
+	if !ok {
+		return nil, false
+	}
+	res.ExpiresAt = ""
+	if v.TTL > 0 {
+		res.ExpiresAt = expiresAt(v.TTL).UTC().Format(time.RFC3339)
+	}
+	v.Result = encodeCached(res)
+	if err := h.cache.Set(ctx, key, v.Result, v.TTL); err != nil {
+		h.logf(ctx, "cache update: %v", err)
+	}
+	h.saveValidators(ctx, key, v)
+	return res, true
+}
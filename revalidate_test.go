@@ -0,0 +1,86 @@
+package unfurlist
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// mapCache is in-memory Cache recording, but ignoring ttl
+type mapCache struct {
+	mu   sync.Mutex
+	m    map[string][]byte
+	ttls map[string]time.Duration
+}
+
+func (c *mapCache) Get(_ context.Context, key string) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if b, ok := c.m[key]; ok {
+		return b, nil
+	}
+	return nil, ErrCacheMiss
+}
+
+func (c *mapCache) Set(_ context.Context, key string, value []byte, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.m == nil {
+		c.m, c.ttls = make(map[string][]byte), make(map[string]time.Duration)
+	}
+	c.m[key], c.ttls[key] = value, ttl
+	return nil
+}
+
+func TestRevalidation(t *testing.T) {
+	var mu sync.Mutex
+	etag, title, full := `"v1"`, "First", 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/page" {
+			http.NotFound(w, r)
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		w.Header().Set("ETag", etag)
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		full++
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><head><title>` + title + `</title></head></html>`))
+	}))
+	defer srv.Close()
+	cache := new(mapCache)
+	h := New(WithCache(cache), WithCacheTTL(time.Hour), WithRevalidation(24*time.Hour)).(*unfurlHandler)
+	link := srv.URL + "/page"
+	expire := func() {
+		cache.mu.Lock()
+		delete(cache.m, h.cacheKey(context.Background(), link))
+		cache.mu.Unlock()
+	}
+	check := func(wantTitle string, wantFull int) {
+		t.Helper()
+		res := h.processURL(context.Background(), link)
+		mu.Lock()
+		defer mu.Unlock()
+		if res.Title != wantTitle || full != wantFull {
+			t.Fatalf("got title %q after %d full responses, want %q after %d", res.Title, full, wantTitle, wantFull)
+		}
+	}
+	check("First", 1)
+	expire()
+	check("First", 1) // 304
+	if _, err := cache.Get(context.Background(), h.cacheKey(context.Background(), link)); err != nil {
+		t.Fatal("revalidated result was not cached again")
+	}
+	expire()
+	mu.Lock()
+	etag, title = `"v2"`, "Second"
+	mu.Unlock()
+	check("Second", 2)
+}
@@ -0,0 +1,84 @@
+package unfurlist
+
+import (
+	"strconv"
+	"strings"
+)
+
+// WithRobotsDirectives configures unfurl handler to honor robots meta tags and
+// X-Robots-Tag headers of pages: nosnippet suppresses description,
+// max-snippet:N truncates it to N characters, max-image-preview:none
+// suppresses image; noindex and none suppress both description and image.
+// Directives addressed to specific crawlers (i.e. "googlebot: nosnippet")
+// are ignored.
+func WithRobotsDirectives(enable bool) ConfFunc {
+	return func(h *unfurlHandler) *unfurlHandler {
+		h.robots = enable
+		return h
+	}
+}
+
+// robotsDirectives describes restrictions of robots directives relevant to
+// previews
+type robotsDirectives struct {
+	noSnippet  bool
+	maxSnippet int // max description length in characters, -1 if unlimited
+	noImage    bool
+}
+
+// parseRobots returns directives found in X-Robots-Tag header values or
+// content attributes of robots meta tags
+func parseRobots(values ...string) robotsDirectives {
+	d := robotsDirectives{maxSnippet: -1}
+	for _, v := range values {
+		d.parse(v)
+	}
+	return d
+}
+
+func (d *robotsDirectives) parse(s string) {
+	for i, tok := range strings.Split(s, ",") {
+		name, arg, _ := strings.Cut(strings.ToLower(strings.TrimSpace(tok)), ":")
+		name, arg = strings.TrimSpace(name), strings.TrimSpace(arg)
+		switch name {
+		case "noindex", "none":
+			d.noSnippet, d.noImage = true, true
+		case "nosnippet":
+			d.noSnippet = true
+		case "max-snippet":
+			if n, err := strconv.Atoi(arg); err == nil && n >= 0 && (d.maxSnippet < 0 || n < d.maxSnippet) {
+				d.maxSnippet = n
+			}
+		case "max-image-preview":
+			if arg == "none" {
+				d.noImage = true
+			}
+		case "noarchive", "nofollow", "notranslate", "noimageindex", "indexifembedded",
+			"unavailable_after", "max-video-preview", "all", "index", "follow":
+		default:
+			if i == 0 && arg != "" {
+				// "crawler: directives" form, not addressed to us
+				return
+			}
+		}
+	}
+}
+
+// apply removes from r what directives don't allow to show
+func (d robotsDirectives) apply(r *Result) {
+	if d.noSnippet || d.maxSnippet == 0 {
+		r.Description = ""
+		for _, k := range []string{"description", "og:description", "twitter:description"} {
+			delete(r.RawMeta, k)
+		}
+	} else if d.maxSnippet > 0 && len([]rune(r.Description)) > d.maxSnippet {
+		r.Description = excerpt(r.Description, d.maxSnippet-1)
+	}
+	if d.noImage {
+		r.Image, r.ImageWidth, r.ImageHeight = "", 0, 0
+		r.ImageSize, r.DominantColor, r.BlurHash = 0, "", ""
+		for _, k := range []string{"og:image", "og:image:url", "og:image:secure_url", "twitter:image", "twitter:image:src"} {
+			delete(r.RawMeta, k)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package unfurlist
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParseRobots(t *testing.T) {
+	for _, tc := range []struct {
+		values []string
+		want   robotsDirectives
+	}{
+		{nil, robotsDirectives{maxSnippet: -1}},
+		{[]string{"index, follow"}, robotsDirectives{maxSnippet: -1}},
+		{[]string{"nosnippet"}, robotsDirectives{noSnippet: true, maxSnippet: -1}},
+		{[]string{"max-snippet:50, max-image-preview:large"}, robotsDirectives{maxSnippet: 50}},
+		{[]string{"max-snippet: 50", "max-snippet:20"}, robotsDirectives{maxSnippet: 20}},
+		{[]string{"max-image-preview:none"}, robotsDirectives{maxSnippet: -1, noImage: true}},
+		{[]string{"NOINDEX"}, robotsDirectives{noSnippet: true, maxSnippet: -1, noImage: true}},
+		{[]string{"googlebot: nosnippet"}, robotsDirectives{maxSnippet: -1}},
+		{[]string{"googlebot: nosnippet", "max-snippet:10"}, robotsDirectives{maxSnippet: 10}},
+	} {
+		if got := parseRobots(tc.values...); got != tc.want {
+			t.Errorf("%q: got %+v, want %+v", tc.values, got, tc.want)
+		}
+	}
+}
+
+func TestRobotsDirectives(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/favicon.ico" {
+			http.NotFound(w, r)
+			return
+		}
+		meta := ""
+		switch r.URL.Path {
+		case "/nosnippet":
+			w.Header().Set("X-Robots-Tag", "nosnippet")
+		case "/short":
+			meta = `<meta name="robots" content="max-snippet:12, max-image-preview:none">`
+		}
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><head><title>Page</title>` + meta + `
+<meta property="og:title" content="Page"><meta property="og:description" content="Long description of the page">
+<meta property="og:image" content="/img.png"></head></html>`))
+	}))
+	defer srv.Close()
+	h := New(WithRobotsDirectives(true)).(*unfurlHandler)
+	for _, tc := range []struct {
+		path, description string
+		image             bool
+	}{
+		{"/", "Long description of the page", true},
+		{"/nosnippet", "", true},
+		{"/short", "Long…", false},
+	} {
+		res := h.processURL(context.Background(), srv.URL+tc.path)
+		if res.Title != "Page" || res.Description != tc.description || (res.Image != "") != tc.image {
+			t.Errorf("%s: unexpected result: %+v", tc.path, res)
+		}
+		if !tc.image && res.RawMeta["og:image"] != "" {
+			t.Errorf("%s: image in raw meta: %v", tc.path, res.RawMeta)
+		}
+	}
+}
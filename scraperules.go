@@ -0,0 +1,162 @@
+package unfurlist
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// ScrapeRule describes how to extract page metadata with CSS selectors on
+// hosts matching Domain, used for sites that lack standard metadata.
+//
+// Selectors support type, #id, .class and [attr], [attr=value],
+// [attr^=value], [attr$=value], [attr*=value] attribute selectors combined
+// with descendant and child (>) combinators, i.e. "article > h1.title".
+// Value is the normalized text of the first matching element, unless
+// selector ends with "@name", in which case value of its name attribute is
+// taken: "figure.hero img @src".
+//
+// Selectors starting with / are XPath expressions instead, supporting a
+// subset of XPath 1.0 location paths: "//meta[@itemprop='name']/@content",
+// "//table[@id='specs']//tr[2]/td[last()]/text()". Steps may use element
+// names, *, . and .., predicates may check position ([1], [last()]),
+// attribute presence and compare attribute or text with =, !=, contains()
+// and starts-with(), joined with "and".
+type ScrapeRule struct {
+	Domain      string // host glob in path.Match syntax, i.e. *.example.com
+	Title       string // selector for page title
+	Description string // selector for page description
+	Image       string // selector for page image
+}
+
+type scrapeRule struct {
+	domain                    string
+	title, description, image finder
+}
+
+// finder is a compiled CSS selector or XPath expression
+type finder interface {
+	// find returns value of the first matching node in doc, or empty
+	// string if nothing is found
+	find(doc *html.Node) string
+}
+
+func compileFinder(s string) (finder, error) {
+	if s = strings.TrimSpace(s); strings.HasPrefix(s, "/") {
+		return compileXPath(s)
+	}
+	return compileSelector(s)
+}
+
+func parseScrapeRules(rules []ScrapeRule) ([]scrapeRule, error) {
+	var parsed []scrapeRule
+	var firstErr error
+	for _, r := range rules {
+		sr, err := parseScrapeRule(r)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("scrape rule for %q: %w", r.Domain, err)
+			}
+			continue
+		}
+		parsed = append(parsed, sr)
+	}
+	return parsed, firstErr
+}
+
+func parseScrapeRule(r ScrapeRule) (scrapeRule, error) {
+	sr := scrapeRule{domain: strings.ToLower(strings.TrimSpace(r.Domain))}
+	if sr.domain == "" {
+		return sr, errors.New("empty domain")
+	}
+	if _, err := path.Match(sr.domain, ""); err != nil {
+		return sr, err
+	}
+	for _, f := range []struct {
+		dst *finder
+		src string
+	}{
+		{&sr.title, r.Title},
+		{&sr.description, r.Description},
+		{&sr.image, r.Image},
+	} {
+		if strings.TrimSpace(f.src) == "" {
+			continue
+		}
+		fn, err := compileFinder(f.src)
+		if err != nil {
+			return sr, fmt.Errorf("selector %q: %w", f.src, err)
+		}
+		*f.dst = fn
+	}
+	if sr.title == nil && sr.description == nil && sr.image == nil {
+		return sr, errors.New("no selectors")
+	}
+	return sr, nil
+}
+
+// WithScrapeRules configures unfurl handler to fill title, description and
+// image of html pages missing them using rules for the first matching host.
+// Invalid rules are ignored, use CheckScrapeRules to validate them.
+func WithScrapeRules(rules []ScrapeRule) ConfFunc {
+	parsed, _ := parseScrapeRules(rules)
+	return func(h *unfurlHandler) *unfurlHandler {
+		if len(parsed) > 0 {
+			h.scrapeRules.Store(&parsed)
+		}
+		return h
+	}
+}
+
+// CheckScrapeRules returns the first error found in rules intended for
+// WithScrapeRules.
+func CheckScrapeRules(rules []ScrapeRule) error {
+	_, err := parseScrapeRules(rules)
+	return err
+}
+
+func (h *unfurlHandler) SetScrapeRules(rules []ScrapeRule) {
+	parsed, _ := parseScrapeRules(rules)
+	h.scrapeRules.Store(&parsed)
+}
+
+// scrape fills empty title, description and image of result using the first
+// rule configured with WithScrapeRules matching host of the page
+func (h *unfurlHandler) scrape(chunk *pageChunk, result *Result) {
+	p := h.scrapeRules.Load()
+	if p == nil || len(*p) == 0 {
+		return
+	}
+	host := strings.ToLower(chunk.url.Host)
+	if hst, _, ok := strings.Cut(host, ":"); ok && !strings.HasPrefix(host, "[") {
+		host = hst
+	}
+	for _, r := range *p {
+		if ok, _ := path.Match(r.domain, host); !ok {
+			continue
+		}
+		if (r.title == nil || result.Title != "") &&
+			(r.description == nil || result.Description != "") &&
+			(r.image == nil || result.Image != "") {
+			return
+		}
+		doc, err := html.Parse(bytes.NewReader(chunk.data))
+		if err != nil {
+			return
+		}
+		if r.title != nil && result.Title == "" {
+			result.Title = r.title.find(doc)
+		}
+		if r.description != nil && result.Description == "" {
+			result.Description = r.description.find(doc)
+		}
+		if r.image != nil && result.Image == "" {
+			result.Image = r.image.find(doc)
+		}
+		return
+	}
+}
@@ -0,0 +1,120 @@
+package unfurlist
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/html"
+)
+
+func TestSelector(t *testing.T) {
+	const page = `<html><head><title>x</title></head><body>
+<div id="main"><article class="post featured"><h1 class="article-title">
+  Hello,  <em>world</em></h1>
+<div class="summary"><p>Not a lead</p></div>
+<p class="lead" data-kind="intro-text">Lead paragraph</p>
+<figure class="hero"><img src="/hero.jpg" alt="Hero"></figure></article></div>
+<h1 class="article-title">Second</h1></body></html>`
+	doc, err := html.Parse(strings.NewReader(page))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct{ sel, want string }{
+		{"h1.article-title", "Hello, world"},
+		{"#main > article.featured > p", "Lead paragraph"},
+		{"article > p", "Lead paragraph"},
+		{"#main > p", ""},
+		{"div p", "Not a lead"},
+		{"p[data-kind^=intro]", "Lead paragraph"},
+		{`p[data-kind="intro"]`, ""},
+		{"figure.hero img @src", "/hero.jpg"},
+		{"img[alt] @alt", "Hero"},
+		{"*.summary", "Not a lead"},
+	} {
+		sel, err := compileSelector(tc.sel)
+		if err != nil {
+			t.Errorf("%q: %v", tc.sel, err)
+			continue
+		}
+		if got := sel.find(doc); got != tc.want {
+			t.Errorf("%q: got %q, want %q", tc.sel, got, tc.want)
+		}
+	}
+	for _, s := range []string{"", "> p", "p >", "div..x", "p[", "a:hover", "img @"} {
+		if _, err := compileSelector(s); err == nil {
+			t.Errorf("%q: expected error", s)
+		}
+	}
+}
+
+func TestXPath(t *testing.T) {
+	const page = `<html><head><meta itemprop="name" content=" Product name ">
+<meta property="og:site_name" content="Shop"></head><body>
+<div class="item"><span>First</span></div>
+<table id="specs"><tr><td>Weight</td><td>1 kg</td></tr>
+<tr><td>Color</td><td class="value">Red <b>and</b> blue</td></tr></table>
+<ul><li>a</li><li data-x="1">b</li><li>c</li></ul>
+<p class="note">Price: <span>10</span> EUR</p></body></html>`
+	doc, err := html.Parse(strings.NewReader(page))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct{ xp, want string }{
+		{"//meta[@itemprop='name']/@content", "Product name"},
+		{`//meta[@property="og:site_name" and @content]/@content`, "Shop"},
+		{"//table[@id='specs']//tr[2]/td[last()]", "Red and blue"},
+		{"//table[@id='specs']//tr[2]/td[last()]/text()", "Red"},
+		{"//td[.='Weight']/../td[2]", "1 kg"},
+		{"//li[2]", "b"},
+		{"//li[@data-x]/@data-x", "1"},
+		{"//li[@data-x!='1']", ""},
+		{"//p[starts-with(.,'Price')]/span", "10"},
+		{"//*[contains(@class,'ite')]/span", "First"},
+		{"/html/body/ul/li[last()]", "c"},
+		{"//h1", ""},
+	} {
+		xp, err := compileXPath(tc.xp)
+		if err != nil {
+			t.Errorf("%q: %v", tc.xp, err)
+			continue
+		}
+		if got := xp.find(doc); got != tc.want {
+			t.Errorf("%q: got %q, want %q", tc.xp, got, tc.want)
+		}
+	}
+	for _, s := range []string{"", "a/b", "//a[", "//a[0]", "//@href", "//a/@href/b",
+		"//a[foo()]", "//a[@href=x]", "//a/text()/b"} {
+		if _, err := compileXPath(s); err == nil {
+			t.Errorf("%q: expected error", s)
+		}
+	}
+}
+
+func TestScrapeRules(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><head><meta name="description" content="Meta description"></head><body>
+<h1 class="article-title">Scraped title</h1><p class="summary">Scraped summary</p>
+<figure class="hero"><img src="/hero.jpg"></figure></body></html>`))
+	}))
+	defer srv.Close()
+	rules := []ScrapeRule{
+		{Domain: "*.example.com", Title: "h2"},
+		{Domain: "127.0.0.1", Title: "//h1[@class='article-title']", Description: "p.summary", Image: "figure.hero img @src"},
+	}
+	if err := CheckScrapeRules(append(rules, ScrapeRule{Domain: "x", Title: "p["})); err == nil {
+		t.Fatal("CheckScrapeRules: expected error for invalid selector")
+	}
+	h := New(WithScrapeRules(rules)).(*unfurlHandler)
+	res := h.processURL(context.Background(), srv.URL+"/")
+	if res.Title != "Scraped title" || res.Description != "Meta description" || res.Image != srv.URL+"/hero.jpg" {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+}
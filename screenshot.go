@@ -0,0 +1,31 @@
+package unfurlist
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/url"
+)
+
+// screenshotService builds links to an external service rendering page
+// screenshots
+type screenshotService struct {
+	endpoint *url.URL
+	secret   []byte
+}
+
+// imageURL returns url of the screenshot of the page at pageURL. Page url is
+// passed in the "url" query parameter; if secret is set, hex-encoded
+// HMAC-SHA256 signature of page url is passed in the "sig" query parameter.
+func (s *screenshotService) imageURL(pageURL string) string {
+	u := *s.endpoint
+	vals := u.Query()
+	vals.Set("url", pageURL)
+	if len(s.secret) != 0 {
+		mac := hmac.New(sha256.New, s.secret)
+		mac.Write([]byte(pageURL))
+		vals.Set("sig", hex.EncodeToString(mac.Sum(nil)))
+	}
+	u.RawQuery = vals.Encode()
+	return u.String()
+}
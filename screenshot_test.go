@@ -0,0 +1,21 @@
+package unfurlist
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestScreenshotService(t *testing.T) {
+	endpoint, err := url.Parse("https://shots.example.com/render?size=small")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &screenshotService{endpoint: endpoint, secret: []byte("secret")}
+	got := s.imageURL("https://example.com/dashboard")
+	want := "https://shots.example.com/render?sig=" +
+		"7030cd8d0eef7e18a0176bb88b2cc479e3ba7a504a574ee0a6814e0786f74be8" +
+		"&size=small&url=https%3A%2F%2Fexample.com%2Fdashboard"
+	if got != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
@@ -0,0 +1,272 @@
+package unfurlist
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// selector is a compiled subset of CSS selector: compound selectors of type,
+// #id, .class and [attr], [attr=value], [attr^=value], [attr$=value],
+// [attr*=value] attribute selectors combined with descendant and child (>)
+// combinators. Selector may end with "@name" to take value of the element
+// attribute instead of its text.
+type selector struct {
+	steps []selectorStep // the last one matches the element itself
+	attr  string         // attribute to take value from, text if empty
+}
+
+type selectorStep struct {
+	child   bool // the element must be a child of the one matching previous step
+	tag     string
+	id      string
+	classes []string
+	attrs   []attrCond
+}
+
+type attrCond struct {
+	name, op, value string // op is one of "", "=", "^=", "$=", "*="
+}
+
+func compileSelector(s string) (*selector, error) {
+	sel := new(selector)
+	if i := strings.LastIndexByte(s, '@'); i >= 0 && !strings.ContainsAny(s[i:], "]\"'") {
+		sel.attr = strings.ToLower(strings.TrimSpace(s[i+1:]))
+		if sel.attr == "" {
+			return nil, errors.New("empty attribute name after @")
+		}
+		s = s[:i]
+	}
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, errors.New("empty selector")
+	}
+	child := false
+	for s != "" {
+		switch s[0] {
+		case ' ', '\t', '\n':
+			s = s[1:]
+			continue
+		case '>':
+			if child || len(sel.steps) == 0 {
+				return nil, errors.New("misplaced >")
+			}
+			child = true
+			s = s[1:]
+			continue
+		}
+		step, rest, err := parseSelectorStep(s)
+		if err != nil {
+			return nil, err
+		}
+		step.child = child
+		sel.steps = append(sel.steps, step)
+		s, child = rest, false
+	}
+	if child {
+		return nil, errors.New("selector ends with >")
+	}
+	return sel, nil
+}
+
+// parseSelectorStep parses compound selector at the start of s, returning the
+// rest of s
+func parseSelectorStep(s string) (selectorStep, string, error) {
+	var st selectorStep
+	ident := func() string {
+		i := 0
+		for i < len(s) && (s[i] == '-' || s[i] == '_' || s[i] >= 0x80 ||
+			s[i] >= '0' && s[i] <= '9' || s[i] >= 'a' && s[i] <= 'z' || s[i] >= 'A' && s[i] <= 'Z') {
+			i++
+		}
+		id := s[:i]
+		s = s[i:]
+		return id
+	}
+	if strings.HasPrefix(s, "*") {
+		s = s[1:]
+	} else {
+		st.tag = strings.ToLower(ident())
+	}
+	for s != "" {
+		switch s[0] {
+		case '#':
+			s = s[1:]
+			if st.id = ident(); st.id == "" {
+				return st, s, errors.New("empty id")
+			}
+		case '.':
+			s = s[1:]
+			c := ident()
+			if c == "" {
+				return st, s, errors.New("empty class")
+			}
+			st.classes = append(st.classes, c)
+		case '[':
+			end := strings.IndexByte(s, ']')
+			if end < 0 {
+				return st, s, errors.New("unterminated attribute selector")
+			}
+			cond, err := parseAttrCond(s[1:end])
+			if err != nil {
+				return st, s, err
+			}
+			st.attrs = append(st.attrs, cond)
+			s = s[end+1:]
+		case ' ', '\t', '\n', '>':
+			return st, s, nil
+		default:
+			return st, s, fmt.Errorf("unexpected character %q", s[0])
+		}
+	}
+	return st, s, nil
+}
+
+func parseAttrCond(s string) (attrCond, error) {
+	var c attrCond
+	i := strings.IndexAny(s, "=^$*")
+	if i < 0 {
+		c.name = strings.ToLower(strings.TrimSpace(s))
+	} else {
+		c.name = strings.ToLower(strings.TrimSpace(s[:i]))
+		op, val, ok := strings.Cut(s[i:], "=")
+		if !ok || len(op) > 1 {
+			return c, fmt.Errorf("unsupported attribute selector [%s]", s)
+		}
+		c.op = op + "="
+		val = strings.TrimSpace(val)
+		if len(val) >= 2 && (val[0] == '"' || val[0] == '\'') && val[len(val)-1] == val[0] {
+			val = val[1 : len(val)-1]
+		}
+		c.value = val
+	}
+	if c.name == "" {
+		return c, fmt.Errorf("empty attribute name in [%s]", s)
+	}
+	return c, nil
+}
+
+// find returns value of the first element in document order matching
+// selector: its attribute value or normalized text
+func (sel *selector) find(doc *html.Node) string {
+	var found *html.Node
+	var walk func(n *html.Node) bool
+	walk = func(n *html.Node) bool {
+		if n.Type == html.ElementNode && sel.matches(n, len(sel.steps)-1) {
+			found = n
+			return true
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if walk(c) {
+				return true
+			}
+		}
+		return false
+	}
+	if !walk(doc) {
+		return ""
+	}
+	if sel.attr != "" {
+		return strings.TrimSpace(nodeAttr(found, sel.attr))
+	}
+	return strings.Join(strings.Fields(nodeText(found)), " ")
+}
+
+// matches reports whether element n matches selector steps up to i
+func (sel *selector) matches(n *html.Node, i int) bool {
+	st := sel.steps[i]
+	if !st.match(n) {
+		return false
+	}
+	if i == 0 {
+		return true
+	}
+	for p := n.Parent; p != nil && p.Type == html.ElementNode; p = p.Parent {
+		if sel.matches(p, i-1) {
+			return true
+		}
+		if st.child {
+			return false
+		}
+	}
+	return false
+}
+
+func (st selectorStep) match(n *html.Node) bool {
+	if st.tag != "" && n.Data != st.tag {
+		return false
+	}
+	if st.id != "" && nodeAttr(n, "id") != st.id {
+		return false
+	}
+	if len(st.classes) != 0 {
+		classes := strings.Fields(nodeAttr(n, "class"))
+	outer:
+		for _, c := range st.classes {
+			for _, c2 := range classes {
+				if c == c2 {
+					continue outer
+				}
+			}
+			return false
+		}
+	}
+	for _, c := range st.attrs {
+		v, ok := "", false
+		for _, a := range n.Attr {
+			if a.Namespace == "" && a.Key == c.name {
+				v, ok = a.Val, true
+				break
+			}
+		}
+		if !ok {
+			return false
+		}
+		switch c.op {
+		case "=":
+			ok = v == c.value
+		case "^=":
+			ok = c.value != "" && strings.HasPrefix(v, c.value)
+		case "$=":
+			ok = c.value != "" && strings.HasSuffix(v, c.value)
+		case "*=":
+			ok = c.value != "" && strings.Contains(v, c.value)
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func nodeAttr(n *html.Node, name string) string {
+	for _, a := range n.Attr {
+		if a.Namespace == "" && a.Key == name {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+// nodeText returns concatenated text of n descendants, skipping scripts and
+// styles
+func nodeText(n *html.Node) string {
+	var b strings.Builder
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		switch {
+		case n.Type == html.TextNode:
+			b.WriteString(n.Data)
+			b.WriteByte(' ')
+		case n.Type == html.ElementNode && (n.Data == "script" || n.Data == "style"):
+			return
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(n)
+	return b.String()
+}
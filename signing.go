@@ -0,0 +1,44 @@
+package unfurlist
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"time"
+)
+
+// SignContent returns signature for the request content and timestamp (unix
+// time in seconds) to be passed along with them in "sig" and "ts" request
+// parameters to unfurl handler configured with WithRequestSigning.
+// Signature is hex-encoded HMAC-SHA256 of timestamp and content joined with
+// newline:
+//
+//	hex(HMAC-SHA256(secret, "1700000000\n" + content))
+func SignContent(secret []byte, content string, timestamp int64) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte{'\n'})
+	mac.Write([]byte(content))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// requestSigner verifies requests signed by the calling backend with
+// a shared secret
+type requestSigner struct {
+	secret []byte
+	maxAge time.Duration // max difference between request timestamp and now
+}
+
+// valid reports whether sig is a valid signature of content and timestamp ts
+// and timestamp is not too far from now.
+func (s *requestSigner) valid(content string, ts int64, sig string, now time.Time) bool {
+	if sig == "" || ts <= 0 {
+		return false
+	}
+	if d := now.Sub(time.Unix(ts, 0)); d > s.maxAge || d < -s.maxAge {
+		return false
+	}
+	want := SignContent(s.secret, content, ts)
+	return hmac.Equal([]byte(sig), []byte(want))
+}
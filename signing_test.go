@@ -0,0 +1,41 @@
+package unfurlist
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestRequestSigning(t *testing.T) {
+	secret := []byte("secret")
+	handler := New(WithRequestSigning(secret, time.Minute))
+	const content = "no urls here"
+	now := time.Now().Unix()
+	for i, tc := range []struct {
+		ts   int64
+		sig  string
+		code int
+	}{
+		{now, SignContent(secret, content, now), http.StatusOK},
+		{0, "", http.StatusForbidden},
+		{now, SignContent([]byte("other"), content, now), http.StatusForbidden},
+		{now - 3600, SignContent(secret, content, now-3600), http.StatusForbidden},
+		{now + 3600, SignContent(secret, content, now+3600), http.StatusForbidden},
+	} {
+		vals := url.Values{"content": {content}}
+		if tc.ts != 0 {
+			vals.Set("ts", strconv.FormatInt(tc.ts, 10))
+		}
+		if tc.sig != "" {
+			vals.Set("sig", tc.sig)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/?"+vals.Encode(), nil))
+		if w.Code != tc.code {
+			t.Errorf("case %d: got status %d, want %d", i, w.Code, tc.code)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package unfurlist
+
+import (
+	"net/url"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// siteNameFromURL derives site name from registrable domain of the url host,
+// i.e. "NYTimes" for https://www.nytimes.com/…. It's used for results without
+// site name in their metadata. Returns empty string for IP addresses and
+// hosts it cannot make sense of.
+func siteNameFromURL(u *url.URL) string {
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	if host == "" || strings.Trim(host, "0123456789.:") == "" || strings.Contains(host, ":") {
+		return ""
+	}
+	domain, err := publicsuffix.EffectiveTLDPlusOne(host)
+	if err != nil {
+		return ""
+	}
+	name, _, _ := strings.Cut(domain, ".")
+	if s, ok := knownSiteNames[name]; ok {
+		return s
+	}
+	if strings.HasPrefix(name, "xn--") {
+		return domain // punycode, leave as is
+	}
+	words := strings.FieldsFunc(name, func(r rune) bool { return r == '-' || r == '_' })
+	for i, w := range words {
+		r, n := utf8.DecodeRuneInString(w)
+		words[i] = string(unicode.ToUpper(r)) + w[n:]
+	}
+	return strings.Join(words, " ")
+}
+
+// knownSiteNames maps registrable domain labels to names with spelling that
+// cannot be derived by capitalizing the first letter
+var knownSiteNames = map[string]string{
+	"bbc":            "BBC",
+	"cnn":            "CNN",
+	"dropbox":        "Dropbox",
+	"facebook":       "Facebook",
+	"github":         "GitHub",
+	"gitlab":         "GitLab",
+	"imdb":           "IMDb",
+	"linkedin":       "LinkedIn",
+	"nytimes":        "NYTimes",
+	"paypal":         "PayPal",
+	"soundcloud":     "SoundCloud",
+	"stackexchange":  "Stack Exchange",
+	"stackoverflow":  "Stack Overflow",
+	"techcrunch":     "TechCrunch",
+	"tiktok":         "TikTok",
+	"washingtonpost": "The Washington Post",
+	"wsj":            "WSJ",
+	"youtube":        "YouTube",
+}
@@ -0,0 +1,28 @@
+package unfurlist
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestSiteNameFromURL(t *testing.T) {
+	for raw, want := range map[string]string{
+		"https://www.nytimes.com/2024/01/01/article.html": "NYTimes",
+		"https://news.bbc.co.uk/":                         "BBC",
+		"https://gist.github.com/user/123":                "GitHub",
+		"https://example.com":                             "Example",
+		"https://my-little-blog.co.uk/post":               "My Little Blog",
+		"https://user.github.io/project":                  "User",
+		"http://127.0.0.1:8080/":                          "",
+		"http://[::1]/":                                   "",
+		"http://localhost/":                               "",
+	} {
+		u, err := url.Parse(raw)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := siteNameFromURL(u); got != want {
+			t.Errorf("siteNameFromURL(%q) = %q, want %q", raw, got, want)
+		}
+	}
+}
@@ -0,0 +1,245 @@
+package unfurlist
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"maps"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// StatusReporter is implemented by handler returned by New. It exposes
+// internal state of the handler for monitoring purposes.
+type StatusReporter interface {
+	Status() Status
+}
+
+// Status is a snapshot of handler internal state
+type Status struct {
+	// InFlightURLs is the number of urls being processed across all
+	// requests
+	InFlightURLs int64 `json:"in_flight_urls"`
+	// InFlightFetches is the number of distinct urls being processed,
+	// i.e. the size of the group collapsing concurrent requests for the
+	// same url
+	InFlightFetches int64 `json:"in_flight_fetches"`
+	// OutboundRequests and OutboundQueued are the numbers of outbound
+	// requests in progress and waiting for their turn, only reported
+	// if their number is limited with WithMaxOutboundRequests
+	OutboundRequests int64 `json:"outbound_requests,omitempty"`
+	OutboundQueued   int64 `json:"outbound_queued,omitempty"`
+
+	CacheHits     uint64  `json:"cache_hits"`
+	CacheMisses   uint64  `json:"cache_misses"`
+	CacheHitRatio float64 `json:"cache_hit_ratio"`
+
+	// Domains holds counters since handler start keyed by registrable
+	// domain of urls, i.e. "example.co.uk" for "www.example.co.uk". Once
+	// the number of tracked domains hits the limit, counters for new
+	// domains are accumulated under "*" key.
+	Domains map[string]DomainStatus `json:"domains,omitempty"`
+
+	Blocklist       *ListVersion `json:"blocklist,omitempty"`
+	TitleBlocklist  *ListVersion `json:"title_blocklist,omitempty"`
+	OembedProviders *ListVersion `json:"oembed_providers,omitempty"`
+}
+
+// DomainStatus holds counters of urls processed for a single domain
+type DomainStatus struct {
+	Processed uint64  `json:"processed"`
+	Errors    uint64  `json:"errors"`
+	ErrorRate float64 `json:"error_rate"`
+
+	// Parsers counts successful results by how their metadata was
+	// found, i.e. "opengraph", "oembed" or "cache"
+	Parsers map[string]uint64 `json:"parsers,omitempty"`
+	// ErrorCategories counts errors by their category
+	ErrorCategories map[ErrorCategory]uint64 `json:"error_categories,omitempty"`
+
+	// AvgLatencyMs and MaxLatencyMs describe processing time of urls not
+	// served from cache, in milliseconds
+	AvgLatencyMs float64 `json:"avg_latency_ms,omitempty"`
+	MaxLatencyMs float64 `json:"max_latency_ms,omitempty"`
+}
+
+// WithSlowFetchLog configures handler to log urls that took longer than
+// threshold to process, along with the parser that found their metadata.
+// Results served from cache are not logged.
+func WithSlowFetchLog(threshold time.Duration) ConfFunc {
+	return func(h *unfurlHandler) *unfurlHandler {
+		if threshold > 0 {
+			h.slowFetch = threshold
+		}
+		return h
+	}
+}
+
+// observe records result of processing link that took elapsed time to
+// per-domain counters and logs it if it was slow
+func (h *unfurlHandler) observe(ctx context.Context, link string, res *Result, elapsed time.Duration) {
+	h.stats.record(link, res, elapsed)
+	if h.slowFetch == 0 || elapsed < h.slowFetch || res.parser == "cache" {
+		return
+	}
+	switch {
+	case res.err != nil:
+		h.logf(ctx, "Slow fetch of %q took %v: %v", link, elapsed.Round(time.Millisecond), res.err)
+	default:
+		h.logf(ctx, "Slow fetch of %q took %v, parser %s", link, elapsed.Round(time.Millisecond), res.parser)
+	}
+}
+
+// ListVersion describes currently loaded version of a list, like blocklist or
+// oembed providers list
+type ListVersion struct {
+	Loaded  time.Time `json:"loaded"`
+	Source  string    `json:"source,omitempty"`
+	Entries int       `json:"entries,omitempty"`
+	Digest  string    `json:"digest,omitempty"` // truncated hex-encoded SHA-256 of the list
+}
+
+func newListVersion(source string, entries []string) *ListVersion {
+	sum := sha256.Sum256([]byte(strings.Join(entries, "\n")))
+	return &ListVersion{
+		Loaded:  time.Now().UTC(),
+		Source:  source,
+		Entries: len(entries),
+		Digest:  hex.EncodeToString(sum[:8]),
+	}
+}
+
+// maxTrackedDomains limits number of domains handlerStats keeps separate
+// counters for
+const maxTrackedDomains = 1000
+
+// handlerStats holds counters reported by Status method
+type handlerStats struct {
+	inFlightURLs    atomic.Int64
+	inFlightFetches atomic.Int64
+	cacheHits       atomic.Uint64
+	cacheMisses     atomic.Uint64
+
+	blocklist      atomic.Pointer[ListVersion]
+	titleBlocklist atomic.Pointer[ListVersion]
+	providers      atomic.Pointer[ListVersion]
+
+	mu      sync.Mutex
+	domains map[string]*domainCounters
+}
+
+// domainCounters is a mutable counterpart of DomainStatus
+type domainCounters struct {
+	processed, errors uint64
+	parsers           map[string]uint64
+	categories        map[ErrorCategory]uint64
+
+	fetched             uint64 // results not served from cache
+	latency, maxLatency time.Duration
+}
+
+// record updates per-domain counters with the result of processing link
+func (s *handlerStats) record(link string, res *Result, elapsed time.Duration) {
+	err := res.err
+	if errors.Is(err, errBlocklisted) || errors.Is(err, errDangerousURL) || errors.Is(err, context.Canceled) {
+		return
+	}
+	u, perr := url.Parse(link)
+	if perr != nil {
+		return
+	}
+	host := statsDomain(u.Hostname())
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.domains == nil {
+		s.domains = make(map[string]*domainCounters)
+	}
+	d, ok := s.domains[host]
+	if !ok {
+		if len(s.domains) >= maxTrackedDomains {
+			host = "*"
+		}
+		if d, ok = s.domains[host]; !ok {
+			d = new(domainCounters)
+			s.domains[host] = d
+		}
+	}
+	d.processed++
+	switch {
+	case err != nil:
+		d.errors++
+		if d.categories == nil {
+			d.categories = make(map[ErrorCategory]uint64)
+		}
+		d.categories[fetchErrorCategory(err)]++
+	case res.parser != "":
+		if d.parsers == nil {
+			d.parsers = make(map[string]uint64)
+		}
+		d.parsers[res.parser]++
+	}
+	if res.parser != "cache" {
+		d.fetched++
+		d.latency += elapsed
+		d.maxLatency = max(d.maxLatency, elapsed)
+	}
+}
+
+// statsDomain returns registrable domain of host to key counters by,
+// falling back to host itself for IP addresses and unknown suffixes
+func statsDomain(host string) string {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	if net.ParseIP(host) != nil {
+		return host
+	}
+	if domain, err := publicsuffix.EffectiveTLDPlusOne(host); err == nil {
+		return domain
+	}
+	return host
+}
+
+// Status returns a snapshot of handler internal state
+func (h *unfurlHandler) Status() Status {
+	st := Status{
+		InFlightURLs:    h.stats.inFlightURLs.Load(),
+		InFlightFetches: h.stats.inFlightFetches.Load(),
+		CacheHits:       h.stats.cacheHits.Load(),
+		CacheMisses:     h.stats.cacheMisses.Load(),
+		Blocklist:       h.stats.blocklist.Load(),
+		TitleBlocklist:  h.stats.titleBlocklist.Load(),
+		OembedProviders: h.stats.providers.Load(),
+	}
+	if h.outbound != nil {
+		st.OutboundRequests = h.outbound.active.Load()
+		st.OutboundQueued = h.outbound.queued.Load()
+	}
+	if total := st.CacheHits + st.CacheMisses; total > 0 {
+		st.CacheHitRatio = float64(st.CacheHits) / float64(total)
+	}
+	h.stats.mu.Lock()
+	defer h.stats.mu.Unlock()
+	if len(h.stats.domains) > 0 {
+		st.Domains = make(map[string]DomainStatus, len(h.stats.domains))
+	}
+	for host, d := range h.stats.domains {
+		ds := DomainStatus{Processed: d.processed, Errors: d.errors}
+		if ds.Processed > 0 {
+			ds.ErrorRate = float64(ds.Errors) / float64(ds.Processed)
+		}
+		ds.Parsers = maps.Clone(d.parsers)
+		ds.ErrorCategories = maps.Clone(d.categories)
+		if d.fetched > 0 {
+			ds.AvgLatencyMs = float64(d.latency) / float64(d.fetched) / float64(time.Millisecond)
+			ds.MaxLatencyMs = float64(d.maxLatency) / float64(time.Millisecond)
+		}
+		st.Domains[host] = ds
+	}
+	return st
+}
@@ -0,0 +1,109 @@
+package unfurlist
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStatus(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/page" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><head><title>Page</title></head></html>`))
+	}))
+	defer srv.Close()
+	h := New(WithBlocklistPrefixes([]string{"https://blocked.example.com/"}))
+	content := srv.URL + "/page " + srv.URL + "/missing https://blocked.example.com/x"
+	req := httptest.NewRequest(http.MethodGet, "/?content="+url.QueryEscape(content), nil)
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	st := h.(StatusReporter).Status()
+	if st.InFlightURLs != 0 || st.InFlightFetches != 0 {
+		t.Fatalf("unexpected in-flight counters: %+v", st)
+	}
+	host := mustParse(t, srv.URL).Hostname()
+	got := st.Domains[host]
+	if got.Processed != 2 || got.Errors != 1 || got.ErrorRate != 0.5 {
+		t.Fatalf("domain %q status: got %+v", host, got)
+	}
+	if want := map[string]uint64{"html": 1}; !reflect.DeepEqual(got.Parsers, want) {
+		t.Fatalf("domain %q parsers: got %v, want %v", host, got.Parsers, want)
+	}
+	if want := map[ErrorCategory]uint64{CategoryStatus: 1}; !reflect.DeepEqual(got.ErrorCategories, want) {
+		t.Fatalf("domain %q error categories: got %v, want %v", host, got.ErrorCategories, want)
+	}
+	if got.AvgLatencyMs <= 0 || got.MaxLatencyMs < got.AvgLatencyMs {
+		t.Fatalf("domain %q unexpected latency: %+v", host, got)
+	}
+	if _, ok := st.Domains["blocked.example.com"]; ok {
+		t.Fatal("blocklisted urls should not be counted")
+	}
+	if st.Blocklist == nil || st.Blocklist.Entries != 1 || st.Blocklist.Digest == "" {
+		t.Fatalf("unexpected blocklist version: %+v", st.Blocklist)
+	}
+	if st.OembedProviders == nil || st.OembedProviders.Source != "embedded" {
+		t.Fatalf("unexpected oembed providers version: %+v", st.OembedProviders)
+	}
+	old := *st.Blocklist
+	h.(Reloader).SetBlocklistPrefixes([]string{"https://a.example.com/", "https://b.example.com/"})
+	if bl := h.(StatusReporter).Status().Blocklist; bl.Entries != 2 || bl.Digest == old.Digest {
+		t.Fatalf("blocklist version was not updated: %+v", bl)
+	}
+}
+
+func TestStatsDomain(t *testing.T) {
+	for host, want := range map[string]string{
+		"www.example.co.uk": "example.co.uk",
+		"Docs.Example.com.": "example.com",
+		"example.com":       "example.com",
+		"127.0.0.1":         "127.0.0.1",
+		"::1":               "::1",
+		"localhost":         "localhost",
+	} {
+		if got := statsDomain(host); got != want {
+			t.Errorf("statsDomain(%q): got %q, want %q", host, got, want)
+		}
+	}
+}
+
+func TestSlowFetchLog(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			time.Sleep(50 * time.Millisecond)
+		}
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><head><title>Page</title></head></html>`))
+	}))
+	defer srv.Close()
+	var buf bytes.Buffer
+	h := New(WithSlowFetchLog(40*time.Millisecond), WithLogger(log.New(&buf, "", 0)))
+	content := srv.URL + "/slow " + srv.URL + "/fast"
+	req := httptest.NewRequest(http.MethodGet, "/?content="+url.QueryEscape(content), nil)
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	logs := buf.String()
+	if !strings.Contains(logs, "Slow fetch of \""+srv.URL+"/slow\"") || !strings.Contains(logs, "parser html") {
+		t.Fatalf("slow fetch was not logged:\n%s", logs)
+	}
+	if strings.Contains(logs, srv.URL+"/fast\" took") {
+		t.Fatalf("fast fetch was logged:\n%s", logs)
+	}
+}
+
+func mustParse(t *testing.T, s string) *url.URL {
+	t.Helper()
+	u, err := url.Parse(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
@@ -0,0 +1,67 @@
+package unfurlist
+
+import (
+	"net"
+	"strconv"
+	"time"
+)
+
+// statsdClient sends metrics over UDP in StatsD format, which is also
+// understood by DogStatsD. Send errors are ignored. Methods are safe to call
+// on nil client.
+type statsdClient struct {
+	conn   net.Conn
+	prefix string
+}
+
+// WithStatsd configures unfurl handler to emit metrics in StatsD format over
+// UDP to addr (host:port). Metric names are prefixed with prefix, which
+// normally ends with dot. The following metrics are emitted:
+//
+//	request.time                  timer, time to process request
+//	fetch.time                    timer, time to fetch first chunk of url
+//	fetch.status.<code>           counter, upstream response statuses
+//	fetch.error                   counter, failed upstream requests
+//	cache.hit, cache.miss         counters, result cache lookups
+//	cache.revalidated             counter, expired results confirmed valid
+//	                              with conditional requests
+//	parser.<name>                 counter, how metadata was found: oembed,
+//	                              opengraph, html, fetcher.<name>, or none
+//	outbound.queued               gauge, outbound requests waiting for their
+//	                              turn, see WithMaxOutboundRequests
+//
+// If addr is invalid, metrics are disabled and the error is logged once
+// handler is created.
+func WithStatsd(addr, prefix string) ConfFunc {
+	conn, err := net.Dial("udp", addr)
+	return func(h *unfurlHandler) *unfurlHandler {
+		if err != nil {
+			h.statsdErr = err
+			return h
+		}
+		h.statsd, h.statsdErr = &statsdClient{conn: conn, prefix: prefix}, nil
+		return h
+	}
+}
+
+func (c *statsdClient) count(name string) {
+	if c == nil {
+		return
+	}
+	c.conn.Write([]byte(c.prefix + name + ":1|c"))
+}
+
+func (c *statsdClient) timing(name string, d time.Duration) {
+	if c == nil {
+		return
+	}
+	ms := strconv.FormatFloat(float64(d)/float64(time.Millisecond), 'f', 3, 64)
+	c.conn.Write([]byte(c.prefix + name + ":" + ms + "|ms"))
+}
+
+func (c *statsdClient) gauge(name string, v int64) {
+	if c == nil {
+		return
+	}
+	c.conn.Write([]byte(c.prefix + name + ":" + strconv.FormatInt(v, 10) + "|g"))
+}
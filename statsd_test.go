@@ -0,0 +1,72 @@
+package unfurlist
+
+import (
+	"bytes"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStatsd(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/page" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><head><meta property="og:title" content="Page"></head></html>`))
+	}))
+	defer srv.Close()
+	handler := New(WithStatsd(pc.LocalAddr().String(), "unfurlist."))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/?content="+url.QueryEscape(srv.URL+"/page"), nil))
+
+	want := map[string]bool{
+		"unfurlist.fetch.status.200:1|c": false,
+		"unfurlist.parser.opengraph:1|c": false,
+	}
+	var sawTimer bool
+	buf := make([]byte, 1024)
+	pc.SetReadDeadline(time.Now().Add(time.Second))
+	for {
+		n, _, err := pc.ReadFrom(buf)
+		if err != nil {
+			break
+		}
+		m := string(buf[:n])
+		if _, ok := want[m]; ok {
+			want[m] = true
+		}
+		if strings.HasPrefix(m, "unfurlist.request.time:") && strings.HasSuffix(m, "|ms") {
+			sawTimer = true
+		}
+	}
+	for m, ok := range want {
+		if !ok {
+			t.Errorf("metric %q was not received", m)
+		}
+	}
+	if !sawTimer {
+		t.Error("request timer was not received")
+	}
+}
+
+func TestStatsdInvalidAddr(t *testing.T) {
+	var buf bytes.Buffer
+	h := New(WithStatsd("no port", "unfurlist."), WithLogger(log.New(&buf, "", 0))).(*unfurlHandler)
+	if h.statsd != nil {
+		t.Fatal("statsd client is set up for invalid address")
+	}
+	if !strings.Contains(buf.String(), "statsd") {
+		t.Fatalf("invalid address was not logged: %q", buf.String())
+	}
+}
@@ -0,0 +1,60 @@
+package unfurlist
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// themeColor returns hex-encoded color (i.e. "#a0b1c2") from theme-color or
+// msapplication-TileColor meta tags, as collected by extractRawMeta. It
+// returns empty string if page has no such tags or their values are not
+// hex or rgb() colors.
+func themeColor(meta map[string]string) string {
+	for _, key := range [...]string{"theme-color", "msapplication-tilecolor"} {
+		if c, ok := parseCSSColor(meta[key]); ok {
+			return c
+		}
+	}
+	return ""
+}
+
+// parseCSSColor parses CSS color in #rgb, #rgba, #rrggbb, #rrggbbaa or
+// rgb(r, g, b) notation, returning it in #rrggbb form. Alpha is ignored.
+func parseCSSColor(s string) (string, bool) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if hex, ok := strings.CutPrefix(s, "#"); ok {
+		switch len(hex) {
+		case 3, 4:
+			hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+		case 6, 8:
+			hex = hex[:6]
+		default:
+			return "", false
+		}
+		if _, err := strconv.ParseUint(hex, 16, 32); err != nil {
+			return "", false
+		}
+		return "#" + hex, true
+	}
+	args, ok := strings.CutPrefix(s, "rgb(")
+	if !ok {
+		args, ok = strings.CutPrefix(s, "rgba(")
+	}
+	if args, ok = strings.CutSuffix(args, ")"); !ok {
+		return "", false
+	}
+	parts := strings.FieldsFunc(args, func(r rune) bool { return r == ',' || r == ' ' || r == '/' })
+	if len(parts) < 3 {
+		return "", false
+	}
+	var rgb [3]uint8
+	for i := range rgb {
+		n, err := strconv.ParseUint(parts[i], 10, 8)
+		if err != nil {
+			return "", false
+		}
+		rgb[i] = uint8(n)
+	}
+	return fmt.Sprintf("#%02x%02x%02x", rgb[0], rgb[1], rgb[2]), true
+}
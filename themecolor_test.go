@@ -0,0 +1,24 @@
+package unfurlist
+
+import "testing"
+
+func TestThemeColor(t *testing.T) {
+	for _, tc := range []struct {
+		meta map[string]string
+		want string
+	}{
+		{map[string]string{"theme-color": "#4285F4"}, "#4285f4"},
+		{map[string]string{"theme-color": "#abc"}, "#aabbcc"},
+		{map[string]string{"theme-color": "#11223344"}, "#112233"},
+		{map[string]string{"theme-color": "rgb(255, 0, 10)"}, "#ff000a"},
+		{map[string]string{"theme-color": "rgba(1 2 3 / 50%)"}, "#010203"},
+		{map[string]string{"theme-color": "red", "msapplication-tilecolor": "#da532c"}, "#da532c"},
+		{map[string]string{"theme-color": "#ggg"}, ""},
+		{map[string]string{"theme-color": "rgb(300, 0, 0)"}, ""},
+		{nil, ""},
+	} {
+		if got := themeColor(tc.meta); got != tc.want {
+			t.Errorf("themeColor(%v) = %q, want %q", tc.meta, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,236 @@
+package unfurlist
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const (
+	maxThumbnailSize     = 1024     // max width or height of thumbnail
+	defaultThumbnailSize = 320      // width and height bound if not requested
+	maxThumbnailSource   = 10 << 20 // max size of the source image, bytes
+	maxThumbnailPixels   = 40e6     // max number of pixels of the source image
+
+	// max number of images decoded concurrently, decoded image of
+	// maxThumbnailPixels takes up to 160 MB
+	maxThumbnailDecodes = 2
+)
+
+// thumbnailService serves resized result images at /thumbnail
+type thumbnailService struct {
+	base    string // public url of the handler, without trailing slash
+	secret  []byte
+	decodes chan struct{} // limits concurrent decodes, see maxThumbnailDecodes
+}
+
+// WithThumbnails configures unfurl handler to serve resized result images at
+// /thumbnail path. Results with image get `thumbnail` attribute holding url
+// of this endpoint signed with secret, relative to baseURL, which must be a
+// public url of the handler. Clients may add "w" and "h" query parameters to
+// thumbnail url to bound its width and height (320 by default, up to 1024);
+// images are never upscaled. Thumbnails are kept in cache configured with
+// WithCache or WithMemcache. Configuration is ignored if baseURL or secret is
+// empty.
+func WithThumbnails(baseURL string, secret []byte) ConfFunc {
+	return func(h *unfurlHandler) *unfurlHandler {
+		if baseURL != "" && len(secret) != 0 {
+			h.thumbnails = &thumbnailService{
+				base:    strings.TrimSuffix(baseURL, "/"),
+				secret:  secret,
+				decodes: make(chan struct{}, maxThumbnailDecodes),
+			}
+		}
+		return h
+	}
+}
+
+func (s *thumbnailService) sign(imageURL string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(imageURL))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// thumbnailURL returns signed url of thumbnail for imageURL
+func (s *thumbnailService) thumbnailURL(imageURL string) string {
+	vals := url.Values{"url": {imageURL}, "sig": {s.sign(imageURL)}}
+	return s.base + "/thumbnail?" + vals.Encode()
+}
+
+func (h *unfurlHandler) serveThumbnail(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+	default:
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	s := h.thumbnails
+	if s == nil {
+		http.NotFound(w, r)
+		return
+	}
+	q := r.URL.Query()
+	imageURL := q.Get("url")
+	if !validURL(imageURL) || !hmac.Equal([]byte(q.Get("sig")), []byte(s.sign(imageURL))) {
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return
+	}
+	width, ok1 := thumbnailDimension(q.Get("w"))
+	height, ok2 := thumbnailDimension(q.Get("h"))
+	if !ok1 || !ok2 {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	ctx := r.Context()
+	key := "unfurlist:thumb:" + mcKey(fmt.Sprintf("%dx%d %s", width, height, imageURL))
+	if h.cacheNamespace != "" {
+		key = h.cacheNamespace + ":" + key
+	}
+	var data []byte
+	if h.cache != nil {
+		if b, err := h.cache.Get(ctx, key); err == nil {
+			data = b
+		}
+	}
+	if data == nil {
+		var err error
+		if data, err = h.makeThumbnail(r.Context(), imageURL, width, height); err != nil {
+			h.logf(ctx, "thumbnail of %q: %v", imageURL, err)
+			h.reportError(ctx, imageURL, CategoryImage, err)
+			http.Error(w, http.StatusText(http.StatusBadGateway), http.StatusBadGateway)
+			return
+		}
+		if h.cache != nil {
+			if err := h.cache.Set(ctx, key, data, h.cacheTTL); err != nil {
+				h.logf(ctx, "thumbnail cache update: %v", err)
+			}
+		}
+	}
+	w.Header().Set("Content-Type", http.DetectContentType(data))
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	w.Header().Set("Cache-Control", "public, max-age=86400")
+	w.Write(data)
+}
+
+// thumbnailDimension parses w or h query parameter
+func thumbnailDimension(s string) (int, bool) {
+	if s == "" {
+		return defaultThumbnailSize, true
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 1 {
+		return 0, false
+	}
+	return min(n, maxThumbnailSize), true
+}
+
+// makeThumbnail fetches image and returns it resized to fit into width x
+// height, encoded as jpeg, or as png if the image has transparency
+func (h *unfurlHandler) makeThumbnail(ctx context.Context, imageURL string, width, height int) ([]byte, error) {
+	resp, err := h.httpGet(ctx, imageURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %q", resp.Status)
+	}
+	src, err := io.ReadAll(io.LimitReader(resp.Body, maxThumbnailSource+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(src) > maxThumbnailSource {
+		return nil, fmt.Errorf("image is larger than %d bytes", maxThumbnailSource)
+	}
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(src))
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Width*cfg.Height > maxThumbnailPixels {
+		return nil, fmt.Errorf("image is too large: %dx%d", cfg.Width, cfg.Height)
+	}
+	select {
+	case h.thumbnails.decodes <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	img, _, err := image.Decode(bytes.NewReader(src))
+	if err == nil {
+		img = resizeImage(img, width, height)
+	}
+	<-h.thumbnails.decodes
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if opaque(img) {
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: 85})
+	} else {
+		err = png.Encode(&buf, img)
+	}
+	return buf.Bytes(), err
+}
+
+// resizeImage scales img down to fit into width x height keeping its aspect
+// ratio, averaging source pixels covered by each destination pixel
+func resizeImage(img image.Image, width, height int) image.Image {
+	b := img.Bounds()
+	if b.Dx() <= width && b.Dy() <= height {
+		return img
+	}
+	scale := min(float64(width)/float64(b.Dx()), float64(height)/float64(b.Dy()))
+	dw, dh := max(int(float64(b.Dx())*scale), 1), max(int(float64(b.Dy())*scale), 1)
+	at := func(x, y int) (r, g, b, a uint32) { return img.At(x, y).RGBA() }
+	if src, ok := img.(image.RGBA64Image); ok {
+		// avoids allocation of color value for each pixel
+		at = func(x, y int) (r, g, b, a uint32) {
+			c := src.RGBA64At(x, y)
+			return uint32(c.R), uint32(c.G), uint32(c.B), uint32(c.A)
+		}
+	}
+	dst := image.NewNRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		y0, y1 := y*b.Dy()/dh, max((y+1)*b.Dy()/dh, y*b.Dy()/dh+1)
+		for x := 0; x < dw; x++ {
+			x0, x1 := x*b.Dx()/dw, max((x+1)*b.Dx()/dw, x*b.Dx()/dw+1)
+			// colors are alpha-premultiplied, so that transparent
+			// pixels don't darken the result
+			var r, g, bl, a, n uint64
+			for sy := b.Min.Y + y0; sy < b.Min.Y+y1; sy++ {
+				for sx := b.Min.X + x0; sx < b.Min.X+x1; sx++ {
+					pr, pg, pb, pa := at(sx, sy)
+					r, g, bl, a = r+uint64(pr), g+uint64(pg), bl+uint64(pb), a+uint64(pa)
+					n++
+				}
+			}
+			i := y*dst.Stride + x*4
+			if a != 0 {
+				dst.Pix[i] = uint8(r * 0xff / a)
+				dst.Pix[i+1] = uint8(g * 0xff / a)
+				dst.Pix[i+2] = uint8(bl * 0xff / a)
+			}
+			dst.Pix[i+3] = uint8((a / n) >> 8)
+		}
+	}
+	return dst
+}
+
+// opaque reports whether img has no transparent pixels
+func opaque(img image.Image) bool {
+	if o, ok := img.(interface{ Opaque() bool }); ok {
+		return o.Opaque()
+	}
+	return false
+}
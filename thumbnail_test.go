@@ -0,0 +1,97 @@
+package unfurlist
+
+import (
+	"bytes"
+	"encoding/json"
+	"image"
+	"image/color"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestResizeImage(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 200, 100))
+	for i := range img.Pix {
+		img.Pix[i] = 0xff
+	}
+	for _, tc := range []struct{ w, h, wantW, wantH int }{
+		{50, 50, 50, 25},
+		{400, 10, 20, 10},
+		{300, 300, 200, 100},
+	} {
+		b := resizeImage(img, tc.w, tc.h).Bounds()
+		if b.Dx() != tc.wantW || b.Dy() != tc.wantH {
+			t.Errorf("%dx%d: got %dx%d, want %dx%d", tc.w, tc.h, b.Dx(), b.Dy(), tc.wantW, tc.wantH)
+		}
+	}
+	if c := color.NRGBAModel.Convert(resizeImage(img, 10, 10).At(3, 2)).(color.NRGBA); c != (color.NRGBA{0xff, 0xff, 0xff, 0xff}) {
+		t.Errorf("unexpected color after resize: %v", c)
+	}
+
+	// half transparent image not starting at the origin
+	half := image.NewNRGBA(image.Rect(0, 0, 40, 20))
+	for y := 0; y < 20; y++ {
+		for x := 0; x < 10; x++ {
+			half.SetNRGBA(x, y, color.NRGBA{0xff, 0, 0, 0xff})
+		}
+	}
+	sub := half.SubImage(image.Rect(0, 10, 20, 20))
+	if c := resizeImage(sub, 1, 1).(*image.NRGBA).NRGBAAt(0, 0); c != (color.NRGBA{0xff, 0, 0, 0x7f}) {
+		t.Errorf("unexpected color after resize of transparent image: %v", c)
+	}
+}
+
+func TestThumbnails(t *testing.T) {
+	var imgData bytes.Buffer
+	if err := png.Encode(&imgData, image.NewGray(image.Rect(0, 0, 200, 100))); err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/image.png":
+			w.Header().Set("Content-Type", "image/png")
+			w.Write(imgData.Bytes())
+		case "/page":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(`<html><head><meta property="og:title" content="Page">
+<meta property="og:image" content="/image.png"></head></html>`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	handler := New(WithThumbnails("https://unfurl.example.com/", []byte("secret")))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/?content="+url.QueryEscape(srv.URL+"/page"), nil))
+	var res []Result
+	if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 1 || !strings.HasPrefix(res[0].Thumbnail, "https://unfurl.example.com/thumbnail?") {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+	thumb := strings.TrimPrefix(res[0].Thumbnail, "https://unfurl.example.com")
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, thumb+"&w=50", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected status: %d", w.Code)
+	}
+	img, format, err := image.Decode(w.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := img.Bounds(); format != "jpeg" || b.Dx() != 50 || b.Dy() != 25 {
+		t.Fatalf("unexpected thumbnail: %s %dx%d", format, b.Dx(), b.Dy())
+	}
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/thumbnail?sig=00&url="+url.QueryEscape(srv.URL+"/image.png"), nil))
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("unexpected status for invalid signature: %d", w.Code)
+	}
+}
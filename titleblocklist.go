@@ -0,0 +1,102 @@
+package unfurlist
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// titleRule is a parsed entry of the list configured with WithBlocklistTitles
+type titleRule struct {
+	domain string         // host glob the rule is limited to, empty for any host
+	substr string         // lower-cased substring, if re is nil
+	re     *regexp.Regexp // case-insensitive
+}
+
+// parseTitleRules parses title blocklist entries, see WithBlocklistTitles for
+// syntax. Invalid entries are skipped, error describes the first of them.
+func parseTitleRules(entries []string) ([]titleRule, error) {
+	var rules []titleRule
+	var firstErr error
+	for _, s := range entries {
+		r, err := parseTitleRule(s)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("title blocklist rule %q: %w", s, err)
+			}
+			continue
+		}
+		rules = append(rules, r)
+	}
+	return rules, firstErr
+}
+
+func parseTitleRule(s string) (titleRule, error) {
+	var r titleRule
+	if rest, ok := strings.CutPrefix(s, "@"); ok {
+		domain, rule, ok := strings.Cut(rest, " ")
+		if !ok {
+			return r, errors.New("domain must be followed by space and rule")
+		}
+		domain = strings.ToLower(domain)
+		if _, err := path.Match(domain, ""); err != nil {
+			return r, err
+		}
+		r.domain, s = domain, strings.TrimSpace(rule)
+	}
+	if len(s) > 2 && strings.HasPrefix(s, "/") && strings.HasSuffix(s, "/") {
+		re, err := regexp.Compile("(?i)" + s[1:len(s)-1])
+		if err != nil {
+			return r, err
+		}
+		r.re = re
+		return r, nil
+	}
+	if s == "" {
+		return r, errors.New("empty rule")
+	}
+	r.substr = strings.ToLower(s)
+	return r, nil
+}
+
+// match reports whether rule matches text of the page on given host
+func (r titleRule) match(host, lowerText string) bool {
+	if r.domain != "" {
+		if ok, _ := path.Match(r.domain, host); !ok {
+			return false
+		}
+	}
+	if r.re != nil {
+		return r.re.MatchString(lowerText)
+	}
+	return strings.Contains(lowerText, r.substr)
+}
+
+// CheckBlocklistTitles returns error describing the first invalid entry of
+// the list suitable for WithBlocklistTitles, or nil if all are valid
+func CheckBlocklistTitles(entries []string) error {
+	_, err := parseTitleRules(entries)
+	return err
+}
+
+// contentBlocklisted reports whether title or description of the page on
+// host match any rule configured with WithBlocklistTitles
+func (h *unfurlHandler) contentBlocklisted(host, title, description string) bool {
+	p := h.titleBlocklist.Load()
+	if p == nil || len(*p) == 0 || title == "" && description == "" {
+		return false
+	}
+	host = strings.ToLower(host)
+	if hst, _, ok := strings.Cut(host, ":"); ok && !strings.HasPrefix(host, "[") {
+		host = hst
+	}
+	lt, ld := strings.ToLower(title), strings.ToLower(description)
+	for _, r := range *p {
+		if lt != "" && r.match(host, lt) || ld != "" && r.match(host, ld) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,145 @@
+package unfurlist
+
+import (
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TransportOptions configures transport returned by NewTransport
+type TransportOptions struct {
+	// ForceHTTP2 makes transport attempt HTTP/2 even if it has custom
+	// dialer or TLS configuration, which otherwise disables HTTP/2
+	ForceHTTP2 bool
+	// MaxConnsPerHost limits total number of connections per host, 0 means
+	// no limit
+	MaxConnsPerHost int
+	// HTTP3, if set, is used to make requests over HTTP/3, i.e.
+	// *http3.Transport from github.com/quic-go/quic-go/http3. It is only
+	// used for hosts that advertised HTTP/3 support with Alt-Svc header in
+	// earlier responses; requests falling over HTTP/3 are retried with
+	// HTTP/1.1 or HTTP/2.
+	HTTP3 http.RoundTripper
+}
+
+// NewTransport returns transport to use with http.Client passed to
+// WithHTTPClient. It uses base, or a clone of http.DefaultTransport if base
+// is nil, configured with opts. Unless opts.HTTP3 is set, returned value is
+// *http.Transport.
+func NewTransport(base *http.Transport, opts TransportOptions) http.RoundTripper {
+	var t *http.Transport
+	if base != nil {
+		t = base.Clone()
+	} else {
+		t = http.DefaultTransport.(*http.Transport).Clone()
+	}
+	if opts.ForceHTTP2 {
+		t.ForceAttemptHTTP2 = true
+	}
+	if opts.MaxConnsPerHost > 0 {
+		t.MaxConnsPerHost = opts.MaxConnsPerHost
+	}
+	if opts.HTTP3 == nil {
+		return t
+	}
+	return &altSvcTransport{Transport: t, h3: opts.HTTP3, hosts: make(map[string]time.Time)}
+}
+
+// maxAltSvcHosts limits number of hosts altSvcTransport remembers
+const maxAltSvcHosts = 10000
+
+// altSvcTransport sends requests over HTTP/3 to hosts that advertised it
+// with Alt-Svc header, other requests are sent with embedded transport
+type altSvcTransport struct {
+	*http.Transport
+	h3 http.RoundTripper
+
+	mu    sync.Mutex
+	hosts map[string]time.Time // HTTP/3 capable hosts to expiration times
+}
+
+func (t *altSvcTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Scheme != "https" {
+		return t.Transport.RoundTrip(req)
+	}
+	// only requests without body can be safely retried
+	if (req.Body == nil || req.Body == http.NoBody) && t.supported(req.URL.Host) {
+		resp, err := t.h3.RoundTrip(req)
+		if err == nil {
+			return resp, nil
+		}
+		if req.Context().Err() != nil {
+			return nil, err
+		}
+		t.update(req.URL.Host, "clear")
+	}
+	resp, err := t.Transport.RoundTrip(req)
+	if err == nil {
+		if v := resp.Header.Get("Alt-Svc"); v != "" {
+			t.update(req.URL.Host, v)
+		}
+	}
+	return resp, err
+}
+
+func (t *altSvcTransport) CloseIdleConnections() {
+	t.Transport.CloseIdleConnections()
+	if c, ok := t.h3.(interface{ CloseIdleConnections() }); ok {
+		c.CloseIdleConnections()
+	}
+}
+
+func (t *altSvcTransport) supported(host string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	exp, ok := t.hosts[host]
+	if ok && time.Now().After(exp) {
+		delete(t.hosts, host)
+		return false
+	}
+	return ok
+}
+
+// update records whether host supports HTTP/3 on the same port, as
+// advertised by Alt-Svc header value (RFC 7838)
+func (t *altSvcTransport) update(host, altSvc string) {
+	port := "443"
+	if _, p, err := net.SplitHostPort(host); err == nil {
+		port = p
+	}
+	ttl, ok := h3MaxAge(altSvc, port)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !ok {
+		delete(t.hosts, host)
+		return
+	}
+	if _, known := t.hosts[host]; !known && len(t.hosts) >= maxAltSvcHosts {
+		return
+	}
+	t.hosts[host] = time.Now().Add(ttl)
+}
+
+// h3MaxAge reports whether Alt-Svc header value advertises HTTP/3 on the
+// given port of the same host, and for how long
+func h3MaxAge(altSvc, port string) (time.Duration, bool) {
+	for _, alt := range strings.Split(altSvc, ",") {
+		params := strings.Split(alt, ";")
+		proto, authority, ok := strings.Cut(strings.TrimSpace(params[0]), "=")
+		if !ok || proto != "h3" || strings.Trim(authority, `"`) != ":"+port {
+			continue
+		}
+		ttl := 24 * time.Hour
+		for _, p := range params[1:] {
+			k, v, _ := strings.Cut(strings.TrimSpace(p), "=")
+			if n, err := strconv.Atoi(strings.Trim(v, `"`)); k == "ma" && err == nil {
+				ttl = time.Duration(n) * time.Second
+			}
+		}
+		return ttl, ttl > 0
+	}
+	return 0, false
+}
@@ -0,0 +1,76 @@
+package unfurlist
+
+import (
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestNewTransport(t *testing.T) {
+	tr, ok := NewTransport(nil, TransportOptions{ForceHTTP2: true, MaxConnsPerHost: 4}).(*http.Transport)
+	if !ok || !tr.ForceAttemptHTTP2 || tr.MaxConnsPerHost != 4 {
+		t.Fatalf("unexpected transport: %+v", tr)
+	}
+}
+
+func TestH3MaxAge(t *testing.T) {
+	for _, tc := range []struct {
+		altSvc, port string
+		ttl          time.Duration
+		ok           bool
+	}{
+		{`h3=":443"; ma=86400`, "443", 24 * time.Hour, true},
+		{`h3-29=":443", h3=":443"; ma=60`, "443", time.Minute, true},
+		{`h3=":443"`, "443", 24 * time.Hour, true},
+		{`h3=":8443"; ma=60`, "443", 0, false},
+		{`h3="alt.example.com:443"`, "443", 0, false},
+		{`h2=":443"`, "443", 0, false},
+		{`clear`, "443", 0, false},
+	} {
+		ttl, ok := h3MaxAge(tc.altSvc, tc.port)
+		if ttl != tc.ttl || ok != tc.ok {
+			t.Errorf("%q: got %v, %v", tc.altSvc, ttl, ok)
+		}
+	}
+}
+
+func TestAltSvcTransport(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, port, _ := net.SplitHostPort(r.Host)
+		w.Header().Set("Alt-Svc", `h3=":`+port+`"; ma=60`)
+		io.WriteString(w, "tcp")
+	}))
+	defer srv.Close()
+	h3Fail := false
+	h3 := roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		if h3Fail {
+			return nil, errors.New("quic handshake failed")
+		}
+		w := httptest.NewRecorder()
+		io.WriteString(w, "h3")
+		return w.Result(), nil
+	})
+	client := &http.Client{Transport: NewTransport(srv.Client().Transport.(*http.Transport), TransportOptions{HTTP3: h3})}
+	get := func() string {
+		resp, err := client.Get(srv.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		b, _ := io.ReadAll(resp.Body)
+		return string(b)
+	}
+	for i, want := range []string{"tcp", "h3", "h3"} {
+		if got := get(); got != want {
+			t.Fatalf("request %d: got %q, want %q", i, got, want)
+		}
+	}
+	h3Fail = true
+	if got := get(); got != "tcp" {
+		t.Fatalf("got %q after HTTP/3 failure, want tcp", got)
+	}
+}
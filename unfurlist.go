@@ -7,6 +7,12 @@
 // The endpoint accepts GET and POST requests with `content` as the main argument.
 // It then returns a JSON encoded list of URLs that were parsed.
 //
+// Versioned API is available at /v1/unfurl path, it accepts the same arguments
+// but returns results wrapped in an envelope which also describes urls that
+// could not be processed (see Envelope type):
+//
+//	{"results": [...], "errors": [{"url": "...", "error": "..."}]}
+//
 // If an URL lacks an attribute (e.g. `image`) then this attribute will be omitted from the result.
 //
 // Example:
@@ -31,10 +37,113 @@
 //
 // If handler was configured with FetchImageSize=true in its config, each hash
 // may have additional fields `image_width` and `image_height` specifying
-// dimensions of image provided by `image` attribute.
+// dimensions of image provided by `image` attribute. If image is small enough
+// to be fetched in full, results also have `dominant_color` field holding
+// hex-encoded color like "#a0b1c2" and `blurhash` field with BlurHash
+// (https://blurha.sh) of the image to render placeholder with.
+// Results also have `image_size` field with image size in bytes if it's known,
+// see WithMaxImageSize.
+//
+// If URL points directly to an image, its file name is used as `title`, and
+// result may have additional fields `image_format` (i.e. "png" or "jpeg") and
+// `content_length`.
+//
+// Results for urls pointing to other non-html resources, like PDF documents,
+// have `content_type` field and, if server reported it, `content_length`.
+// If url path points to a file with well-known extension (i.e. "zip" or
+// "docx"), result has "file" `url_type`, file name as `title` and
+// `extension` field, even if file could not be fetched.
+//
+// Links to iCalendar files have "event" `url_type`, title of the first event
+// as `title`, and `event_start` and `event_location` fields. So do results for
+// pages describing schema.org Event in JSON-LD, like Eventbrite or Meetup.
+//
+// Results for pages marking their content as not freely accessible (with
+// isAccessibleForFree=false in JSON-LD or article:content_tier meta tag) have
+// `paywalled` field set to true.
+//
+// Results for pages having theme-color or msapplication-TileColor meta tag
+// have `theme_color` field holding hex-encoded color like "#a0b1c2".
+//
+// Results for article pages (having <article> element or "article" og:type)
+// have `word_count` and `reading_time_minutes` fields if the article text fits
+// into the first MaxBodyChunkSize bytes of the page.
+//
+// Results for product pages have `price`, `currency` and `availability` fields
+// taken from product:price:amount, product:price:currency and
+// product:availability meta tags, or from schema.org Offer in JSON-LD.
+// Availability is schema.org ItemAvailability name, i.e. "InStock".
+//
+// For YouTube, Vimeo and Loom video links `html` field holds iframe embed
+// snippet made from video id if provider doesn't return one, i.e. when page
+// cannot be fetched because of captcha wall.
+//
+// Video results (having "video" or "video.*" `url_type`) have
+// `duration_seconds` field if the length of video is known from oEmbed
+// response, og:video:duration meta tag or schema.org VideoObject in JSON-LD.
+//
+// If handler is configured with WithThumbnails, results having `image` also
+// have `thumbnail` field with signed url of resized image served by the
+// handler at /thumbnail; optional "w" and "h" parameters may be added to this
+// url to bound thumbnail size.
+//
+// If handler is configured with WithURLReputation, urls known to be dangerous
+// are not fetched and their results have `dangerous` field set to true.
+//
+// Results that expire from cache have `expires_at` field holding RFC 3339
+// time until which clients may reuse them without asking again.
 //
 // Additionally you can supply `callback` to wrap the result in a JavaScript callback (JSONP),
-// the type of this response would be "application/x-javascript"
+// the type of this response would be "application/x-javascript". Callback must
+// be a JavaScript identifier or a dot-separated chain of them (i.e.
+// "jQuery.cb_123"), requests with other callback values are rejected with 400
+// Bad Request status. JSONP support can be disabled with WithJSONP(false).
+//
+// Response encoding is selected according to the request Accept header:
+// besides JSON, MessagePack ("application/msgpack") and protobuf
+// ("application/protobuf", see unfurlist.proto for the schema) are supported.
+//
+// Optional `timeout` argument (i.e. timeout=1.5s) limits time spent processing
+// request, it's capped by server-side maximum configured with WithMaxTimeout.
+// If some urls are not processed in time, results completed so far are
+// returned, while results for unfinished urls only have `url` attribute and
+// `status` attribute set to "timeout".
+//
+// Optional `skip_empty` argument (i.e. skip_empty=1) omits results that have
+// no metadata besides `url`, see also WithSkipEmptyResults.
+//
+// Optional `include` argument lists extra result attributes to return,
+// comma-separated. With include=raw_meta results for html pages have
+// `raw_meta` attribute with all og:*, twitter:* and other meta tags of the page
+// keyed by their property or name. With include=oembed results based on oEmbed
+// have `oembed` attribute with all attributes of provider response, i.e.
+// author_url or cache_age.
+//
+// Optional `max` argument (i.e. max=1) limits number of urls processed, it's
+// capped by server-side maximum configured with WithMaxResults.
+//
+// Responses have X-Request-ID header with id of the request taken from request
+// header of the same name, or generated if request has none. Handler log lines
+// are prefixed with this id.
+//
+// OpenAPI 3 specification of the endpoints is served at /openapi.json path.
+//
+// If handler is configured with WithRequestSigning, each request must also
+// have `ts` (unix timestamp) and `sig` (signature made by SignContent)
+// arguments, otherwise it's rejected with 403 Forbidden status.
+//
+// If handler is configured with WithAsyncCallbacks and WithCallbackPrefixes,
+// requests may have `callback_url` argument matching one of the prefixes: such
+// requests are replied with 202 Accepted status and job id right away, results
+// are POSTed to callback url once ready (see AsyncResult). Requests over the
+// limit of jobs in progress (see WithMaxJobs) are replied with 503 Service
+// Unavailable status.
+//
+// If handler is configured with WithMemcache or WithCache, long batches can
+// be submitted as jobs: POST request to /jobs path accepts the same arguments
+// and is replied with 202 Accepted status and job id, job status and results
+// are then available with GET request to /jobs/{id} path (see Job type). Jobs
+// process up to DefaultMaxJobResults urls, see WithMaxJobResults.
 //
 // If an optional `markdown` boolean argument is set (markdown=true), then
 // provided content is parsed as markdown formatted text and links are extracted
@@ -63,25 +172,34 @@ import (
 	"bytes"
 	"compress/zlib"
 	"context"
+	"crypto/cipher"
 	"crypto/sha1"
+	"crypto/sha256"
 	_ "embed"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"html"
 	"io"
 	"log"
+	"mime"
 	"net/http"
 	"net/url"
+	"reflect"
 	"sort"
+	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"golang.org/x/net/html/charset"
 	"golang.org/x/sync/singleflight"
 
 	"github.com/artyom/httpflags"
 	"github.com/artyom/oembed"
-	"github.com/bradfitz/gomemcache/memcache"
 	"github.com/golang/snappy"
 )
 
@@ -91,11 +209,20 @@ const defaultMaxBodyChunkSize = 1024 * 64 //64KB
 // WithMaxResults function
 const DefaultMaxResults = 20
 
+// DefaultMaxContentSize is maximum size of request content in bytes if not
+// configured by WithMaxContentSize function
+const DefaultMaxContentSize = 256 << 10
+
 type unfurlHandler struct {
 	HTTPClient       *http.Client
 	Log              Logger
 	oembedLookupFunc oembed.LookupFunc
-	Cache            *memcache.Client
+	cache            Cache
+	cacheTTL         time.Duration // expiration of cached results, 0 if none
+	cacheNamespace   string        // see WithCacheNamespace
+	cacheAEAD        cipher.AEAD   // see WithCacheEncryption
+	cold             Cache         // optional second tier, see WithColdCache
+	coldTTL          time.Duration
 	MaxBodyChunkSize int64
 	FetchImageSize   bool
 
@@ -104,43 +231,241 @@ type unfurlHandler struct {
 	// otherwise Headers are ignored.
 	Headers []string
 
-	titleBlocklist []string
+	titleBlocklist atomic.Pointer[[]titleRule]
+	scrapeRules    atomic.Pointer[[]scrapeRule]
+
+	cookies        map[string]string      // Cookie header values by domain, see WithCookies
+	authorization  map[string]TokenSource // by domain, see WithAuthorization
+	forwardHeaders []string               // names of client request headers to forward
+
+	signer *requestSigner // if set, only signed requests are accepted
+
+	loginPages loginPageDetector // see WithLoginPageDetection
+	reputation URLReputation     // see WithURLReputation
+
+	cacheControl string // Cache-Control header value for responses
+	noJSONP      bool   // reject requests with callback argument
+
+	pmap atomic.Pointer[prefixMap] // built from BlocklistPrefix
+
+	descriptionRules []descriptionRule // see WithDescriptionSources
+
+	maxResults int  // max number of urls to process
+	maxContent int  // max size of request content or body
+	skipEmpty  bool // omit empty results unless request says otherwise
+	schemeless bool // also find www.example.com urls in plain text
+
+	maxTimeout time.Duration // max time to process single request
+
+	// fetchSem limits number of urls processed concurrently across all
+	// requests, nil if unlimited
+	fetchSem chan struct{}
+	// outbound limits number of outbound requests, nil if unlimited
+	outbound *outboundLimiter
+	// hedgeOembed enables fetching pages concurrently with oembed
+	// requests
+	hedgeOembed bool
+	// revalidateTTL is how long validators of pages are kept after
+	// their results expire, 0 if revalidation is disabled
+	revalidateTTL time.Duration
+	// upstreamTTL enables caching results of pages according to their
+	// Cache-Control or Expires headers, bounded by minTTL and maxTTL
+	upstreamTTL    bool
+	minTTL, maxTTL time.Duration
+
+	robots bool // honor robots directives of pages
+
+	memo    *lruCache // recent results, nil if disabled
+	memoTTL time.Duration
+
+	slowFetch time.Duration // log urls taking longer to process, 0 if disabled
+
+	screenshots *screenshotService
+	async       *asyncCallbacks // if set, callback_url argument is supported
+
+	callbackPrefixes *prefixMap    // allowed callback urls, none if nil
+	maxJobs          int           // see WithMaxJobs
+	maxJobResults    int           // see WithMaxJobResults
+	jobSem           chan struct{} // limits number of jobs in progress
 
-	pmap *prefixMap // built from BlocklistPrefix
+	providersRefresh *providersRefresher
+	oembedFn         atomic.Pointer[oembed.LookupFunc] // currently used providers list
 
-	maxResults int // max number of urls to process
+	preFetchHooks  []PreFetchHook
+	resultHooks    []ResultHook
+	errorReporters []ErrorReporter
+	imageRewriter  func(string) string
+	thumbnails     *thumbnailService // nil if disabled, see WithThumbnails
+	maxImageSize   int64             // images larger than this are dropped, 0 if unlimited
 
-	fetchers []FetchFunc
+	fetchers *FetcherRegistry
 	inFlight singleflight.Group // in-flight urls processed
+
+	forwardRequestID bool // add X-Request-ID header to outgoing requests
+
+	stats     handlerStats
+	statsd    *statsdClient // nil if metrics are not sent
+	statsdErr error         // why statsd client could not be set up
 }
 
-// Result that's returned back to the client
-type unfurlResult struct {
-	URL         string `json:"url"`
-	Title       string `json:"title,omitempty"`
-	Type        string `json:"url_type,omitempty"`
-	Description string `json:"description,omitempty"`
-	HTML        string `json:"html,omitempty"`
-	SiteName    string `json:"site_name,omitempty"`
-	Favicon     string `json:"favicon,omitempty"`
-	Image       string `json:"image,omitempty"`
-	ImageWidth  int    `json:"image_width,omitempty"`
-	ImageHeight int    `json:"image_height,omitempty"`
+// Result describes metadata of a single url that's returned back to the client
+type Result struct {
+	URL         string `json:"url" pb:"1"`
+	Title       string `json:"title,omitempty" pb:"2"`
+	Type        string `json:"url_type,omitempty" pb:"3"`
+	Description string `json:"description,omitempty" pb:"4"`
+	HTML        string `json:"html,omitempty" pb:"5"`
+	SiteName    string `json:"site_name,omitempty" pb:"6"`
+	Favicon     string `json:"favicon,omitempty" pb:"7"`
+	Image       string `json:"image,omitempty" pb:"8"`
+	ImageWidth  int    `json:"image_width,omitempty" pb:"9"`
+	ImageHeight int    `json:"image_height,omitempty" pb:"10"`
+
+	CanonicalURL string `json:"canonical_url,omitempty" pb:"11"`
+	Author       string `json:"author,omitempty" pb:"12"`
+
+	// ThemeColor is hex-encoded color (i.e. "#a0b1c2") of html page taken
+	// from its theme-color or msapplication-TileColor meta tag
+	ThemeColor string `json:"theme_color,omitempty" pb:"26"`
+
+	// fields below are only set for article pages, i.e. ones having
+	// <article> element or "article" type, if the article fits into the
+	// fetched chunk of the page
+	WordCount   int `json:"word_count,omitempty" pb:"27"`
+	ReadingTime int `json:"reading_time_minutes,omitempty" pb:"28"`
+
+	// fields below are only set for product pages; Price is decimal number
+	// as specified by the page, Currency is ISO 4217 code and
+	// Availability is schema.org ItemAvailability name, i.e. "InStock"
+	Price        string `json:"price,omitempty" pb:"29"`
+	Currency     string `json:"currency,omitempty" pb:"30"`
+	Availability string `json:"availability,omitempty" pb:"31"`
+
+	// Duration is length of video in seconds, only set for video results
+	Duration int `json:"duration_seconds,omitempty" pb:"35"`
+
+	// Thumbnail is signed url of resized Image served by the handler, only
+	// set if handler is configured with WithThumbnails
+	Thumbnail string `json:"thumbnail,omitempty" pb:"32"`
+
+	// fields below are only set with FetchImageSize=true for images small
+	// enough to be fetched in full; DominantColor is hex-encoded color like
+	// "#a0b1c2", BlurHash is placeholder encoded as described at
+	// https://blurha.sh
+	DominantColor string `json:"dominant_color,omitempty" pb:"15"`
+	BlurHash      string `json:"blurhash,omitempty" pb:"33"`
+
+	// ImageSize is size of Image in bytes, set if it is known from fetching
+	// the image, see FetchImageSize and WithMaxImageSize
+	ImageSize int64 `json:"image_size,omitempty" pb:"34"`
+
+	// fields below are only set for urls pointing directly to images
+	ImageFormat string `json:"image_format,omitempty" pb:"13"`
+
+	// fields below are only set for urls pointing to non-html resources,
+	// as reported by server
+	ContentType   string `json:"content_type,omitempty" pb:"19"`
+	ContentLength int64  `json:"content_length,omitempty" pb:"14"`
+
+	// Extension is only set for results of "file" type, i.e. "zip"
+	Extension string `json:"extension,omitempty" pb:"20"`
+
+	// fields below are only set for results of "event" type, i.e. links to
+	// iCalendar files or pages with schema.org Event; EventStart is RFC
+	// 3339 time, or date for all-day events
+	EventStart    string `json:"event_start,omitempty" pb:"21"`
+	EventLocation string `json:"event_location,omitempty" pb:"22"`
+
+	// MeetingID is only set for results of "meeting" type, see
+	// VideoConferenceHook
+	MeetingID string `json:"meeting_id,omitempty" pb:"23"`
+
+	// Status is only set for incomplete or blocked results, see Status*
+	// constants
+	Status string `json:"status,omitempty" pb:"16"`
+
+	// Paywalled is set for pages marking their content as not freely
+	// accessible
+	Paywalled bool `json:"paywalled,omitempty" pb:"17"`
+
+	// Dangerous is set for urls known to host malware or phishing, see
+	// WithURLReputation; such urls are not fetched
+	Dangerous bool `json:"dangerous,omitempty" pb:"18"`
+
+	// ExpiresAt is RFC 3339 time when result expires from cache, set if
+	// results are cached with expiration, see WithCacheTTL
+	ExpiresAt string `json:"expires_at,omitempty" pb:"36"`
 
-	idx int
+	// RawMeta holds all og:*, twitter:* and other meta tags of html page
+	// keyed by their property or name attribute. It's only returned when
+	// requested with include=raw_meta argument.
+	RawMeta map[string]string `json:"raw_meta,omitempty" pb:"24"`
+
+	// Oembed holds all attributes of oEmbed provider response, if result
+	// is based on it, with non-string values in JSON form. It's only
+	// returned when requested with include=oembed argument.
+	Oembed map[string]string `json:"oembed,omitempty" pb:"25"`
+
+	idx    int
+	err    error  // processing error, only reported by versioned API
+	parser string // how metadata was found, "cache" for cached results
 }
 
-func (u *unfurlResult) Empty() bool {
+// Result statuses
+const (
+	// StatusTimeout is the status of results for urls that were not
+	// processed before request deadline
+	StatusTimeout = "timeout"
+	// StatusBotProtection is the status of results for urls leading to
+	// captcha or interstitial pages, see ErrBotProtection
+	StatusBotProtection = "blocked_by_bot_protection"
+)
+
+var errTimeout = errors.New("url was not processed in time")
+
+// Empty reports whether result has no metadata
+func (u *Result) Empty() bool {
 	return u.URL == "" && u.Title == "" && u.Type == "" &&
 		u.Description == "" && u.Image == ""
 }
 
-func (u *unfurlResult) normalize() {
-	b := bytes.Join(bytes.Fields([]byte(u.Title)), []byte{' '})
-	u.Title = string(b)
+// bare reports whether result has no attributes besides url. Site name and
+// generic "website" type alone do not count, as html pages without metadata
+// get them anyway: site name is derived from url host. Cache expiration is
+// not metadata either.
+func (u *Result) bare() bool {
+	r := *u
+	r.URL, r.idx, r.err, r.parser = "", 0, nil, ""
+	r.SiteName, r.ExpiresAt = "", ""
+	if r.Type == "website" {
+		r.Type = ""
+	}
+	return reflect.ValueOf(r).IsZero()
+}
+
+func (u *Result) normalize() {
+	u.Title = normalizeText(u.Title)
+	u.Description = normalizeText(u.Description)
+	u.SiteName = normalizeText(u.SiteName)
 }
 
-func (u *unfurlResult) Merge(u2 *unfurlResult) {
+// normalizeText decodes HTML entities left in s, i.e. by sites encoding them
+// twice, replaces control characters with spaces and collapses whitespace
+func normalizeText(s string) string {
+	if strings.IndexByte(s, '&') >= 0 {
+		s = html.UnescapeString(s)
+	}
+	s = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) || r == utf8.RuneError {
+			return ' '
+		}
+		return r
+	}, s)
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// Merge fills empty attributes of u with values from u2
+func (u *Result) Merge(u2 *Result) {
 	if u2 == nil {
 		return
 	}
@@ -171,14 +496,126 @@ func (u *unfurlResult) Merge(u2 *unfurlResult) {
 	if u.ImageHeight == 0 {
 		u.ImageHeight = u2.ImageHeight
 	}
+	if u.CanonicalURL == "" {
+		u.CanonicalURL = u2.CanonicalURL
+	}
+	if u.Author == "" {
+		u.Author = u2.Author
+	}
+	if u.ImageFormat == "" {
+		u.ImageFormat = u2.ImageFormat
+	}
+	if u.ContentLength == 0 {
+		u.ContentLength = u2.ContentLength
+	}
+	if u.DominantColor == "" {
+		u.DominantColor = u2.DominantColor
+	}
+	if u.BlurHash == "" {
+		u.BlurHash = u2.BlurHash
+	}
+	if u.ImageSize == 0 {
+		u.ImageSize = u2.ImageSize
+	}
+	if u.Duration == 0 {
+		u.Duration = u2.Duration
+	}
+	if u.ExpiresAt == "" {
+		u.ExpiresAt = u2.ExpiresAt
+	}
+	if u.ContentType == "" {
+		u.ContentType = u2.ContentType
+	}
+	if u.Extension == "" {
+		u.Extension = u2.Extension
+	}
+	if u.EventStart == "" {
+		u.EventStart = u2.EventStart
+	}
+	if u.EventLocation == "" {
+		u.EventLocation = u2.EventLocation
+	}
+	if u.MeetingID == "" {
+		u.MeetingID = u2.MeetingID
+	}
+	if !u.Paywalled {
+		u.Paywalled = u2.Paywalled
+	}
+	if !u.Dangerous {
+		u.Dangerous = u2.Dangerous
+	}
+	if u.RawMeta == nil {
+		u.RawMeta = u2.RawMeta
+	}
+	if u.Oembed == nil {
+		u.Oembed = u2.Oembed
+	}
+	if u.ThemeColor == "" {
+		u.ThemeColor = u2.ThemeColor
+	}
+	if u.WordCount == 0 {
+		u.WordCount, u.ReadingTime = u2.WordCount, u2.ReadingTime
+	}
+	if u.Price == "" && u.Availability == "" {
+		u.Price, u.Currency, u.Availability = u2.Price, u2.Currency, u2.Availability
+	}
 }
 
-type unfurlResults []*unfurlResult
+type unfurlResults []*Result
 
 func (rs unfurlResults) Len() int           { return len(rs) }
 func (rs unfurlResults) Less(i, j int) bool { return rs[i].idx < rs[j].idx }
 func (rs unfurlResults) Swap(i, j int)      { rs[i], rs[j] = rs[j], rs[i] }
 
+// nonEmpty returns results that have any metadata besides url
+func (rs unfurlResults) nonEmpty() unfurlResults {
+	out := make(unfurlResults, 0, len(rs))
+	for _, r := range rs {
+		if !r.bare() {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+// filter returns results prepared for response according to request
+// arguments: attributes that were not requested are removed, and results
+// without metadata are omitted if requested
+func (args *requestArgs) filter(rs unfurlResults) unfurlResults {
+	rawMeta, oembed := args.includes("raw_meta"), args.includes("oembed")
+	for _, r := range rs {
+		if !rawMeta {
+			r.RawMeta = nil
+		}
+		if !oembed {
+			r.Oembed = nil
+		}
+	}
+	if args.SkipEmpty {
+		return rs.nonEmpty()
+	}
+	return rs
+}
+
+// includes reports whether attribute is listed in include argument
+func (args *requestArgs) includes(attr string) bool {
+	for _, s := range strings.Split(args.Include, ",") {
+		if strings.TrimSpace(s) == attr {
+			return true
+		}
+	}
+	return false
+}
+
+// filterJob wraps job completion function so that it gets envelope with
+// results filtered according to request arguments
+func (args *requestArgs) filterJob(done func(context.Context, string, *Envelope)) func(context.Context, string, *Envelope) {
+	return func(ctx context.Context, id string, env *Envelope) {
+		env.Results = args.filter(env.Results)
+		done(ctx, id, env)
+	}
+}
+
 // ConfFunc is used to configure new unfurl handler; such functions should be
 // used as arguments to New function
 type ConfFunc func(*unfurlHandler) *unfurlHandler
@@ -187,14 +624,27 @@ type ConfFunc func(*unfurlHandler) *unfurlHandler
 // provided, sane defaults would be used.
 func New(conf ...ConfFunc) http.Handler {
 	h := &unfurlHandler{
-		maxResults: DefaultMaxResults,
+		maxResults:    DefaultMaxResults,
+		maxContent:    DefaultMaxContentSize,
+		maxJobs:       DefaultMaxJobs,
+		maxJobResults: DefaultMaxJobResults,
 	}
 	for _, f := range conf {
 		h = f(h)
 	}
+	h.jobSem = make(chan struct{}, h.maxJobs)
 	if h.HTTPClient == nil {
 		h.HTTPClient = http.DefaultClient
 	}
+	if h.HTTPClient.CheckRedirect == nil {
+		c := *h.HTTPClient
+		c.CheckRedirect = h.loginPages.checkRedirect
+		h.HTTPClient = &c
+	}
+	if h.outbound != nil {
+		h.outbound.statsd = h.statsd
+		h.HTTPClient = h.outbound.wrap(h.HTTPClient)
+	}
 	if len(h.Headers)%2 != 0 {
 		h.Headers = nil
 	}
@@ -204,17 +654,86 @@ func New(conf ...ConfFunc) http.Handler {
 	if h.Log == nil {
 		h.Log = log.New(io.Discard, "", 0)
 	}
+	if h.statsdErr != nil {
+		h.Log.Printf("statsd metrics are disabled: %v", h.statsdErr)
+	}
+	if h.cacheAEAD != nil {
+		h.cache = encryptCache(h.cache, h.cacheAEAD)
+		h.cold = encryptCache(h.cold, h.cacheAEAD)
+	}
 	if h.oembedLookupFunc == nil {
 		fn, err := oembed.Providers(bytes.NewReader(providersData))
 		if err != nil {
 			panic(err)
 		}
 		h.oembedLookupFunc = fn
+		sum := sha256.Sum256(providersData)
+		h.stats.providers.Store(&ListVersion{Loaded: time.Now().UTC(), Source: "embedded",
+			Digest: hex.EncodeToString(sum[:8])})
+	} else {
+		h.stats.providers.Store(&ListVersion{Loaded: time.Now().UTC(), Source: "custom"})
+	}
+	h.oembedFn.Store(&h.oembedLookupFunc)
+	if h.providersRefresh != nil {
+		// embedded (or explicitly configured) list is fresh enough to
+		// start with
+		h.providersRefresh.last.Store(time.Now().UnixNano())
 	}
 	return h
 }
 
+// requestArgs describes arguments of unfurl request. Flag tag descriptions are
+// used in OpenAPI specification.
+type requestArgs struct {
+	Content   string        `flag:"content,text to extract urls from"`
+	Callback  string        `flag:"callback,JSONP callback name"`
+	Markdown  bool          `flag:"markdown,parse content as markdown"`
+	Format    string        `flag:"content_type,format of content: text (default), markdown, html or mrkdwn (Slack message formatting)"`
+	Timestamp int64         `flag:"ts,unix timestamp of signed request"`
+	Signature string        `flag:"sig,signature of signed request"`
+	Timeout   time.Duration `flag:"timeout,time limit to process request, i.e. 1.5s"`
+	Max       int           `flag:"max,maximum number of urls to process, capped by server limit"`
+	SkipEmpty bool          `flag:"skip_empty,omit results without any metadata besides url"`
+	Include   string        `flag:"include,comma-separated optional result attributes to include: raw_meta, oembed"`
+
+	CallbackURL string `flag:"callback_url,process request asynchronously and POST results to this url"`
+}
+
+// requestTimeout returns timeout to process request given the requested one:
+// requested timeout is capped by the one configured with WithMaxTimeout,
+// which is also used by default.
+func (h *unfurlHandler) requestTimeout(requested time.Duration) time.Duration {
+	if requested <= 0 || (h.maxTimeout > 0 && requested > h.maxTimeout) {
+		return h.maxTimeout
+	}
+	return requested
+}
+
+// requestMaxResults returns maximum number of urls to process given the
+// requested one: requested number is capped by the one configured with
+// WithMaxResults, which is also used by default.
+func (h *unfurlHandler) requestMaxResults(requested int) int {
+	if requested <= 0 || requested > h.maxResults {
+		return h.maxResults
+	}
+	return requested
+}
+
 func (h *unfurlHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	reqID := requestID(r.Header.Get(RequestIDHeader))
+	w.Header().Set(RequestIDHeader, reqID)
+	r = r.WithContext(withRequestID(r.Context(), reqID))
+	switch {
+	case r.URL.Path == "/openapi.json":
+		serveOpenAPI(w, r)
+		return
+	case strings.HasPrefix(r.URL.Path, "/jobs/"):
+		h.serveJob(w, r)
+		return
+	case r.URL.Path == "/thumbnail":
+		h.serveThumbnail(w, r)
+		return
+	}
 	switch r.Method {
 	case http.MethodGet, http.MethodPost:
 	default:
@@ -222,170 +741,490 @@ func (h *unfurlHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
 		return
 	}
-	args := struct {
-		Content  string `flag:"content"`
-		Callback string `flag:"callback"`
-		Markdown bool   `flag:"markdown"`
-	}{}
+	if r.Body != nil {
+		r.Body = http.MaxBytesReader(w, r.Body, int64(h.maxContent))
+	}
+	args := requestArgs{SkipEmpty: h.skipEmpty}
 	if err := httpflags.Parse(&args, r); err != nil || args.Content == "" {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, http.StatusText(http.StatusRequestEntityTooLarge), http.StatusRequestEntityTooLarge)
+			return
+		}
 		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
 		return
 	}
-
-	var urls []string
-	switch {
-	case args.Markdown:
-		urls = parseMarkdownURLs(args.Content, h.maxResults)
+	if len(args.Content) > h.maxContent {
+		http.Error(w, http.StatusText(http.StatusRequestEntityTooLarge), http.StatusRequestEntityTooLarge)
+		return
+	}
+	if args.Markdown && args.Format == "" {
+		args.Format = formatMarkdown
+	}
+	switch args.Format {
+	case "", formatText, formatMarkdown, formatHTML, formatSlack:
 	default:
-		urls = parseURLsMax(args.Content, h.maxResults)
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	if args.Callback != "" && (h.noJSONP || !validCallback(args.Callback)) {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	if h.signer != nil && !h.signer.valid(args.Content, args.Timestamp, args.Signature, time.Now()) {
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return
 	}
 
-	jobResults := make(chan *unfurlResult, 1)
-	results := make(unfurlResults, 0, len(urls))
+	if args.CallbackURL != "" {
+		if h.async == nil || !h.validCallbackURL(args.CallbackURL) {
+			http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+			return
+		}
+		ctx := withForwardedHeaders(r.Context(), r.Header, h.forwardHeaders)
+		urls, texts := h.parseURLs(args.Content, args.Format, h.requestMaxResults(args.Max))
+		ctx = withLinkTexts(ctx, texts)
+		setAccessURLs(r.Context(), len(urls))
+		done := args.filterJob(h.callbackDelivery(args.CallbackURL))
+		id, ok := h.startJob(ctx, urls, h.requestTimeout(args.Timeout), done)
+		if !ok {
+			writeTooManyJobs(w)
+			return
+		}
+		writeAccepted(w, id)
+		return
+	}
+	if r.URL.Path == "/jobs" {
+		h.submitJob(w, r, args)
+		return
+	}
+
+	defer func(start time.Time) { h.statsd.timing("request.time", time.Since(start)) }(time.Now())
 	ctx := r.Context()
+	if timeout := h.requestTimeout(args.Timeout); timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	if len(h.forwardHeaders) != 0 {
+		ctx = withForwardedHeaders(ctx, r.Header, h.forwardHeaders)
+		w.Header().Set("Vary", strings.Join(h.forwardHeaders, ", "))
+	}
+	urls, texts := h.parseURLs(args.Content, args.Format, h.requestMaxResults(args.Max))
+	setAccessURLs(r.Context(), len(urls))
+	results := h.unfurl(withLinkTexts(ctx, texts), urls)
+	if r.Context().Err() != nil {
+		return // client is gone
+	}
+
+	if r.URL.Path == "/v1/unfurl" {
+		env := newEnvelope(results)
+		env.Results = args.filter(env.Results)
+		h.writeResults(w, r, env, args.Callback)
+		return
+	}
+	h.writeResults(w, r, args.filter(results), args.Callback)
+}
+
+// Unfurler is implemented by handler returned by New. It allows processing
+// urls outside of http requests, i.e. from a queue consumer.
+type Unfurler interface {
+	// Unfurl extracts urls from content, parsing it as markdown if
+	// markdown is true, and returns results for them in the order urls
+	// appear in content. Time spent is limited by ctx and by timeout
+	// configured with WithMaxTimeout.
+	Unfurl(ctx context.Context, content string, markdown bool) *Envelope
+}
 
+func (h *unfurlHandler) Unfurl(ctx context.Context, content string, markdown bool) *Envelope {
+	if timeout := h.requestTimeout(0); timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	format := formatText
+	if markdown {
+		format = formatMarkdown
+	}
+	urls, texts := h.parseURLs(content, format, h.maxResults)
+	env := newEnvelope(h.unfurl(withLinkTexts(ctx, texts), urls))
+	env.Results = new(requestArgs).filter(env.Results)
+	return env
+}
+
+// Supported formats of request content
+const (
+	formatText     = "text"
+	formatMarkdown = "markdown"
+	formatHTML     = "html"
+	formatSlack    = "mrkdwn"
+)
+
+// parseURLs returns up to maxItems urls found in content of given format.
+// For markdown, html and mrkdwn content, it also returns texts of links keyed
+// by their urls.
+func (h *unfurlHandler) parseURLs(content, format string, maxItems int) ([]string, map[string]string) {
+	switch format {
+	case formatMarkdown:
+		return parseMarkdownURLs(content, maxItems)
+	case formatHTML:
+		return parseHTMLURLs(content, maxItems)
+	case formatSlack:
+		return parseSlackURLs(content, maxItems)
+	}
+	geo := h.fetchers.handlesGeoURIs()
+	switch {
+	case h.schemeless && geo:
+		return addScheme(parseURLsRe(content, reUrlsGeoWWW, maxItems)), nil
+	case h.schemeless:
+		return addScheme(parseURLsRe(content, reUrlsWWW, maxItems)), nil
+	case geo:
+		return parseURLsRe(content, reUrlsGeo, maxItems), nil
+	}
+	return parseURLsMax(content, maxItems), nil
+}
+
+type linkTextsKey struct{}
+
+// withLinkTexts returns context carrying link texts used as title fallback
+// by unfurl
+func withLinkTexts(ctx context.Context, texts map[string]string) context.Context {
+	if len(texts) == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, linkTextsKey{}, texts)
+}
+
+// unfurl processes urls concurrently and returns their normalized results in
+// the original order. If ctx has deadline, urls not processed shortly before
+// it are reported with StatusTimeout.
+func (h *unfurlHandler) unfurl(ctx context.Context, urls []string) unfurlResults {
+	ctx = h.withCacheBatch(ctx, urls)
+	found, _ := ctx.Value(cacheBatchKey{}).(map[string]*Result)
+	jobResults := make(chan *Result, 1)
+	results := make(unfurlResults, 0, len(urls))
+	var pending int
 	for i, r := range urls {
-		go func(ctx context.Context, i int, link string, jobResults chan *unfurlResult) {
+		if _, ok := found[h.cacheKey(ctx, r)]; ok && h.reputation == nil {
+			// results found by batch lookup are ready, only checks
+			// and hooks are left, which are cheap enough to run
+			// inline; reputation lookups may not be
+			results = append(results, h.processURLidx(ctx, i, r))
+			continue
+		}
+		pending++
+		go func(ctx context.Context, i int, link string, jobResults chan *Result) {
 			select {
 			case jobResults <- h.processURLidx(ctx, i, link):
 			case <-ctx.Done():
 			}
 		}(ctx, i, r, jobResults)
 	}
-	for i := 0; i < len(urls); i++ {
+	// stop waiting for results a bit before deadline, so that partial
+	// results can be sent back in time
+	var expiring <-chan time.Time
+	if deadline, ok := ctx.Deadline(); ok {
+		left := time.Until(deadline)
+		t := time.NewTimer(left - min(left/10, 100*time.Millisecond))
+		defer t.Stop()
+		expiring = t.C
+	}
+collect:
+	for i := 0; i < pending; i++ {
 		select {
 		case <-ctx.Done():
-			return
+			break collect
+		case <-expiring:
+			break collect
 		case res := <-jobResults:
 			results = append(results, res)
 		}
 	}
-
+	if len(results) < len(urls) {
+		done := make([]bool, len(urls))
+		for _, res := range results {
+			done[res.idx] = true
+		}
+		for i, link := range urls {
+			if !done[i] {
+				results = append(results, &Result{URL: link, Status: StatusTimeout, idx: i, err: errTimeout})
+			}
+		}
+	}
 	sort.Sort(results)
+	texts, _ := ctx.Value(linkTextsKey{}).(map[string]string)
 	for _, r := range results {
+		// pages without title are described by text of the link pointing
+		// to them, unless they were blocked
+		if r.Title == "" && !errors.Is(r.err, errBlocklisted) && !errors.Is(r.err, errDangerousURL) {
+			r.Title = texts[r.URL]
+		}
 		r.normalize()
 	}
-
-	if args.Callback != "" {
-		w.Header().Set("Content-Type", "application/x-javascript")
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-	} else {
-		w.Header().Set("Content-Type", "application/json")
-	}
-
-	if args.Callback != "" {
-		io.WriteString(w, args.Callback+"(")
-		json.NewEncoder(w).Encode(results)
-		w.Write([]byte(")"))
-		return
-	}
-	json.NewEncoder(w).Encode(results)
+	return results
 }
 
 // processURLidx wraps processURL and adds provided index i to the result. It
 // also collapses multiple in-flight requests for the same url to a single
 // processURL call
-func (h *unfurlHandler) processURLidx(ctx context.Context, i int, link string) *unfurlResult {
-	defer h.inFlight.Forget(link)
-	v, _, shared := h.inFlight.Do(link, func() (any, error) { return h.processURL(ctx, link), nil })
-	res, ok := v.(*unfurlResult)
+func (h *unfurlHandler) processURLidx(ctx context.Context, i int, link string) *Result {
+	for _, fn := range h.preFetchHooks {
+		switch skip, override := fn(ctx, link); {
+		case override != nil:
+			res := *override
+			res.idx = i
+			return h.applyResultHooks(ctx, link, &res)
+		case skip:
+			return &Result{URL: link, idx: i}
+		}
+	}
+	h.stats.inFlightURLs.Add(1)
+	defer h.stats.inFlightURLs.Add(-1)
+	key := resultKey(ctx, link)
+	defer h.inFlight.Forget(key)
+	v, _, shared := h.inFlight.Do(key, func() (any, error) {
+		if res, ok := h.memoized(key); ok {
+			return res, nil
+		}
+		h.stats.inFlightFetches.Add(1)
+		defer h.stats.inFlightFetches.Add(-1)
+		start := time.Now()
+		res := h.processURL(ctx, link)
+		h.observe(ctx, link, res, time.Since(start))
+		h.memoize(key, res)
+		return res, nil
+	})
+	res, ok := v.(*Result)
 	if !ok {
 		panic("got unexpected type from singleflight.Do")
 	}
-	if shared && (*res == unfurlResult{URL: link}) && ctx.Err() == nil {
+	if shared && res.URL == link && res.bare() && ctx.Err() == nil {
 		// an *incomplete* shared result, e.g. if context in another goroutine
 		// that called processURL was canceled early, need to refetch
 		res = h.processURL(ctx, link)
 	}
 	res2 := *res // make a copy because we're going to modify it
 	res2.idx = i
-	return &res2
+	return h.applyResultHooks(ctx, link, &res2)
+}
+
+// applyResultHooks passes result through all configured result hooks and
+// image rewriter
+func (h *unfurlHandler) applyResultHooks(ctx context.Context, link string, res *Result) *Result {
+	for _, fn := range h.resultHooks {
+		idx := res.idx
+		if r := fn(ctx, link, res); r != nil {
+			res = r
+		}
+		res.idx = idx
+	}
+	if h.thumbnails != nil && res.Image != "" && validURL(res.Image) {
+		res.Thumbnail = h.thumbnails.thumbnailURL(res.Image)
+	}
+	if fn := h.imageRewriter; fn != nil {
+		if res.Image != "" {
+			if res.Image = fn(res.Image); res.Image == "" {
+				res.ImageWidth, res.ImageHeight = 0, 0
+			}
+		}
+		if res.Favicon != "" {
+			res.Favicon = fn(res.Favicon)
+		}
+	}
+	return res
 }
 
 // Processes the URL by first looking in cache, then trying oEmbed, OpenGraph
 // If no match is found the result will be an object that just contains the URL
-func (h *unfurlHandler) processURL(ctx context.Context, link string) *unfurlResult {
-	result := &unfurlResult{URL: link}
-	if h.pmap != nil && h.pmap.Match(link) { // blocklisted
-		h.Log.Printf("Blocklisted %q", link)
+func (h *unfurlHandler) processURL(ctx context.Context, link string) *Result {
+	result := &Result{URL: link}
+	if h.pmap.Load().Match(link) { // blocklisted
+		h.logf(ctx, "Blocklisted %q", link)
+		result.err = errBlocklisted
 		return result
 	}
+	if h.reputation != nil {
+		switch h.reputation(ctx, link) {
+		case VerdictDangerous:
+			h.logf(ctx, "Dangerous %q", link)
+			result.Dangerous, result.err = true, errDangerousURL
+			return result
+		case VerdictBlock:
+			h.logf(ctx, "Dangerous %q, skipped", link)
+			result.err = errDangerousURL
+			return result
+		}
+	}
 
-	if mc := h.Cache; mc != nil {
-		if it, err := mc.Get(mcKey(link)); err == nil {
-			if b, err := snappy.Decode(nil, it.Value); err == nil {
-				var cached unfurlResult
-				if err = json.Unmarshal(b, &cached); err == nil {
-					h.Log.Printf("Cache hit for %q", link)
-					return &cached
-				}
-			}
+	if h.cache != nil || h.cold != nil {
+		if cached, ok := h.cachedResult(ctx, link); ok {
+			h.logf(ctx, "Cache hit for %q", link)
+			h.stats.cacheHits.Add(1)
+			h.statsd.count("cache.hit")
+			cached.parser = "cache"
+			return cached
+		}
+		h.stats.cacheMisses.Add(1)
+		h.statsd.count("cache.miss")
+	}
+	if h.fetchSem != nil {
+		select {
+		case h.fetchSem <- struct{}{}:
+			defer func() { <-h.fetchSem }()
+		case <-ctx.Done():
+			result.err = ctx.Err()
+			return result
 		}
 	}
 	var chunk *pageChunk
 	var err error
+	var pendingOembed <-chan oembedResult // oembed response still in flight
+	parser := "none"                      // how metadata was found, for metrics
+	if scheme, _, _ := strings.Cut(link, ":"); strings.EqualFold(scheme, "geo") {
+		if u, err := url.Parse(strings.ToLower(scheme) + link[len(scheme):]); err == nil {
+			for _, f := range h.fetchers.lookup("geo:") {
+				meta, ok := f.Fetch(ctx, h.HTTPClient, u)
+				if !ok || !meta.Valid() {
+					continue
+				}
+				meta.apply(result)
+				parser = "fetcher." + f.Name()
+				goto hasMatch
+			}
+		}
+		result.err = errors.New("no fetcher for geo: uri")
+		return result
+	}
 	// Optimistically apply oembed logic to url we have, which can only work
 	// for non-minimized urls; however if it works, it'll let us skip fetching
 	// url altogether. This can also somewhat help against sites redirecting to
 	// captchas/login pages when they see requests from non "home ISP"
 	// networks.
-	if endpoint, ok := h.oembedLookupFunc(result.URL); ok {
-		if res, err := fetchOembed(ctx, endpoint, h.httpGet); err == nil {
-			result.Merge(res)
+	if endpoint, ok := h.oembedLookup(result.URL); ok && h.hedgeOembed {
+		hctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		hr := h.hedgedFetch(hctx, link, endpoint)
+		if hr.oembed != nil {
+			result.Merge(hr.oembed)
+			parser = "oembed"
 			goto hasMatch
 		}
+		chunk, err, pendingOembed = hr.chunk, hr.err, hr.pending
+	} else {
+		if ok {
+			res, err := fetchOembed(ctx, endpoint, h.httpGet)
+			if err == nil {
+				result.Merge(res)
+				parser = "oembed"
+				goto hasMatch
+			}
+			h.reportError(ctx, link, CategoryOembed, err)
+		}
+		stale := h.staleValidators(ctx, h.cacheKey(ctx, link))
+		chunk, err = h.fetchData(withValidators(ctx, stale), result.URL)
+		if errors.Is(err, errNotModified) {
+			if res, ok := h.revalidated(ctx, h.cacheKey(ctx, link), stale); ok {
+				h.statsd.count("cache.revalidated")
+				res.parser = "cache"
+				return res
+			}
+			chunk, err = h.fetchData(ctx, result.URL)
+		}
 	}
-	chunk, err = h.fetchData(ctx, result.URL)
 	if err != nil {
 		if chunk != nil && strings.Contains(chunk.url.Host, "youtube.com") {
 			if meta, ok := youtubeFetcher(ctx, h.HTTPClient, chunk.url); ok && meta.Valid() {
-				result.Title = meta.Title
-				result.Type = meta.Type
-				result.Description = meta.Description
-				result.Image = meta.Image
-				result.ImageWidth = meta.ImageWidth
-				result.ImageHeight = meta.ImageHeight
+				meta.apply(result)
+				parser = "fetcher.youtube"
 				goto hasMatch
 			}
 		}
+		h.reportError(ctx, link, fetchErrorCategory(err), err)
+		if u, perr := url.Parse(result.URL); perr == nil {
+			// fetchers may get metadata from API even if page
+			// itself is behind login or captcha wall
+			if name, ok := h.applyFetchers(ctx, u, result); ok {
+				parser = "fetcher." + name
+				goto hasMatch
+			}
+		}
+		if u, perr := url.Parse(result.URL); perr == nil {
+			// video sites often respond with captcha walls, embed
+			// snippet can still be made from video id
+			if s := embedHTML(u); s != "" {
+				result.HTML, result.Type = s, "video"
+				parser = "embed"
+				goto hasMatch
+			}
+		}
+		if u, perr := url.Parse(result.URL); perr == nil && getDisallowed(err) {
+			// servers often disallow GET requests for files
+			if res := fileResult(u); res != nil {
+				result.Merge(res)
+				parser = "file"
+				goto hasMatch
+			}
+		}
+		if errors.Is(err, ErrBotProtection) {
+			result.Status = StatusBotProtection
+		}
+		h.logf(ctx, "Fetch of %q failed: %v", link, err)
+		result.err = err
 		return result
 	}
 	if s, err := h.faviconLookup(ctx, chunk); err == nil && s != "" {
 		result.Favicon = s
 	}
-	for _, f := range h.fetchers {
-		meta, ok := f(ctx, h.HTTPClient, chunk.url)
-		if !ok || !meta.Valid() {
-			continue
+	if u, err := url.Parse(result.URL); err == nil && !strings.EqualFold(u.Host, chunk.url.Host) {
+		// page redirected to another host, i.e. to SSO login page
+		if name, ok := h.applyFetchers(ctx, u, result); ok {
+			parser = "fetcher." + name
+			goto hasMatch
 		}
-		result.Title = meta.Title
-		result.Type = meta.Type
-		result.Description = meta.Description
-		result.Image = meta.Image
-		result.ImageWidth = meta.ImageWidth
-		result.ImageHeight = meta.ImageHeight
+	}
+	if name, ok := h.applyFetchers(ctx, chunk.url, result); ok {
+		parser = "fetcher." + name
 		goto hasMatch
 	}
 
 	if res := openGraphParseHTML(chunk); res != nil {
-		if !blocklisted(h.titleBlocklist, res.Title) {
+		if !h.contentBlocklisted(chunk.url.Host, res.Title, res.Description) {
 			result.Merge(res)
+			parser = "opengraph"
 			goto hasMatch
 		}
 	}
-	if endpoint, found := chunk.oembedEndpoint(h.oembedLookupFunc); found {
-		if res, err := fetchOembed(ctx, endpoint, h.httpGet); err == nil {
+	if endpoint, found := chunk.oembedEndpoint(h.oembedLookup); found {
+		res, err := fetchOembed(ctx, endpoint, h.httpGet)
+		if err == nil {
 			result.Merge(res)
+			parser = "oembed"
 			goto hasMatch
 		}
+		h.reportError(ctx, link, CategoryOembed, err)
 	}
 	if res := basicParseHTML(chunk); res != nil {
-		if !blocklisted(h.titleBlocklist, res.Title) {
+		if !h.contentBlocklisted(chunk.url.Host, res.Title, res.Description) {
 			result.Merge(res)
+			parser = "html"
 		}
 	}
 
 hasMatch:
+	if pendingOembed != nil {
+		parser = mergePendingOembed(result, parser, pendingOembed)
+	}
+	h.statsd.count("parser." + parser)
+	result.parser = parser
+	if chunk != nil && strings.HasPrefix(http.DetectContentType(chunk.data), "text/html") {
+		h.scrape(chunk, result)
+	}
+	var robots robotsDirectives
+	if h.robots && chunk != nil {
+		robots = parseRobots(chunk.robots...)
+		robots.parse(extractRawMeta(chunk)["robots"])
+		robots.apply(result) // before image is fetched
+	}
 	switch absURL, err := absoluteImageURL(result.URL, result.Image); err {
 	case errEmptyImageURL:
 	case nil:
@@ -395,22 +1234,130 @@ hasMatch:
 		default:
 			result.Image = ""
 		}
-		if result.Image != "" && h.FetchImageSize && (result.ImageWidth == 0 || result.ImageHeight == 0) {
-			if width, height, err := imageDimensions(ctx, h.HTTPClient, result.Image); err != nil {
-				h.Log.Printf("dimensions detect for image %q: %v", result.Image, err)
+		if result.Image != "" && chunk != nil && result.Image == chunk.url.String() && chunk.size > 0 {
+			result.ImageSize = chunk.size
+		}
+		if result.Image != "" && h.FetchImageSize && chunk != nil && result.Image == chunk.url.String() {
+			// url points directly to an image, its first chunk is
+			// already at hand
+			if img, err := decodeImageChunk(chunk); err == nil {
+				b := img.Bounds()
+				result.ImageWidth, result.ImageHeight = b.Dx(), b.Dy()
+				result.DominantColor, result.BlurHash = dominantColor(img), blurHash(img)
+			} else if cfg, _, err := decodeImageConfig(bytes.NewReader(chunk.data)); err == nil {
+				result.ImageWidth, result.ImageHeight = cfg.Width, cfg.Height
+			}
+		}
+		if result.Image != "" && h.FetchImageSize && (result.ImageWidth == 0 || result.ImageHeight == 0 || result.BlurHash == "") {
+			if info, err := fetchImageInfo(ctx, h.HTTPClient, result.Image); err != nil {
+				h.logf(ctx, "dimensions detect for image %q: %v", result.Image, err)
+				h.reportError(ctx, link, CategoryImage, err)
+			} else {
+				if result.ImageWidth == 0 || result.ImageHeight == 0 {
+					result.ImageWidth, result.ImageHeight = info.width, info.height
+				}
+				if info.img != nil {
+					result.DominantColor, result.BlurHash = dominantColor(info.img), blurHash(info.img)
+				}
+				if result.ImageSize == 0 {
+					result.ImageSize = info.size
+				}
+			}
+		}
+		if result.Image != "" && h.maxImageSize > 0 && result.ImageSize == 0 {
+			if size, err := imageSize(ctx, h.HTTPClient, result.Image); err != nil {
+				h.logf(ctx, "size detect for image %q: %v", result.Image, err)
 			} else {
-				result.ImageWidth, result.ImageHeight = width, height
+				result.ImageSize = size
 			}
 		}
+		if h.maxImageSize > 0 && result.ImageSize > h.maxImageSize {
+			h.logf(ctx, "image %q is too large: %d bytes", result.Image, result.ImageSize)
+			result.Image, result.ImageWidth, result.ImageHeight = "", 0, 0
+			result.ImageSize, result.DominantColor, result.BlurHash = 0, "", ""
+		}
 	default:
-		h.Log.Printf("cannot get absolute image url for %q: %v", result.Image, err)
+		h.logf(ctx, "cannot get absolute image url for %q: %v", result.Image, err)
+		h.reportError(ctx, link, CategoryParse, err)
 		result.Image, result.ImageWidth, result.ImageHeight = "", 0, 0
 	}
+	if h.screenshots != nil && result.Image == "" && chunk != nil &&
+		strings.HasPrefix(http.DetectContentType(chunk.data), "text/html") {
+		result.Image = h.screenshots.imageURL(chunk.url.String())
+	}
+	if chunk != nil && strings.HasPrefix(http.DetectContentType(chunk.data), "text/html") {
+		result.Paywalled = detectPaywall(chunk)
+		result.RawMeta = extractRawMeta(chunk)
+		if result.ThemeColor == "" {
+			result.ThemeColor = themeColor(result.RawMeta)
+		}
+		if result.WordCount == 0 {
+			result.WordCount, result.ReadingTime = readingTime(chunk, result.Type == "article")
+		}
+		ld := jsonLD(chunk)
+		if parser == "opengraph" || parser == "html" {
+			if s, ok := h.pageDescription(chunk.url.Host, result.RawMeta, ld); ok {
+				result.Description = s
+			}
+		}
+		if result.Price == "" && result.Availability == "" {
+			result.Price, result.Currency, result.Availability = productOffer(ld, result.RawMeta)
+		}
+		if result.Duration == 0 && isVideoType(result.Type) {
+			result.Duration = videoDuration(result.RawMeta, ld)
+		}
+		if result.EventStart == "" && result.EventLocation == "" {
+			if ev, ok := findEvent(ld); ok {
+				result.Type, result.EventStart, result.EventLocation = "event", ev.start, ev.location
+				if result.Title == "" {
+					result.Title = ev.name
+				}
+			}
+		}
+	}
+	if h.robots {
+		robots.apply(result) // also to description and raw meta found since
+	}
+	if result.HTML == "" {
+		if u, err := url.Parse(result.URL); err == nil {
+			if s := embedHTML(u); s != "" {
+				result.HTML = s
+				if result.Type == "" || result.Type == "website" {
+					result.Type = "video"
+				}
+			}
+		}
+	}
+	if result.SiteName == "" {
+		if chunk != nil {
+			result.SiteName = siteNameFromURL(chunk.url)
+		} else if u, err := url.Parse(result.URL); err == nil {
+			result.SiteName = siteNameFromURL(u)
+		}
+	}
 
-	if mc := h.Cache; mc != nil && !result.Empty() {
+	if (h.cache != nil || h.cold != nil) && !result.Empty() {
+		ttl := h.cacheTTL
+		if !strings.HasPrefix(parser, "fetcher.") {
+			ttl = h.resultTTL(chunk)
+		}
+		if h.cache != nil && ttl > 0 {
+			result.ExpiresAt = expiresAt(ttl).UTC().Format(time.RFC3339)
+		}
 		if cdata, err := json.Marshal(result); err == nil {
-			h.Log.Printf("Cache update for %q", link)
-			mc.Set(&memcache.Item{Key: mcKey(link), Value: snappy.Encode(nil, cdata)})
+			h.logf(ctx, "Cache update for %q", link)
+			key, data := h.cacheKey(ctx, link), snappy.Encode(nil, cdata)
+			if h.cache != nil {
+				if err := h.cache.Set(ctx, key, data, ttl); err != nil {
+					h.logf(ctx, "cache update: %v", err)
+				}
+				if !strings.HasPrefix(parser, "fetcher.") {
+					h.storeValidators(ctx, key, chunk, data, ttl)
+				}
+			}
+			if h.cold != nil {
+				h.storeCold(ctx, key, data)
+			}
 		}
 	}
 	return result
@@ -421,6 +1368,24 @@ type pageChunk struct {
 	data []byte   // first chunk of resource data
 	url  *url.URL // final url resource was fetched from (after all redirects)
 	ct   string   // Content-Type as reported by server
+	size int64    // Content-Length as reported by server, -1 if unknown
+
+	etag, lastModified string // validators as reported by server
+
+	ttl    time.Duration // how long response may be cached for
+	hasTTL bool          // whether server specified ttl
+
+	robots []string // X-Robots-Tag header values
+}
+
+// mediaType returns media type of the resource without parameters, as
+// reported by server or detected from data
+func (p *pageChunk) mediaType() string {
+	if mt, _, err := mime.ParseMediaType(p.ct); err == nil {
+		return mt
+	}
+	mt, _, _ := strings.Cut(http.DetectContentType(p.data), ";")
+	return mt
 }
 
 func (p *pageChunk) oembedEndpoint(fn oembed.LookupFunc) (url string, found bool) {
@@ -449,27 +1414,50 @@ func (h *unfurlHandler) httpGet(ctx context.Context, URL string) (*http.Response
 	if err != nil {
 		return nil, err
 	}
-	for i := 0; i < len(h.Headers); i += 2 {
-		req.Header.Set(h.Headers[i], h.Headers[i+1])
-	}
 	req = req.WithContext(ctx)
+	h.setHeaders(req)
+	setConditional(ctx, req)
 	return client.Do(req)
 }
 
+// applyFetchers calls fetchers registered for u host until one of them
+// returns valid metadata, which is applied to result. It returns name of that
+// fetcher.
+func (h *unfurlHandler) applyFetchers(ctx context.Context, u *url.URL, result *Result) (string, bool) {
+	for _, f := range h.fetchers.lookup(u.Host) {
+		meta, ok := f.Fetch(ctx, h.HTTPClient, u)
+		if !ok || !meta.Valid() {
+			continue
+		}
+		meta.apply(result)
+		return f.Name(), true
+	}
+	return "", false
+}
+
 // fetchData fetches the first chunk of the resource. The chunk size is
 // determined by h.MaxBodyChunkSize.
 func (h *unfurlHandler) fetchData(ctx context.Context, URL string) (*pageChunk, error) {
+	defer func(start time.Time) { h.statsd.timing("fetch.time", time.Since(start)) }(time.Now())
 	resp, err := h.httpGet(ctx, URL)
 	if err != nil {
+		h.statsd.count("fetch.error")
 		return nil, err
 	}
 	defer resp.Body.Close()
+	h.statsd.count("fetch.status." + strconv.Itoa(resp.StatusCode))
+	if resp.StatusCode == http.StatusNotModified {
+		return nil, errNotModified
+	}
 
 	if resp.StatusCode >= http.StatusBadRequest {
+		if isBotWall(resp.StatusCode, resp.Header, resp.Request.URL, errorBodyPeek(resp)) {
+			return &pageChunk{url: resp.Request.URL}, ErrBotProtection
+		}
 		// returning pageChunk with the final url (after all redirects) so that
 		// special cases like youtube returning 429 can be handled by
 		// specialized fetchers like youtubeFetcher
-		return &pageChunk{url: resp.Request.URL}, errors.New("bad status: " + resp.Status)
+		return &pageChunk{url: resp.Request.URL}, &statusError{code: resp.StatusCode, status: resp.Status}
 	}
 	if resp.Header.Get("Content-Encoding") == "deflate" &&
 		(strings.HasSuffix(resp.Request.Host, "twitter.com") ||
@@ -482,14 +1470,27 @@ func (h *unfurlHandler) fetchData(ctx context.Context, URL string) (*pageChunk,
 			return nil, err
 		}
 	}
+	ttl, hasTTL := responseTTL(resp.Header, time.Now())
 	head, err := io.ReadAll(io.LimitReader(resp.Body, h.MaxBodyChunkSize))
 	if err != nil {
 		return nil, err
 	}
+	if isBotWall(resp.StatusCode, resp.Header, resp.Request.URL, head) {
+		return &pageChunk{url: resp.Request.URL}, ErrBotProtection
+	}
 	return &pageChunk{
 		data: head,
 		url:  resp.Request.URL,
 		ct:   resp.Header.Get("Content-Type"),
+		size: resp.ContentLength,
+
+		etag:         resp.Header.Get("ETag"),
+		lastModified: resp.Header.Get("Last-Modified"),
+
+		ttl:    ttl,
+		hasTTL: hasTTL,
+
+		robots: resp.Header.Values("X-Robots-Tag"),
 	}, nil
 }
 
@@ -515,12 +1516,10 @@ probeDefaultIcon:
 	if err != nil {
 		return "", err
 	}
-	for i := 0; i < len(h.Headers); i += 2 {
-		req.Header.Set(h.Headers[i], h.Headers[i+1])
-	}
 	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
 	defer cancel()
 	req = req.WithContext(ctx)
+	h.setHeaders(req)
 	r, err := client.Do(req)
 	if err != nil {
 		return "", err
@@ -538,18 +1537,7 @@ func mcKey(s string) string {
 	return fmt.Sprintf("%x", sha1.Sum([]byte(s)))
 }
 
-func blocklisted(blocklilst []string, title string) bool {
-	if title == "" || len(blocklilst) == 0 {
-		return false
-	}
-	lt := strings.ToLower(title)
-	for _, s := range blocklilst {
-		if strings.Contains(lt, s) {
-			return true
-		}
-	}
-	return false
-}
+var errBlocklisted = errors.New("url is blocklisted")
 
 //go:embed data/providers.json
 var providersData []byte
@@ -1,14 +1,20 @@
 package unfurlist
 
 import (
+	"context"
 	_ "embed"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"log"
 	"net"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"reflect"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -75,7 +81,7 @@ func TestUnfurlist__multibyteHTML(t *testing.T) {
 	}
 }
 
-func doRequest(url string, t *testing.T) []unfurlResult {
+func doRequest(url string, t *testing.T) []Result {
 	pp := newPipePool()
 	defer pp.Close()
 	go http.Serve(pp, http.HandlerFunc(replayHandler))
@@ -95,7 +101,7 @@ func doRequest(url string, t *testing.T) []unfurlResult {
 		return nil
 	}
 
-	var result []unfurlResult
+	var result []Result
 	err := json.Unmarshal(w.Body.Bytes(), &result)
 	if err != nil {
 		t.Fatalf("Result isn't JSON %v", w.Body.String())
@@ -241,3 +247,787 @@ func init() {
 		panic(err)
 	}
 }
+
+func TestHooks(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/favicon.ico" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><head><title>Page title</title></head></html>`))
+	}))
+	defer srv.Close()
+	preFetch := func(_ context.Context, link string) (bool, *Result) {
+		switch {
+		case strings.HasSuffix(link, "/skip"):
+			return true, nil
+		case strings.HasSuffix(link, "/override"):
+			return false, &Result{URL: link, Title: "Overridden"}
+		}
+		return false, nil
+	}
+	postProcess := func(_ context.Context, _ string, r *Result) *Result {
+		r.Title = strings.ToUpper(r.Title)
+		return r
+	}
+	handler := New(WithPreFetchHook(preFetch), WithResultHook(postProcess))
+	content := srv.URL + "/page " + srv.URL + "/skip " + srv.URL + "/override"
+	req := httptest.NewRequest(http.MethodGet, "/?content="+url.QueryEscape(content), nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	var res []Result
+	if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+	want := []Result{
+		{URL: srv.URL + "/page", Title: "PAGE TITLE", Type: "website"},
+		{URL: srv.URL + "/skip"},
+		{URL: srv.URL + "/override", Title: "OVERRIDDEN"},
+	}
+	if len(res) != len(want) {
+		t.Fatalf("invalid result length: %v", res)
+	}
+	for i := range want {
+		if !reflect.DeepEqual(res[i], want[i]) {
+			t.Errorf("result %d:\ngot:  %+v\nwant: %+v", i, res[i], want[i])
+		}
+	}
+}
+
+func TestImageRewriter(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/favicon.ico" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><head><title>Page</title>
+<meta property="og:title" content="Page"><meta property="og:image" content="/img.png">
+<link rel="icon" href="/icon.png"></head></html>`))
+	}))
+	defer srv.Close()
+	rewrite := func(s string) string { return "https://cdn.example.com/?u=" + url.QueryEscape(s) }
+	handler := New(WithImageRewriter(rewrite))
+	for i := 0; i < 2; i++ { // second time from cache
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/?content="+url.QueryEscape(srv.URL+"/page"), nil))
+		var res []Result
+		if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+			t.Fatal(err)
+		}
+		if len(res) != 1 || res[0].Image != rewrite(srv.URL+"/img.png") || res[0].Favicon != rewrite(srv.URL+"/icon.png") {
+			t.Fatalf("unexpected result: %+v", res)
+		}
+	}
+}
+
+func TestImageRewriterInsecureOnly(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/favicon.ico" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><head><title>Page</title>
+<meta property="og:title" content="Page"><meta property="og:image" content="/img.png">
+<link rel="icon" href="https://example.com/icon.png"></head></html>`))
+	}))
+	defer srv.Close()
+	rewrite := func(s string) string { return "https://cdn.example.com/?u=" + url.QueryEscape(s) }
+	for _, tc := range []struct {
+		rewrite func(string) string
+		favicon string
+	}{
+		{InsecureOnly(rewrite), "https://example.com/icon.png"},
+		{rewrite, rewrite("https://example.com/icon.png")},
+	} {
+		w := httptest.NewRecorder()
+		New(WithImageRewriter(tc.rewrite)).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/?content="+url.QueryEscape(srv.URL+"/page"), nil))
+		var res []Result
+		if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+			t.Fatal(err)
+		}
+		if len(res) != 1 || res[0].Image != rewrite(srv.URL+"/img.png") || res[0].Favicon != tc.favicon {
+			t.Fatalf("unexpected result: %+v", res)
+		}
+	}
+}
+
+func TestCookies(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/favicon.ico" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprintf(w, "<html><head><title>%s</title></head></html>", r.Header.Get("Cookie"))
+	}))
+	defer srv.Close()
+	h := New(WithCookies(map[string]string{
+		"127.0.0.1":   "consent=yes",
+		"example.com": "session=x",
+	})).(*unfurlHandler)
+	if res := h.processURL(context.Background(), srv.URL); res.Title != "consent=yes" {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+	if got := h.domainCookies("wiki.example.com"); got != "session=x" {
+		t.Fatalf("subdomain cookies: got %q", got)
+	}
+	if got := h.domainCookies("example.org"); got != "" {
+		t.Fatalf("unexpected cookies: %q", got)
+	}
+}
+
+func TestExpiresAt(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/favicon.ico" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><head><title>Page</title></head></html>`))
+	}))
+	defer srv.Close()
+	h := New(WithCache(new(mapCache)), WithCacheTTL(time.Hour)).(*unfurlHandler)
+	res := h.processURL(context.Background(), srv.URL)
+	exp, err := time.Parse(time.RFC3339, res.ExpiresAt)
+	if err != nil {
+		t.Fatalf("invalid expires_at: %+v", res)
+	}
+	if d := time.Until(exp); d < 59*time.Minute || d > time.Hour {
+		t.Fatalf("result expires in %v", d)
+	}
+	if cached := h.processURL(context.Background(), srv.URL); cached.ExpiresAt != res.ExpiresAt {
+		t.Fatalf("cached result expires at %q, want %q", cached.ExpiresAt, res.ExpiresAt)
+	}
+	h = New(WithCache(new(mapCache))).(*unfurlHandler)
+	if res := h.processURL(context.Background(), srv.URL); res.ExpiresAt != "" {
+		t.Fatalf("result cached without ttl expires at %q", res.ExpiresAt)
+	}
+}
+
+func TestResultMemo(t *testing.T) {
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/favicon.ico" {
+			http.NotFound(w, r)
+			return
+		}
+		hits.Add(1)
+		w.Header().Set("Content-Type", "text/html")
+		if r.URL.Path == "/blank" {
+			w.Write([]byte(`<html><head></head></html>`))
+			return
+		}
+		w.Write([]byte(`<html><head><title>Page</title></head></html>`))
+	}))
+	defer srv.Close()
+	h := New(WithResultMemo(50*time.Millisecond, 1<<20)).(*unfurlHandler)
+	for _, want := range []int32{1, 2} {
+		h.processURLidx(context.Background(), 0, srv.URL+"/blank")
+		if n := hits.Load(); n != want {
+			t.Fatalf("got %d upstream requests for result without metadata, want %d", n, want)
+		}
+	}
+	hits.Store(0)
+	for _, want := range []int32{1, 1} {
+		if res := h.processURLidx(context.Background(), 0, srv.URL); res.Title != "Page" {
+			t.Fatalf("unexpected result: %+v", res)
+		}
+		if n := hits.Load(); n != want {
+			t.Fatalf("got %d upstream requests, want %d", n, want)
+		}
+	}
+	time.Sleep(60 * time.Millisecond)
+	h.processURLidx(context.Background(), 0, srv.URL)
+	if n := hits.Load(); n != 2 {
+		t.Fatalf("got %d upstream requests after memo expired, want 2", n)
+	}
+}
+
+func TestForwardedHeaders(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/favicon.ico" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprintf(w, "<html><head><title>%s</title></head></html>", r.Header.Get("Accept-Language"))
+	}))
+	defer srv.Close()
+	handler := New(
+		WithExtraHeaders(map[string]string{"Accept-Language": "en"}),
+		WithForwardedHeaders("accept-language"),
+	)
+	for _, lang := range []string{"de", "fr", ""} {
+		req := httptest.NewRequest(http.MethodGet, "/?content="+url.QueryEscape(srv.URL), nil)
+		if lang != "" {
+			req.Header.Set("Accept-Language", lang)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		if got := w.Header().Get("Vary"); got != "Accept-Language" {
+			t.Errorf("unexpected Vary header: %q", got)
+		}
+		var res []Result
+		if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+			t.Fatal(err)
+		}
+		want := lang
+		if want == "" {
+			want = "en"
+		}
+		if len(res) != 1 || res[0].Title != want {
+			t.Errorf("got %+v, want title %q", res, want)
+		}
+	}
+}
+
+func TestETag(t *testing.T) {
+	handler := New(WithCacheControl("private, max-age=60"))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/?content=no+urls", nil))
+	etag := w.Header().Get("ETag")
+	if w.Code != http.StatusOK || etag == "" {
+		t.Fatalf("unexpected response: %d, ETag: %q", w.Code, etag)
+	}
+	if got := w.Header().Get("Cache-Control"); got != "private, max-age=60" {
+		t.Fatalf("unexpected Cache-Control: %q", got)
+	}
+	req := httptest.NewRequest(http.MethodGet, "/?content=no+urls", nil)
+	req.Header.Set("If-None-Match", `"foo", W/`+etag)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+		t.Fatalf("want empty 304 response, got %d with body %q", w.Code, w.Body)
+	}
+}
+
+func TestJSONPCallback(t *testing.T) {
+	for i, tc := range []struct {
+		callback string
+		enabled  bool
+		code     int
+	}{
+		{"cb", true, http.StatusOK},
+		{"jQuery.cb_123$", true, http.StatusOK},
+		{"alert(1);cb", true, http.StatusBadRequest},
+		{"cb.", true, http.StatusBadRequest},
+		{"1cb", true, http.StatusBadRequest},
+		{"cb", false, http.StatusBadRequest},
+	} {
+		handler := New(WithJSONP(tc.enabled))
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/?content=no+urls&callback="+url.QueryEscape(tc.callback), nil)
+		handler.ServeHTTP(w, req)
+		if w.Code != tc.code {
+			t.Errorf("case %d: got status %d, want %d", i, w.Code, tc.code)
+		}
+	}
+}
+
+func TestVersionedAPI(t *testing.T) {
+	pp := newPipePool()
+	defer pp.Close()
+	go http.Serve(pp, http.HandlerFunc(replayHandler))
+	handler := New(WithHTTPClient(&http.Client{
+		Transport: &http.Transport{
+			Dial:    pp.Dial,
+			DialTLS: pp.Dial,
+		}}))
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/v1/unfurl?content=https://news.ycombinator.com/+https://example.com/missing", nil)
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("invalid status code: %v", w.Code)
+	}
+	var env Envelope
+	if err := json.Unmarshal(w.Body.Bytes(), &env); err != nil {
+		t.Fatal(err)
+	}
+	if len(env.Results) != 2 || env.Results[0].Title != "Hacker News" {
+		t.Fatalf("unexpected results: %+v", env.Results)
+	}
+	if len(env.Errors) != 1 || env.Errors[0].URL != "https://example.com/missing" {
+		t.Fatalf("unexpected errors: %+v", env.Errors)
+	}
+}
+
+func TestMaxConcurrentFetches(t *testing.T) {
+	var mu sync.Mutex
+	var cur, peak int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		cur++
+		peak = max(peak, cur)
+		mu.Unlock()
+		defer func() {
+			mu.Lock()
+			cur--
+			mu.Unlock()
+		}()
+		time.Sleep(20 * time.Millisecond)
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><head><title>Page</title></head></html>`))
+	}))
+	defer srv.Close()
+	handler := New(WithMaxConcurrentFetches(2))
+	var content []string
+	for i := 0; i < 6; i++ {
+		content = append(content, fmt.Sprintf("%s/page%d", srv.URL, i))
+	}
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/?content="+url.QueryEscape(strings.Join(content, " ")), nil)
+	handler.ServeHTTP(w, req)
+	var res []Result
+	if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != len(content) {
+		t.Fatalf("invalid result length: %v", res)
+	}
+	if peak > 2 {
+		t.Fatalf("%d concurrent upstream requests, want at most 2", peak)
+	}
+}
+
+func TestMaxOutboundRequests(t *testing.T) {
+	var mu sync.Mutex
+	var cur, peak int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		cur++
+		peak = max(peak, cur)
+		mu.Unlock()
+		defer func() {
+			mu.Lock()
+			cur--
+			mu.Unlock()
+		}()
+		time.Sleep(20 * time.Millisecond)
+		if r.URL.Path == "/favicon.ico" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><head><title>Page</title></head></html>`))
+	}))
+	defer srv.Close()
+	handler := New(WithMaxOutboundRequests(3))
+	var content []string
+	for i := 0; i < 6; i++ {
+		content = append(content, fmt.Sprintf("%s/page%d", srv.URL, i))
+	}
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/?content="+url.QueryEscape(strings.Join(content, " ")), nil)
+	handler.ServeHTTP(w, req)
+	var res []Result
+	if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != len(content) || res[0].Title != "Page" {
+		t.Fatalf("unexpected result: %v", res)
+	}
+	if peak > 3 {
+		t.Fatalf("%d concurrent upstream requests, want at most 3", peak)
+	}
+	if st := handler.(StatusReporter).Status(); st.OutboundRequests != 0 || st.OutboundQueued != 0 {
+		t.Fatalf("requests still hold slots: %+v", st)
+	}
+}
+
+func TestRequestTimeout(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(time.Second):
+		case <-r.Context().Done():
+		}
+	}))
+	defer srv.Close()
+	handler := New(WithMaxTimeout(time.Minute))
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/?timeout=50ms&content="+url.QueryEscape(srv.URL), nil)
+	begin := time.Now()
+	handler.ServeHTTP(w, req)
+	if d := time.Since(begin); d > 500*time.Millisecond {
+		t.Fatalf("request took %v", d)
+	}
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected status: %d", w.Code)
+	}
+	var res []Result
+	if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+	if want := (Result{URL: srv.URL, Status: StatusTimeout}); len(res) != 1 || !reflect.DeepEqual(res[0], want) {
+		t.Fatalf("got %+v, want single result %+v", res, want)
+	}
+
+	h := handler.(*unfurlHandler)
+	for requested, want := range map[time.Duration]time.Duration{
+		0:                time.Minute,
+		time.Second:      time.Second,
+		time.Hour:        time.Minute,
+		-1 * time.Second: time.Minute,
+	} {
+		if got := h.requestTimeout(requested); got != want {
+			t.Errorf("requested %v: got %v, want %v", requested, got, want)
+		}
+	}
+}
+
+func TestPartialResults(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			select {
+			case <-time.After(time.Second):
+			case <-r.Context().Done():
+			}
+			return
+		}
+		if r.URL.Path == "/favicon.ico" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><head><title>Fast</title></head></html>`))
+	}))
+	defer srv.Close()
+	w := httptest.NewRecorder()
+	content := srv.URL + "/slow " + srv.URL + "/fast"
+	req := httptest.NewRequest(http.MethodGet, "/?timeout=300ms&content="+url.QueryEscape(content), nil)
+	New().ServeHTTP(w, req)
+	var res []Result
+	if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+	want := []Result{
+		{URL: srv.URL + "/slow", Status: StatusTimeout},
+		{URL: srv.URL + "/fast", Title: "Fast", Type: "website"},
+	}
+	if !reflect.DeepEqual(res, want) {
+		t.Fatalf("got:\n%+v\nwant:\n%+v", res, want)
+	}
+}
+
+func TestReloader(t *testing.T) {
+	h := New(WithBlocklistPrefixes([]string{"https://a.example.com/"}))
+	r, ok := h.(Reloader)
+	if !ok {
+		t.Fatal("handler does not implement Reloader")
+	}
+	uh := h.(*unfurlHandler)
+	if !uh.pmap.Load().Match("https://a.example.com/page") {
+		t.Fatal("url should be blocklisted")
+	}
+	r.SetBlocklistPrefixes([]string{"https://b.example.com/"})
+	if uh.pmap.Load().Match("https://a.example.com/page") || !uh.pmap.Load().Match("https://b.example.com/page") {
+		t.Fatal("blocklist was not replaced")
+	}
+	r.SetBlocklistTitles([]string{"Robot Check"})
+	if !uh.contentBlocklisted("www.amazon.com", "amazon robot check", "") {
+		t.Fatal("title should be blocklisted")
+	}
+}
+
+func TestTitleBlocklistRules(t *testing.T) {
+	h := New(WithBlocklistTitles([]string{
+		"Access denied",
+		"/^page (not )?found$/",
+		"@*.amazon.* robot check",
+		"/[/", // invalid, ignored
+	})).(*unfurlHandler)
+	for _, tc := range []struct {
+		host, title, description string
+		want                     bool
+	}{
+		{"example.com", "ACCESS DENIED by firewall", "", true},
+		{"example.com", "", "Sorry, access denied", true},
+		{"example.com", "Page Not Found", "", true},
+		{"example.com", "Lost page found", "", false},
+		{"www.amazon.co.uk", "Robot Check", "", true},
+		{"www.amazon.co.uk:443", "Robot Check", "", true},
+		{"example.com", "Robot check for beginners", "", false},
+	} {
+		if got := h.contentBlocklisted(tc.host, tc.title, tc.description); got != tc.want {
+			t.Errorf("contentBlocklisted(%q, %q, %q) = %v, want %v", tc.host, tc.title, tc.description, got, tc.want)
+		}
+	}
+	if err := CheckBlocklistTitles([]string{"ok", "@example.com /(/"}); err == nil {
+		t.Fatal("invalid rule was not reported")
+	}
+}
+
+func TestUnfurler(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/page" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><head><title>Page</title></head></html>`))
+	}))
+	defer srv.Close()
+	u, ok := New().(Unfurler)
+	if !ok {
+		t.Fatal("handler does not implement Unfurler")
+	}
+	env := u.Unfurl(context.Background(), "[page]("+srv.URL+"/page) "+srv.URL+"/missing", true)
+	if len(env.Results) != 2 || env.Results[0].Title != "Page" || env.Results[1].URL != srv.URL+"/missing" {
+		t.Fatalf("unexpected results: %+v", env.Results)
+	}
+	if len(env.Errors) != 1 || env.Errors[0] != (ResultError{URL: srv.URL + "/missing", Error: "status", StatusCode: 404}) {
+		t.Fatalf("unexpected errors: %+v", env.Errors)
+	}
+}
+
+func TestResultErrorDetails(t *testing.T) {
+	dialErr := &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connect: connection refused"),
+		Addr: &net.TCPAddr{IP: net.IPv4(10, 0, 0, 5), Port: 80}}
+	for _, tc := range []struct {
+		err  error
+		want ResultError
+	}{
+		{fmt.Errorf("get: %w", dialErr), ResultError{URL: "u", Error: "fetch"}},
+		{&statusError{code: 403, status: "403 Forbidden"}, ResultError{URL: "u", Error: "status", StatusCode: 403}},
+		{errTimeout, ResultError{URL: "u", Error: "timeout"}},
+		{context.DeadlineExceeded, ResultError{URL: "u", Error: "timeout"}},
+		{errBlocklisted, ResultError{URL: "u", Error: "blocked"}},
+		{errDangerousURL, ResultError{URL: "u", Error: "blocked"}},
+		{ErrBotProtection, ResultError{URL: "u", Error: "bot_protection"}},
+	} {
+		if got := newResultError("u", tc.err); got != tc.want {
+			t.Errorf("%v: got %+v, want %+v", tc.err, got, tc.want)
+		}
+	}
+}
+
+func TestErrorReporter(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "gone", http.StatusGone)
+	}))
+	defer srv.Close()
+	var mu sync.Mutex
+	reported := make(map[string]error)
+	reporter := func(_ context.Context, link string, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		reported[link] = err
+	}
+	handler := New(WithErrorReporter(reporter))
+	link := srv.URL + "/page"
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/?content="+url.QueryEscape(link), nil))
+	mu.Lock()
+	defer mu.Unlock()
+	var perr *ProcessingError
+	if !errors.As(reported[link], &perr) {
+		t.Fatalf("no processing error reported for %q: %v", link, reported)
+	}
+	if perr.Category != CategoryStatus {
+		t.Fatalf("got error category %q, want %q", perr.Category, CategoryStatus)
+	}
+}
+
+func TestRequestID(t *testing.T) {
+	upstreamIDs := make(chan string, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upstreamIDs <- r.Header.Get(RequestIDHeader)
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><head><title>Page</title></head></html>`))
+	}))
+	defer srv.Close()
+	logs := new(strings.Builder)
+	handler := New(WithRequestIDForwarding(true), WithLogger(log.New(logs, "", 0)),
+		WithBlocklistPrefixes([]string{"https://blocked.example.com/"}))
+
+	content := url.QueryEscape(srv.URL + "/page https://blocked.example.com/")
+	req := httptest.NewRequest(http.MethodGet, "/?content="+content, nil)
+	req.Header.Set(RequestIDHeader, "abc-123")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if got := w.Header().Get(RequestIDHeader); got != "abc-123" {
+		t.Fatalf("got response request id %q, want %q", got, "abc-123")
+	}
+	if got := <-upstreamIDs; got != "abc-123" {
+		t.Fatalf("got upstream request id %q, want %q", got, "abc-123")
+	}
+	if !strings.Contains(logs.String(), "[abc-123] Blocklisted") {
+		t.Fatalf("log lines have no request id: %q", logs.String())
+	}
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/?content="+content, nil))
+	if w.Header().Get(RequestIDHeader) == "" {
+		t.Fatal("request id was not generated")
+	}
+}
+
+func TestResultNormalize(t *testing.T) {
+	r := &Result{
+		Title:       "  Tom &amp;amp; Jerry\n\t&#8217;s  show ",
+		Description: "Line one\r\nline\x00 two &lt;b&gt;",
+		SiteName:    "Cartoons&nbsp;&amp; Co",
+	}
+	r.normalize()
+	want := Result{
+		Title:       "Tom &amp; Jerry ’s show",
+		Description: "Line one line two <b>",
+		SiteName:    "Cartoons & Co",
+	}
+	if !reflect.DeepEqual(*r, want) {
+		t.Fatalf("got %+v, want %+v", *r, want)
+	}
+}
+
+func TestMarkdownLinkTitle(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		switch r.URL.Path {
+		case "/titled":
+			w.Write([]byte(`<html><head><title>Page</title></head></html>`))
+		case "/untitled":
+			w.Write([]byte(`<html><body>No title here</body></html>`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	env := New().(Unfurler).Unfurl(context.Background(),
+		"[Quarterly *report*]("+srv.URL+"/untitled), [other text]("+srv.URL+"/titled)", true)
+	if len(env.Results) != 2 {
+		t.Fatalf("unexpected results: %+v", env.Results)
+	}
+	if got := env.Results[0].Title; got != "Quarterly report" {
+		t.Errorf("got title %q for page without title, want link text", got)
+	}
+	if got := env.Results[1].Title; got != "Page" {
+		t.Errorf("got title %q for page with title, want page title", got)
+	}
+}
+
+func TestHTMLContent(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/page" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><head><title>Page</title></head></html>`))
+	}))
+	defer srv.Close()
+	handler := New()
+	content := `<p><a href="` + srv.URL + `/page">page</a></p><pre>` + srv.URL + `/skipped</pre>`
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/?content_type=html&content="+url.QueryEscape(content), nil))
+	var res []Result
+	if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 1 || res[0].URL != srv.URL+"/page" || res[0].Title != "Page" {
+		t.Fatalf("unexpected results: %+v", res)
+	}
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/?content_type=pdf&content="+url.QueryEscape(content), nil))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("got status %d for unsupported content type, want %d", w.Code, http.StatusBadRequest)
+	}
+}
+
+func TestMaxContentSize(t *testing.T) {
+	handler := New(WithMaxContentSize(100))
+	content := "http://example.com/ " + strings.Repeat("x", 100)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/?content="+url.QueryEscape(content), nil))
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("GET: got status %d, want %d", w.Code, http.StatusRequestEntityTooLarge)
+	}
+	form := url.Values{"content": {content}}.Encode()
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(form))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("POST: got status %d, want %d", w.Code, http.StatusRequestEntityTooLarge)
+	}
+}
+
+func TestRequestMaxResults(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	defer srv.Close()
+	handler := New(WithMaxResults(2))
+	content := url.QueryEscape(srv.URL + "/a " + srv.URL + "/b " + srv.URL + "/c")
+	for _, tc := range []struct {
+		max  string
+		want int
+	}{{"", 2}, {"1", 1}, {"50", 2}, {"-1", 2}} {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/?max="+tc.max+"&content="+content, nil))
+		var res []Result
+		if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+			t.Fatalf("max=%s: %v", tc.max, err)
+		}
+		if len(res) != tc.want {
+			t.Errorf("max=%s: got %d results, want %d", tc.max, len(res), tc.want)
+		}
+	}
+}
+
+func TestResultBare(t *testing.T) {
+	for _, tc := range []struct {
+		res  Result
+		want bool
+	}{
+		{Result{URL: "https://example.com/"}, true},
+		{Result{URL: "https://example.com/", SiteName: "Example"}, true},
+		{Result{URL: "https://example.com/", SiteName: "Example", Type: "website", parser: "none"}, true},
+		{Result{URL: "https://example.com/", Type: "video"}, false},
+		{Result{URL: "https://example.com/", ExpiresAt: "2026-01-02T15:04:05Z"}, true},
+		{Result{URL: "https://example.com/", SiteName: "Example", Title: "Page"}, false},
+		{Result{URL: "https://example.com/", Image: "https://example.com/i.png"}, false},
+	} {
+		if got := tc.res.bare(); got != tc.want {
+			t.Errorf("%+v: got bare %v, want %v", tc.res, got, tc.want)
+		}
+	}
+}
+
+func TestSkipEmpty(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/page":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(`<html><head><title>Page</title></head></html>`))
+		case "/blank":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(`<html><head></head><body></body></html>`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	content := url.QueryEscape(srv.URL + "/page " + srv.URL + "/missing " + srv.URL + "/blank")
+	get := func(handler http.Handler, path string, v any) {
+		t.Helper()
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path+"&content="+content, nil))
+		if err := json.Unmarshal(w.Body.Bytes(), v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var res []Result
+	get(New(), "/?skip_empty=1", &res)
+	if len(res) != 1 || res[0].Title != "Page" {
+		t.Fatalf("unexpected results: %+v", res)
+	}
+	var env Envelope
+	get(New(WithSkipEmptyResults(true)), "/v1/unfurl?", &env)
+	if len(env.Results) != 1 || len(env.Errors) != 1 || env.Errors[0].URL != srv.URL+"/missing" {
+		t.Fatalf("unexpected envelope: %+v", env)
+	}
+	res = nil
+	get(New(WithSkipEmptyResults(true)), "/?skip_empty=0", &res)
+	if len(res) != 3 {
+		t.Fatalf("unexpected results: %+v", res)
+	}
+}
@@ -4,9 +4,13 @@ import (
 	"net/url"
 	"regexp"
 	"strings"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/gomarkdown/markdown/ast"
 	"github.com/gomarkdown/markdown/parser"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
 )
 
 // reUrls matches sequence of characters described by RFC 3986 having http:// or
@@ -19,12 +23,48 @@ var reUrls = regexp.MustCompile(`(?i:https?)://[%:/?#\[\]@!$&'\(\){}*+,;=\pL\pN.
 // characters are searched for. This function is optimized for extraction of
 // urls from plain text where it can be mixed with punctuation symbols: trailing
 // symbols []()<>,;. are removed, but // trailing >]) are left if any opening
-// <[( is found inside url.
+// <[( is found inside url. Non-ASCII punctuation, like 。」） or », is never
+// considered a part of url.
 func ParseURLs(content string) []string { return parseURLsMax(content, -1) }
 
+// reUrlsGeo is like reUrls, but also matches geo: URIs (RFC 5870)
+var reUrlsGeo = regexp.MustCompile(reUrls.String() + `|(?i:geo):-?[0-9.]+,-?[0-9.]+(?:,-?[0-9.]+)?(?:;[a-zA-Z0-9=.-]+)*(?:\?z=[0-9]+)?`)
+
+// wwwURL is regular expression matching urls without scheme starting with
+// www. at the start of content or after space or opening bracket or quote; the
+// preceding character is a part of match.
+const wwwURL = `|(?:^|[\s(<\["'])(?i:www)\.[\pL\pN-]+\.[%:/?#\[\]@!$&'\(\){}*+,;=\pL\pN._~-]+`
+
+var (
+	reUrlsWWW    = regexp.MustCompile(reUrls.String() + wwwURL)
+	reUrlsGeoWWW = regexp.MustCompile(reUrlsGeo.String() + wwwURL)
+)
+
+// addScheme prepends https:// to urls found without scheme by regexp built
+// with wwwURL, removing duplicates that may appear as a result
+func addScheme(urls []string) []string {
+	out := urls[:0]
+	seen := make(map[string]struct{}, len(urls))
+	for _, u := range urls {
+		if i := strings.Index(strings.ToLower(u), "www."); i == 0 || i == 1 {
+			u = "https://" + u[i:]
+		}
+		if _, ok := seen[u]; ok {
+			continue
+		}
+		seen[u] = struct{}{}
+		out = append(out, u)
+	}
+	return out
+}
+
 func parseURLsMax(content string, maxItems int) []string {
+	return parseURLsRe(content, reUrls, maxItems)
+}
+
+func parseURLsRe(content string, re *regexp.Regexp, maxItems int) []string {
 	const punct = `[]()<>{},;.*_`
-	res := reUrls.FindAllString(content, maxItems)
+	res := re.FindAllString(content, maxItems)
 	for i, s := range res {
 		// remove all combinations of trailing >)],. characters only if
 		// no similar characters were found somewhere in the middle
@@ -122,9 +162,31 @@ func validURL(s string) bool {
 	return true
 }
 
-func parseMarkdownURLs(content string, maxItems int) []string {
-	doc := parser.New().Parse([]byte(content))
+// parseMarkdownURLs returns unique urls of links and images found in
+// markdown content, including ones in headings, tables, lists and footnotes.
+// It also returns text of explicit links (alt text for images) keyed by url.
+func parseMarkdownURLs(content string, maxItems int) ([]string, map[string]string) {
+	doc := parser.NewWithExtensions(parser.CommonExtensions | parser.Footnotes).Parse([]byte(content))
 	var urls []string
+	seen := make(map[string]struct{})
+	texts := make(map[string]string)
+	add := func(dst []byte, node ast.Node) {
+		s, text := string(dst), linkText(node)
+		if _, ok := node.(*ast.Link); ok && text == s {
+			// autolink, its destination may have trailing
+			// punctuation of surrounding text
+			s = cutUnicodePunct(s)
+			text = ""
+		}
+		if _, ok := seen[s]; ok || !validURL(s) {
+			return
+		}
+		seen[s] = struct{}{}
+		urls = append(urls, s)
+		if text != "" && text != s {
+			texts[s] = text
+		}
+	}
 	walkFn := func(node ast.Node, entering bool) ast.WalkStatus {
 		if maxItems >= 0 && len(urls) == maxItems {
 			return ast.Terminate
@@ -134,14 +196,156 @@ func parseMarkdownURLs(content string, maxItems int) []string {
 		}
 		switch n := node.(type) {
 		case *ast.Link:
-			if s := string(n.Destination); validURL(s) {
-				urls = append(urls, s)
-			}
+			add(n.Destination, n)
+		case *ast.Image:
+			add(n.Destination, n)
 		case *ast.Code, *ast.CodeBlock:
 			return ast.SkipChildren
 		}
 		return ast.GoToNext
 	}
 	_ = ast.Walk(doc, ast.NodeVisitorFunc(walkFn))
-	return urls
+	return urls, texts
+}
+
+// cutUnicodePunct truncates s at the first non-ASCII punctuation character,
+// like 。、」）» or ”, that commonly follows urls in CJK and European text.
+// Markdown parser includes such characters into autolinks, while reUrls
+// doesn't match them in the first place.
+func cutUnicodePunct(s string) string {
+	if i := strings.IndexFunc(s, isUnicodePunct); i >= 0 {
+		return s[:i]
+	}
+	return s
+}
+
+func isUnicodePunct(r rune) bool { return r >= utf8.RuneSelf && unicode.IsPunct(r) }
+
+// linkText returns concatenated text of node children
+func linkText(node ast.Node) string {
+	var b strings.Builder
+	ast.WalkFunc(node, func(n ast.Node, entering bool) ast.WalkStatus {
+		if leaf := n.AsLeaf(); entering && leaf != nil {
+			b.Write(leaf.Literal)
+		}
+		return ast.GoToNext
+	})
+	return strings.TrimSpace(b.String())
+}
+
+// parseHTMLURLs returns unique urls of <a href> links and bare urls in text
+// found in html content, skipping ones inside <code> and <pre> elements. It
+// also returns text of links keyed by their urls.
+func parseHTMLURLs(content string, maxItems int) ([]string, map[string]string) {
+	var urls []string
+	seen := make(map[string]struct{})
+	texts := make(map[string]string)
+	add := func(s string) bool {
+		if _, ok := seen[s]; ok || !validURL(s) || (maxItems >= 0 && len(urls) == maxItems) {
+			return false
+		}
+		seen[s] = struct{}{}
+		urls = append(urls, s)
+		return true
+	}
+	var skip int    // depth of elements to skip urls in
+	var link string // url of the link which text is collected
+	var text strings.Builder
+	endLink := func() {
+		if s := strings.Join(strings.Fields(text.String()), " "); s != "" && s != link {
+			texts[link] = s
+		}
+		link = ""
+	}
+	z := html.NewTokenizer(strings.NewReader(content))
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			if link != "" {
+				endLink()
+			}
+			return urls, texts
+		case html.StartTagToken:
+			name, hasAttr := z.TagName()
+			switch atom.Lookup(name) {
+			case atom.Code, atom.Pre, atom.Script, atom.Style:
+				skip++
+			case atom.A:
+				if skip > 0 || !hasAttr || link != "" {
+					continue
+				}
+				if href := strings.TrimSpace(attrValue(z, "href")); add(href) {
+					link = href
+					text.Reset()
+				}
+			}
+		case html.EndTagToken:
+			name, _ := z.TagName()
+			switch atom.Lookup(name) {
+			case atom.Code, atom.Pre, atom.Script, atom.Style:
+				if skip > 0 {
+					skip--
+				}
+			case atom.A:
+				if link != "" {
+					endLink()
+				}
+			}
+		case html.TextToken:
+			switch {
+			case link != "":
+				text.Write(z.Text())
+			case skip == 0:
+				for _, u := range parseURLsMax(string(z.Text()), -1) {
+					add(u)
+				}
+			}
+		}
+	}
+}
+
+// reSlackTokens matches Slack mrkdwn code blocks, code spans and <url|label>
+// links
+var reSlackTokens = regexp.MustCompile("(?s)```.*?```|`[^`\n]+`|<([^<>|]+)(?:\\|([^<>]*))?>")
+
+// slackUnescape replaces entities Slack escapes in message text
+var slackUnescape = strings.NewReplacer("&amp;", "&", "&lt;", "<", "&gt;", ">")
+
+// parseSlackURLs returns unique urls found in Slack mrkdwn formatted content:
+// ones in <url|label> and <url> links and bare urls, skipping ones in code
+// blocks and code spans. It also returns link labels keyed by their urls.
+func parseSlackURLs(content string, maxItems int) ([]string, map[string]string) {
+	var urls []string
+	seen := make(map[string]struct{})
+	texts := make(map[string]string)
+	add := func(s string) bool {
+		if _, ok := seen[s]; ok || !validURL(s) || (maxItems >= 0 && len(urls) == maxItems) {
+			return false
+		}
+		seen[s] = struct{}{}
+		urls = append(urls, s)
+		return true
+	}
+	addText := func(text string) {
+		for _, u := range parseURLsMax(slackUnescape.Replace(text), -1) {
+			add(u)
+		}
+	}
+	var last int
+	for _, m := range reSlackTokens.FindAllStringSubmatchIndex(content, -1) {
+		addText(content[last:m[0]])
+		last = m[1]
+		if m[2] < 0 { // code
+			continue
+		}
+		link := slackUnescape.Replace(content[m[2]:m[3]])
+		if !add(link) || m[4] < 0 {
+			continue
+		}
+		if label := strings.TrimSpace(slackUnescape.Replace(content[m[4]:m[5]])); label != "" && label != link {
+			texts[link] = label
+		}
+	}
+	addText(content[last:])
+	return urls, texts
 }
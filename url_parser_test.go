@@ -2,6 +2,7 @@ package unfurlist
 
 import (
 	"fmt"
+	"reflect"
 	"testing"
 )
 
@@ -94,6 +95,16 @@ func TestValidURL(t *testing.T) {
 	}
 }
 
+func TestSchemelessURLs(t *testing.T) {
+	text := "www.example.com/foo, visit www.example.com/foo, (WWW.Example.org) or https://www.example.com/foo; " +
+		"not www.localhost, not bob@www.example.net and not foo.www"
+	got := addScheme(parseURLsRe(text, reUrlsWWW, -1))
+	want := []string{"https://www.example.com/foo", "https://WWW.Example.org"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("want: %v, got: %v", want, got)
+	}
+}
+
 func TestParseMarkdownURLs(t *testing.T) {
 	text := `Implicit url: http://example.com/1, [explicit url](http://example.com/2).
 
@@ -103,7 +114,7 @@ This url should be skipped ` + "`http://example.com/3`" + `, as well as the one
 
 Another paragraph with implicit link http://example.com/5.
 	`
-	got := parseMarkdownURLs(text, 10)
+	got, _ := parseMarkdownURLs(text, 10)
 	want := []string{"http://example.com/1", "http://example.com/2", "http://example.com/5"}
 	if len(got) != len(want) {
 		t.Fatalf("want: %v, got: %v", want, got)
@@ -115,6 +126,120 @@ Another paragraph with implicit link http://example.com/5.
 	}
 }
 
+func TestParseMarkdownURLsNodeTypes(t *testing.T) {
+	text := "# Heading with [link](http://example.com/h)\n\n" +
+		"| name | url |\n|------|-----|\n| [cell](http://example.com/t) | http://example.com/t2 |\n\n" +
+		"- [ ] task with [link](http://example.com/task)\n- [x] http://example.com/task2\n\n" +
+		"![image](http://example.com/i.png) and [again](http://example.com/h)\n\n" +
+		"Footnote reference[^1]\n\n[^1]: See [note](http://example.com/fn).\n"
+	got, texts := parseMarkdownURLs(text, -1)
+	want := []string{
+		"http://example.com/h",
+		"http://example.com/t",
+		"http://example.com/t2",
+		"http://example.com/task",
+		"http://example.com/task2",
+		"http://example.com/i.png",
+		"http://example.com/fn",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("want: %v, got: %v", want, got)
+	}
+	wantTexts := map[string]string{
+		"http://example.com/h":     "link",
+		"http://example.com/t":     "cell",
+		"http://example.com/task":  "link",
+		"http://example.com/i.png": "image",
+		"http://example.com/fn":    "note",
+	}
+	if !reflect.DeepEqual(texts, wantTexts) {
+		t.Fatalf("want texts: %v, got: %v", wantTexts, texts)
+	}
+}
+
+func TestParseHTMLURLs(t *testing.T) {
+	text := `<p>See <a href="http://example.com/1">the <b>first</b>
+	page</a> and http://example.com/2.</p>
+<pre>http://example.com/3</pre> <code><a href="http://example.com/4">code</a></code>
+<a href="http://example.com/5">http://example.com/5</a> <a href="/relative">relative</a>
+<a href="http://example.com/1">again</a> <a href="http://example.com/6?a=1&amp;b=2">last</a>`
+	got, texts := parseHTMLURLs(text, -1)
+	want := []string{
+		"http://example.com/1",
+		"http://example.com/2",
+		"http://example.com/5",
+		"http://example.com/6?a=1&b=2",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("want: %v, got: %v", want, got)
+	}
+	wantTexts := map[string]string{
+		"http://example.com/1":         "the first page",
+		"http://example.com/6?a=1&b=2": "last",
+	}
+	if !reflect.DeepEqual(texts, wantTexts) {
+		t.Fatalf("want texts: %v, got: %v", wantTexts, texts)
+	}
+	if got, _ := parseHTMLURLs(text, 2); len(got) != 2 {
+		t.Fatalf("got %d urls with limit of 2", len(got))
+	}
+}
+
+func TestParseSlackURLs(t *testing.T) {
+	text := "Hi <@U024BE7LH>, see <http://example.com/1|the first page> and http://example.com/2.\n" +
+		"Code `http://example.com/3` and ```\nhttp://example.com/4\n``` are skipped, <#C024BE7LR|general>\n" +
+		"<http://example.com/5?a=1&amp;b=2> <http://example.com/1|again> <mailto:bob@example.com|Bob>"
+	got, texts := parseSlackURLs(text, -1)
+	want := []string{
+		"http://example.com/1",
+		"http://example.com/2",
+		"http://example.com/5?a=1&b=2",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("want: %v, got: %v", want, got)
+	}
+	wantTexts := map[string]string{"http://example.com/1": "the first page"}
+	if !reflect.DeepEqual(texts, wantTexts) {
+		t.Fatalf("want texts: %v, got: %v", wantTexts, texts)
+	}
+}
+
+func TestUnicodePunctuation(t *testing.T) {
+	text := "见 https://example.com/a。和 「https://example.com/b」 «https://example.com/c» " +
+		"“https://example.com/d” （https://example.com/e） https://example.com/f、x"
+	want := []string{
+		"https://example.com/a",
+		"https://example.com/b",
+		"https://example.com/c",
+		"https://example.com/d",
+		"https://example.com/e",
+		"https://example.com/f",
+	}
+	if got := ParseURLs(text); !reflect.DeepEqual(got, want) {
+		t.Errorf("plain text: want %v, got %v", want, got)
+	}
+	got, texts := parseMarkdownURLs(text, -1)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("markdown: want %v, got %v", want, got)
+	}
+	if len(texts) != 0 {
+		t.Errorf("markdown: unexpected link texts: %v", texts)
+	}
+}
+
+func TestMarkdownUnicodePunctuation(t *testing.T) {
+	const link = "https://ja.wikipedia.org/wiki/東京（曖昧さ回避）"
+	text := "[東京](" + link + ") ![地図](https://example.com/地図（大）.png)"
+	got, texts := parseMarkdownURLs(text, -1)
+	want := []string{link, "https://example.com/地図（大）.png"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("want %v, got %v", want, got)
+	}
+	if texts[link] != "東京" {
+		t.Errorf("unexpected link texts: %v", texts)
+	}
+}
+
 var escape []string
 
 func BenchmarkMarkdownURLs(b *testing.B) {
@@ -129,6 +254,6 @@ Another paragraph with implicit link http://example.com/5.
 	b.ReportAllocs()
 	b.SetBytes(int64(len(text)))
 	for i := 0; i < b.N; i++ {
-		escape = parseMarkdownURLs(text, 10)
+		escape, _ = parseMarkdownURLs(text, 10)
 	}
 }
@@ -0,0 +1,68 @@
+package unfurlist
+
+import (
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// isVideoType reports whether result type t denotes video: oEmbed "video" or
+// one of Open Graph video.* types
+func isVideoType(t string) bool { return t == "video" || strings.HasPrefix(t, "video.") }
+
+// videoDuration returns duration in seconds of video page taken from
+// og:video:duration or video:duration meta tags, or duration of the first
+// schema.org VideoObject in JSON-LD blocks ld
+func videoDuration(meta map[string]string, ld []any) int {
+	for _, k := range [...]string{"og:video:duration", "video:duration"} {
+		if n := parseSeconds(meta[k]); n > 0 {
+			return n
+		}
+	}
+	isVideo := func(t string) bool { return t == "VideoObject" }
+	for _, v := range ld {
+		if obj := jsonLDTyped(v, isVideo); obj != nil {
+			if n := parseISODuration(jsonLDString(obj["duration"])); n > 0 {
+				return n
+			}
+		}
+	}
+	return 0
+}
+
+// parseSeconds parses non-negative number of seconds, rounding fractional
+// values; it returns 0 on failure
+func parseSeconds(s string) int {
+	f, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil || f < 0 || f > math.MaxInt32 || math.IsNaN(f) {
+		return 0
+	}
+	return int(math.Round(f))
+}
+
+var reISODuration = regexp.MustCompile(`^P(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+(?:\.\d+)?)S)?)?$`)
+
+// parseISODuration parses ISO 8601 duration like "PT1H2M3S" or "P1DT2H"
+// into number of seconds; it returns 0 on failure
+func parseISODuration(s string) int {
+	m := reISODuration.FindStringSubmatch(strings.ToUpper(strings.TrimSpace(s)))
+	if m == nil {
+		return 0
+	}
+	var total float64
+	for i, mult := range [...]float64{86400, 3600, 60, 1} {
+		if m[i+1] == "" {
+			continue
+		}
+		v, err := strconv.ParseFloat(m[i+1], 64)
+		if err != nil {
+			return 0
+		}
+		total += v * mult
+	}
+	if total > math.MaxInt32 {
+		return 0
+	}
+	return int(math.Round(total))
+}
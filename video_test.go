@@ -0,0 +1,53 @@
+package unfurlist
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParseISODuration(t *testing.T) {
+	for s, want := range map[string]int{
+		"PT1H2M3S":  3723,
+		"PT90S":     90,
+		"pt4m":      240,
+		"P1DT2H":    93600,
+		"PT12.6S":   13,
+		"P":         0,
+		"1:30":      0,
+		"PT1H2M3SX": 0,
+	} {
+		if got := parseISODuration(s); got != want {
+			t.Errorf("%q: got %d, want %d", s, got, want)
+		}
+	}
+}
+
+func TestVideoDuration(t *testing.T) {
+	ld := []any{map[string]any{"@type": "VideoObject", "duration": "PT2M5S"}}
+	if got := videoDuration(map[string]string{"og:video:duration": "42"}, ld); got != 42 {
+		t.Errorf("meta: got %d, want 42", got)
+	}
+	if got := videoDuration(map[string]string{"video:duration": "x"}, ld); got != 125 {
+		t.Errorf("json-ld: got %d, want 125", got)
+	}
+	if got := videoDuration(nil, nil); got != 0 {
+		t.Errorf("empty: got %d, want 0", got)
+	}
+}
+
+func TestOembedDuration(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"version":"1.0","type":"video","title":"Clip","html":"<iframe></iframe>","width":640,"height":360,"duration":61.4}`))
+	}))
+	defer srv.Close()
+	res, err := fetchOembed(context.Background(), srv.URL, (&unfurlHandler{}).httpGet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Duration != 61 {
+		t.Fatalf("unexpected duration: %d", res.Duration)
+	}
+}
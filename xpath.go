@@ -0,0 +1,396 @@
+package unfurlist
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// xpath is a compiled subset of XPath 1.0 location path: steps separated by /
+// and // with element name, *, . and .. node tests, optionally ending with
+// @name or text() step. Steps may have predicates: position ([1], [last()]),
+// attribute presence ([@name]) and comparisons ([@name='v'], [text()!='v'],
+// [contains(@name,'v')], [starts-with(.,'v')]) joined with "and".
+type xpath struct {
+	steps []xpathStep
+	attr  string // final @name step
+	text  bool   // final text() step
+}
+
+type xpathStep struct {
+	descendant bool   // step is preceded by //
+	name       string // element name, "*", "." or ".."
+	preds      [][]xpathCond
+}
+
+type xpathCond struct {
+	pos   int    // position in the node set, -1 for last(); 0 if cond is not positional
+	attr  string // attribute name, empty for text of the element
+	op    string // one of "", "=", "!=", "contains", "starts-with"
+	value string
+}
+
+func compileXPath(s string) (*xpath, error) {
+	s = strings.TrimSpace(s)
+	if !strings.HasPrefix(s, "/") {
+		return nil, errors.New("xpath must start with /")
+	}
+	xp := new(xpath)
+	for s != "" {
+		if xp.attr != "" || xp.text {
+			return nil, errors.New("@attribute and text() must be the last step")
+		}
+		var st xpathStep
+		switch {
+		case strings.HasPrefix(s, "//"):
+			st.descendant, s = true, s[2:]
+		case strings.HasPrefix(s, "/"):
+			s = s[1:]
+		default:
+			return nil, fmt.Errorf("unexpected %q", s)
+		}
+		switch {
+		case strings.HasPrefix(s, "@"):
+			s = s[1:]
+			xp.attr = strings.ToLower(xpathName(&s))
+			if xp.attr == "" || st.descendant {
+				return nil, errors.New("invalid attribute step")
+			}
+			continue
+		case strings.HasPrefix(s, "text()"):
+			if st.descendant {
+				return nil, errors.New("invalid text() step")
+			}
+			xp.text, s = true, s[len("text()"):]
+			continue
+		case strings.HasPrefix(s, ".."):
+			st.name, s = "..", s[2:]
+		case strings.HasPrefix(s, "."):
+			st.name, s = ".", s[1:]
+		case strings.HasPrefix(s, "*"):
+			st.name, s = "*", s[1:]
+		default:
+			if st.name = strings.ToLower(xpathName(&s)); st.name == "" {
+				return nil, fmt.Errorf("expected node test at %q", s)
+			}
+		}
+		for strings.HasPrefix(s, "[") {
+			end := xpathPredEnd(s)
+			if end < 0 {
+				return nil, errors.New("unterminated predicate")
+			}
+			conds, err := parseXPathPred(s[1:end])
+			if err != nil {
+				return nil, err
+			}
+			st.preds = append(st.preds, conds)
+			s = s[end+1:]
+		}
+		xp.steps = append(xp.steps, st)
+	}
+	return xp, nil
+}
+
+// xpathName consumes name at the start of *s and returns it
+func xpathName(s *string) string {
+	i := 0
+	for i < len(*s) {
+		c := (*s)[i]
+		if c == '-' || c == '_' || c == ':' || c >= 0x80 || c >= '0' && c <= '9' ||
+			c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' {
+			i++
+			continue
+		}
+		break
+	}
+	name := (*s)[:i]
+	*s = (*s)[i:]
+	return name
+}
+
+// xpathPredEnd returns index of ] closing predicate starting at s[0], skipping
+// quoted strings
+func xpathPredEnd(s string) int {
+	var quote byte
+	for i := 1; i < len(s); i++ {
+		switch c := s[i]; {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == ']':
+			return i
+		}
+	}
+	return -1
+}
+
+func parseXPathPred(s string) ([]xpathCond, error) {
+	var conds []xpathCond
+	for _, part := range splitXPathAnd(s) {
+		c, err := parseXPathCond(strings.TrimSpace(part))
+		if err != nil {
+			return nil, fmt.Errorf("predicate [%s]: %w", s, err)
+		}
+		conds = append(conds, c)
+	}
+	return conds, nil
+}
+
+// splitXPathAnd splits s on " and " outside quoted strings
+func splitXPathAnd(s string) []string {
+	var parts []string
+	var quote byte
+	last := 0
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case strings.HasPrefix(s[i:], " and "):
+			parts = append(parts, s[last:i])
+			last = i + len(" and ")
+			i = last - 1
+		}
+	}
+	return append(parts, s[last:])
+}
+
+func parseXPathCond(s string) (xpathCond, error) {
+	var c xpathCond
+	if s == "last()" {
+		c.pos = -1
+		return c, nil
+	}
+	if n, err := strconv.Atoi(s); err == nil {
+		if n < 1 {
+			return c, errors.New("position must be positive")
+		}
+		c.pos = n
+		return c, nil
+	}
+	for _, fn := range []string{"contains", "starts-with"} {
+		if rest, ok := strings.CutPrefix(s, fn+"("); ok {
+			args, ok := strings.CutSuffix(rest, ")")
+			if !ok {
+				return c, errors.New("unterminated function call")
+			}
+			arg, lit, ok := strings.Cut(args, ",")
+			if !ok {
+				return c, fmt.Errorf("%s() takes two arguments", fn)
+			}
+			var err error
+			if c.attr, err = xpathOperand(strings.TrimSpace(arg)); err != nil {
+				return c, err
+			}
+			if c.value, err = xpathLiteral(strings.TrimSpace(lit)); err != nil {
+				return c, err
+			}
+			c.op = fn
+			return c, nil
+		}
+	}
+	lhs, rhs, ok := strings.Cut(s, "=")
+	if !ok {
+		var err error
+		c.attr, err = xpathOperand(s)
+		if err != nil || c.attr == "" {
+			return c, fmt.Errorf("unsupported condition %q", s)
+		}
+		return c, nil
+	}
+	c.op = "="
+	if l, ok := strings.CutSuffix(lhs, "!"); ok {
+		lhs, c.op = l, "!="
+	}
+	var err error
+	if c.attr, err = xpathOperand(strings.TrimSpace(lhs)); err != nil {
+		return c, err
+	}
+	c.value, err = xpathLiteral(strings.TrimSpace(rhs))
+	return c, err
+}
+
+// xpathOperand parses @name, text() or . and returns attribute name, empty
+// for element text
+func xpathOperand(s string) (string, error) {
+	switch {
+	case s == "text()" || s == "." || s == "normalize-space()" || s == "normalize-space(.)":
+		return "", nil
+	case strings.HasPrefix(s, "@") && len(s) > 1:
+		rest := s[1:]
+		name := xpathName(&rest)
+		if rest != "" {
+			break
+		}
+		return strings.ToLower(name), nil
+	}
+	return "", fmt.Errorf("unsupported operand %q", s)
+}
+
+func xpathLiteral(s string) (string, error) {
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0] {
+		return s[1 : len(s)-1], nil
+	}
+	return "", fmt.Errorf("expected quoted string, got %q", s)
+}
+
+// find returns value of the first node selected by xp: attribute value, the
+// first non-blank text node for text() step, or normalized element text
+func (xp *xpath) find(doc *html.Node) string {
+	set := []*html.Node{doc}
+	for _, st := range xp.steps {
+		set = st.eval(set)
+		if len(set) == 0 {
+			return ""
+		}
+	}
+	for _, n := range set {
+		switch {
+		case xp.attr != "":
+			for _, a := range n.Attr {
+				if a.Namespace == "" && a.Key == xp.attr {
+					return strings.TrimSpace(a.Val)
+				}
+			}
+		case xp.text:
+			for c := n.FirstChild; c != nil; c = c.NextSibling {
+				if c.Type != html.TextNode {
+					continue
+				}
+				if s := strings.Join(strings.Fields(c.Data), " "); s != "" {
+					return s
+				}
+			}
+		default:
+			return strings.Join(strings.Fields(nodeText(n)), " ")
+		}
+	}
+	return ""
+}
+
+// eval returns nodes selected by step from context nodes, in document order
+// of context nodes, without duplicates
+func (st xpathStep) eval(context []*html.Node) []*html.Node {
+	var out []*html.Node
+	seen := make(map[*html.Node]struct{})
+	add := func(group []*html.Node) {
+		for _, n := range st.filter(group) {
+			if _, ok := seen[n]; !ok {
+				seen[n] = struct{}{}
+				out = append(out, n)
+			}
+		}
+	}
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		add(st.candidates(n))
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type == html.ElementNode {
+				walk(c)
+			}
+		}
+	}
+	for _, n := range context {
+		if st.descendant {
+			walk(n)
+			continue
+		}
+		add(st.candidates(n))
+	}
+	return out
+}
+
+// candidates returns nodes matching step node test relative to n
+func (st xpathStep) candidates(n *html.Node) []*html.Node {
+	switch st.name {
+	case ".":
+		return []*html.Node{n}
+	case "..":
+		if n.Parent == nil {
+			return nil
+		}
+		return []*html.Node{n.Parent}
+	}
+	var out []*html.Node
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.ElementNode && (st.name == "*" || c.Data == st.name) {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// filter applies step predicates to nodes in order
+func (st xpathStep) filter(nodes []*html.Node) []*html.Node {
+	for _, conds := range st.preds {
+		var kept []*html.Node
+		for i, n := range nodes {
+			if xpathMatch(conds, n, i+1, len(nodes)) {
+				kept = append(kept, n)
+			}
+		}
+		nodes = kept
+	}
+	return nodes
+}
+
+func xpathMatch(conds []xpathCond, n *html.Node, pos, size int) bool {
+	for _, c := range conds {
+		switch {
+		case c.pos == -1:
+			if pos != size {
+				return false
+			}
+			continue
+		case c.pos > 0:
+			if pos != c.pos {
+				return false
+			}
+			continue
+		}
+		var v string
+		if c.attr != "" {
+			var ok bool
+			for _, a := range n.Attr {
+				if a.Namespace == "" && a.Key == c.attr {
+					v, ok = a.Val, true
+					break
+				}
+			}
+			if !ok {
+				return false
+			}
+		} else {
+			v = strings.Join(strings.Fields(nodeText(n)), " ")
+			if c.op == "" && v == "" {
+				return false
+			}
+		}
+		var ok bool
+		switch c.op {
+		case "":
+			ok = true
+		case "=":
+			ok = v == c.value
+		case "!=":
+			ok = v != c.value
+		case "contains":
+			ok = strings.Contains(v, c.value)
+		case "starts-with":
+			ok = strings.HasPrefix(v, c.value)
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
@@ -45,5 +45,8 @@ func youtubeFetcher(ctx context.Context, client *http.Client, u *url.URL) (*Meta
 		Image:       meta.Thumbnail,
 		ImageWidth:  meta.ThumbnailWidth,
 		ImageHeight: meta.ThumbnailHeight,
+		SiteName:    meta.Provider,
+		HTML:        meta.HTML,
+		Author:      meta.AuthorName,
 	}, true
 }